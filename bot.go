@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"math/rand"
 	"regexp"
@@ -12,11 +13,13 @@ import (
 	"bytes"
 
 	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/cluster"
 	"github.com/Seklfreak/Robyul2/helpers"
 	"github.com/Seklfreak/Robyul2/metrics"
 	"github.com/Seklfreak/Robyul2/models"
 	"github.com/Seklfreak/Robyul2/modules"
 	"github.com/Seklfreak/Robyul2/ratelimits"
+	"github.com/Seklfreak/Robyul2/statecache"
 	"github.com/bwmarrin/discordgo"
 	raven "github.com/getsentry/raven-go"
 	"github.com/sirupsen/logrus"
@@ -49,20 +52,29 @@ func OnFirstReady(session *discordgo.Session, event *discordgo.Ready) {
 		helpers.AddAutoleaverGuildID(guild.ID)
 	}
 
-	// request guild members from the gateway
+	// request guild members from the gateway, guilds keeping their members only in redis are skipped if they are recent
 	go func() {
 		time.Sleep(5 * time.Minute)
 
+		resyncAfter := time.Hour * 24
+		if helpers.GetConfig().ExistsP("state.resync_members_hours") {
+			resyncAfter = time.Duration(helpers.GetConfig().Path("state.resync_members_hours").Data().(float64)) * time.Hour
+		}
+
 		for _, guild := range session.State.Guilds {
 			if helpers.IsBlacklistedGuild(guild.ID) {
 				continue
 			}
 
+			if statecache.IsMemberLimited(guild.MemberCount) && statecache.IsMembersSynced(guild.ID, resyncAfter) {
+				continue
+			}
+
 			//if guild.Large {
 			err := session.RequestGuildMembers(guild.ID, "", 0)
 			if err != nil && strings.Contains(err.Error(), "no websocket connection exists") {
-				cache.GetLogger().WithField("module", "bot").Warn("OnFirstReady: no websocket connection exists, stopping Robyul")
-				BotRuntimeChannel <- os.Interrupt
+				cache.GetLogger().WithField("module", "bot").Warnf("OnFirstReady: no websocket connection exists, restarting shard %d", session.ShardID)
+				helpers.RelaxLog(cache.GetSession().RestartShard(session.ShardID, "no websocket connection exists"))
 				return
 			}
 			helpers.RelaxLog(err)
@@ -130,8 +142,8 @@ func OnReconnect(session *discordgo.Session, event *discordgo.Ready) {
 			//if guild.Large {
 			err := session.RequestGuildMembers(guild.ID, "", 0)
 			if err != nil && strings.Contains(err.Error(), "no websocket connection exists") {
-				cache.GetLogger().WithField("module", "bot").Warn("OnReconnect: no websocket connection exists, stopping Robyul")
-				BotRuntimeChannel <- os.Interrupt
+				cache.GetLogger().WithField("module", "bot").Warnf("OnReconnect: no websocket connection exists, restarting shard %d", session.ShardID)
+				helpers.RelaxLog(cache.GetSession().RestartShard(session.ShardID, "no websocket connection exists"))
 				return
 			}
 			helpers.RelaxLog(err)
@@ -163,6 +175,8 @@ func BotGuildOnPresenceUpdate(session *discordgo.Session, presence *discordgo.Pr
 		return
 	}
 
+	modules.CallPluginOnPresenceUpdate(presence)
+
 	member, err := session.State.Member(presence.GuildID, presence.User.ID)
 	if err != nil {
 		if strings.Contains(err.Error(), "state cache not found") {
@@ -354,7 +368,9 @@ func BotOnMessageCreate(session *discordgo.Session, message *discordgo.MessageCr
 					"Are you sure you want me to shutdown Robyul?", "✅", "🚫") {
 					cache.GetLogger().WithField("module", "debug").Warnf("shutting down Robuyul on request by %s#%s (%s)",
 						message.Author.Username, message.Author.Discriminator, message.Author.ID)
-					BotRuntimeChannel <- os.Interrupt
+					// stops all processes, including this one
+					_, err := cluster.Request("shutdown", nil)
+					helpers.RelaxLog(err)
 				}
 			})
 
@@ -608,6 +624,87 @@ func BotOnReactionRemove(session *discordgo.Session, reaction *discordgo.Message
 func BotOnGuildCreate(session *discordgo.Session, guild *discordgo.GuildCreate) {
 }
 
+func BotOnMessageUpdate(session *discordgo.Session, message *discordgo.MessageUpdate) {
+	if message.Author != nil {
+		if helpers.IsBlacklisted(message.Author.ID) {
+			return
+		}
+	}
+
+	channel, err := helpers.GetChannelWithoutApi(message.ChannelID)
+	if err != nil {
+		return
+	}
+
+	if helpers.IsBlacklistedGuild(channel.GuildID) {
+		return
+	}
+
+	modules.CallPluginOnMessageUpdate(message)
+}
+
+func BotOnVoiceStateUpdate(session *discordgo.Session, state *discordgo.VoiceStateUpdate) {
+	modules.CallPluginOnVoiceStateUpdate(state)
+}
+
+func BotOnChannelCreate(session *discordgo.Session, channel *discordgo.ChannelCreate) {
+	modules.CallPluginOnChannelCreate(channel)
+}
+
+func BotOnChannelUpdate(session *discordgo.Session, channel *discordgo.ChannelUpdate) {
+	modules.CallPluginOnChannelUpdate(channel)
+}
+
+func BotOnChannelDelete(session *discordgo.Session, channel *discordgo.ChannelDelete) {
+	modules.CallPluginOnChannelDelete(channel)
+}
+
+func BotOnGuildUpdate(session *discordgo.Session, guild *discordgo.GuildUpdate) {
+	modules.CallPluginOnGuildUpdate(guild)
+}
+
+func BotOnGuildRoleCreate(session *discordgo.Session, role *discordgo.GuildRoleCreate) {
+	modules.CallPluginOnGuildRoleCreate(role)
+}
+
+func BotOnGuildRoleUpdate(session *discordgo.Session, role *discordgo.GuildRoleUpdate) {
+	modules.CallPluginOnGuildRoleUpdate(role)
+}
+
+func BotOnGuildRoleDelete(session *discordgo.Session, role *discordgo.GuildRoleDelete) {
+	modules.CallPluginOnGuildRoleDelete(role)
+}
+
+func BotOnGuildMemberUpdate(session *discordgo.Session, member *discordgo.GuildMemberUpdate) {
+	modules.CallPluginOnGuildMemberUpdate(member)
+}
+
+func BotOnWebhooksUpdate(session *discordgo.Session, webhooks *discordgo.WebhooksUpdate) {
+	modules.CallPluginOnWebhooksUpdate(webhooks)
+}
+
+// BotOnEvent gets called for every event, used for events discordgo doesn't know yet
+func BotOnEvent(session *discordgo.Session, event *discordgo.Event) {
+	switch event.Type {
+	case "INVITE_CREATE":
+		var invite modules.InviteCreate
+		err := json.Unmarshal(event.RawData, &invite)
+		if err != nil {
+			helpers.RelaxLog(err)
+			return
+		}
+		modules.CallPluginOnInviteCreate(&invite)
+	case "INVITE_DELETE":
+		var invite modules.InviteDelete
+		err := json.Unmarshal(event.RawData, &invite)
+		if err != nil {
+			helpers.RelaxLog(err)
+			return
+		}
+		modules.CallPluginOnInviteDelete(&invite)
+	}
+}
+
 func BotOnGuildDelete(session *discordgo.Session, guild *discordgo.GuildDelete) {
 }
 
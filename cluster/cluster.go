@@ -0,0 +1,187 @@
+// Package cluster coordinates multiple processes running different shard ranges of the bot, using redis.
+// Without Init every call behaves as if this process were the only one.
+package cluster
+
+import (
+	"encoding/json"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	processesKey = "robyul-cluster:processes"
+	// processes are announced with this interval, and considered dead after three missed announcements
+	announceInterval = time.Second * 15
+	processTimeout   = announceInterval * 3
+)
+
+// Process is a process of the bot
+type Process struct {
+	ID         string
+	Hostname   string
+	FirstShard int
+	LastShard  int
+	NumShards  int
+	StartedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+var (
+	enabled bool
+	self    Process
+	stop    chan struct{}
+	lock    sync.RWMutex
+)
+
+// Init joins the cluster, the process runs the shards from first to last including out of numShards
+func Init(firstShard, lastShard, numShards int) error {
+	hostname, _ := os.Hostname()
+
+	lock.Lock()
+	self = Process{
+		ID:         hostname + "-" + strconv.Itoa(os.Getpid()),
+		Hostname:   hostname,
+		FirstShard: firstShard,
+		LastShard:  lastShard,
+		NumShards:  numShards,
+		StartedAt:  time.Now(),
+	}
+	stop = make(chan struct{})
+	lock.Unlock()
+
+	err := announce()
+	if err != nil {
+		return err
+	}
+
+	err = subscribe()
+	if err != nil {
+		return err
+	}
+
+	lock.Lock()
+	enabled = true
+	lock.Unlock()
+
+	go announceLoop()
+	go renewLeadershipsLoop()
+
+	logger().Infof("joined cluster as %s with shards %d-%d of %d", self.ID, firstShard, lastShard, numShards)
+	return nil
+}
+
+// Uninit leaves the cluster, leaderships are released so other processes can take over immediately
+func Uninit() {
+	if !IsEnabled() {
+		return
+	}
+
+	lock.Lock()
+	enabled = false
+	close(stop)
+	lock.Unlock()
+
+	releaseLeaderships()
+	err := cache.GetRedisClient().HDel(processesKey, self.ID).Err()
+	if err != nil {
+		logger().WithError(err).Warn("failed to remove process")
+	}
+}
+
+// IsEnabled returns true if this process is part of a cluster
+func IsEnabled() bool {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return enabled
+}
+
+// ProcessID returns the ID of this process
+func ProcessID() string {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return self.ID
+}
+
+// GetProcesses returns all live processes, including this one
+func GetProcesses() (processes []Process, err error) {
+	if !IsEnabled() {
+		return []Process{self}, nil
+	}
+
+	values, err := cache.GetRedisClient().HGetAll(processesKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, value := range values {
+		var process Process
+		err = json.Unmarshal([]byte(value), &process)
+		if err != nil {
+			continue
+		}
+		if time.Since(process.UpdatedAt) > processTimeout {
+			continue
+		}
+		processes = append(processes, process)
+	}
+	return processes, nil
+}
+
+// announce stores the process in the list of processes, so requests know how many responses to wait for
+func announce() error {
+	lock.Lock()
+	self.UpdatedAt = time.Now()
+	data, err := json.Marshal(self)
+	lock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	return cache.GetRedisClient().HSet(processesKey, self.ID, data).Err()
+}
+
+func announceLoop() {
+	ticker := time.NewTicker(announceInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			err := announce()
+			if err != nil {
+				logger().WithError(err).Warn("failed to announce process")
+			}
+			removeDeadProcesses()
+		}
+	}
+}
+
+// removeDeadProcesses removes processes which stopped without leaving the cluster
+func removeDeadProcesses() {
+	values, err := cache.GetRedisClient().HGetAll(processesKey).Result()
+	if err != nil {
+		return
+	}
+
+	for id, value := range values {
+		var process Process
+		err = json.Unmarshal([]byte(value), &process)
+		if err == nil && time.Since(process.UpdatedAt) <= processTimeout {
+			continue
+		}
+		cache.GetRedisClient().HDel(processesKey, id)
+	}
+}
+
+func logger() *logrus.Entry {
+	return cache.GetLogger().WithField("module", "cluster")
+}
@@ -0,0 +1,114 @@
+package cluster
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/go-redis/redis"
+)
+
+const (
+	leaderKeyPrefix = "robyul-cluster:leader:"
+	// leaderships of dead processes expire after this time
+	leaderTTL = time.Minute
+	// leaderships are renewed with this interval while the process is alive
+	leaderRenewInterval = leaderTTL / 3
+)
+
+var (
+	// renewScript extends the leadership if it is held by the process
+	renewScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("pexpire", KEYS[1], ARGV[2])
+end
+return 0`)
+
+	// releaseScript deletes the leadership if it is held by the process
+	releaseScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("del", KEYS[1])
+end
+return 0`)
+
+	leaderships     = make(map[string]bool)
+	leadershipsLock sync.Mutex
+)
+
+// IsLeader returns true if this process runs the task, only one process of the cluster is leader of a task at a time.
+// Background loops which must run exactly once call it on every iteration.
+// Leaderships are kept while the process is alive, if the leader dies another process takes over within a minute.
+// task	: the name of the task, e.g. reminders
+func IsLeader(task string) bool {
+	if !IsEnabled() {
+		return true
+	}
+
+	key := leaderKeyPrefix + task
+	acquired, err := cache.GetRedisClient().SetNX(key, ProcessID(), leaderTTL).Result()
+	if err != nil {
+		logger().WithError(err).Warn("failed to acquire leadership for ", task)
+		return false
+	}
+	if !acquired {
+		renewed, err := renewScript.Run(cache.GetRedisClient(), []string{key},
+			ProcessID(), int64(leaderTTL/time.Millisecond)).Int64()
+		if err != nil {
+			logger().WithError(err).Warn("failed to renew leadership for ", task)
+			return false
+		}
+		acquired = renewed == 1
+	}
+
+	leadershipsLock.Lock()
+	if acquired && !leaderships[task] {
+		logger().Info("became leader for ", task)
+	}
+	leaderships[task] = acquired
+	leadershipsLock.Unlock()
+
+	return acquired
+}
+
+// renewLeadershipsLoop keeps the leaderships of tasks with long intervals between their iterations
+func renewLeadershipsLoop() {
+	ticker := time.NewTicker(leaderRenewInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			for _, task := range getLeaderships() {
+				IsLeader(task)
+			}
+		}
+	}
+}
+
+func releaseLeaderships() {
+	for _, task := range getLeaderships() {
+		err := releaseScript.Run(cache.GetRedisClient(), []string{leaderKeyPrefix + task}, ProcessID()).Err()
+		if err != nil && err != redis.Nil {
+			logger().WithError(err).Warn("failed to release leadership for ", task)
+		}
+	}
+
+	leadershipsLock.Lock()
+	leaderships = make(map[string]bool)
+	leadershipsLock.Unlock()
+}
+
+// getLeaderships returns all tasks this process is leader of
+func getLeaderships() (tasks []string) {
+	leadershipsLock.Lock()
+	defer leadershipsLock.Unlock()
+
+	for task, leader := range leaderships {
+		if leader {
+			tasks = append(tasks, task)
+		}
+	}
+	return tasks
+}
@@ -0,0 +1,228 @@
+package cluster
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/globalsign/mgo/bson"
+)
+
+const (
+	requestsChannel        = "robyul-cluster:requests"
+	responsesChannelPrefix = "robyul-cluster:responses:"
+	// requests stop waiting for processes which didn't respond after this time
+	requestTimeout = time.Second * 5
+)
+
+// Handler answers a request, the result is encoded as JSON
+type Handler func(payload json.RawMessage) (result interface{}, err error)
+
+// Response is the answer of a process to a request
+type Response struct {
+	ProcessID string
+	Payload   json.RawMessage
+	Error     string
+}
+
+// Decode decodes the payload of the response into the value
+func (r Response) Decode(value interface{}) error {
+	if r.Error != "" {
+		return errors.New(r.Error)
+	}
+	return json.Unmarshal(r.Payload, value)
+}
+
+type requestMessage struct {
+	ID      string
+	Topic   string
+	From    string
+	Payload json.RawMessage
+}
+
+type responseMessage struct {
+	ID string
+	Response
+}
+
+var (
+	handlers     = make(map[string]Handler)
+	handlersLock sync.RWMutex
+
+	pendingRequests     = make(map[string]chan Response)
+	pendingRequestsLock sync.Mutex
+)
+
+// Handle registers the handler for requests of the topic, from this and all other processes
+func Handle(topic string, handler Handler) {
+	handlersLock.Lock()
+	defer handlersLock.Unlock()
+
+	handlers[topic] = handler
+}
+
+// Request sends the request to all processes, including this one, and returns their responses.
+// Processes not responding in time are left out.
+// topic	: the topic of the request, a handler has to be registered for it
+// payload	: the payload of the request, encoded as JSON
+func Request(topic string, payload interface{}) (responses []Response, err error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	request := requestMessage{
+		ID:      bson.NewObjectId().Hex(),
+		Topic:   topic,
+		From:    ProcessID(),
+		Payload: data,
+	}
+
+	// this process answers directly
+	responses = append(responses, handleRequest(request))
+	if !IsEnabled() {
+		return responses, nil
+	}
+
+	processes, err := GetProcesses()
+	if err != nil {
+		return responses, err
+	}
+	expected := len(processes) - 1
+	if expected <= 0 {
+		return responses, nil
+	}
+
+	responseChannel := make(chan Response, expected)
+	pendingRequestsLock.Lock()
+	pendingRequests[request.ID] = responseChannel
+	pendingRequestsLock.Unlock()
+	defer func() {
+		pendingRequestsLock.Lock()
+		delete(pendingRequests, request.ID)
+		pendingRequestsLock.Unlock()
+	}()
+
+	data, err = json.Marshal(request)
+	if err != nil {
+		return responses, err
+	}
+	err = cache.GetRedisClient().Publish(requestsChannel, data).Err()
+	if err != nil {
+		return responses, err
+	}
+
+	timeout := time.NewTimer(requestTimeout)
+	defer timeout.Stop()
+	for expected > 0 {
+		select {
+		case response := <-responseChannel:
+			responses = append(responses, response)
+			expected--
+		case <-timeout.C:
+			logger().Warnf("%d processes did not respond to %s in time", expected, topic)
+			return responses, nil
+		}
+	}
+
+	return responses, nil
+}
+
+// handleRequest runs the handler of the request, and returns the response of this process
+func handleRequest(request requestMessage) (response Response) {
+	response.ProcessID = ProcessID()
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			response.Error = fmt.Sprintf("handler for %s panicked: %v", request.Topic, recovered)
+		}
+	}()
+
+	handlersLock.RLock()
+	handler, ok := handlers[request.Topic]
+	handlersLock.RUnlock()
+	if !ok {
+		response.Error = "no handler for " + request.Topic
+		return response
+	}
+
+	result, err := handler(request.Payload)
+	if err != nil {
+		response.Error = err.Error()
+		return response
+	}
+
+	response.Payload, err = json.Marshal(result)
+	if err != nil {
+		response.Error = err.Error()
+	}
+	return response
+}
+
+// subscribe starts listening for requests from other processes, and for responses to requests of this process
+func subscribe() error {
+	pubSub := cache.GetRedisClient().Subscribe(requestsChannel, responsesChannelPrefix+ProcessID())
+	// wait for the subscription to be confirmed, requests sent before would get lost
+	_, err := pubSub.Receive()
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for message := range pubSub.Channel() {
+			if message.Channel == requestsChannel {
+				go answerRequest(message.Payload)
+				continue
+			}
+
+			var response responseMessage
+			err := json.Unmarshal([]byte(message.Payload), &response)
+			if err != nil {
+				continue
+			}
+
+			pendingRequestsLock.Lock()
+			responseChannel, ok := pendingRequests[response.ID]
+			pendingRequestsLock.Unlock()
+			if !ok {
+				// arrived after the timeout
+				continue
+			}
+			select {
+			case responseChannel <- response.Response:
+			default:
+			}
+		}
+	}()
+
+	go func() {
+		<-stop
+		pubSub.Close()
+	}()
+
+	return nil
+}
+
+// answerRequest handles a request from another process, and publishes the response to it
+func answerRequest(payload string) {
+	var request requestMessage
+	err := json.Unmarshal([]byte(payload), &request)
+	if err != nil || request.From == ProcessID() {
+		return
+	}
+
+	data, err := json.Marshal(responseMessage{
+		ID:       request.ID,
+		Response: handleRequest(request),
+	})
+	if err != nil {
+		return
+	}
+
+	err = cache.GetRedisClient().Publish(responsesChannelPrefix+request.From, data).Err()
+	if err != nil {
+		logger().WithError(err).Warn("failed to respond to ", request.Topic)
+	}
+}
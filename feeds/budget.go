@@ -0,0 +1,74 @@
+package feeds
+
+import (
+	"sync"
+	"time"
+)
+
+// Budget limits the cost of requests to a source within a period, like an API quota
+type Budget struct {
+	limit   int64
+	period  time.Duration
+	used    int64
+	resetAt time.Time
+
+	sync.Mutex
+}
+
+func NewBudget(limit int64, period time.Duration) *Budget {
+	return &Budget{
+		limit:   limit,
+		period:  period,
+		resetAt: time.Now().Add(period),
+	}
+}
+
+func (b *Budget) reset(now time.Time) {
+	if !now.Before(b.resetAt) {
+		b.used = 0
+		b.resetAt = now.Add(b.period)
+	}
+}
+
+// Take uses the cost from the budget, returns false if there is not enough budget left
+func (b *Budget) Take(cost int64) bool {
+	b.Lock()
+	defer b.Unlock()
+
+	b.reset(time.Now())
+	if b.used+cost > b.limit {
+		return false
+	}
+	b.used += cost
+	return true
+}
+
+// Left returns the budget left in the current period
+func (b *Budget) Left() int64 {
+	b.Lock()
+	defer b.Unlock()
+
+	b.reset(time.Now())
+	return b.limit - b.used
+}
+
+// ResetAt returns the end of the current period
+func (b *Budget) ResetAt() time.Time {
+	b.Lock()
+	defer b.Unlock()
+
+	b.reset(time.Now())
+	return b.resetAt
+}
+
+// Interval returns the minimum time between two checks of the same key,
+// so checking all keys with the given cost stays within the budget
+func (b *Budget) Interval(keys int, cost int64) time.Duration {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.limit <= 0 {
+		return b.period
+	}
+	return time.Duration(int64(b.period) / b.limit * int64(keys) * cost)
+}
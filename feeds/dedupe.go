@@ -0,0 +1,79 @@
+package feeds
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/go-redis/redis"
+)
+
+const (
+	// maximum number of item IDs remembered per key
+	dedupeMaxItems = 500
+	// keys which haven't been seen for this long are forgotten
+	dedupeExpiration = time.Hour * 24 * 30
+)
+
+func dedupeRedisKey(source, key string) string {
+	return fmt.Sprintf("robyul2-discord:feeds:%s:%s", source, key)
+}
+
+// FilterNew returns the items which haven't been handled for the source and key yet, and remembers them
+// all items are remembered without being returned for keys without any handled items, to not post old items
+func FilterNew(source, key string, items []Item) (newItems []Item, err error) {
+	redisClient := cache.GetRedisClient()
+	redisKey := dedupeRedisKey(source, key)
+
+	known, err := redisClient.Exists(redisKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	if known > 0 {
+		for _, item := range items {
+			_, err = redisClient.ZScore(redisKey, item.ID).Result()
+			if err == redis.Nil {
+				newItems = append(newItems, item)
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	err = MarkHandled(source, key, items...)
+	return newItems, err
+}
+
+// MarkHandled remembers the items as handled for the source and key
+func MarkHandled(source, key string, items ...Item) (err error) {
+	if len(items) <= 0 {
+		return nil
+	}
+
+	redisClient := cache.GetRedisClient()
+	redisKey := dedupeRedisKey(source, key)
+
+	members := make([]redis.Z, 0, len(items))
+	for _, item := range items {
+		members = append(members, redis.Z{Score: float64(time.Now().UnixNano()), Member: item.ID})
+	}
+
+	pipe := redisClient.TxPipeline()
+	pipe.ZAdd(redisKey, members...)
+	pipe.ZRemRangeByRank(redisKey, 0, -dedupeMaxItems-1)
+	pipe.Expire(redisKey, dedupeExpiration)
+	_, err = pipe.Exec()
+	return err
+}
+
+// IsHandled returns true if the item has been handled for the source and key
+func IsHandled(source, key string, item Item) (handled bool, err error) {
+	_, err = cache.GetRedisClient().ZScore(dedupeRedisKey(source, key), item.ID).Result()
+	if err == redis.Nil {
+		return false, nil
+	}
+	return err == nil, err
+}
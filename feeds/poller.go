@@ -0,0 +1,288 @@
+package feeds
+
+import (
+	"expvar"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/cluster"
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/metrics"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	pollers     []*Poller
+	pollersLock sync.RWMutex
+)
+
+// Options configure the scheduling of a Poller
+type Options struct {
+	// Interval is the target time between two checks of the same key
+	Interval time.Duration
+	// Jitter randomizes the interval, 0.1 checks within ±10% of the interval
+	Jitter float64
+	// MaxBackoff is the maximum time between two checks of a key returning errors
+	MaxBackoff time.Duration
+	// Budget limits the requests to the source, optional
+	Budget *Budget
+	// Cost is taken from the budget for every fetch
+	Cost int64
+	// Tick is the time between two passes over all keys
+	Tick time.Duration
+	// RefreshTime is set to the duration of each round in seconds, optional, for expvars of sources from before the Poller
+	RefreshTime *expvar.Float
+}
+
+type keyState struct {
+	nextCheck time.Time
+	failures  int
+	lastError string
+}
+
+// Health is a snapshot of the state of a Poller
+type Health struct {
+	Source            string
+	Keys              int
+	Subscriptions     int
+	FailingKeys       int
+	Checks            int64
+	Errors            int64
+	LastError         string
+	LastErrorAt       time.Time
+	LastRoundAt       time.Time
+	LastRoundDuration time.Duration
+	BudgetLeft        int64 // -1 without a budget
+}
+
+// Poller checks all keys of a source on a jittered schedule, keys returning errors are backed off exponentially
+type Poller struct {
+	source  Source
+	options Options
+	states  map[string]*keyState
+	health  Health
+
+	sync.Mutex
+}
+
+func NewPoller(source Source, options Options) *Poller {
+	if options.Interval <= 0 {
+		options.Interval = time.Minute
+	}
+	if options.MaxBackoff <= 0 {
+		options.MaxBackoff = time.Hour
+	}
+	if options.Tick <= 0 {
+		options.Tick = time.Second * 5
+	}
+	if options.Cost <= 0 {
+		options.Cost = 1
+	}
+
+	return &Poller{
+		source:  source,
+		options: options,
+		states:  make(map[string]*keyState),
+		health:  Health{Source: source.Name(), BudgetLeft: -1},
+	}
+}
+
+// Start starts polling in the background, and registers the poller for health reporting
+func (p *Poller) Start() {
+	pollersLock.Lock()
+	pollers = append(pollers, p)
+	pollersLock.Unlock()
+
+	go p.loop()
+	p.logger().Infof("started poller, interval %s", p.options.Interval)
+}
+
+func (p *Poller) loop() {
+	defer helpers.Recover()
+	defer func() {
+		go func() {
+			p.logger().Error("The poller loop died. Please investigate! Will be restarted in 60 seconds")
+			time.Sleep(60 * time.Second)
+			p.loop()
+		}()
+	}()
+
+	for {
+		// only one process polls each source
+		if cluster.IsLeader("feeds-" + p.source.Name()) {
+			p.pass(time.Now())
+		}
+		time.Sleep(p.options.Tick)
+	}
+}
+
+// pass checks all keys which are due
+func (p *Poller) pass(now time.Time) {
+	bundled, err := p.source.Subscriptions()
+	if err != nil {
+		p.logger().WithError(err).Error("failed to get subscriptions")
+		return
+	}
+
+	interval := p.interval(len(bundled))
+	start := time.Now()
+
+	p.Lock()
+	// forget removed keys, and spread new keys over the interval
+	for key := range p.states {
+		if _, ok := bundled[key]; !ok {
+			delete(p.states, key)
+		}
+	}
+	var subscriptionsCount int
+	dueKeys := make([]string, 0)
+	nextChecks := make(map[string]time.Time)
+	for key, subscriptions := range bundled {
+		subscriptionsCount += len(subscriptions)
+		state, ok := p.states[key]
+		if !ok {
+			state = &keyState{nextCheck: now.Add(time.Duration(rand.Int63n(int64(interval) + 1)))}
+			p.states[key] = state
+		}
+		if !state.nextCheck.After(now) {
+			dueKeys = append(dueKeys, key)
+			nextChecks[key] = state.nextCheck
+		}
+	}
+	p.health.Keys = len(bundled)
+	p.health.Subscriptions = subscriptionsCount
+	p.Unlock()
+
+	// check the most overdue keys first, in case the budget runs out
+	sort.Slice(dueKeys, func(i, j int) bool {
+		return nextChecks[dueKeys[i]].Before(nextChecks[dueKeys[j]])
+	})
+
+	for _, key := range dueKeys {
+		if p.options.Budget != nil && !p.options.Budget.Take(p.options.Cost) {
+			break
+		}
+		p.check(key, bundled[key], interval, now)
+	}
+
+	p.Lock()
+	var failingKeys int
+	for _, state := range p.states {
+		if state.failures > 0 {
+			failingKeys++
+		}
+	}
+	p.health.FailingKeys = failingKeys
+	if len(dueKeys) > 0 {
+		p.health.LastRoundAt = now
+		p.health.LastRoundDuration = time.Since(start)
+	}
+	if p.options.Budget != nil {
+		p.health.BudgetLeft = p.options.Budget.Left()
+	}
+	health := p.health
+	p.Unlock()
+
+	setMetric(metrics.FeedsKeysCount, health.Source, int64(health.Keys))
+	setMetric(metrics.FeedsFailingKeysCount, health.Source, int64(health.FailingKeys))
+	if len(dueKeys) > 0 {
+		refreshTime := new(expvar.Float)
+		refreshTime.Set(health.LastRoundDuration.Seconds())
+		metrics.FeedsRefreshTime.Set(health.Source, refreshTime)
+		if p.options.RefreshTime != nil {
+			p.options.RefreshTime.Set(health.LastRoundDuration.Seconds())
+		}
+	}
+}
+
+func (p *Poller) check(key string, subscriptions []Subscription, interval time.Duration, now time.Time) {
+	items, err := p.source.Fetch(key)
+	if err == nil {
+		err = p.source.Handle(key, items, subscriptions)
+	}
+
+	metrics.FeedsChecks.Add(p.source.Name(), 1)
+
+	p.Lock()
+	defer p.Unlock()
+
+	p.health.Checks++
+	state := p.states[key]
+	if err != nil {
+		metrics.FeedsErrors.Add(p.source.Name(), 1)
+		p.health.Errors++
+		p.health.LastError = key + ": " + err.Error()
+		p.health.LastErrorAt = time.Now()
+		state.failures++
+		state.lastError = err.Error()
+		state.nextCheck = now.Add(p.backoff(interval, state.failures))
+		p.logger().WithField("key", key).WithError(err).Warnf("check failed %d times, next check in %s",
+			state.failures, state.nextCheck.Sub(now).Round(time.Second))
+		return
+	}
+
+	state.failures = 0
+	state.lastError = ""
+	state.nextCheck = now.Add(p.jitter(interval))
+}
+
+// interval returns the interval between checks of the same key, within the budget
+func (p *Poller) interval(keys int) time.Duration {
+	interval := p.options.Interval
+	if p.options.Budget != nil {
+		if budgetInterval := p.options.Budget.Interval(keys, p.options.Cost); budgetInterval > interval {
+			interval = budgetInterval
+		}
+	}
+	return interval
+}
+
+func (p *Poller) jitter(interval time.Duration) time.Duration {
+	if p.options.Jitter <= 0 {
+		return interval
+	}
+	return time.Duration(float64(interval) * (1 + p.options.Jitter*(rand.Float64()*2-1)))
+}
+
+// backoff returns the time until the next check after the given number of failures
+func (p *Poller) backoff(interval time.Duration, failures int) time.Duration {
+	backoff := interval
+	for i := 0; i < failures && backoff < p.options.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > p.options.MaxBackoff {
+		backoff = p.options.MaxBackoff
+	}
+	return p.jitter(backoff)
+}
+
+func setMetric(metric *expvar.Map, key string, value int64) {
+	metricValue := new(expvar.Int)
+	metricValue.Set(value)
+	metric.Set(key, metricValue)
+}
+
+// Health returns a snapshot of the state of the poller
+func (p *Poller) Health() Health {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.health
+}
+
+func (p *Poller) logger() *logrus.Entry {
+	return cache.GetLogger().WithField("module", "feeds").WithField("source", p.source.Name())
+}
+
+// Pollers returns all started pollers
+func Pollers() []*Poller {
+	pollersLock.RLock()
+	defer pollersLock.RUnlock()
+
+	result := make([]*Poller, len(pollers))
+	copy(result, pollers)
+	return result
+}
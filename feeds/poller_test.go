@@ -0,0 +1,124 @@
+package feeds
+
+import (
+	"errors"
+	"expvar"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/sirupsen/logrus"
+)
+
+type testSource struct {
+	bundled map[string][]Subscription
+	failing map[string]bool
+	fetched []string
+}
+
+func (s *testSource) Name() string {
+	return "test"
+}
+
+func (s *testSource) Subscriptions() (map[string][]Subscription, error) {
+	return s.bundled, nil
+}
+
+func (s *testSource) Fetch(key string) ([]Item, error) {
+	s.fetched = append(s.fetched, key)
+	if s.failing[key] {
+		return nil, errors.New("fetch failed")
+	}
+	return []Item{{ID: key}}, nil
+}
+
+func (s *testSource) Handle(key string, items []Item, subscriptions []Subscription) error {
+	return nil
+}
+
+func init() {
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	cache.SetLogger(logger)
+}
+
+func TestPollerPass(t *testing.T) {
+	source := &testSource{
+		bundled: map[string][]Subscription{"a": {1, 2}, "b": {3}},
+		failing: map[string]bool{"b": true},
+	}
+	refreshTime := new(expvar.Float)
+	refreshTime.Set(-1)
+	poller := NewPoller(source, Options{Interval: time.Minute, RefreshTime: refreshTime})
+
+	// new keys are spread over the interval, all of them are due after one interval
+	now := time.Now()
+	poller.pass(now)
+	if len(source.fetched) > 2 {
+		t.Fatalf("feeds.Poller.pass() failed to spread new keys, checked %v", source.fetched)
+	}
+	source.fetched = nil
+	now = now.Add(time.Minute)
+	poller.pass(now)
+	if len(source.fetched) != 2 {
+		t.Fatalf("feeds.Poller.pass() failed to check all due keys, checked %v", source.fetched)
+	}
+
+	health := poller.Health()
+	if health.Keys != 2 || health.Subscriptions != 3 || health.FailingKeys != 1 || health.Errors != 1 || health.LastError == "" {
+		t.Fatalf("feeds.Poller.pass() failed to report health, got %+v", health)
+	}
+	if refreshTime.Value() < 0 {
+		t.Fatalf("feeds.Poller.pass() failed to set the refresh time, got %v", refreshTime.Value())
+	}
+
+	// nothing is due directly after the pass
+	poller.pass(now)
+	if len(source.fetched) != 2 {
+		t.Fatalf("feeds.Poller.pass() failed to skip keys which are not due, checked %v", source.fetched)
+	}
+
+	// removed keys are forgotten
+	delete(source.bundled, "b")
+	poller.pass(now)
+	if health := poller.Health(); health.Keys != 1 || health.FailingKeys != 0 {
+		t.Fatalf("feeds.Poller.pass() failed to forget removed keys, got %+v", health)
+	}
+}
+
+func TestPollerBudget(t *testing.T) {
+	source := &testSource{
+		bundled: map[string][]Subscription{"a": {1}, "b": {2}, "c": {3}},
+	}
+	poller := NewPoller(source, Options{Interval: time.Minute, Budget: NewBudget(2, time.Hour)})
+
+	now := time.Now()
+	poller.pass(now)
+	source.fetched = nil
+	poller.pass(now.Add(time.Hour * 2))
+	if len(source.fetched) != 2 {
+		t.Fatalf("feeds.Poller.pass() failed to stop when the budget ran out, checked %v", source.fetched)
+	}
+	if health := poller.Health(); health.BudgetLeft != 0 {
+		t.Fatalf("feeds.Poller.pass() failed to report the budget left, got %d", health.BudgetLeft)
+	}
+}
+
+func TestPollerBackoff(t *testing.T) {
+	poller := NewPoller(&testSource{}, Options{Interval: time.Minute, MaxBackoff: time.Minute * 10})
+
+	expected := []time.Duration{time.Minute * 2, time.Minute * 4, time.Minute * 8, time.Minute * 10, time.Minute * 10}
+	for i, duration := range expected {
+		if backoff := poller.backoff(time.Minute, i+1); backoff != duration {
+			t.Fatalf("feeds.Poller.backoff() failed after %d failures, expected %s, got %s", i+1, duration, backoff)
+		}
+	}
+}
+
+func TestBudgetInterval(t *testing.T) {
+	budget := NewBudget(600, time.Minute)
+	if interval := budget.Interval(1200, 1); interval != time.Minute*2 {
+		t.Fatalf("feeds.Budget.Interval() failed to spread keys over the budget, got %s", interval)
+	}
+}
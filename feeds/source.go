@@ -0,0 +1,28 @@
+package feeds
+
+import "time"
+
+// Item is a single result of a source, like a post or a stream
+type Item struct {
+	ID   string // unique for the key, used for dedupe
+	Time time.Time
+	Data interface{}
+}
+
+// Subscription is a plugin specific entry, like models.TwitchEntry
+type Subscription interface{}
+
+// Source is a remote service polled for new items, like Twitch or Reddit
+type Source interface {
+	// Name identifies the source in logs, metrics, and the dedupe storage
+	Name() string
+
+	// Subscriptions returns all current subscriptions, bundled by key
+	Subscriptions() (bundled map[string][]Subscription, err error)
+
+	// Fetch returns the current items for the key, like a twitch user ID or a subreddit name
+	Fetch(key string) (items []Item, err error)
+
+	// Handle processes the fetched items for all subscriptions of the key
+	Handle(key string, items []Item, subscriptions []Subscription) (err error)
+}
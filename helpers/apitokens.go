@@ -0,0 +1,125 @@
+package helpers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/globalsign/mgo/bson"
+)
+
+const (
+	// ApiTokenPrefix is the start of all API tokens, to recognise them in leaks
+	ApiTokenPrefix = "robyul_"
+	// the last use of a token is saved at most with this interval
+	apiTokenLastUsedInterval = time.Minute
+)
+
+var (
+	// ApiTokenGroups are the endpoint groups of the REST API, tokens can be limited to some of them
+	ApiTokenGroups = []string{
+		"bot", "users", "members", "profiles", "rankings", "guilds", "randompictures", "statistics",
+		"chatlog", "eventlog", "vanityinvites", "files", "backgrounds",
+		"customcommands", "autoroles", "starboard", "greeter", "levels", "modulepermissions", "feeds",
+	}
+
+	ErrApiTokenInvalid = errors.New("invalid api token")
+)
+
+// HashApiToken returns the hash of the token, as it is stored in the database
+func HashApiToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// CreateApiToken stores a new token with the name and scopes of the entry, the token is only returned here
+func CreateApiToken(entry models.ApiTokenEntry) (token string, created models.ApiTokenEntry, err error) {
+	for _, group := range entry.Groups {
+		if !SliceContains(ApiTokenGroups, group) {
+			return "", created, errors.New("unknown endpoint group " + group)
+		}
+	}
+
+	data := make([]byte, 32)
+	_, err = rand.Read(data)
+	if err != nil {
+		return "", created, err
+	}
+	token = ApiTokenPrefix + base64.RawURLEncoding.EncodeToString(data)
+
+	entry.TokenHash = HashApiToken(token)
+	entry.CreatedAt = time.Now()
+	entry.ID, err = MDbInsert(models.ApiTokensTable, entry)
+	return token, entry, err
+}
+
+// GetApiToken returns the entry of a token, ErrApiTokenInvalid if it doesn't exist or has been revoked
+func GetApiToken(token string) (entry models.ApiTokenEntry, err error) {
+	if !strings.HasPrefix(token, ApiTokenPrefix) {
+		return entry, ErrApiTokenInvalid
+	}
+
+	err = MdbOneWithoutLogging(
+		MdbCollection(models.ApiTokensTable).Find(bson.M{"tokenhash": HashApiToken(token)}),
+		&entry,
+	)
+	if IsMdbNotFound(err) || (err == nil && entry.Revoked) {
+		return entry, ErrApiTokenInvalid
+	}
+	if err != nil {
+		return entry, err
+	}
+
+	if time.Since(entry.LastUsedAt) > apiTokenLastUsedInterval {
+		entry.LastUsedAt = time.Now()
+		RelaxLog(MDbUpdateQueryWithoutLogging(models.ApiTokensTable,
+			bson.M{"_id": entry.ID}, bson.M{"$set": bson.M{"lastusedat": entry.LastUsedAt}}))
+	}
+
+	return entry, nil
+}
+
+// GetApiTokens returns all tokens, including revoked ones
+func GetApiTokens() (entries []models.ApiTokenEntry, err error) {
+	err = MDbIter(MdbCollection(models.ApiTokensTable).Find(nil).Sort("createdat")).All(&entries)
+	return entries, err
+}
+
+// RevokeApiToken revokes the token, it is kept for the audit log
+func RevokeApiToken(id bson.ObjectId) (err error) {
+	return MDbUpdateQuery(models.ApiTokensTable, bson.M{"_id": id}, bson.M{"$set": bson.M{"revoked": true}})
+}
+
+// ApiTokenAllowsGuild returns true if the token can access the guild
+func ApiTokenAllowsGuild(entry models.ApiTokenEntry, guildID string) bool {
+	return len(entry.GuildIDs) <= 0 || SliceContains(entry.GuildIDs, guildID)
+}
+
+// ApiTokenAllowsGroup returns true if the token can access the endpoint group
+func ApiTokenAllowsGroup(entry models.ApiTokenEntry, group string) bool {
+	return len(entry.Groups) <= 0 || SliceContains(entry.Groups, group)
+}
+
+// LogApiCall adds the call to the audit log
+func LogApiCall(entry models.ApiAuditLogEntry) {
+	entry.CreatedAt = time.Now()
+	_, err := MDbInsertWithoutLogging(models.ApiAuditLogTable, entry)
+	RelaxLog(err)
+}
+
+// GetApiAuditLog returns the latest calls, newest first
+// tokenID	: only calls with this token, all calls if empty
+func GetApiAuditLog(tokenID bson.ObjectId, limit int) (entries []models.ApiAuditLogEntry, err error) {
+	query := bson.M{}
+	if tokenID != "" {
+		query["tokenid"] = tokenID
+	}
+
+	err = MDbIter(MdbCollection(models.ApiAuditLogTable).Find(query).Sort("-createdat").Limit(limit)).All(&entries)
+	return entries, err
+}
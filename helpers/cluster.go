@@ -0,0 +1,207 @@
+package helpers
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/cluster"
+	"github.com/Seklfreak/Robyul2/shardmanager"
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	clusterTopicGuilds = "guilds"
+	clusterTopicStats  = "stats"
+	clusterTopicShards = "shards"
+	// guilds of other processes are cached for this time, to keep the number of requests low
+	remoteGuildsCacheTime = time.Second * 30
+)
+
+// ClusterGuild is a guild on a shard of another process, only the basic information is sent between processes
+type ClusterGuild struct {
+	ID          string
+	Name        string
+	Icon        string
+	OwnerID     string
+	MemberCount int
+	Large       bool
+}
+
+// ClusterStats are the statistics of the guilds of all processes
+type ClusterStats struct {
+	Processes int
+	Guilds    int
+	Channels  int
+	// users are counted per process, users on guilds of multiple processes are counted multiple times
+	Users int
+}
+
+// ClusterShards are the status and restarts of the shards of a process
+type ClusterShards struct {
+	Status   *shardmanager.Status
+	Restarts []*shardmanager.Restart
+}
+
+var (
+	remoteGuilds          []*discordgo.Guild
+	remoteGuildsUpdatedAt time.Time
+	remoteGuildsLock      sync.Mutex
+)
+
+// RegisterClusterHandlers answers requests about the guilds of this process from other processes
+func RegisterClusterHandlers() {
+	cluster.Handle(clusterTopicGuilds, func(payload json.RawMessage) (interface{}, error) {
+		var guilds []ClusterGuild
+		for _, guild := range LocalGuilds() {
+			guilds = append(guilds, ClusterGuild{
+				ID:          guild.ID,
+				Name:        guild.Name,
+				Icon:        guild.Icon,
+				OwnerID:     guild.OwnerID,
+				MemberCount: guild.MemberCount,
+				Large:       guild.Large,
+			})
+		}
+		return guilds, nil
+	})
+
+	cluster.Handle(clusterTopicStats, func(payload json.RawMessage) (interface{}, error) {
+		users := make(map[string]bool)
+		stats := ClusterStats{Processes: 1}
+		for _, guild := range LocalGuilds() {
+			stats.Guilds++
+			stats.Channels += len(guild.Channels)
+			members, err := GetGuildMembers(guild.ID)
+			if err != nil {
+				continue
+			}
+			for _, member := range members {
+				users[member.User.ID] = true
+			}
+		}
+		stats.Users = len(users)
+		return stats, nil
+	})
+
+	cluster.Handle(clusterTopicShards, func(payload json.RawMessage) (interface{}, error) {
+		return ClusterShards{
+			Status:   cache.GetSession().GetFullStatus(),
+			Restarts: cache.GetSession().GetRestartHistory(),
+		}, nil
+	})
+}
+
+// LocalGuilds returns all guilds on the shards of this process
+func LocalGuilds() []*discordgo.Guild {
+	var guilds []*discordgo.Guild
+	for _, shard := range cache.GetSession().Sessions {
+		for _, guild := range shard.State.Guilds {
+			guilds = append(guilds, guild)
+		}
+	}
+
+	return guilds
+}
+
+// getRemoteGuilds returns the guilds of all other processes, they contain no members, channels or roles
+func getRemoteGuilds() []*discordgo.Guild {
+	if !cluster.IsEnabled() {
+		return nil
+	}
+
+	remoteGuildsLock.Lock()
+	defer remoteGuildsLock.Unlock()
+
+	if time.Since(remoteGuildsUpdatedAt) < remoteGuildsCacheTime {
+		return remoteGuilds
+	}
+
+	responses, err := cluster.Request(clusterTopicGuilds, nil)
+	if err != nil {
+		cache.GetLogger().WithField("module", "cluster").WithError(err).Warn("failed to request guilds")
+		return remoteGuilds
+	}
+
+	var guilds []*discordgo.Guild
+	for _, response := range responses {
+		if response.ProcessID == cluster.ProcessID() {
+			continue
+		}
+
+		var clusterGuilds []ClusterGuild
+		err = response.Decode(&clusterGuilds)
+		if err != nil {
+			cache.GetLogger().WithField("module", "cluster").WithError(err).Warn(
+				"failed to get guilds of process ", response.ProcessID)
+			continue
+		}
+		for _, clusterGuild := range clusterGuilds {
+			guilds = append(guilds, &discordgo.Guild{
+				ID:          clusterGuild.ID,
+				Name:        clusterGuild.Name,
+				Icon:        clusterGuild.Icon,
+				OwnerID:     clusterGuild.OwnerID,
+				MemberCount: clusterGuild.MemberCount,
+				Large:       clusterGuild.Large,
+			})
+		}
+	}
+
+	remoteGuilds = guilds
+	remoteGuildsUpdatedAt = time.Now()
+	return remoteGuilds
+}
+
+// GetClusterStats returns the statistics of the guilds of all processes
+func GetClusterStats() (stats ClusterStats, err error) {
+	responses, err := cluster.Request(clusterTopicStats, nil)
+	if err != nil {
+		return stats, err
+	}
+
+	for _, response := range responses {
+		var processStats ClusterStats
+		err = response.Decode(&processStats)
+		if err != nil {
+			return stats, err
+		}
+
+		stats.Processes += processStats.Processes
+		stats.Guilds += processStats.Guilds
+		stats.Channels += processStats.Channels
+		stats.Users += processStats.Users
+	}
+	return stats, nil
+}
+
+// GetClusterShards returns the status and restarts of the shards of all processes, ordered by shard and time
+func GetClusterShards() (shards ClusterShards, err error) {
+	responses, err := cluster.Request(clusterTopicShards, nil)
+	if err != nil {
+		return shards, err
+	}
+
+	shards.Status = &shardmanager.Status{}
+	for _, response := range responses {
+		var processShards ClusterShards
+		err = response.Decode(&processShards)
+		if err != nil {
+			return shards, err
+		}
+
+		shards.Status.Shards = append(shards.Status.Shards, processShards.Status.Shards...)
+		shards.Status.NumGuilds += processShards.Status.NumGuilds
+		shards.Restarts = append(shards.Restarts, processShards.Restarts...)
+	}
+
+	sort.Slice(shards.Status.Shards, func(i, j int) bool {
+		return shards.Status.Shards[i].Shard < shards.Status.Shards[j].Shard
+	})
+	sort.SliceStable(shards.Restarts, func(i, j int) bool {
+		return shards.Restarts[i].Time.Before(shards.Restarts[j].Time)
+	})
+	return shards, nil
+}
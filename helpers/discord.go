@@ -14,6 +14,7 @@ import (
 	"github.com/RichardKnop/machinery/v1/tasks"
 	"github.com/Seklfreak/Robyul2/cache"
 	"github.com/Seklfreak/Robyul2/models"
+	"github.com/Seklfreak/Robyul2/statecache"
 	"github.com/bradfitz/slice"
 	"github.com/bwmarrin/discordgo"
 	raven "github.com/getsentry/raven-go"
@@ -819,7 +820,7 @@ func LogMachineryError(errorMessage string) (err error) {
 }
 
 func GetGuildMember(guildID string, userID string) (*discordgo.Member, error) {
-	targetMember, err := cache.GetSession().SessionForGuildS(guildID).State.Member(guildID, userID)
+	targetMember, err := GetGuildMemberWithoutApi(guildID, userID)
 	if targetMember == nil || targetMember.GuildID == "" || targetMember.JoinedAt == "" {
 		cache.GetLogger().WithField("module", "discord").WithField("method", "GetGuildMember").Debug(
 			fmt.Sprintf("discord api request: GuildMember: %s, %s", guildID, userID))
@@ -831,8 +832,40 @@ func GetGuildMember(guildID string, userID string) (*discordgo.Member, error) {
 	return targetMember, err
 }
 
+// GetGuildMemberWithoutApi returns the member from the state, or from the redis state cache
+// if the guild is on another process or keeps its members only in redis.
+// Members of other guilds are not looked up in redis, the state is more recent.
 func GetGuildMemberWithoutApi(guildID string, userID string) (*discordgo.Member, error) {
-	return cache.GetSession().SessionForGuildS(guildID).State.Member(guildID, userID)
+	session := cache.GetSession().SessionForGuildS(guildID)
+	targetMember, err := session.State.Member(guildID, userID)
+	if (err != nil || targetMember == nil) && statecache.IsEnabled() {
+		if !cache.GetSession().IsLocalGuild(guildID) {
+			return statecache.Member(guildID, userID)
+		}
+		guild, guildErr := session.State.Guild(guildID)
+		if guildErr == nil && statecache.IsMemberLimited(guild.MemberCount) {
+			return statecache.Member(guildID, userID)
+		}
+	}
+	return targetMember, err
+}
+
+// GetGuildMembers returns all members of the guild from the state, or from the redis state cache
+// if the guild is on another process or keeps its members only in redis
+func GetGuildMembers(guildID string) ([]*discordgo.Member, error) {
+	session := cache.GetSession().SessionForGuildS(guildID)
+	guild, err := session.State.Guild(guildID)
+	if (err != nil || guild == nil || statecache.IsMemberLimited(guild.MemberCount)) && statecache.IsEnabled() {
+		return statecache.Members(guildID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// the members of the guild in the state are changed by the event handlers
+	session.State.RLock()
+	defer session.State.RUnlock()
+	return append([]*discordgo.Member{}, guild.Members...), nil
 }
 
 func GetIsInGuild(guildID string, userID string) bool {
@@ -845,7 +878,7 @@ func GetIsInGuild(guildID string, userID string) bool {
 }
 
 func GetGuild(guildID string) (*discordgo.Guild, error) {
-	targetGuild, err := cache.GetSession().SessionForGuildS(guildID).State.Guild(guildID)
+	targetGuild, err := GetGuildWithoutApi(guildID)
 	if targetGuild == nil || targetGuild.ID == "" {
 		//cache.GetLogger().WithField("module", "discord").WithField("method", "GetGuild").Debug(
 		//		fmt.Sprintf("discord api request: Guild: %s", guildID))
@@ -854,8 +887,13 @@ func GetGuild(guildID string) (*discordgo.Guild, error) {
 	return targetGuild, err
 }
 
+// GetGuildWithoutApi returns the guild from the state, or from the redis state cache if the guild is on another process.
+// Guilds from the redis state cache contain no members.
 func GetGuildWithoutApi(guildID string) (*discordgo.Guild, error) {
 	targetGuild, err := cache.GetSession().SessionForGuildS(guildID).State.Guild(guildID)
+	if (err != nil || targetGuild == nil) && statecache.IsEnabled() {
+		return statecache.Guild(guildID)
+	}
 	return targetGuild, err
 }
 
@@ -872,6 +910,11 @@ func GetChannelWithoutApi(channelID string) (*discordgo.Channel, error) {
 		}
 	}
 
+	// channels of guilds on other processes
+	if statecache.IsEnabled() {
+		return statecache.Channel(channelID)
+	}
+
 	return nil, discordgo.ErrStateNotFound
 }
 
@@ -1675,13 +1718,7 @@ func EmojIURL(emojiID string, animated bool) string {
 	return discordgo.EndpointCDN + "emojis/" + emojiID + ".png"
 }
 
+// AllGuilds returns the guilds of all processes, guilds of other processes contain no members, channels or roles
 func AllGuilds() []*discordgo.Guild {
-	var guilds []*discordgo.Guild
-	for _, shard := range cache.GetSession().Sessions {
-		for _, guild := range shard.State.Guilds {
-			guilds = append(guilds, guild)
-		}
-	}
-
-	return guilds
+	return append(LocalGuilds(), getRemoteGuilds()...)
 }
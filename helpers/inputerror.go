@@ -0,0 +1,26 @@
+package helpers
+
+// InputError is an invalid input of a user, shared between chat commands and the REST API
+// the message is the translated text of the key
+type InputError struct {
+	Key          string
+	Replacements []interface{}
+}
+
+// NewInputError returns an InputError with the text of the key, formatted with the replacements
+func NewInputError(key string, replacements ...interface{}) error {
+	return &InputError{Key: key, Replacements: replacements}
+}
+
+func (e *InputError) Error() string {
+	if len(e.Replacements) > 0 {
+		return GetTextF(e.Key, e.Replacements...)
+	}
+	return GetText(e.Key)
+}
+
+// IsInputError returns true if the error is an InputError, its message can be shown to the user
+func IsInputError(err error) bool {
+	_, ok := err.(*InputError)
+	return ok
+}
@@ -0,0 +1,126 @@
+package helpers
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/metrics/openmetrics"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/bwmarrin/discordgo"
+	"github.com/globalsign/mgo"
+)
+
+var (
+	// MongoDbQueryDuration is a histogram of the durations of MongoDB queries per type and collection
+	MongoDbQueryDuration = openmetrics.NewHistogramVec("robyul_mongodb_query_duration_seconds",
+		"Duration of MongoDB queries.", "type", "collection")
+
+	// ElasticRequestDuration is a histogram of the durations of ElasticSearch requests per method and operation
+	ElasticRequestDuration = openmetrics.NewHistogramVec("robyul_elastic_request_duration_seconds",
+		"Duration of ElasticSearch requests.", "method", "operation", "status")
+
+	// DiscordRequestDuration is a histogram of the durations of Discord REST requests per shard, method and route
+	DiscordRequestDuration = openmetrics.NewHistogramVec("robyul_discord_request_duration_seconds",
+		"Duration of Discord REST requests.", "shard", "method", "route", "status")
+
+	discordIDRegex = regexp.MustCompile(`^[0-9]{15,}$`)
+)
+
+// observeMdbQuery adds the duration of the query to the MongoDB histogram
+func observeMdbQuery(queryType string, collection models.MongoDbCollection, took time.Duration) {
+	MongoDbQueryDuration.Observe(took, queryType, stripRobyulDatabaseFromCollection(collection.String()))
+}
+
+// getMdbQueryCollection returns the name of the collection of the query
+func getMdbQueryCollection(query *mgo.Query) models.MongoDbCollection {
+	queryOp := reflect.ValueOf(query).Elem().FieldByName("query").FieldByName("op")
+	return models.MongoDbCollection(queryOp.FieldByName("collection").String())
+}
+
+// instrumentedTransport observes the duration of all requests
+type instrumentedTransport struct {
+	next    http.RoundTripper
+	observe func(request *http.Request, status string, took time.Duration)
+}
+
+func (t *instrumentedTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	start := time.Now()
+	response, err := t.next.RoundTrip(request)
+
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(response.StatusCode)
+	}
+	t.observe(request, status, time.Since(start))
+
+	return response, err
+}
+
+func getInstrumentedClient(client *http.Client,
+	observe func(request *http.Request, status string, took time.Duration)) *http.Client {
+	instrumentedClient := &http.Client{}
+	if client != nil {
+		*instrumentedClient = *client
+	}
+
+	next := instrumentedClient.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	instrumentedClient.Transport = &instrumentedTransport{next: next, observe: observe}
+	return instrumentedClient
+}
+
+// GetElasticInstrumentedClient returns a http client observing the duration of all ElasticSearch requests
+func GetElasticInstrumentedClient() *http.Client {
+	return getInstrumentedClient(nil, func(request *http.Request, status string, took time.Duration) {
+		ElasticRequestDuration.Observe(took, request.Method, getElasticOperation(request.URL.Path), status)
+	})
+}
+
+// getElasticOperation returns the API of the path, like _search or _bulk, requests to documents return document
+func getElasticOperation(path string) string {
+	for _, part := range strings.Split(path, "/") {
+		if strings.HasPrefix(part, "_") {
+			return part
+		}
+	}
+	return "document"
+}
+
+// InstrumentDiscordSession observes the duration of all REST requests of the session, labeled with the shard of the session
+func InstrumentDiscordSession(session *discordgo.Session) {
+	session.Client = getInstrumentedClient(session.Client, func(request *http.Request, status string, took time.Duration) {
+		DiscordRequestDuration.Observe(took,
+			strconv.Itoa(session.ShardID), request.Method, getDiscordRoute(request.URL.EscapedPath()), status)
+	})
+}
+
+// getDiscordRoute returns the route of the API path, with IDs, emoji and tokens replaced, to keep the number of routes small
+// e.g. /api/v6/channels/1234567890123456/messages/1234567890123456 becomes /channels/:id/messages/:id
+func getDiscordRoute(path string) string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	// strip the API prefix and version
+	if len(parts) >= 2 && parts[0] == "api" && strings.HasPrefix(parts[1], "v") {
+		parts = parts[2:]
+	}
+
+	for i, part := range parts {
+		switch {
+		case discordIDRegex.MatchString(part):
+			parts[i] = ":id"
+		case i > 0 && parts[i-1] == "reactions":
+			parts[i] = ":emoji"
+		case i > 0 && parts[i-1] == "invites":
+			parts[i] = ":code"
+		case i > 1 && parts[i-2] == "webhooks":
+			parts[i] = ":token"
+		}
+	}
+
+	return "/" + strings.Join(parts, "/")
+}
@@ -126,6 +126,7 @@ func MDbInsert(collection models.MongoDbCollection, data interface{}) (rid bson.
 	start := time.Now()
 	err = GetMDb().C(collection.String()).Insert(recordData.Interface())
 	took := time.Since(start)
+	observeMdbQuery("insert", collection, took)
 
 	if cache.HasKeen() {
 		go func() {
@@ -176,7 +177,9 @@ func MDbInsertWithoutLogging(collection models.MongoDbCollection, data interface
 		idField.SetString(newID)
 	}
 
+	start := time.Now()
 	err = GetMDb().C(collection.String()).Insert(recordData.Interface())
+	observeMdbQuery("insert", collection, time.Since(start))
 
 	if err != nil {
 		return bson.ObjectId(""), err
@@ -193,6 +196,7 @@ func MDbUpdate(collection models.MongoDbCollection, id bson.ObjectId, data inter
 	start := time.Now()
 	err = GetMDb().C(collection.String()).UpdateId(id, data)
 	took := time.Since(start)
+	observeMdbQuery("update", collection, took)
 
 	if cache.HasKeen() {
 		go func() {
@@ -224,13 +228,18 @@ func MDbUpdateWithoutLogging(collection models.MongoDbCollection, id bson.Object
 		return errors.New("invalid id")
 	}
 
-	return GetMDb().C(collection.String()).UpdateId(id, data)
+	start := time.Now()
+	err = GetMDb().C(collection.String()).UpdateId(id, data)
+	observeMdbQuery("update", collection, time.Since(start))
+
+	return err
 }
 
 func MDbUpdateQuery(collection models.MongoDbCollection, selector interface{}, data interface{}) (err error) {
 	start := time.Now()
 	err = GetMDb().C(collection.String()).Update(selector, data)
 	took := time.Since(start)
+	observeMdbQuery("update", collection, took)
 
 	if cache.HasKeen() {
 		go func() {
@@ -258,7 +267,11 @@ func MDbUpdateQuery(collection models.MongoDbCollection, selector interface{}, d
 }
 
 func MDbUpdateQueryWithoutLogging(collection models.MongoDbCollection, selector interface{}, data interface{}) (err error) {
-	return GetMDb().C(collection.String()).Update(selector, data)
+	start := time.Now()
+	err = GetMDb().C(collection.String()).Update(selector, data)
+	observeMdbQuery("update", collection, time.Since(start))
+
+	return err
 }
 
 func MDbUpsertID(collection models.MongoDbCollection, id bson.ObjectId, data interface{}) (err error) {
@@ -269,6 +282,7 @@ func MDbUpsertID(collection models.MongoDbCollection, id bson.ObjectId, data int
 	start := time.Now()
 	_, err = GetMDb().C(collection.String()).UpsertId(id, data)
 	took := time.Since(start)
+	observeMdbQuery("upsert", collection, took)
 
 	if cache.HasKeen() {
 		go func() {
@@ -300,7 +314,9 @@ func MDbUpsertIDWithoutLogging(collection models.MongoDbCollection, id bson.Obje
 		return errors.New("invalid id")
 	}
 
+	start := time.Now()
 	_, err = GetMDb().C(collection.String()).UpsertId(id, data)
+	observeMdbQuery("upsert", collection, time.Since(start))
 
 	return err
 }
@@ -309,6 +325,7 @@ func MDbUpsert(collection models.MongoDbCollection, selector interface{}, data i
 	start := time.Now()
 	_, err = GetMDb().C(collection.String()).Upsert(selector, data)
 	took := time.Since(start)
+	observeMdbQuery("upsert", collection, took)
 
 	if cache.HasKeen() {
 		go func() {
@@ -332,7 +349,9 @@ func MDbUpsert(collection models.MongoDbCollection, selector interface{}, data i
 }
 
 func MDbUpsertWithoutLogging(collection models.MongoDbCollection, selector interface{}, data interface{}) (err error) {
+	start := time.Now()
 	_, err = GetMDb().C(collection.String()).Upsert(selector, data)
+	observeMdbQuery("upsert", collection, time.Since(start))
 
 	return err
 }
@@ -345,6 +364,7 @@ func MDbDelete(collection models.MongoDbCollection, id bson.ObjectId) (err error
 	start := time.Now()
 	err = GetMDb().C(collection.String()).RemoveId(id)
 	took := time.Since(start)
+	observeMdbQuery("remove", collection, took)
 
 	if cache.HasKeen() {
 		go func() {
@@ -375,13 +395,18 @@ func MDbDeleteWithoutLogging(collection models.MongoDbCollection, id bson.Object
 		return errors.New("invalid id")
 	}
 
-	return GetMDb().C(collection.String()).RemoveId(id)
+	start := time.Now()
+	err = GetMDb().C(collection.String()).RemoveId(id)
+	observeMdbQuery("remove", collection, time.Since(start))
+
+	return err
 }
 
 func MdbDeleteQuery(collection models.MongoDbCollection, selector interface{}) (err error) {
 	start := time.Now()
 	err = GetMDb().C(collection.String()).Remove(selector)
 	took := time.Since(start)
+	observeMdbQuery("remove", collection, took)
 
 	if cache.HasKeen() {
 		go func() {
@@ -408,18 +433,63 @@ func MdbDeleteQuery(collection models.MongoDbCollection, selector interface{}) (
 }
 
 func MdbDeleteQueryWithoutLogging(collection models.MongoDbCollection, selector interface{}) (err error) {
-	return GetMDb().C(collection.String()).Remove(selector)
+	start := time.Now()
+	err = GetMDb().C(collection.String()).Remove(selector)
+	observeMdbQuery("remove", collection, time.Since(start))
+
+	return err
 }
 
 func MdbCollection(collection models.MongoDbCollection) (query *mgo.Collection) {
 	return GetMDb().C(collection.String())
 }
 
-func MDbIter(query *mgo.Query) (iter *mgo.Iter) {
-	start := time.Now()
-	iter = query.Iter()
-	took := time.Since(start)
-	if cache.HasKeen() {
+// MdbIter is an iterator which observes the duration of the query once the iteration is done
+type MdbIter struct {
+	*mgo.Iter
+	start   time.Time
+	observe func(took time.Duration)
+}
+
+func newMdbIter(query *mgo.Query, observe func(took time.Duration)) *MdbIter {
+	return &MdbIter{start: time.Now(), Iter: query.Iter(), observe: observe}
+}
+
+func (iter *MdbIter) done() {
+	if iter.observe != nil {
+		iter.observe(time.Since(iter.start))
+		iter.observe = nil
+	}
+}
+
+// Close closes the iterator and observes the duration of the query
+func (iter *MdbIter) Close() (err error) {
+	err = iter.Iter.Close()
+	iter.done()
+	return err
+}
+
+// All retrieves all documents, closes the iterator and observes the duration of the query
+func (iter *MdbIter) All(result interface{}) (err error) {
+	err = iter.Iter.All(result)
+	iter.done()
+	return err
+}
+
+// For calls f for every document, closes the iterator and observes the duration of the query
+func (iter *MdbIter) For(result interface{}, f func() error) (err error) {
+	err = iter.Iter.For(result, f)
+	iter.done()
+	return err
+}
+
+func MDbIter(query *mgo.Query) (iter *MdbIter) {
+	return newMdbIter(query, func(took time.Duration) {
+		observeMdbQuery("query", getMdbQueryCollection(query), took)
+		if !cache.HasKeen() {
+			return
+		}
+
 		go func() {
 			defer Recover()
 
@@ -439,18 +509,20 @@ func MDbIter(query *mgo.Query) (iter *mgo.Iter) {
 				cache.GetLogger().WithField("module", "mdb").Error("Error logging MongoDB request to keen: ", err.Error())
 			}
 		}()
-	}
-	return
+	})
 }
 
-func MDbIterWithoutLogging(query *mgo.Query) (iter *mgo.Iter) {
-	return query.Iter()
+func MDbIterWithoutLogging(query *mgo.Query) (iter *MdbIter) {
+	return newMdbIter(query, func(took time.Duration) {
+		observeMdbQuery("query", getMdbQueryCollection(query), took)
+	})
 }
 
 func MdbOne(query *mgo.Query, object interface{}) (err error) {
 	start := time.Now()
 	err = query.One(object)
 	took := time.Since(start)
+	observeMdbQuery("query", getMdbQueryCollection(query), took)
 	if cache.HasKeen() {
 		go func() {
 			defer Recover()
@@ -476,13 +548,18 @@ func MdbOne(query *mgo.Query, object interface{}) (err error) {
 }
 
 func MdbOneWithoutLogging(query *mgo.Query, object interface{}) (err error) {
-	return query.One(object)
+	start := time.Now()
+	err = query.One(object)
+	observeMdbQuery("query", getMdbQueryCollection(query), time.Since(start))
+
+	return err
 }
 
 func MdbPipeOne(collection models.MongoDbCollection, pipeline interface{}, object interface{}) (err error) {
 	start := time.Now()
 	err = MdbCollection(collection).Pipe(pipeline).One(object)
 	took := time.Since(start)
+	observeMdbQuery("pipeline", collection, took)
 	if cache.HasKeen() {
 		go func() {
 			defer Recover()
@@ -503,13 +580,18 @@ func MdbPipeOne(collection models.MongoDbCollection, pipeline interface{}, objec
 }
 
 func MdbPipeOneWithoutLogging(collection models.MongoDbCollection, pipeline interface{}, object interface{}) (err error) {
-	return MdbCollection(collection).Pipe(pipeline).One(object)
+	start := time.Now()
+	err = MdbCollection(collection).Pipe(pipeline).One(object)
+	observeMdbQuery("pipeline", collection, time.Since(start))
+
+	return err
 }
 
 func MdbCount(collection models.MongoDbCollection, query interface{}) (count int, err error) {
 	start := time.Now()
 	count, err = MdbCollection(collection).Find(query).Count()
 	took := time.Since(start)
+	observeMdbQuery("count", collection, took)
 	if cache.HasKeen() {
 		go func() {
 			defer Recover()
@@ -530,7 +612,11 @@ func MdbCount(collection models.MongoDbCollection, query interface{}) (count int
 }
 
 func MdbCountWithoutLogging(collection models.MongoDbCollection, query interface{}) (count int, err error) {
-	return MdbCollection(collection).Find(query).Count()
+	start := time.Now()
+	count, err = MdbCollection(collection).Find(query).Count()
+	observeMdbQuery("count", collection, time.Since(start))
+
+	return count, err
 }
 
 // Returns a human readable ID version of a ObjectID
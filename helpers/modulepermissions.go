@@ -68,11 +68,14 @@ const (
 	ModulePermVanityInvite       // vanityinvite.go
 	ModulePerm8ball              // 8ball.go
 	ModulePermAllPlaceholder
-	ModulePermFeedback  // feedback.go
-	ModulePermEmbedPost // embedpost.go
-	ModulePermEventlog  // eventlog/
-	ModulePermCrypto    // crypto.go
-	ModulePermImgur     // imgur.go
+	ModulePermFeedback      // feedback.go
+	ModulePermEmbedPost     // embedpost.go
+	ModulePermEventlog      // eventlog/
+	ModulePermCrypto        // crypto.go
+	ModulePermImgur         // imgur.go
+	ModulePermReactionRoles // reactionroles.go
+	ModulePermSchedule      // schedule/
+	ModulePermFeeds         // feeds/
 
 	ModulePermAll = ModulePermStats | ModulePermTranslator | ModulePermUrban | ModulePermWeather | ModulePermVLive |
 		ModulePermInstagram | ModulePermFacebook | ModulePermWolframAlpha | ModulePermLastFm | ModulePermTwitter |
@@ -84,7 +87,8 @@ const (
 		ModulePermAutoRole | ModulePermBias | ModulePermDiscordmoney | ModulePermGallery |
 		ModulePermGuildAnnouncements | ModulePermMirror | ModulePermMirror | ModulePermMod | ModulePermNotifications |
 		ModulePermNuke | ModulePermPersistency | ModulePermPing | ModulePermTroublemaker | ModulePermVanityInvite |
-		ModulePerm8ball | ModulePermFeedback | ModulePermEmbedPost | ModulePermEventlog | ModulePermCrypto | ModulePermImgur
+		ModulePerm8ball | ModulePermFeedback | ModulePermEmbedPost | ModulePermEventlog | ModulePermCrypto | ModulePermImgur |
+		ModulePermReactionRoles | ModulePermSchedule | ModulePermFeeds
 )
 
 var (
@@ -143,6 +147,9 @@ var (
 		{Names: []string{"eventlog"}, Permission: ModulePermEventlog},
 		{Names: []string{"crypto"}, Permission: ModulePermCrypto},
 		{Names: []string{"imgur"}, Permission: ModulePermImgur},
+		{Names: []string{"reactionroles"}, Permission: ModulePermReactionRoles},
+		{Names: []string{"schedule"}, Permission: ModulePermSchedule},
+		{Names: []string{"feeds", "rss"}, Permission: ModulePermFeeds},
 	}
 )
 
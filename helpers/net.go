@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"errors"
 	"io"
+	"net"
 	"net/http"
 	"strconv"
+	"syscall"
 
 	"time"
 
@@ -19,6 +21,68 @@ var DefaultClient = &http.Client{
 	Timeout: time.Duration(15 * time.Second),
 }
 
+// PublicClient only connects to public addresses, it has to be used for user supplied URLs
+var PublicClient = &http.Client{
+	Timeout: time.Duration(15 * time.Second),
+	Transport: &http.Transport{
+		// the address is checked after the host has been resolved, for every connection including redirects
+		DialContext: (&net.Dialer{
+			Timeout: time.Duration(15 * time.Second),
+			Control: PublicDialControl,
+		}).DialContext,
+		TLSHandshakeTimeout: time.Duration(15 * time.Second),
+	},
+}
+
+var (
+	ErrPrivateAddress = errors.New("connections to private addresses are not allowed")
+
+	// privateNetworks are private, shared and reserved networks, the loopback, link-local and
+	// multicast ranges are checked with the methods of net.IP
+	privateNetworks = parseCIDRs(
+		"0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10", "172.16.0.0/12", "192.0.0.0/24", "192.168.0.0/16",
+		"198.18.0.0/15", "240.0.0.0/4", "fc00::/7",
+	)
+)
+
+// PublicDialControl refuses connections to private addresses, use it as Control of a net.Dialer
+func PublicDialControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || IsPrivateIP(ip) {
+		return ErrPrivateAddress
+	}
+	return nil
+}
+
+// IsPrivateIP returns true for private, loopback, link-local, multicast and reserved addresses
+func IsPrivateIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() {
+		return true
+	}
+	for _, network := range privateNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseCIDRs(cidrs ...string) (networks []*net.IPNet) {
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
 // NetGet executes a GET request to url with the Karen/Discord-Bot user-agent
 func NetGet(url string) []byte {
 	return NetGetUA(url, DEFAULT_UA)
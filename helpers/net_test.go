@@ -0,0 +1,19 @@
+package helpers
+
+import (
+	"net"
+	"testing"
+)
+
+func TestIsPrivateIP(t *testing.T) {
+	for _, address := range []string{"127.0.0.1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254", "100.64.0.1", "::1", "fe80::1", "fd00::1", "0.0.0.0"} {
+		if !IsPrivateIP(net.ParseIP(address)) {
+			t.Fatalf("helpers.IsPrivateIP() failed to block %s", address)
+		}
+	}
+	for _, address := range []string{"1.1.1.1", "172.32.0.1", "2606:4700:4700::1111"} {
+		if IsPrivateIP(net.ParseIP(address)) {
+			t.Fatalf("helpers.IsPrivateIP() blocked public address %s", address)
+		}
+	}
+}
@@ -29,7 +29,10 @@ func ParseKeyValueString(text string) (data map[string]string) {
 	// create and fill the map
 	data = make(map[string]string)
 	for _, item := range items {
-		x := strings.Split(item, "=")
+		x := strings.SplitN(item, "=", 2)
+		if len(x) < 2 {
+			continue
+		}
 		data[x[0]] = x[1]
 	}
 	return data
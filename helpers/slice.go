@@ -42,3 +42,13 @@ func StringSliceDiff(rolesA, rolesB []string) (added, removed []string) {
 
 	return
 }
+
+// SliceContains returns true if the slice contains the string
+func SliceContains(slice []string, s string) bool {
+	for _, item := range slice {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
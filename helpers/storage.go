@@ -3,14 +3,6 @@ package helpers
 import (
 	"sync"
 
-	"bytes"
-
-	"io/ioutil"
-
-	"os"
-
-	"path/filepath"
-
 	"errors"
 
 	"fmt"
@@ -27,20 +19,16 @@ import (
 
 	"github.com/Seklfreak/Robyul2/cache"
 	"github.com/Seklfreak/Robyul2/models"
+	"github.com/Seklfreak/Robyul2/storage"
 	"github.com/globalsign/mgo/bson"
-	"github.com/kennygrant/sanitize"
-	minio "github.com/minio/minio-go"
 	uuid "github.com/satori/go.uuid"
 )
 
 var (
-	minioBucket string
-	minioClient *minio.Client
-	minioLock   sync.Mutex
+	storageBackend storage.Backend
+	storageLock    sync.Mutex
 )
 
-// TODO: watch cache folder size
-
 type AddFileMetadata struct {
 	Filename           string            // the actual file name, can be empty
 	ChannelID          string            // the source channel ID, can be empty, but should be set if possible
@@ -49,8 +37,7 @@ type AddFileMetadata struct {
 	AdditionalMetadata map[string]string // additional metadata attached to the object
 }
 
-// TODO: prevent duplicates
-// Stores a file
+// Stores a file, files with the same content share the object in the object storage
 // name		: the name of the new object, can be empty to generate an unique name
 // data		: the file data
 // metadata	: metadata attached to the object
@@ -85,6 +72,11 @@ func AddFile(name string, data []byte, metadata AddFileMetadata, source string,
 	filetype, _ := SniffMime(data)
 	// get filesize
 	filesize := binary.Size(data)
+	// check storage quotas
+	err = checkStorageQuota(guildID, metadata.UserID, source, filesize)
+	if err != nil {
+		return "", err
+	}
 	// update metadata
 	if metadata.AdditionalMetadata == nil {
 		metadata.AdditionalMetadata = make(map[string]string, 0)
@@ -100,8 +92,14 @@ func AddFile(name string, data []byte, metadata AddFileMetadata, source string,
 	if public {
 		metadata.AdditionalMetadata["public"] = "yes"
 	}
-	// upload file
-	err = uploadFile(objectName, data, metadata.AdditionalMetadata)
+	// get the file we replace, if any
+	var previousEntry models.StorageEntry
+	if name != "" {
+		previousEntry, _ = RetrieveFileInformation(objectName)
+	}
+	// upload file, if the content isn't stored yet
+	contentHash := getContentHash(data)
+	_, err = retainBlob(contentHash, data, filetype)
 	if err != nil {
 		return "", err
 	}
@@ -122,11 +120,22 @@ func AddFile(name string, data []byte, metadata AddFileMetadata, source string,
 			Filesize:       filesize,
 			Public:         public,
 			Metadata:       metadata.AdditionalMetadata,
+			ContentHash:    contentHash,
 		},
 	)
 	if err != nil {
+		RelaxLog(releaseBlob(contentHash))
 		return "", err
 	}
+	// release the content of the replaced file
+	if previousEntry.ID != "" {
+		if previousEntry.ContentHash != "" {
+			err = releaseBlob(previousEntry.ContentHash)
+		} else {
+			err = deleteObject(objectName)
+		}
+		RelaxLog(err)
+	}
 	// warm up cache for public files
 	if public {
 		go func() {
@@ -162,72 +171,17 @@ func RetrieveFileInformation(objectName string) (info models.StorageEntry, err e
 // retrieves a file
 // objectName	: the name of the file to retrieve
 func RetrieveFile(objectName string) (data []byte, err error) {
-	// setup minioClient if not yet done
-	if minioClient == nil {
-		err = setupMinioClient()
-		if err != nil {
-			return data, err
-		}
-	}
-
-	// Increase MongoDB RetrievedCount
-	go func() {
-		defer Recover()
-		err := MDbUpdateQueryWithoutLogging(models.StorageTable, bson.M{"objectname": objectName}, bson.M{"$inc": bson.M{"retrievedcount": 1}})
-		if err != nil && !IsMdbNotFound(err) {
-			RelaxLog(err)
-		}
-	}()
-
-	data = getBucketCache(objectName)
-	if data != nil {
-		cache.GetLogger().WithField("module", "storage").Infof("retrieving " + objectName + " from minio cache")
-		return data, nil
-	}
+	cache.GetLogger().WithField("module", "storage").Info("retrieving " + objectName + " from storage")
 
-	cache.GetLogger().WithField("module", "storage").Infof("retrieving " + objectName + " from minio storage")
-
-	// retrieve the object
-	minioObject, err := minioClient.GetObject(minioBucket, sanitize.BaseName(objectName), minio.GetObjectOptions{})
-	if err != nil {
-		if strings.Contains(err.Error(), "Please reduce your request rate.") {
-			cache.GetLogger().WithField("module", "storage").Infof("object storage ratelimited, waiting for one second, then retrying")
-			time.Sleep(1 * time.Second)
-			return RetrieveFile(objectName)
-		}
-		if strings.Contains(err.Error(), "net/http") || strings.Contains(err.Error(), "timeout") {
-			cache.GetLogger().WithField("module", "storage").Infof("network error retrieving, waiting for one second, then retrying")
-			time.Sleep(1 * time.Second)
-			return RetrieveFile(objectName)
-		}
-		return data, err
-	}
-
-	// read the object into a byte slice
-	data, err = ioutil.ReadAll(minioObject)
-	if err != nil {
-		return data, err
-	}
-
-	go func() {
-		defer Recover()
-		cache.GetLogger().WithField("module", "storage").Infof("caching " + objectName + " into minio cache")
-		err := setBucketCache(objectName, data)
-		RelaxLog(err)
-	}()
-
-	return data, nil
+	return RetrieveFileWithoutLogging(objectName)
 }
 
 // retrieves a file without logging
 // objectName	: the name of the file to retrieve
 func RetrieveFileWithoutLogging(objectName string) (data []byte, err error) {
-	// setup minioClient if not yet done
-	if minioClient == nil {
-		err = setupMinioClient()
-		if err != nil {
-			return data, err
-		}
+	backend, err := getStorage()
+	if err != nil {
+		return data, err
 	}
 
 	// Increase MongoDB RetrievedCount
@@ -239,40 +193,7 @@ func RetrieveFileWithoutLogging(objectName string) (data []byte, err error) {
 		}
 	}()
 
-	data = getBucketCache(objectName)
-	if data != nil {
-		return data, nil
-	}
-
-	// retrieve the object
-	minioObject, err := minioClient.GetObject(minioBucket, sanitize.BaseName(objectName), minio.GetObjectOptions{})
-	if err != nil {
-		if strings.Contains(err.Error(), "Please reduce your request rate.") {
-			cache.GetLogger().WithField("module", "storage").Infof("object storage ratelimited, waiting for one second, then retrying")
-			time.Sleep(1 * time.Second)
-			return RetrieveFileWithoutLogging(objectName)
-		}
-		if strings.Contains(err.Error(), "net/http") || strings.Contains(err.Error(), "timeout") {
-			cache.GetLogger().WithField("module", "storage").Infof("network error retrieving, waiting for one second, then retrying")
-			time.Sleep(1 * time.Second)
-			return RetrieveFileWithoutLogging(objectName)
-		}
-		return data, err
-	}
-
-	// read the object into a byte slice
-	data, err = ioutil.ReadAll(minioObject)
-	if err != nil {
-		return data, err
-	}
-
-	go func() {
-		defer Recover()
-		err := setBucketCache(objectName, data)
-		RelaxLog(err)
-	}()
-
-	return data, nil
+	return backend.Get(getBlobObjectNameForFile(objectName))
 }
 
 // Retrieves a file by the object name md5 hash
@@ -310,6 +231,25 @@ func RetrieveFileByHash(hash string) (filename, filetype string, data []byte, er
 	return entryBucket.Filename, entryBucket.MimeType, data, nil
 }
 
+// Retrieves a public file by the object name md5 hash, returns an error if the file is not public
+// hash	: the md5 hash
+func RetrievePublicFileByHash(hash string) (filename, filetype string, data []byte, err error) {
+	var entryBucket models.StorageEntry
+	err = MdbOneWithoutLogging(
+		MdbCollection(models.StorageTable).Find(bson.M{"objectnamehash": hash, "public": true}),
+		&entryBucket,
+	)
+	if err != nil {
+		return "", "", nil, errors.New("file not found")
+	}
+
+	data, err = RetrieveFileWithoutLogging(entryBucket.ObjectName)
+	if err != nil {
+		return "", "", nil, err
+	}
+	return entryBucket.Filename, entryBucket.MimeType, data, nil
+}
+
 // Retrieves files by additional object metadta
 // currently supported file sources: custom commands
 // hash	: the md5 hash
@@ -363,28 +303,23 @@ func GetFileLink(objectName string) (url string, err error) {
 	return url, nil
 }
 
-// Deletes a file
+// Deletes a file, the object in the object storage is deleted when no other file has the same content
 // objectName	: the name of the object
 func DeleteFile(objectName string) (err error) {
-	// setup minioClient if not yet done
-	if minioClient == nil {
-		err = setupMinioClient()
-		if err != nil {
+	info, err := RetrieveFileInformation(objectName)
+	if err == nil && info.ContentHash != "" {
+		cache.GetLogger().WithField("module", "storage").Info("deleting " + objectName + " from storage")
+
+		err = MdbDeleteQuery(models.StorageTable, bson.M{"objectname": objectName})
+		if err != nil && !IsMdbNotFound(err) {
 			return err
 		}
-	}
-
-	cache.GetLogger().WithField("module", "storage").Infof("deleting " + objectName + " from minio storage")
 
-	go func() {
-		defer Recover()
-		cache.GetLogger().WithField("module", "storage").Infof("deleting " + objectName + " from minio cache")
-		err = deleteBucketCache(objectName)
-		RelaxLog(err)
-	}()
+		return releaseBlob(info.ContentHash)
+	}
 
-	// delete the object
-	err = minioClient.RemoveObject(minioBucket, sanitize.BaseName(objectName))
+	// files stored before content addressing, or not stored with AddFile
+	err = deleteObject(objectName)
 
 	// delete mongo db entry
 	go func() {
@@ -410,142 +345,96 @@ func GeneratePublicFileLink(filename, filehash string) (link string) {
 		filehash, filename)
 }
 
-// Checks if an object exists by checking cache for a file or requested metadata from minio
+// Checks if an object exists in the storage
 // objectName	: the name of the file to retrieve
 func ObjectExists(objectName string) bool {
-
-	// setup minioClient if not yet done
-	if minioClient == nil {
-		err := setupMinioClient()
-		if err != nil {
-			return false
-		}
-	}
-
-	data := getBucketCache(objectName)
-	if data != nil {
-		return true
+	backend, err := getStorage()
+	if err != nil {
+		return false
 	}
 
-	// retrieve the object
-	minioStatObject, err := minioClient.StatObject(minioBucket, sanitize.BaseName(objectName), minio.StatObjectOptions{})
+	info, err := backend.Stat(getBlobObjectNameForFile(objectName))
 	if err != nil {
-		if strings.Contains(err.Error(), "Please reduce your request rate.") {
-			cache.GetLogger().WithField("module", "storage").Infof("object storage ratelimited, waiting for one second, then retrying")
-			time.Sleep(1 * time.Second)
-			return ObjectExists(objectName)
-		}
-		if strings.Contains(err.Error(), "net/http") || strings.Contains(err.Error(), "timeout") {
-			cache.GetLogger().WithField("module", "storage").Infof("network error retrieving, waiting for one second, then retrying")
-			time.Sleep(1 * time.Second)
-			return ObjectExists(objectName)
-		}
 		return false
 	}
 
-	// check if the returned object is nil
-	if minioStatObject.Size == 0 {
+	// check if the returned object is empty
+	if info.Size == 0 {
 		return false
 	}
 
 	return true
 }
 
-// TODO: prevent overwrites
-// uploads a file to the minio object storage
+// uploads a file to the storage
 // objectName	: the name of the file to upload
 // data			: the data for the new object
 // metadata		: additional metadata attached to the object
 func uploadFile(objectName string, data []byte, metadata map[string]string) (err error) {
-	// setup minioClient if not yet done
-	if minioClient == nil {
-		err = setupMinioClient()
-		if err != nil {
-			return err
-		}
+	backend, err := getStorage()
+	if err != nil {
+		return err
 	}
 
-	options := minio.PutObjectOptions{}
-
 	// add content type
-	filetype, err := SniffMime(data)
-	if err == nil {
-		options.ContentType = filetype
-	}
-
-	// add metadata
-	if metadata != nil && len(metadata) > 0 {
-		options.UserMetadata = metadata
-	}
+	filetype, _ := SniffMime(data)
 
-	// upload the data
-	_, err = minioClient.PutObject(minioBucket, sanitize.BaseName(objectName), bytes.NewReader(data), -1, options)
-	return err
+	return backend.Put(objectName, data, filetype, metadata)
 }
 
-func getBucketCache(objectName string) (data []byte) {
-	var err error
-
-	if _, err = os.Stat(getObjectPath(objectName)); os.IsNotExist(err) {
-		return nil
-	}
-
-	data, err = ioutil.ReadFile(getObjectPath(objectName))
+// deleteObject deletes an object from the storage
+// objectName	: the name of the object in the storage
+func deleteObject(objectName string) (err error) {
+	backend, err := getStorage()
 	if err != nil {
-		return nil
-	}
-
-	return data
-}
-
-func setBucketCache(objectName string, data []byte) (err error) {
-	if _, err = os.Stat(filepath.Dir(getObjectPath(objectName))); os.IsNotExist(err) {
-		err = os.MkdirAll(filepath.Dir(getObjectPath(objectName)), os.ModePerm)
-		if err != nil {
-			return err
-		}
-	}
-
-	err = ioutil.WriteFile(getObjectPath(objectName), data, 0644)
-	return err
-}
-
-func deleteBucketCache(objectName string) (err error) {
-	if _, err = os.Stat(getObjectPath(objectName)); os.IsNotExist(err) {
-		return nil
+		return err
 	}
 
-	err = os.Remove(getObjectPath(objectName))
-	return err
-}
+	cache.GetLogger().WithField("module", "storage").Info("deleting " + objectName + " from storage")
 
-func getObjectPath(objectName string) (path string) {
-	return GetConfig().Path("cache_folder").Data().(string) + "/minio-" + GetConfig().Path("s3.bucket").Data().(string) + "/" + sanitize.BaseName(objectName)
+	return backend.Delete(objectName)
 }
 
-// Initialize the minio client object, and creates the bucket if it doesn't exist yet
-func setupMinioClient() (err error) {
-	minioLock.Lock()
-	minioBucket = GetConfig().Path("s3.bucket").Data().(string)
-	minioClient, err = minio.New(
-		GetConfig().Path("s3.endpoint").Data().(string),
-		GetConfig().Path("s3.access_key").Data().(string),
-		GetConfig().Path("s3.secret_secret_key").Data().(string),
-		true,
-	)
-	minioLock.Unlock()
+// getStorage returns the storage backend set in the config, and connects to it if not yet done
+// storage.backend can be minio (default), local, or memory
+func getStorage() (backend storage.Backend, err error) {
+	storageLock.Lock()
+	defer storageLock.Unlock()
 
-	bucketExists, err := minioClient.BucketExists(minioBucket)
-	if err != nil {
-		return err
+	if storageBackend != nil {
+		return storageBackend, nil
 	}
 
-	if !bucketExists {
-		err = minioClient.MakeBucket(minioBucket, "ams3")
+	backendName, _ := GetConfig().Path("storage.backend").Data().(string)
+	switch backendName {
+	case "", "minio":
+		minioBackend, err := storage.NewMinio(
+			GetConfig().Path("s3.endpoint").Data().(string),
+			GetConfig().Path("s3.access_key").Data().(string),
+			GetConfig().Path("s3.secret_secret_key").Data().(string),
+			GetConfig().Path("s3.bucket").Data().(string),
+		)
 		if err != nil {
-			return err
+			return nil, err
 		}
+		storageBackend = storage.NewCached(minioBackend,
+			GetConfig().Path("cache_folder").Data().(string)+"/minio-"+GetConfig().Path("s3.bucket").Data().(string),
+			getStorageConfigMegabytes("storage.cache_size_mb"))
+	case "local":
+		folder, _ := GetConfig().Path("storage.folder").Data().(string)
+		if folder == "" {
+			return nil, errors.New("storage.folder has to be set for local storage")
+		}
+		storageBackend, err = storage.NewLocal(folder)
+		if err != nil {
+			return nil, err
+		}
+	case "memory":
+		storageBackend = storage.NewMemory()
+	default:
+		return nil, errors.New("unknown storage backend: " + backendName)
 	}
 
-	return err
+	cache.GetLogger().WithField("module", "storage").Info("using " + backendName + " storage")
+	return storageBackend, nil
 }
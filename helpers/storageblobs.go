@@ -0,0 +1,251 @@
+package helpers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+func getContentHash(data []byte) string {
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+// getBlobObjectName returns a new object name for a blob, every time a blob is added again after it was deleted it
+// gets a new object, so deleting the object of a released blob can't delete the content of the new blob
+func getBlobObjectName(contentHash string) string {
+	return "sha256-" + contentHash + "-" + bson.NewObjectId().Hex()
+}
+
+const (
+	// uploads of blobs claimed longer ago are considered failed, and taken over by the next caller waiting for the blob
+	blobUploadTimeout = time.Minute * 2
+	// callers wait this long for a pending blob, before giving up
+	blobPendingTimeout      = time.Minute * 5
+	blobPendingPollInterval = time.Millisecond * 250
+)
+
+// retainBlob adds a reference to the blob with the content, and uploads the content if it isn't stored yet.
+// If another caller is uploading the content, it waits until the upload is confirmed, and takes it over if it failed.
+// contentHash	: the content hash of the data
+// data			: the content
+// mimeType		: the mime type of the content
+func retainBlob(contentHash string, data []byte, mimeType string) (blob models.StorageBlobEntry, err error) {
+	filesize := len(data)
+
+	// the first reference claims the upload
+	blob, err = upsertBlob(contentHash, bson.M{
+		"objectname":      getBlobObjectName(contentHash),
+		"mimetype":        mimeType,
+		"filesize":        filesize,
+		"uploaddate":      time.Now(),
+		"pending":         true,
+		"uploadclaimedat": time.Now(),
+	})
+	if err != nil {
+		return blob, err
+	}
+
+	if blob.References <= 1 && blob.Pending {
+		err = uploadBlob(blob, data)
+	} else {
+		err = waitForBlob(blob, data)
+	}
+	if err != nil {
+		RelaxLog(releaseBlob(contentHash))
+		return blob, err
+	}
+
+	return blob, nil
+}
+
+// upsertBlob adds a reference to the blob with the content hash, or inserts the blob if it doesn't exist.
+// If another caller inserted the blob at the same time the insert fails on the unique index, it is retried once.
+// contentHash	: the content hash of the blob
+// setOnInsert	: the fields of a new blob
+func upsertBlob(contentHash string, setOnInsert bson.M) (blob models.StorageBlobEntry, err error) {
+	change := mgo.Change{
+		Update: bson.M{
+			"$inc":         bson.M{"references": 1},
+			"$set":         bson.M{"retainedat": time.Now()},
+			"$setOnInsert": setOnInsert,
+		},
+		Upsert:    true,
+		ReturnNew: true,
+	}
+
+	_, err = MdbCollection(models.StorageBlobTable).Find(bson.M{"contenthash": contentHash}).Apply(change, &blob)
+	if mgo.IsDup(err) {
+		_, err = MdbCollection(models.StorageBlobTable).Find(bson.M{"contenthash": contentHash}).Apply(change, &blob)
+	}
+	return blob, err
+}
+
+// uploadBlob uploads the content of a blob claimed by the caller, and marks the blob as uploaded.
+// If the upload fails, the claim is reset.
+// blob	: the blob
+// data	: the content
+func uploadBlob(blob models.StorageBlobEntry, data []byte) (err error) {
+	err = uploadFile(blob.ObjectName, data, map[string]string{
+		"contenthash": blob.ContentHash,
+		"mimetype":    blob.MimeType,
+		"filesize":    strconv.Itoa(blob.Filesize),
+	})
+	if err != nil {
+		RelaxLog(MDbUpdateQueryWithoutLogging(models.StorageBlobTable,
+			bson.M{"_id": blob.ID}, bson.M{"$set": bson.M{"uploadclaimedat": time.Time{}}}))
+		return err
+	}
+
+	return MDbUpdateQueryWithoutLogging(models.StorageBlobTable,
+		bson.M{"_id": blob.ID}, bson.M{"$set": bson.M{"pending": false}})
+}
+
+// waitForBlob waits until the content of the blob is uploaded,
+// and uploads it if the upload of another caller failed or timed out
+// blob	: the blob
+// data	: the content
+func waitForBlob(blob models.StorageBlobEntry, data []byte) (err error) {
+	deadline := time.Now().Add(blobPendingTimeout)
+	for blob.Pending {
+		if time.Now().After(deadline) {
+			return errors.New("timed out waiting for the upload of " + blob.ObjectName)
+		}
+
+		// take over failed uploads
+		var claimedBlob models.StorageBlobEntry
+		_, err = MdbCollection(models.StorageBlobTable).Find(bson.M{
+			"_id":             blob.ID,
+			"pending":         true,
+			"uploadclaimedat": bson.M{"$lt": time.Now().Add(-blobUploadTimeout)},
+		}).Apply(mgo.Change{
+			Update:    bson.M{"$set": bson.M{"uploadclaimedat": time.Now()}},
+			ReturnNew: true,
+		}, &claimedBlob)
+		if err == nil {
+			return uploadBlob(claimedBlob, data)
+		}
+		if !IsMdbNotFound(err) {
+			return err
+		}
+
+		time.Sleep(blobPendingPollInterval)
+
+		err = MdbOneWithoutLogging(MdbCollection(models.StorageBlobTable).Find(bson.M{"_id": blob.ID}), &blob)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// releaseBlob removes a reference from the blob, and deletes the content when the last reference is gone
+// contentHash	: the content hash of the blob
+func releaseBlob(contentHash string) (err error) {
+	var blob models.StorageBlobEntry
+	_, err = MdbCollection(models.StorageBlobTable).Find(bson.M{"contenthash": contentHash}).Apply(mgo.Change{
+		Update:    bson.M{"$inc": bson.M{"references": -1}},
+		ReturnNew: true,
+	}, &blob)
+	if err != nil {
+		if IsMdbNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	if blob.References > 0 {
+		return nil
+	}
+
+	// only remove the blob if it didn't get a new reference in the meantime,
+	// a blob added again after this gets a new object, see getBlobObjectName
+	err = MdbCollection(models.StorageBlobTable).Remove(bson.M{"_id": blob.ID, "references": bson.M{"$lte": 0}})
+	if err != nil {
+		if IsMdbNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	return deleteObject(blob.ObjectName)
+}
+
+// getBlobObjectNameForFile returns the name of the object in the object storage holding the content of the file,
+// files stored before content addressing are stored under their own name
+// objectName	: the name of the file
+func getBlobObjectNameForFile(objectName string) string {
+	info, err := RetrieveFileInformation(objectName)
+	if err != nil || info.ContentHash == "" {
+		return objectName
+	}
+
+	var blob models.StorageBlobEntry
+	err = MdbOneWithoutLogging(
+		MdbCollection(models.StorageBlobTable).Find(bson.M{"contenthash": info.ContentHash}),
+		&blob,
+	)
+	if err != nil {
+		return objectName
+	}
+
+	return blob.ObjectName
+}
+
+// MigrateFileToBlob hashes the content of a file stored before content addressing, and turns it into a reference.
+// If the content is already stored by another file, the copy is deleted.
+// entry	: the file to migrate
+func MigrateFileToBlob(entry models.StorageEntry) (deduplicated bool, err error) {
+	if entry.ContentHash != "" {
+		return false, nil
+	}
+
+	backend, err := getStorage()
+	if err != nil {
+		return false, err
+	}
+
+	data, err := backend.Get(entry.ObjectName)
+	if err != nil {
+		return false, err
+	}
+	contentHash := getContentHash(data)
+
+	// the existing object becomes the blob, unless the content is stored already
+	// if the reference can't be updated afterwards, the blob keeps an additional reference and is never deleted, but no content is lost
+	blob, err := upsertBlob(contentHash, bson.M{
+		"objectname": entry.ObjectName,
+		"mimetype":   entry.MimeType,
+		"filesize":   entry.Filesize,
+		"uploaddate": entry.UploadDate,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	// the content is being uploaded by another file, the copy is only deleted once the upload is confirmed
+	err = waitForBlob(blob, data)
+	if err != nil {
+		RelaxLog(releaseBlob(contentHash))
+		return false, err
+	}
+
+	err = MDbUpdateQueryWithoutLogging(models.StorageTable,
+		bson.M{"_id": entry.ID}, bson.M{"$set": bson.M{"contenthash": contentHash}})
+	if err != nil {
+		return false, err
+	}
+
+	if blob.ObjectName == entry.ObjectName {
+		return false, nil
+	}
+
+	return true, deleteObject(entry.ObjectName)
+}
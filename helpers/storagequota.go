@@ -0,0 +1,173 @@
+package helpers
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/Seklfreak/Robyul2/storage"
+	"github.com/globalsign/mgo/bson"
+)
+
+// ErrStorageQuotaExceeded is returned by AddFile if the file doesn't fit into the quota of the server or user
+var ErrStorageQuotaExceeded = errors.New("storage quota exceeded")
+
+// files stored by Robyul itself don't count towards quotas
+var storageQuotaExemptSources = []string{"eventlog"}
+
+// StorageUsage is the storage used by a server or user
+type StorageUsage struct {
+	Total    int64            // in bytes
+	Files    int              // number of files
+	BySource map[string]int64 // in bytes
+	Quota    int64            // in bytes, 0 if unlimited
+}
+
+// getStorageConfigMegabytes returns the config value in bytes, or 0 if it isn't set
+func getStorageConfigMegabytes(path string) int64 {
+	megabytes, _ := GetConfig().Path(path).Data().(float64)
+	return int64(megabytes * 1e+6)
+}
+
+// GetStorageGuildQuota returns the storage quota of servers in bytes, 0 if unlimited
+func GetStorageGuildQuota() int64 {
+	return getStorageConfigMegabytes("storage.guild_quota_mb")
+}
+
+// GetStorageUserQuota returns the storage quota of users in bytes, 0 if unlimited
+func GetStorageUserQuota() int64 {
+	return getStorageConfigMegabytes("storage.user_quota_mb")
+}
+
+// GetStorageUsage returns the storage counting towards the quota of a server or user
+// key		: guildid or userid
+// id		: the ID of the server or user
+func GetStorageUsage(key, id string) (usage StorageUsage, err error) {
+	var results []struct {
+		Source string `bson:"_id"`
+		Total  int64  `bson:"total"`
+		Files  int    `bson:"files"`
+	}
+	err = MdbCollection(models.StorageTable).Pipe([]bson.M{
+		{"$match": bson.M{key: id, "source": bson.M{"$nin": storageQuotaExemptSources}}},
+		{"$group": bson.M{
+			"_id":   "$source",
+			"total": bson.M{"$sum": "$filesize"},
+			"files": bson.M{"$sum": 1},
+		}},
+	}).All(&results)
+	if err != nil {
+		return usage, err
+	}
+
+	usage.BySource = make(map[string]int64)
+	for _, result := range results {
+		usage.Total += result.Total
+		usage.Files += result.Files
+		usage.BySource[result.Source] = result.Total
+	}
+
+	switch key {
+	case "guildid":
+		usage.Quota = GetStorageGuildQuota()
+	case "userid":
+		usage.Quota = GetStorageUserQuota()
+	}
+
+	return usage, nil
+}
+
+// checkStorageQuota returns ErrStorageQuotaExceeded if the file doesn't fit into the quota of the server or user
+func checkStorageQuota(guildID, userID, source string, filesize int) (err error) {
+	for _, exemptSource := range storageQuotaExemptSources {
+		if source == exemptSource {
+			return nil
+		}
+	}
+
+	if guildID != "" && GetStorageGuildQuota() > 0 {
+		usage, err := GetStorageUsage("guildid", guildID)
+		if err != nil {
+			return err
+		}
+		if usage.Total+int64(filesize) > usage.Quota {
+			return ErrStorageQuotaExceeded
+		}
+	}
+
+	if userID != "" && GetStorageUserQuota() > 0 && !IsBotAdmin(userID) {
+		usage, err := GetStorageUsage("userid", userID)
+		if err != nil {
+			return err
+		}
+		if usage.Total+int64(filesize) > usage.Quota {
+			return ErrStorageQuotaExceeded
+		}
+	}
+
+	return nil
+}
+
+// GetStorageCacheStats returns statistics about the storage cache, ok is false if the storage has no cache
+func GetStorageCacheStats() (stats storage.CacheStats, ok bool) {
+	storageLock.Lock()
+	cachedBackend, ok := storageBackend.(*storage.Cached)
+	storageLock.Unlock()
+	if !ok {
+		return stats, false
+	}
+
+	return cachedBackend.Stats(), true
+}
+
+// CollectStorageGarbage deletes blobs without any files referencing them, blobs are only deleted if they haven't been
+// referenced for the given duration, to not interfere with files being stored at the moment
+func CollectStorageGarbage(unreferencedFor time.Duration) (deleted int, err error) {
+	var blobs []models.StorageBlobEntry
+	err = MDbIterWithoutLogging(MdbCollection(models.StorageBlobTable).Find(bson.M{
+		"$or": []bson.M{
+			{"retainedat": bson.M{"$lt": time.Now().Add(-unreferencedFor)}},
+			{"retainedat": bson.M{"$exists": false}, "uploaddate": bson.M{"$lt": time.Now().Add(-unreferencedFor)}},
+		},
+	})).All(&blobs)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, blob := range blobs {
+		references, err := MdbCountWithoutLogging(models.StorageTable, bson.M{"contenthash": blob.ContentHash})
+		if err != nil {
+			return deleted, err
+		}
+		if references > 0 {
+			continue
+		}
+
+		// only remove the blob if it didn't get a new reference in the meantime
+		query := bson.M{"_id": blob.ID, "references": blob.References, "retainedat": blob.RetainedAt}
+		if blob.RetainedAt.IsZero() {
+			query["retainedat"] = bson.M{"$exists": false}
+		}
+		err = MdbCollection(models.StorageBlobTable).Remove(query)
+		if err != nil {
+			if IsMdbNotFound(err) {
+				continue
+			}
+			return deleted, err
+		}
+
+		err = deleteObject(blob.ObjectName)
+		if err != nil {
+			RelaxLog(err)
+			continue
+		}
+		deleted++
+	}
+
+	if deleted > 0 {
+		cache.GetLogger().WithField("module", "storage").Infof("collected %d unreferenced blobs", deleted)
+	}
+
+	return deleted, nil
+}
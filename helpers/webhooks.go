@@ -1,7 +1,12 @@
 package helpers
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
 
 	"time"
 
@@ -33,6 +38,99 @@ func WebhookExecuteWithResult(id, token string, data *discordgo.WebhookParams) (
 	return message, err
 }
 
+// Executes a webhook with files and waites for the response
+// id	: the ID of the webhook to use
+// token		: the token of the webhook to use
+// data			: webhook params to send
+// files		: the files to upload
+func WebhookExecuteWithFilesWithResult(id, token string, data *discordgo.WebhookParams, files []*discordgo.File) (message *discordgo.Message, err error) {
+	if len(files) <= 0 {
+		return WebhookExecuteWithResult(id, token, data)
+	}
+
+	uri := discordgo.EndpointWebhookToken(id, token) + "?wait=true"
+
+	if data != nil && data.Content != "" {
+		data.Content = CleanDiscordContent(data.Content)
+	}
+
+	body := &bytes.Buffer{}
+	bodywriter := multipart.NewWriter(body)
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return message, err
+	}
+
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", `form-data; name="payload_json"`)
+	header.Set("Content-Type", "application/json")
+	part, err := bodywriter.CreatePart(header)
+	if err != nil {
+		return message, err
+	}
+	_, err = part.Write(payload)
+	if err != nil {
+		return message, err
+	}
+
+	for i, file := range files {
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file%d"; filename="%s"`,
+			i, strings.NewReplacer("\\", "\\\\", `"`, "\\\"").Replace(file.Name)))
+		contentType := file.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		header.Set("Content-Type", contentType)
+
+		part, err = bodywriter.CreatePart(header)
+		if err != nil {
+			return message, err
+		}
+		_, err = io.Copy(part, file.Reader)
+		if err != nil {
+			return message, err
+		}
+	}
+
+	err = bodywriter.Close()
+	if err != nil {
+		return message, err
+	}
+
+	session := cache.GetSession().Session(0)
+	result, err := session.RequestWithLockedBucket("POST", uri, bodywriter.FormDataContentType(), body.Bytes(),
+		session.Ratelimiter.LockBucket(discordgo.EndpointWebhookToken("", "")), 0)
+	if err != nil {
+		return message, err
+	}
+
+	err = json.Unmarshal(result, &message)
+	return message, err
+}
+
+// Edits the content of a message sent by a webhook
+// id	: the ID of the webhook which sent the message
+// token		: the token of the webhook which sent the message
+// messageID	: the ID of the message to edit
+// content		: the new content
+func WebhookMessageEdit(id, token, messageID, content string) (message *discordgo.Message, err error) {
+	uri := discordgo.EndpointWebhookToken(id, token) + "/messages/" + messageID
+
+	data := struct {
+		Content string `json:"content"`
+	}{CleanDiscordContent(content)}
+
+	result, err := cache.GetSession().Session(0).RequestWithBucketID("PATCH", uri, data, discordgo.EndpointWebhookToken("", "")+"/messages/")
+	if err != nil {
+		return message, err
+	}
+
+	err = json.Unmarshal(result, &message)
+	return message, err
+}
+
 // Gets a webhook for a channel (checks for permission, and uses cache)
 // guildID		: the guild from which to get the webhook
 // channelID	: the channel for which to get the webhook
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"math/rand"
@@ -10,6 +11,7 @@ import (
 	"os"
 	"os/signal"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 
@@ -17,14 +19,17 @@ import (
 	marchineryConfig "github.com/RichardKnop/machinery/v1/config"
 	marchineryLog "github.com/RichardKnop/machinery/v1/log"
 	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/cluster"
 	"github.com/Seklfreak/Robyul2/helpers"
 	"github.com/Seklfreak/Robyul2/logging"
 	"github.com/Seklfreak/Robyul2/metrics"
 	"github.com/Seklfreak/Robyul2/migrations"
 	"github.com/Seklfreak/Robyul2/modules"
 	"github.com/Seklfreak/Robyul2/modules/plugins"
+	"github.com/Seklfreak/Robyul2/modules/plugins/schedule"
 	"github.com/Seklfreak/Robyul2/rest"
 	"github.com/Seklfreak/Robyul2/shardmanager"
+	"github.com/Seklfreak/Robyul2/statecache"
 	"github.com/Seklfreak/Robyul2/version"
 	"github.com/Seklfreak/polr-go"
 	"github.com/Unleash/unleash-client-go"
@@ -34,6 +39,7 @@ import (
 	"github.com/go-redis/redis"
 	"github.com/kz/discordrus"
 	"github.com/olivere/elastic"
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/drive/v3"
@@ -101,8 +107,14 @@ func main() {
 	// Show version
 	version.DumpInfo()
 
-	// Start metric server
-	metrics.Init()
+	// Start metric server, processes running a range of shards on the same host need their own address
+	metricsAddress := getArg("metrics-address")
+	if metricsAddress == "" {
+		metricsAddress = helpers.GetConfig().Path("metrics_ip").Data().(string) + ":1337"
+	}
+	if metricsAddress != "off" {
+		metrics.Init(metricsAddress)
+	}
 
 	// Make the randomness more random
 	rand.Seed(time.Now().UTC().UnixNano())
@@ -138,6 +150,7 @@ func main() {
 			),
 			elastic.SetSniff(true),
 			elastic.SetErrorLog(log),
+			elastic.SetHttpClient(helpers.GetElasticInstrumentedClient()),
 			// elastic.SetInfoLog(log),
 		)
 		if err != nil {
@@ -167,18 +180,7 @@ func main() {
 		cache.SetPolr(polrClient)
 	}
 
-	// Run migrations
-	migrations.Run()
-
-	// stop after migrations?
-	for _, arg := range os.Args {
-		if arg == "stop-after-migration" {
-			log.WithField("module", "launcher").Info("stopping after migration")
-			return
-		}
-	}
-
-	// Connecting to redis
+	// Connecting to redis, migrations are locked in redis
 	log.WithField("module", "launcher").Info("Connecting to redis...")
 	redisClient := redis.NewClient(&redis.Options{
 		Addr:         config.Path("redis.address").Data().(string),
@@ -189,6 +191,33 @@ func main() {
 	})
 	cache.SetRedisClient(redisClient)
 
+	// list or roll back migrations?
+	for _, arg := range os.Args {
+		if arg == "--migrate-dry-run" {
+			migrations.DryRun()
+			return
+		}
+		if strings.HasPrefix(arg, "--migrate-rollback=") {
+			toVersion, err := strconv.Atoi(strings.TrimPrefix(arg, "--migrate-rollback="))
+			if err != nil {
+				panic(err)
+			}
+			migrations.Rollback(toVersion)
+			return
+		}
+	}
+
+	// Run migrations
+	migrations.Run()
+
+	// stop after migrations?
+	for _, arg := range os.Args {
+		if arg == "stop-after-migration" || arg == "--migrate-only" {
+			log.WithField("module", "launcher").Info("stopping after migration")
+			return
+		}
+	}
+
 	// Set up Google Drive Client
 	if helpers.GetConfig().Path("google.client_credentials_json_location").Data().(string) != "" {
 		driveCtx := context.Background()
@@ -266,15 +295,38 @@ func main() {
 		panic(err)
 	}
 
+	discord.SessionFunc = func(token string) (*discordgo.Session, error) {
+		session, err := discord.StdSessionFunc(token)
+		if err != nil {
+			return nil, err
+		}
+		helpers.InstrumentDiscordSession(session)
+		return session, nil
+	}
+
 	discord.LogChannel = config.Path("sharding-channel").Data().(string)
 	discord.StatusMessageChannel = config.Path("sharding-channel").Data().(string)
+	if config.ExistsP("sharding-stall-timeout-minutes") {
+		discord.StallTimeout = time.Duration(config.Path("sharding-stall-timeout-minutes").Data().(float64)) * time.Minute
+	}
 
-	amount, err := discord.GetRecommendedCount()
+	// run only a range of the shards?
+	firstShard, lastShard, totalShards, err := getShardRangeArgs()
 	if err != nil {
 		panic(err)
 	}
+	if totalShards > 0 {
+		discord.SetNumShards(totalShards)
+		discord.SetShardRange(firstShard, lastShard)
+		discord.Name = fmt.Sprintf("Robyul %d-%d", firstShard, lastShard)
+	} else {
+		amount, err := discord.GetRecommendedCount()
+		if err != nil {
+			panic(err)
+		}
 
-	discord.SetNumShards(amount)
+		discord.SetNumShards(amount)
+	}
 
 	discord.AddHandler(BotOnReady)
 	discord.AddHandler(BotOnMessageCreate)
@@ -291,6 +343,18 @@ func main() {
 	discord.AddHandler(BotGuildOnPresenceUpdate)
 	discord.AddHandler(BotOnGuildCreate)
 	discord.AddHandler(BotOnGuildDelete)
+	discord.AddHandler(BotOnMessageUpdate)
+	discord.AddHandler(BotOnVoiceStateUpdate)
+	discord.AddHandler(BotOnChannelCreate)
+	discord.AddHandler(BotOnChannelUpdate)
+	discord.AddHandler(BotOnChannelDelete)
+	discord.AddHandler(BotOnGuildUpdate)
+	discord.AddHandler(BotOnGuildRoleCreate)
+	discord.AddHandler(BotOnGuildRoleUpdate)
+	discord.AddHandler(BotOnGuildRoleDelete)
+	discord.AddHandler(BotOnGuildMemberUpdate)
+	discord.AddHandler(BotOnWebhooksUpdate)
+	discord.AddHandler(BotOnEvent)
 
 	if cache.HasElastic() {
 		discord.AddHandler(helpers.ElasticOnMessageCreate)
@@ -301,6 +365,16 @@ func main() {
 		// Guild Member Add in modules/plugins/mod.go
 	}
 
+	// keep the state in redis?
+	if config.ExistsP("state.redis") && config.Path("state.redis").Data().(bool) {
+		var memberLimit int
+		if config.ExistsP("state.member_limit") {
+			memberLimit = int(config.Path("state.member_limit").Data().(float64))
+		}
+		statecache.Init(memberLimit)
+		discord.AddHandler(statecache.OnInterface)
+	}
+
 	// robyulState := robyulstate.NewState()
 	// robyulState.Logger = func(msgL, caller int, format string, a ...interface{}) {
 	// 	pc, file, line, _ := runtime.Caller(caller)
@@ -333,6 +407,15 @@ func main() {
 
 	cache.SetSession(discord)
 
+	// join the other processes, before any background loops start
+	if totalShards > 0 {
+		err = cluster.Init(firstShard, lastShard, totalShards)
+		if err != nil {
+			panic(err)
+		}
+	}
+	helpers.RegisterClusterHandlers()
+
 	// connect all shards
 	err = discord.Start()
 	if err != nil {
@@ -352,7 +435,7 @@ func main() {
 			"http://robyul-web.local:8000",
 		},
 		AllowedHeaders: []string{"Content-Type", "Accept", "Origin", "X-CSRF-Token", "Authorization"},
-		AllowedMethods: []string{"GET", "POST"},
+		AllowedMethods: []string{"GET", "POST", "PUT", "DELETE"},
 		MaxAge:         1000,
 		Container:      wsContainer,
 	}
@@ -373,11 +456,18 @@ func main() {
 		logKeenRequest(req, tookTime.Seconds())
 	})
 
-	go func() {
-		server := &http.Server{Addr: "localhost:2021", Handler: wsContainer}
-		log.Fatal(server.ListenAndServe())
-	}()
-	log.WithField("module", "launcher").Info("REST API listening on localhost:2021")
+	// processes running a range of shards on the same host need their own address, or --rest-address=off
+	restAddress := getArg("rest-address")
+	if restAddress == "" {
+		restAddress = "localhost:2021"
+	}
+	if restAddress != "off" {
+		go func() {
+			server := &http.Server{Addr: restAddress, Handler: wsContainer}
+			log.Fatal(server.ListenAndServe())
+		}()
+		log.WithField("module", "launcher").Info("REST API listening on " + restAddress)
+	}
 
 	// Launch machinery
 	marchineryLog.Set(log.WithField("module", "machinery"))
@@ -394,9 +484,10 @@ func main() {
 	}
 	log.WithField("module", "launcher").Info("started machinery server, default queue: robyul_tasks")
 	err = machineryServer.RegisterTasks(map[string]interface{}{
-		"unmute_user":    helpers.UnmuteUserMachinery,
-		"apply_autorole": plugins.AutoroleApply,
-		"log_error":      helpers.LogMachineryError,
+		"unmute_user":            helpers.UnmuteUserMachinery,
+		"apply_autorole":         plugins.AutoroleApply,
+		"post_scheduled_message": schedule.PostScheduledMessage,
+		"log_error":              helpers.LogMachineryError,
 	})
 	if err != nil {
 		raven.CaptureErrorAndWait(err, nil)
@@ -437,6 +528,15 @@ func main() {
 	BotRuntimeChannel = make(chan os.Signal, 1)
 	signal.Notify(BotRuntimeChannel, os.Interrupt, os.Kill)
 
+	// shutdown requests of other processes
+	cluster.Handle("shutdown", func(payload json.RawMessage) (interface{}, error) {
+		select {
+		case BotRuntimeChannel <- os.Interrupt:
+		default:
+		}
+		return nil, nil
+	})
+
 	// Wait until the os wants us to shutdown
 	<-BotRuntimeChannel
 
@@ -447,6 +547,8 @@ func main() {
 	go func() {
 		log.WithField("module", "launcher").Info("Uninitializing plugins...")
 		BotDestroy()
+		log.WithField("module", "launcher").Info("Leaving cluster...")
+		cluster.Uninit()
 		log.WithField("module", "launcher").Info("Disconnecting bot discord session...")
 		discord.StopAll()
 		// discord.Close()
@@ -466,6 +568,51 @@ func main() {
 	}
 }
 
+// getArg returns the value of the argument from --name value or --name=value, or an empty string
+func getArg(name string) (value string) {
+	for i, arg := range os.Args {
+		switch {
+		case arg == "--"+name && i+1 < len(os.Args):
+			value = os.Args[i+1]
+		case strings.HasPrefix(arg, "--"+name+"="):
+			value = strings.TrimPrefix(arg, "--"+name+"=")
+		}
+	}
+	return value
+}
+
+// getShardRangeArgs returns the shards to run from --shards A-B and --total N, total is 0 if all shards should run
+func getShardRangeArgs() (first, last, total int, err error) {
+	shards, totalText := getArg("shards"), getArg("total")
+	if shards == "" && totalText == "" {
+		return 0, 0, 0, nil
+	}
+	if shards == "" || totalText == "" {
+		return 0, 0, 0, errors.New("--shards and --total have to be used together")
+	}
+
+	total, err = strconv.Atoi(totalText)
+	if err != nil {
+		return 0, 0, 0, errors.Wrap(err, "invalid --total")
+	}
+	parts := strings.SplitN(shards, "-", 2)
+	first, err = strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, 0, errors.Wrap(err, "invalid --shards")
+	}
+	last = first
+	if len(parts) == 2 {
+		last, err = strconv.Atoi(parts[1])
+		if err != nil {
+			return 0, 0, 0, errors.Wrap(err, "invalid --shards")
+		}
+	}
+	if first < 0 || last < first || last >= total {
+		return 0, 0, 0, errors.New("--shards has to be a range within 0 and --total - 1")
+	}
+	return first, last, total, nil
+}
+
 type KeenRestEvent struct {
 	Seconds   float64
 	Method    string
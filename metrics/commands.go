@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/metrics/openmetrics"
+)
+
+var (
+	// CommandsTotal counts all executed commands per shard, guild, command and plugin
+	CommandsTotal = openmetrics.NewCounterVec("robyul_commands_total",
+		"Commands executed.", "shard", "guild", "command", "plugin")
+
+	// CommandDuration is a histogram of the durations of commands per command and plugin
+	CommandDuration = openmetrics.NewHistogramVec("robyul_command_duration_seconds",
+		"Duration of command executions.", "command", "plugin")
+
+	// MessagesReceivedTotal counts all received messages per shard and guild
+	MessagesReceivedTotal = openmetrics.NewCounterVec("robyul_messages_received_total",
+		"Messages received.", "shard", "guild")
+
+	// PluginHandlerDuration is a histogram of the durations per plugin and handler
+	PluginHandlerDuration = openmetrics.NewHistogramVec("robyul_plugin_handler_duration_seconds",
+		"Duration of plugin handler calls.", "plugin", "handler")
+
+	// all expvar metrics are exposed too, prefixed with robyul_
+	_ = openmetrics.NewExpvar("robyul_")
+
+	// guild labels can create a lot of series on large bots, they are only added if enabled in the config
+	guildLabels bool
+)
+
+// ObserveCommand counts the command and adds its duration to the histogram of the command
+func ObserveCommand(shardID int, guildID, command, plugin string, duration time.Duration) {
+	CommandsTotal.Inc(strconv.Itoa(shardID), getGuildLabel(guildID), command, plugin)
+	CommandDuration.Observe(duration, command, plugin)
+}
+
+// getGuildLabel returns the guild ID if guild labels are enabled
+func getGuildLabel(guildID string) string {
+	if !guildLabels {
+		return ""
+	}
+	return guildID
+}
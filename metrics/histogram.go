@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// HistogramBuckets are the upper bounds in milliseconds of the buckets of a Histogram, the last bucket has no upper bound
+var HistogramBuckets = []float64{1, 2.5, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000, 30000}
+
+// Histogram counts durations in buckets, it can be published with expvar
+type Histogram struct {
+	counts []int64 // one more than HistogramBuckets
+	count  int64
+	sum    float64
+
+	sync.Mutex
+}
+
+func NewHistogram() *Histogram {
+	return &Histogram{
+		counts: make([]int64, len(HistogramBuckets)+1),
+	}
+}
+
+// Observe adds the duration to the histogram
+func (h *Histogram) Observe(duration time.Duration) {
+	milliseconds := float64(duration) / float64(time.Millisecond)
+
+	h.Lock()
+	defer h.Unlock()
+
+	h.count++
+	h.sum += milliseconds
+	for i, bucket := range HistogramBuckets {
+		if milliseconds <= bucket {
+			h.counts[i]++
+			return
+		}
+	}
+	h.counts[len(HistogramBuckets)]++
+}
+
+// Merge adds all observations of the other histogram to the histogram
+func (h *Histogram) Merge(other *Histogram) {
+	other.Lock()
+	counts := append([]int64{}, other.counts...)
+	count, sum := other.count, other.sum
+	other.Unlock()
+
+	h.Lock()
+	defer h.Unlock()
+
+	for i := range h.counts {
+		h.counts[i] += counts[i]
+	}
+	h.count += count
+	h.sum += sum
+}
+
+// Count returns the number of observations
+func (h *Histogram) Count() int64 {
+	h.Lock()
+	defer h.Unlock()
+
+	return h.count
+}
+
+// Quantile estimates the quantile (0 to 1) from the buckets, it returns the upper bound of the bucket containing the quantile,
+// quantiles above the last bucket return the last bucket
+func (h *Histogram) Quantile(quantile float64) time.Duration {
+	h.Lock()
+	defer h.Unlock()
+
+	if h.count <= 0 {
+		return 0
+	}
+
+	rank := int64(quantile*float64(h.count) + 0.5)
+	if rank < 1 {
+		rank = 1
+	}
+
+	var cumulative int64
+	for i, bucket := range HistogramBuckets {
+		cumulative += h.counts[i]
+		if cumulative >= rank {
+			return time.Duration(bucket * float64(time.Millisecond))
+		}
+	}
+	// slower than the last bucket
+	return time.Duration(HistogramBuckets[len(HistogramBuckets)-1] * float64(time.Millisecond))
+}
+
+// String returns the histogram as JSON, implements expvar.Var
+func (h *Histogram) String() string {
+	h.Lock()
+	defer h.Unlock()
+
+	buckets := make(map[string]int64)
+	var cumulative int64
+	for i, bucket := range HistogramBuckets {
+		cumulative += h.counts[i]
+		buckets[formatHistogramBucket(bucket)] = cumulative
+	}
+	buckets["+Inf"] = h.count
+
+	data, _ := json.Marshal(struct {
+		Buckets map[string]int64 `json:"buckets"`
+		Count   int64            `json:"count"`
+		Sum     float64          `json:"sum"`
+	}{buckets, h.count, h.sum})
+	return string(data)
+}
+
+func formatHistogramBucket(bucket float64) string {
+	data, _ := json.Marshal(bucket)
+	return string(data)
+}
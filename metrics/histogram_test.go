@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHistogramQuantile(t *testing.T) {
+	histogram := NewHistogram()
+	if histogram.Quantile(0.95) != 0 {
+		t.Fatalf("metrics.Histogram.Quantile() failed to return 0 for empty histogram")
+	}
+
+	for i := 0; i < 90; i++ {
+		histogram.Observe(time.Millisecond * 3)
+	}
+	for i := 0; i < 10; i++ {
+		histogram.Observe(time.Millisecond * 200)
+	}
+
+	if histogram.Count() != 100 {
+		t.Fatalf("metrics.Histogram.Count() failed, got %d", histogram.Count())
+	}
+	if quantile := histogram.Quantile(0.5); quantile != time.Millisecond*5 {
+		t.Fatalf("metrics.Histogram.Quantile() failed to estimate median, got %s", quantile)
+	}
+	if quantile := histogram.Quantile(0.95); quantile != time.Millisecond*250 {
+		t.Fatalf("metrics.Histogram.Quantile() failed to estimate p95, got %s", quantile)
+	}
+
+	histogram.Observe(time.Minute)
+	if quantile := histogram.Quantile(1); quantile != time.Second*30 {
+		t.Fatalf("metrics.Histogram.Quantile() failed to cap at last bucket, got %s", quantile)
+	}
+}
+
+func TestHistogramMerge(t *testing.T) {
+	first := NewHistogram()
+	first.Observe(time.Millisecond)
+	second := NewHistogram()
+	second.Observe(time.Second * 2)
+	second.Observe(time.Second * 2)
+
+	first.Merge(second)
+
+	if first.Count() != 3 {
+		t.Fatalf("metrics.Histogram.Merge() failed to add counts, got %d", first.Count())
+	}
+	if quantile := first.Quantile(0.95); quantile != time.Millisecond*2500 {
+		t.Fatalf("metrics.Histogram.Merge() failed to add buckets, got p95 %s", quantile)
+	}
+}
@@ -4,11 +4,13 @@ import (
 	"expvar"
 	"net/http"
 	"runtime"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/Seklfreak/Robyul2/cache"
 	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/metrics/openmetrics"
 	"github.com/Seklfreak/Robyul2/models"
 	"github.com/bwmarrin/discordgo"
 	"gopkg.in/mgo.v2/bson"
@@ -90,6 +92,9 @@ var (
 	// GalleryPostsSent increased with every link reposted
 	GalleryPostsSent = expvar.NewInt("gallery_posts_sent")
 
+	// GalleryDuplicatesSuppressed increased with every near-duplicate image not reposted
+	GalleryDuplicatesSuppressed = expvar.NewInt("gallery_duplicates_suppressed")
+
 	// GalleriesCount counts all galleries in the db
 	MirrorsCount = expvar.NewInt("mirrors_count")
 
@@ -123,7 +128,7 @@ var (
 	// YoutubeLeftQuota counts how many left youtube quotas
 	YoutubeLeftQuota = expvar.NewInt("youtube_left_quota")
 
-	// TwitchRefreshTime counts all connected twitch channels
+	// TwitchChannelsCount counts all connected twitch channels
 	TwitchChannelsCount = expvar.NewInt("twitch_channels_count")
 
 	// TwitchRefreshTime is the latest refresh time
@@ -161,13 +166,62 @@ var (
 
 	// EventlogPendingAuditlogBackfills is the number of games completed
 	EventlogPendingAuditlogBackfills = expvar.NewInt("eventlog_pending_auditlog_backfills")
+
+	// FeedsKeysCount counts all polled keys per feeds source
+	FeedsKeysCount = expvar.NewMap("feeds_keys_count")
+
+	// FeedsFailingKeysCount counts all keys per feeds source which failed their last check
+	FeedsFailingKeysCount = expvar.NewMap("feeds_failing_keys_count")
+
+	// FeedsChecks increases after each check per feeds source
+	FeedsChecks = expvar.NewMap("feeds_checks")
+
+	// FeedsErrors increases after each failed check per feeds source
+	FeedsErrors = expvar.NewMap("feeds_errors")
+
+	// FeedsRefreshTime is the latest refresh time per feeds source
+	FeedsRefreshTime = expvar.NewMap("feeds_refresh_time")
+
+	// StorageCacheHits counts all files retrieved from the storage cache
+	StorageCacheHits = expvar.NewInt("storage_cache_hits")
+
+	// StorageCacheMisses counts all files not found in the storage cache
+	StorageCacheMisses = expvar.NewInt("storage_cache_misses")
+
+	// StorageCacheEvictions counts all files evicted from the storage cache
+	StorageCacheEvictions = expvar.NewInt("storage_cache_evictions")
+
+	// StorageCacheSize is the size of the storage cache in bytes
+	StorageCacheSize = expvar.NewInt("storage_cache_size")
+
+	// PluginHandlerCalls counts all calls per plugin and handler, keyed by <plugin>.<handler>, kept next to the histograms for existing dashboards
+	PluginHandlerCalls = expvar.NewMap("plugin_handler_calls")
+
+	// PluginHandlerSeconds is the total time spent per plugin and handler, keyed by <plugin>.<handler>
+	PluginHandlerSeconds = expvar.NewMap("plugin_handler_seconds")
+
+	// PluginHandlerDurations is a histogram of the durations per plugin and handler, keyed by <plugin>.<handler>
+	PluginHandlerDurations = expvar.NewMap("plugin_handler_durations_ms")
+
+	// PluginHandlerPanics counts all panics per plugin and handler, keyed by <plugin>.<handler>
+	PluginHandlerPanics = expvar.NewMap("plugin_handler_panics")
+
+	// PluginHandlerTimeouts counts all calls per plugin and handler running longer than the watchdog timeout
+	PluginHandlerTimeouts = expvar.NewMap("plugin_handler_timeouts")
 )
 
-// Init starts a http server on 127.0.0.1:1337
-func Init() {
-	cache.GetLogger().WithField("module", "metrics").Info("Listening on TCP/1337")
+// Init starts a http server on the address, serving expvar on /debug/vars and the Prometheus text format on /metrics
+func Init(address string) {
+	cache.GetLogger().WithField("module", "metrics").Info("Listening on " + address)
 	Uptime.Set(time.Now().Unix())
-	go http.ListenAndServe(helpers.GetConfig().Path("metrics_ip").Data().(string)+":1337", nil)
+	if helpers.GetConfig().ExistsP("metrics_guild_labels") {
+		guildLabels, _ = helpers.GetConfig().Path("metrics_guild_labels").Data().(bool)
+	}
+	http.Handle("/metrics", openmetrics.Handler())
+	go func() {
+		err := http.ListenAndServe(address, nil)
+		cache.GetLogger().WithField("module", "metrics").WithError(err).Error("metrics server stopped")
+	}()
 }
 
 var once sync.Once
@@ -183,6 +237,7 @@ func OnReady(session *discordgo.Session, event *discordgo.Ready) {
 // OnMessageCreate listens for said discord event
 func OnMessageCreate(session *discordgo.Session, event *discordgo.MessageCreate) {
 	MessagesReceived.Add(1)
+	MessagesReceivedTotal.Inc(strconv.Itoa(session.ShardID), getGuildLabel(event.GuildID))
 
 	if event.Author.ID == session.State.User.ID {
 		MessagesSent.Add(1)
@@ -270,6 +325,13 @@ func CollectRuntimeMetrics() {
 
 		auditLogBackfills, _ := redis.LLen(models.AuditLogBackfillRedisList).Result()
 		EventlogPendingAuditlogBackfills.Set(auditLogBackfills)
+
+		if storageCacheStats, ok := helpers.GetStorageCacheStats(); ok {
+			StorageCacheHits.Set(storageCacheStats.Hits)
+			StorageCacheMisses.Set(storageCacheStats.Misses)
+			StorageCacheEvictions.Set(storageCacheStats.Evictions)
+			StorageCacheSize.Set(storageCacheStats.Size)
+		}
 	}
 }
 
@@ -0,0 +1,74 @@
+package openmetrics
+
+import (
+	"bytes"
+	"expvar"
+	"sort"
+)
+
+// Expvar exposes all published expvar integers and floats, and maps of them, as untyped samples.
+// The names are kept, with the prefix in front, so dashboards built on /debug/vars can be moved over one by one.
+// Maps are exposed with the map key as the key label.
+type Expvar struct {
+	Prefix string
+}
+
+// NewExpvar creates the bridge and registers it with the default registry
+func NewExpvar(prefix string) *Expvar {
+	bridge := &Expvar{Prefix: prefix}
+	Register(bridge)
+	return bridge
+}
+
+// Name returns the prefix, the expvar samples are written as one block
+func (e *Expvar) Name() string {
+	return e.Prefix
+}
+
+func (e *Expvar) Write(buffer *bytes.Buffer) {
+	expvar.Do(func(variable expvar.KeyValue) {
+		name := SanitizeName(e.Prefix + variable.Key)
+
+		if value, ok := expvarValue(variable.Value); ok {
+			writeHeader(buffer, name, "", "untyped")
+			writeSample(buffer, name, nil, nil, value)
+			return
+		}
+
+		expvarMap, ok := variable.Value.(*expvar.Map)
+		if !ok {
+			return
+		}
+
+		values := make(map[string]float64)
+		expvarMap.Do(func(entry expvar.KeyValue) {
+			if value, ok := expvarValue(entry.Value); ok {
+				values[entry.Key] = value
+			}
+		})
+		if len(values) <= 0 {
+			return
+		}
+
+		keys := make([]string, 0, len(values))
+		for key := range values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		writeHeader(buffer, name, "", "untyped")
+		for _, key := range keys {
+			writeSample(buffer, name, []string{"key"}, []string{key}, values[key])
+		}
+	})
+}
+
+func expvarValue(variable expvar.Var) (value float64, ok bool) {
+	switch typed := variable.(type) {
+	case *expvar.Int:
+		return float64(typed.Value()), true
+	case *expvar.Float:
+		return typed.Value(), true
+	}
+	return 0, false
+}
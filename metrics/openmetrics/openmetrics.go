@@ -0,0 +1,148 @@
+// Package openmetrics exposes metrics in the Prometheus text format
+package openmetrics
+
+import (
+	"bytes"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the content type of the Prometheus text format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Metric is a family of samples which can be written in the Prometheus text format
+type Metric interface {
+	// Name returns the name of the family, it is used to sort the output
+	Name() string
+	// Write appends the family to the buffer
+	Write(buffer *bytes.Buffer)
+}
+
+// Registry holds all metrics exposed by a handler
+type Registry struct {
+	metrics map[string]Metric
+	sync.Mutex
+}
+
+// DefaultRegistry is the registry used by the package level functions
+var DefaultRegistry = NewRegistry()
+
+func NewRegistry() *Registry {
+	return &Registry{
+		metrics: make(map[string]Metric),
+	}
+}
+
+// Register adds the metric to the registry, metrics with the same name are replaced
+func (r *Registry) Register(metric Metric) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.metrics[metric.Name()] = metric
+}
+
+// Write appends all metrics to the buffer, sorted by name
+func (r *Registry) Write(buffer *bytes.Buffer) {
+	r.Lock()
+	metrics := make([]Metric, 0, len(r.metrics))
+	for _, metric := range r.metrics {
+		metrics = append(metrics, metric)
+	}
+	r.Unlock()
+
+	sort.Slice(metrics, func(i, j int) bool {
+		return metrics[i].Name() < metrics[j].Name()
+	})
+	for _, metric := range metrics {
+		metric.Write(buffer)
+	}
+}
+
+// ServeHTTP writes all metrics, implements http.Handler
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	var buffer bytes.Buffer
+	r.Write(&buffer)
+
+	w.Header().Set("Content-Type", ContentType)
+	w.Write(buffer.Bytes())
+}
+
+// Register adds the metric to the default registry
+func Register(metric Metric) {
+	DefaultRegistry.Register(metric)
+}
+
+// Handler returns a http.Handler for the default registry
+func Handler() http.Handler {
+	return DefaultRegistry
+}
+
+// SanitizeName replaces all characters not allowed in metric and label names with underscores
+func SanitizeName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' || r == ':' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+func writeHeader(buffer *bytes.Buffer, name, help, metricType string) {
+	if help != "" {
+		buffer.WriteString("# HELP " + name + " " + escapeHelp(help) + "\n")
+	}
+	buffer.WriteString("# TYPE " + name + " " + metricType + "\n")
+}
+
+// writeSample appends a sample, labels with empty values are left out
+func writeSample(buffer *bytes.Buffer, name string, labelNames, labelValues []string, value float64) {
+	buffer.WriteString(name)
+
+	var written int
+	for i, labelName := range labelNames {
+		if labelValues[i] == "" {
+			continue
+		}
+		if written == 0 {
+			buffer.WriteByte('{')
+		} else {
+			buffer.WriteByte(',')
+		}
+		buffer.WriteString(labelName + `="` + escapeLabelValue(labelValues[i]) + `"`)
+		written++
+	}
+	if written > 0 {
+		buffer.WriteByte('}')
+	}
+
+	buffer.WriteString(" " + formatValue(value) + "\n")
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var (
+	helpReplacer       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelValueReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(text string) string {
+	return helpReplacer.Replace(text)
+}
+
+func escapeLabelValue(text string) string {
+	return labelValueReplacer.Replace(text)
+}
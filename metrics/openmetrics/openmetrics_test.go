@@ -0,0 +1,100 @@
+package openmetrics
+
+import (
+	"bytes"
+	"expvar"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCounterVecWrite(t *testing.T) {
+	registry := NewRegistry()
+	counter := &CounterVec{
+		name:   "test_commands_total",
+		help:   "Commands executed",
+		counts: make(map[string]float64),
+		series: newSeries([]string{"shard", "guild", "command"}),
+	}
+	registry.Register(counter)
+
+	counter.Inc("1", "", "ping")
+	counter.Inc("1", "", "ping")
+	counter.Add(3, "0", "123", `say "hi"`)
+
+	var buffer bytes.Buffer
+	registry.Write(&buffer)
+
+	expected := "# HELP test_commands_total Commands executed\n" +
+		"# TYPE test_commands_total counter\n" +
+		`test_commands_total{shard="0",guild="123",command="say \"hi\""} 3` + "\n" +
+		`test_commands_total{shard="1",command="ping"} 2` + "\n"
+	if buffer.String() != expected {
+		t.Fatalf("openmetrics.CounterVec.Write() failed to write the samples, got %q", buffer.String())
+	}
+
+	if counter.Value("1", "", "ping") != 2 {
+		t.Fatalf("openmetrics.CounterVec.Value() failed to return 2, got %v", counter.Value("1", "", "ping"))
+	}
+}
+
+func TestHistogramVecWrite(t *testing.T) {
+	histogram := &HistogramVec{
+		name:       "test_duration_seconds",
+		buckets:    []float64{.1, 1},
+		histograms: make(map[string]*histogram),
+		series:     newSeries([]string{"command"}),
+	}
+
+	histogram.Observe(time.Millisecond*50, "ping")
+	histogram.Observe(time.Millisecond*500, "ping")
+	histogram.Observe(time.Second*2, "ping")
+
+	var buffer bytes.Buffer
+	histogram.Write(&buffer)
+
+	expected := "# TYPE test_duration_seconds histogram\n" +
+		`test_duration_seconds_bucket{command="ping",le="0.1"} 1` + "\n" +
+		`test_duration_seconds_bucket{command="ping",le="1"} 2` + "\n" +
+		`test_duration_seconds_bucket{command="ping",le="+Inf"} 3` + "\n" +
+		`test_duration_seconds_sum{command="ping"} 2.55` + "\n" +
+		`test_duration_seconds_count{command="ping"} 3` + "\n"
+	if buffer.String() != expected {
+		t.Fatalf("openmetrics.HistogramVec.Write() failed to write the buckets, got %q", buffer.String())
+	}
+
+	if histogram.Count("ping") != 3 {
+		t.Fatalf("openmetrics.HistogramVec.Count() failed to return 3, got %d", histogram.Count("ping"))
+	}
+}
+
+func TestExpvarWrite(t *testing.T) {
+	expvar.NewInt("openmetrics_test_int").Set(42)
+	expvarMap := expvar.NewMap("openmetrics_test_map")
+	expvarMap.Add("b", 2)
+	expvarMap.Add("a", 1)
+
+	var buffer bytes.Buffer
+	(&Expvar{Prefix: "robyul_"}).Write(&buffer)
+	output := buffer.String()
+
+	for _, expected := range []string{
+		"# TYPE robyul_openmetrics_test_int untyped\nrobyul_openmetrics_test_int 42\n",
+		"robyul_openmetrics_test_map{key=\"a\"} 1\nrobyul_openmetrics_test_map{key=\"b\"} 2\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("openmetrics.Expvar.Write() failed to write %q, got %q", expected, output)
+		}
+	}
+
+	// memstats and cmdline are no numbers
+	if strings.Contains(output, "memstats") || strings.Contains(output, "cmdline") {
+		t.Fatalf("openmetrics.Expvar.Write() failed to skip non numeric vars, got %q", output)
+	}
+}
+
+func TestSanitizeName(t *testing.T) {
+	if name := SanitizeName("robyul_plugin.handler-ms"); name != "robyul_plugin_handler_ms" {
+		t.Fatalf("openmetrics.SanitizeName() failed to replace invalid characters, got %s", name)
+	}
+}
@@ -0,0 +1,201 @@
+package openmetrics
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are the upper bounds in seconds of the buckets of a HistogramVec
+var DefaultBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30}
+
+// series holds the samples of a family per combination of label values
+type series struct {
+	labelNames []string
+	values     map[string][]string // label values per key
+	sync.Mutex
+}
+
+func newSeries(labelNames []string) series {
+	return series{
+		labelNames: labelNames,
+		values:     make(map[string][]string),
+	}
+}
+
+// key returns the key of the label values, missing label values are empty
+func (s *series) key(labelValues []string) (key string, normalized []string) {
+	normalized = make([]string, len(s.labelNames))
+	copy(normalized, labelValues)
+	return strings.Join(normalized, "\xff"), normalized
+}
+
+// sortedKeys returns all keys sorted, the lock has to be held
+func (s *series) sortedKeys() []string {
+	keys := make([]string, 0, len(s.values))
+	for key := range s.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// CounterVec is a counter per combination of label values
+type CounterVec struct {
+	name   string
+	help   string
+	counts map[string]float64
+	series
+}
+
+// NewCounterVec creates a counter and registers it with the default registry
+func NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	counter := &CounterVec{
+		name:   name,
+		help:   help,
+		counts: make(map[string]float64),
+		series: newSeries(labelNames),
+	}
+	Register(counter)
+	return counter
+}
+
+// Add adds the value to the counter with the label values, values have to be in the order of the label names
+func (c *CounterVec) Add(value float64, labelValues ...string) {
+	key, normalized := c.key(labelValues)
+
+	c.Lock()
+	defer c.Unlock()
+
+	if _, ok := c.values[key]; !ok {
+		c.values[key] = normalized
+	}
+	c.counts[key] += value
+}
+
+// Inc adds one to the counter with the label values
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Value returns the counter with the label values
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	key, _ := c.key(labelValues)
+
+	c.Lock()
+	defer c.Unlock()
+
+	return c.counts[key]
+}
+
+func (c *CounterVec) Name() string {
+	return c.name
+}
+
+func (c *CounterVec) Write(buffer *bytes.Buffer) {
+	c.Lock()
+	defer c.Unlock()
+
+	writeHeader(buffer, c.name, c.help, "counter")
+	for _, key := range c.sortedKeys() {
+		writeSample(buffer, c.name, c.labelNames, c.values[key], c.counts[key])
+	}
+}
+
+// HistogramVec counts durations in buckets per combination of label values
+type HistogramVec struct {
+	name       string
+	help       string
+	buckets    []float64
+	histograms map[string]*histogram
+	series
+}
+
+type histogram struct {
+	counts []int64 // one per bucket, not cumulative
+	count  int64
+	sum    float64
+}
+
+// NewHistogramVec creates a histogram with the DefaultBuckets and registers it with the default registry
+func NewHistogramVec(name, help string, labelNames ...string) *HistogramVec {
+	vec := &HistogramVec{
+		name:       name,
+		help:       help,
+		buckets:    DefaultBuckets,
+		histograms: make(map[string]*histogram),
+		series:     newSeries(labelNames),
+	}
+	Register(vec)
+	return vec
+}
+
+// Observe adds the duration to the histogram with the label values, values have to be in the order of the label names
+func (h *HistogramVec) Observe(duration time.Duration, labelValues ...string) {
+	seconds := duration.Seconds()
+	key, normalized := h.key(labelValues)
+
+	h.Lock()
+	defer h.Unlock()
+
+	entry, ok := h.histograms[key]
+	if !ok {
+		entry = &histogram{counts: make([]int64, len(h.buckets))}
+		h.histograms[key] = entry
+		h.values[key] = normalized
+	}
+
+	entry.count++
+	entry.sum += seconds
+	for i, bucket := range h.buckets {
+		if seconds <= bucket {
+			entry.counts[i]++
+			break
+		}
+	}
+}
+
+// Count returns the number of observations of the histogram with the label values
+func (h *HistogramVec) Count(labelValues ...string) int64 {
+	key, _ := h.key(labelValues)
+
+	h.Lock()
+	defer h.Unlock()
+
+	if entry, ok := h.histograms[key]; ok {
+		return entry.count
+	}
+	return 0
+}
+
+func (h *HistogramVec) Name() string {
+	return h.name
+}
+
+func (h *HistogramVec) Write(buffer *bytes.Buffer) {
+	h.Lock()
+	defer h.Unlock()
+
+	writeHeader(buffer, h.name, h.help, "histogram")
+
+	bucketLabelNames := append(append([]string{}, h.labelNames...), "le")
+	for _, key := range h.sortedKeys() {
+		entry := h.histograms[key]
+		labelValues := h.values[key]
+		bucketLabelValues := append(append([]string{}, labelValues...), "")
+
+		var cumulative int64
+		for i, bucket := range h.buckets {
+			cumulative += entry.counts[i]
+			bucketLabelValues[len(bucketLabelValues)-1] = formatValue(bucket)
+			writeSample(buffer, h.name+"_bucket", bucketLabelNames, bucketLabelValues, float64(cumulative))
+		}
+		bucketLabelValues[len(bucketLabelValues)-1] = "+Inf"
+		writeSample(buffer, h.name+"_bucket", bucketLabelNames, bucketLabelValues, float64(entry.count))
+
+		writeSample(buffer, h.name+"_sum", h.labelNames, labelValues, entry.sum)
+		writeSample(buffer, h.name+"_count", h.labelNames, labelValues, float64(entry.count))
+	}
+}
@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"expvar"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	pluginHandlerHistograms     = make(map[string]*Histogram)
+	pluginHandlerHistogramsLock sync.Mutex
+)
+
+// PluginStats are the statistics of all handlers of a plugin
+type PluginStats struct {
+	Plugin   string
+	Calls    int64
+	Panics   int64
+	Timeouts int64
+	P95      time.Duration
+	// the handler with the highest p95 latency
+	SlowestHandler    string
+	SlowestHandlerP95 time.Duration
+}
+
+// ObservePluginHandler adds the duration of a call to the histogram of the plugin handler
+func ObservePluginHandler(plugin, handler string, duration time.Duration) {
+	key := plugin + "." + handler
+
+	pluginHandlerHistogramsLock.Lock()
+	histogram, ok := pluginHandlerHistograms[key]
+	if !ok {
+		histogram = NewHistogram()
+		pluginHandlerHistograms[key] = histogram
+		PluginHandlerDurations.Set(key, histogram)
+	}
+	pluginHandlerHistogramsLock.Unlock()
+
+	histogram.Observe(duration)
+	PluginHandlerDuration.Observe(duration, plugin, handler)
+
+	PluginHandlerCalls.Add(key, 1)
+	PluginHandlerSeconds.AddFloat(key, duration.Seconds())
+}
+
+// GetPluginStats returns the statistics of all plugins with at least one handler call, sorted by name
+func GetPluginStats() (stats []PluginStats) {
+	pluginHandlerHistogramsLock.Lock()
+	histograms := make(map[string]*Histogram, len(pluginHandlerHistograms))
+	for key, histogram := range pluginHandlerHistograms {
+		histograms[key] = histogram
+	}
+	pluginHandlerHistogramsLock.Unlock()
+
+	statsByPlugin := make(map[string]*PluginStats)
+	pluginHistograms := make(map[string]*Histogram)
+	for key, histogram := range histograms {
+		// plugin names can contain dots, handler names can't
+		separator := strings.LastIndex(key, ".")
+		if separator < 0 {
+			continue
+		}
+		parts := []string{key[:separator], key[separator+1:]}
+
+		pluginStats, ok := statsByPlugin[parts[0]]
+		if !ok {
+			pluginStats = &PluginStats{Plugin: parts[0]}
+			statsByPlugin[parts[0]] = pluginStats
+			pluginHistograms[parts[0]] = NewHistogram()
+		}
+
+		pluginHistograms[parts[0]].Merge(histogram)
+		pluginStats.Panics += expvarMapInt(PluginHandlerPanics, key)
+		pluginStats.Timeouts += expvarMapInt(PluginHandlerTimeouts, key)
+
+		handlerP95 := histogram.Quantile(0.95)
+		if pluginStats.SlowestHandler == "" || handlerP95 > pluginStats.SlowestHandlerP95 {
+			pluginStats.SlowestHandler = parts[1]
+			pluginStats.SlowestHandlerP95 = handlerP95
+		}
+	}
+
+	for plugin, pluginStats := range statsByPlugin {
+		pluginStats.Calls = pluginHistograms[plugin].Count()
+		pluginStats.P95 = pluginHistograms[plugin].Quantile(0.95)
+		stats = append(stats, *pluginStats)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Plugin < stats[j].Plugin
+	})
+	return stats
+}
+
+func expvarMapInt(expvarMap *expvar.Map, key string) int64 {
+	if value, ok := expvarMap.Get(key).(*expvar.Int); ok {
+		return value.Value()
+	}
+	return 0
+}
@@ -1,5 +1,6 @@
 package migrations
 
-func m29_create_elastic_presence_update_index() {
+func m29_create_elastic_presence_update_index() (applicable bool) {
 	// move to m49
+	return true
 }
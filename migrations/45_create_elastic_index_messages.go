@@ -6,9 +6,9 @@ import (
 	"github.com/Seklfreak/Robyul2/cache"
 )
 
-func m45_create_elastic_index_messages() {
+func m45_create_elastic_index_messages() (applicable bool) {
 	if !cache.HasElastic() {
-		return
+		return false
 	}
 
 	elastic := cache.GetElastic()
@@ -17,7 +17,7 @@ func m45_create_elastic_index_messages() {
 		panic(err)
 	}
 	if exists {
-		return
+		return true
 	}
 
 	messageMapping := map[string]interface{}{
@@ -74,4 +74,6 @@ func m45_create_elastic_index_messages() {
 	if !index.Acknowledged {
 		cache.GetLogger().WithField("module", "migrations").Error("ElasticSearch index not acknowledged")
 	}
+
+	return true
 }
@@ -6,9 +6,9 @@ import (
 	"github.com/Seklfreak/Robyul2/cache"
 )
 
-func m46_create_elastic_index_joins() {
+func m46_create_elastic_index_joins() (applicable bool) {
 	if !cache.HasElastic() {
-		return
+		return false
 	}
 
 	elastic := cache.GetElastic()
@@ -17,7 +17,7 @@ func m46_create_elastic_index_joins() {
 		panic(err)
 	}
 	if exists {
-		return
+		return true
 	}
 
 	joinMapping := map[string]interface{}{
@@ -51,4 +51,6 @@ func m46_create_elastic_index_joins() {
 	if !index.Acknowledged {
 		cache.GetLogger().WithField("module", "migrations").Error("ElasticSearch index not acknowledged")
 	}
+
+	return true
 }
@@ -6,9 +6,9 @@ import (
 	"github.com/Seklfreak/Robyul2/cache"
 )
 
-func m47_create_elastic_index_leaves() {
+func m47_create_elastic_index_leaves() (applicable bool) {
 	if !cache.HasElastic() {
-		return
+		return false
 	}
 
 	elastic := cache.GetElastic()
@@ -17,7 +17,7 @@ func m47_create_elastic_index_leaves() {
 		panic(err)
 	}
 	if exists {
-		return
+		return true
 	}
 
 	leaveMapping := map[string]interface{}{
@@ -45,4 +45,6 @@ func m47_create_elastic_index_leaves() {
 	if !index.Acknowledged {
 		cache.GetLogger().WithField("module", "migrations").Error("ElasticSearch index not acknowledged")
 	}
+
+	return true
 }
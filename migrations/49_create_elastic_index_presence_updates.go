@@ -6,9 +6,9 @@ import (
 	"github.com/Seklfreak/Robyul2/cache"
 )
 
-func m49_create_elastic_index_presence_updates() {
+func m49_create_elastic_index_presence_updates() (applicable bool) {
 	if !cache.HasElastic() {
-		return
+		return false
 	}
 
 	elastic := cache.GetElastic()
@@ -17,7 +17,7 @@ func m49_create_elastic_index_presence_updates() {
 		panic(err)
 	}
 	if exists {
-		return
+		return true
 	}
 
 	presenceUpdateMapping := map[string]interface{}{
@@ -57,4 +57,6 @@ func m49_create_elastic_index_presence_updates() {
 	if !index.Acknowledged {
 		cache.GetLogger().WithField("module", "migrations").Error("ElasticSearch index not acknowledged")
 	}
+
+	return true
 }
@@ -6,9 +6,9 @@ import (
 	"github.com/Seklfreak/Robyul2/cache"
 )
 
-func m50_create_elastic_vanity_invite_clicks() {
+func m50_create_elastic_vanity_invite_clicks() (applicable bool) {
 	if !cache.HasElastic() {
-		return
+		return false
 	}
 
 	elastic := cache.GetElastic()
@@ -17,7 +17,7 @@ func m50_create_elastic_vanity_invite_clicks() {
 		panic(err)
 	}
 	if exists {
-		return
+		return true
 	}
 
 	vanityInviteClickMapping := map[string]interface{}{
@@ -53,4 +53,6 @@ func m50_create_elastic_vanity_invite_clicks() {
 	if !index.Acknowledged {
 		cache.GetLogger().WithField("module", "migrations").Error("ElasticSearch index not acknowledged")
 	}
+
+	return true
 }
@@ -7,9 +7,9 @@ import (
 	"github.com/olivere/elastic"
 )
 
-func m51_reindex_elasticv5_to_v6() {
+func m51_reindex_elasticv5_to_v6() (applicable bool) {
 	if !cache.HasElastic() {
-		return
+		return false
 	}
 
 	elasticClient := cache.GetElastic()
@@ -18,7 +18,7 @@ func m51_reindex_elasticv5_to_v6() {
 		panic(err)
 	}
 	if !exists {
-		return
+		return true
 	}
 
 	cache.GetLogger().WithField("module", "migrations").Info("reindexing ElasticSearch indexes")
@@ -84,4 +84,6 @@ func m51_reindex_elasticv5_to_v6() {
 	if !index.Acknowledged {
 		cache.GetLogger().WithField("module", "migrations").Error("ElasticSearch index not acknowledged")
 	}
+
+	return true
 }
@@ -6,9 +6,9 @@ import (
 	"github.com/Seklfreak/Robyul2/cache"
 )
 
-func m52_create_elastic_index_voice_sessions() {
+func m52_create_elastic_index_voice_sessions() (applicable bool) {
 	if !cache.HasElastic() {
-		return
+		return false
 	}
 
 	elastic := cache.GetElastic()
@@ -17,7 +17,7 @@ func m52_create_elastic_index_voice_sessions() {
 		panic(err)
 	}
 	if exists {
-		return
+		return true
 	}
 
 	messageMapping := map[string]interface{}{
@@ -67,4 +67,6 @@ func m52_create_elastic_index_voice_sessions() {
 	if !index.Acknowledged {
 		cache.GetLogger().WithField("module", "migrations").Error("ElasticSearch index not acknowledged")
 	}
+
+	return true
 }
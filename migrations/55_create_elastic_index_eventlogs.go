@@ -6,9 +6,9 @@ import (
 	"github.com/Seklfreak/Robyul2/cache"
 )
 
-func m55_create_elastic_index_eventlogs() {
+func m55_create_elastic_index_eventlogs() (applicable bool) {
 	if !cache.HasElastic() {
-		return
+		return false
 	}
 
 	elastic := cache.GetElastic()
@@ -17,7 +17,7 @@ func m55_create_elastic_index_eventlogs() {
 		panic(err)
 	}
 	if exists {
-		return
+		return true
 	}
 
 	messageMapping := map[string]interface{}{
@@ -111,4 +111,6 @@ func m55_create_elastic_index_eventlogs() {
 	if !index.Acknowledged {
 		cache.GetLogger().WithField("module", "migrations").Error("ElasticSearch index not acknowledged")
 	}
+
+	return true
 }
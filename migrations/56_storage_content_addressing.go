@@ -0,0 +1,67 @@
+package migrations
+
+import (
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+// m56_storage_content_addressing turns files stored before content addressing into references,
+// and deletes objects with duplicate content, it applies once a storage backend is configured
+func m56_storage_content_addressing() (applicable bool) {
+	if !m56StorageConfigured() {
+		return false
+	}
+
+	err := helpers.MdbCollection(models.StorageBlobTable).EnsureIndex(mgo.Index{
+		Key:    []string{"contenthash"},
+		Unique: true,
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	var entries []models.StorageEntry
+	err = helpers.MDbIterWithoutLogging(helpers.MdbCollection(models.StorageTable).Find(
+		bson.M{"contenthash": bson.M{"$in": []interface{}{nil, ""}}},
+	)).All(&entries)
+	if err != nil {
+		panic(err)
+	}
+	if len(entries) <= 0 {
+		return true
+	}
+
+	log := cache.GetLogger().WithField("module", "migrations")
+	log.Infof("hashing %d stored files", len(entries))
+
+	var migrated, deduplicated int
+	for _, entry := range entries {
+		entryDeduplicated, err := helpers.MigrateFileToBlob(entry)
+		if err != nil {
+			log.Warnf("failed to hash stored file #%s: %s", entry.ObjectName, err.Error())
+			continue
+		}
+		migrated++
+		if entryDeduplicated {
+			deduplicated++
+		}
+	}
+
+	log.Infof("hashed %d stored files, deleted %d duplicates", migrated, deduplicated)
+
+	return true
+}
+
+// m56StorageConfigured returns true if the storage backend is configured, the default minio backend requires s3.endpoint
+func m56StorageConfigured() bool {
+	backendName, _ := helpers.GetConfig().Path("storage.backend").Data().(string)
+	if backendName != "" && backendName != "minio" {
+		return true
+	}
+
+	endpoint, _ := helpers.GetConfig().Path("s3.endpoint").Data().(string)
+	return endpoint != ""
+}
@@ -0,0 +1,98 @@
+package migrations
+
+import (
+	"strings"
+
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/globalsign/mgo"
+)
+
+// m57MongoDbIndexes are the indexes for the hot query paths, they keep the default names so existing indexes on the same keys are reused
+var m57MongoDbIndexes = map[models.MongoDbCollection][]mgo.Index{
+	models.LevelsServerusersTable: {
+		{Key: []string{"guildid", "userid"}},
+		{Key: []string{"userid"}},
+	},
+	models.StarboardEntriesTable: {
+		{Key: []string{"messageid", "guildid"}},
+		{Key: []string{"guildid", "-stars"}},
+	},
+	models.PersistencyRolesTable: {
+		{Key: []string{"guildid", "userid"}},
+	},
+	models.NamesTable: {
+		{Key: []string{"userid", "guildid"}},
+	},
+	models.CustomCommandsTable: {
+		{Key: []string{"guildid", "keyword"}},
+	},
+	models.GuildConfigTable: {
+		{Key: []string{"guildid"}},
+	},
+	models.UserConfigTable: {
+		{Key: []string{"userid", "key"}},
+	},
+	models.StorageTable: {
+		{Key: []string{"objectname"}},
+		{Key: []string{"objectnamehash"}},
+		{Key: []string{"guildid"}},
+		{Key: []string{"userid"}},
+	},
+	models.GalleryImageHashTable: {
+		{Key: []string{"galleryid", "createdat"}},
+	},
+}
+
+// m57_create_mongodb_indexes creates the indexes for the hot query paths, they are built in the background
+func m57_create_mongodb_indexes() (applicable bool) {
+	for collection, indexes := range m57MongoDbIndexes {
+		for _, index := range indexes {
+			index.Background = true
+			err := helpers.MdbCollection(collection).EnsureIndex(index)
+			if err != nil {
+				panic(err)
+			}
+		}
+	}
+
+	return true
+}
+
+func m57_create_mongodb_indexes_down() {
+	for collection, indexes := range m57MongoDbIndexes {
+		existingIndexes, err := helpers.MdbCollection(collection).Indexes()
+		if err != nil {
+			panic(err)
+		}
+
+		existing := make(map[string]bool)
+		for _, existingIndex := range existingIndexes {
+			existing[existingIndex.Name] = true
+		}
+
+		for _, index := range indexes {
+			name := getMdbIndexName(index.Key)
+			if !existing[name] {
+				continue
+			}
+			err = helpers.MdbCollection(collection).DropIndexName(name)
+			if err != nil {
+				panic(err)
+			}
+		}
+	}
+}
+
+// getMdbIndexName returns the name MongoDB gives an index on the keys, e.g. guildid_1_stars_-1
+func getMdbIndexName(keys []string) string {
+	var parts []string
+	for _, key := range keys {
+		if strings.HasPrefix(key, "-") {
+			parts = append(parts, strings.TrimPrefix(key, "-")+"_-1")
+			continue
+		}
+		parts = append(parts, key+"_1")
+	}
+	return strings.Join(parts, "_")
+}
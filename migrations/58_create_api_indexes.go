@@ -0,0 +1,59 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/globalsign/mgo"
+)
+
+// m58ApiIndexes are the indexes for the API tokens and the API audit log, entries of the audit log expire after 90 days
+var m58ApiIndexes = map[models.MongoDbCollection][]mgo.Index{
+	models.ApiTokensTable: {
+		{Key: []string{"tokenhash"}, Unique: true},
+	},
+	models.ApiAuditLogTable: {
+		{Key: []string{"createdat"}, ExpireAfter: time.Hour * 24 * 90},
+		{Key: []string{"tokenid", "-createdat"}},
+	},
+}
+
+func m58_create_api_indexes() (applicable bool) {
+	for collection, indexes := range m58ApiIndexes {
+		for _, index := range indexes {
+			index.Background = true
+			err := helpers.MdbCollection(collection).EnsureIndex(index)
+			if err != nil {
+				panic(err)
+			}
+		}
+	}
+
+	return true
+}
+
+func m58_create_api_indexes_down() {
+	for collection, indexes := range m58ApiIndexes {
+		existingIndexes, err := helpers.MdbCollection(collection).Indexes()
+		if err != nil {
+			panic(err)
+		}
+
+		existing := make(map[string]bool)
+		for _, existingIndex := range existingIndexes {
+			existing[existingIndex.Name] = true
+		}
+
+		for _, index := range indexes {
+			name := getMdbIndexName(index.Key)
+			if !existing[name] {
+				continue
+			}
+			err = helpers.MdbCollection(collection).DropIndexName(name)
+			if err != nil {
+				panic(err)
+			}
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package migrations
+
+import (
+	"time"
+
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/globalsign/mgo"
+)
+
+// m59GalleryImageHashIndexes are the indexes for the duplicate detection of galleries,
+// image hashes expire after 90 days, the time galleries compare reposted images against
+var m59GalleryImageHashIndexes = map[models.MongoDbCollection][]mgo.Index{
+	models.GalleryImageHashTable: {
+		{Key: []string{"createdat"}, ExpireAfter: time.Hour * 24 * 90},
+		{Key: []string{"galleryid", "hashbands"}},
+	},
+}
+
+func m59_create_gallery_image_hash_indexes() (applicable bool) {
+	for collection, indexes := range m59GalleryImageHashIndexes {
+		for _, index := range indexes {
+			index.Background = true
+			err := helpers.MdbCollection(collection).EnsureIndex(index)
+			if err != nil {
+				panic(err)
+			}
+		}
+	}
+
+	return true
+}
+
+func m59_create_gallery_image_hash_indexes_down() {
+	for collection, indexes := range m59GalleryImageHashIndexes {
+		existingIndexes, err := helpers.MdbCollection(collection).Indexes()
+		if err != nil {
+			panic(err)
+		}
+
+		existing := make(map[string]bool)
+		for _, existingIndex := range existingIndexes {
+			existing[existingIndex.Name] = true
+		}
+
+		for _, index := range indexes {
+			name := getMdbIndexName(index.Key)
+			if !existing[name] {
+				continue
+			}
+			err = helpers.MdbCollection(collection).DropIndexName(name)
+			if err != nil {
+				panic(err)
+			}
+		}
+	}
+}
@@ -1,39 +1,264 @@
 package migrations
 
 import (
-	"reflect"
-	"runtime"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
 
 	"github.com/Seklfreak/Robyul2/cache"
 	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/go-redis/redis"
 )
 
-var migrations = []helpers.Callback{
-	m28_create_elastic_indexes,
-	m29_create_elastic_presence_update_index,
-	m43_create_elastic_vanityinvite_click_index,
-	m45_create_elastic_index_messages,
-	m46_create_elastic_index_joins,
-	m47_create_elastic_index_leaves,
-	m49_create_elastic_index_presence_updates,
-	m50_create_elastic_vanity_invite_clicks,
-	m51_reindex_elasticv5_to_v6,
-	m52_create_elastic_index_voice_sessions,
-	m55_create_elastic_index_eventlogs,
-}
-
-// Run executes all registered migrations
+const (
+	migrationsLockKey = "robyul2-discord:migrations:lock"
+	// locks of dead processes expire after this time
+	migrationsLockTTL           = time.Minute
+	migrationsLockRetryInterval = time.Second * 5
+)
+
+var (
+	// migrationsLockRenewScript extends the lock if it is held by the process
+	migrationsLockRenewScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("pexpire", KEYS[1], ARGV[2])
+end
+return 0`)
+
+	// migrationsLockReleaseScript deletes the lock if it is held by the process
+	migrationsLockReleaseScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("del", KEYS[1])
+end
+return 0`)
+)
+
+// Migration changes the databases, applied migrations are recorded in the ledger and never run again.
+// Migrations panic on errors.
+type Migration struct {
+	Version int
+	Name    string
+	// Up applies the migration, it returns false if the migration doesn't apply to the installation yet,
+	// for example without ElasticSearch, then it isn't recorded and runs again on the next start
+	Up func() (applicable bool)
+	// Down reverts Up, nil if the migration can't be reverted
+	Down helpers.Callback
+}
+
+// MigrationStatus is a migration and when it has been applied
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+var migrations = []Migration{
+	{28, "create_elastic_indexes", m28_create_elastic_indexes, nil},
+	{29, "create_elastic_presence_update_index", m29_create_elastic_presence_update_index, nil},
+	{43, "create_elastic_vanityinvite_click_index", m43_create_elastic_vanityinvite_click_index, nil},
+	{45, "create_elastic_index_messages", m45_create_elastic_index_messages, nil},
+	{46, "create_elastic_index_joins", m46_create_elastic_index_joins, nil},
+	{47, "create_elastic_index_leaves", m47_create_elastic_index_leaves, nil},
+	{49, "create_elastic_index_presence_updates", m49_create_elastic_index_presence_updates, nil},
+	{50, "create_elastic_vanity_invite_clicks", m50_create_elastic_vanity_invite_clicks, nil},
+	{51, "reindex_elasticv5_to_v6", m51_reindex_elasticv5_to_v6, nil},
+	{52, "create_elastic_index_voice_sessions", m52_create_elastic_index_voice_sessions, nil},
+	{55, "create_elastic_index_eventlogs", m55_create_elastic_index_eventlogs, nil},
+	{56, "storage_content_addressing", m56_storage_content_addressing, nil},
+	{57, "create_mongodb_indexes", m57_create_mongodb_indexes, m57_create_mongodb_indexes_down},
+	{58, "create_api_indexes", m58_create_api_indexes, m58_create_api_indexes_down},
+	{59, "create_gallery_image_hash_indexes", m59_create_gallery_image_hash_indexes, m59_create_gallery_image_hash_indexes_down},
+}
+
+// Run applies all migrations not in the ledger yet, ordered by version
 func Run() {
-	log := cache.GetLogger()
-	log.WithField("module", "migrator").Info("Running migrations...")
-	for _, migration := range migrations {
-		migrationName := runtime.FuncForPC(
-			reflect.ValueOf(migration).Pointer(),
-		).Name()
+	log := cache.GetLogger().WithField("module", "migrator")
+
+	unlock := lockMigrations()
+	defer unlock()
+
+	log.Info("Running migrations...")
+
+	ensureLedger()
+
+	var applied int
+	for _, status := range Status() {
+		if status.Applied {
+			continue
+		}
+
+		log.Info("Running " + getMigrationName(status.Migration))
+		start := time.Now()
+		if !status.Up() {
+			log.Info("Skipped " + getMigrationName(status.Migration) + ", it doesn't apply yet")
+			continue
+		}
+
+		_, err := helpers.MDbInsertWithoutLogging(models.MigrationsTable, models.MigrationEntry{
+			Version:   status.Version,
+			Name:      status.Name,
+			AppliedAt: time.Now(),
+			Took:      time.Since(start),
+		})
+		if err != nil {
+			panic(err)
+		}
+		applied++
+	}
+
+	log.Info("Migrations finished! Applied " + strconv.Itoa(applied) + " migrations")
+}
+
+// Status returns all migrations, ordered by version, and whether they have been applied
+func Status() (statuses []MigrationStatus) {
+	var entries []models.MigrationEntry
+	err := helpers.MDbIterWithoutLogging(helpers.MdbCollection(models.MigrationsTable).Find(nil)).All(&entries)
+	if err != nil {
+		panic(err)
+	}
+
+	appliedAt := make(map[int]time.Time)
+	for _, entry := range entries {
+		appliedAt[entry.Version] = entry.AppliedAt
+	}
+
+	for _, migration := range getSortedMigrations() {
+		at, applied := appliedAt[migration.Version]
+		statuses = append(statuses, MigrationStatus{
+			Migration: migration,
+			Applied:   applied,
+			AppliedAt: at,
+		})
+	}
+	return statuses
+}
+
+// DryRun logs all migrations and whether they would be applied, without changing anything
+func DryRun() {
+	log := cache.GetLogger().WithField("module", "migrator")
 
-		log.WithField("module", "migrator").Info("Running " + migrationName)
-		migration()
+	var pending int
+	for _, status := range Status() {
+		if status.Applied {
+			log.Info(fmt.Sprintf("applied  %s at %s", getMigrationName(status.Migration), status.AppliedAt.Format(time.RFC3339)))
+			continue
+		}
+		log.Info(fmt.Sprintf("pending  %s", getMigrationName(status.Migration)))
+		pending++
 	}
 
-	log.WithField("module", "migrator").Info("Migrations finished!")
+	log.Info("Dry run finished! " + strconv.Itoa(pending) + " migrations would be applied")
+}
+
+// Rollback reverts all applied migrations newer than the version, newest first.
+// Migrations without a down function stop the rollback.
+func Rollback(toVersion int) {
+	log := cache.GetLogger().WithField("module", "migrator")
+
+	unlock := lockMigrations()
+	defer unlock()
+
+	log.Info("Rolling back migrations to version " + strconv.Itoa(toVersion) + "...")
+
+	statuses := Status()
+	for i := len(statuses) - 1; i >= 0; i-- {
+		status := statuses[i]
+		if status.Version <= toVersion {
+			break
+		}
+		if !status.Applied {
+			continue
+		}
+		if status.Down == nil {
+			panic("migration " + getMigrationName(status.Migration) + " can not be rolled back")
+		}
+
+		log.Info("Reverting " + getMigrationName(status.Migration))
+		status.Down()
+
+		err := helpers.MdbDeleteQueryWithoutLogging(models.MigrationsTable, bson.M{"version": status.Version})
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	log.Info("Rollback finished!")
+}
+
+// lockMigrations waits until no other process migrates, and locks the migrations until unlock is called.
+// The lock is renewed while it is held, if the process dies it expires after migrationsLockTTL.
+func lockMigrations() (unlock func()) {
+	log := cache.GetLogger().WithField("module", "migrator")
+	token := bson.NewObjectId().Hex()
+
+	for {
+		acquired, err := cache.GetRedisClient().SetNX(migrationsLockKey, token, migrationsLockTTL).Result()
+		if err != nil {
+			panic(err)
+		}
+		if acquired {
+			break
+		}
+
+		log.Info("Waiting for another process to finish migrating...")
+		time.Sleep(migrationsLockRetryInterval)
+	}
+
+	stop := make(chan struct{})
+	go func() {
+		defer helpers.Recover()
+
+		ticker := time.NewTicker(migrationsLockTTL / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				err := migrationsLockRenewScript.Run(cache.GetRedisClient(), []string{migrationsLockKey},
+					token, int64(migrationsLockTTL/time.Millisecond)).Err()
+				if err != nil {
+					log.WithError(err).Warn("failed to renew the migrations lock")
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+
+		err := migrationsLockReleaseScript.Run(cache.GetRedisClient(), []string{migrationsLockKey}, token).Err()
+		if err != nil && err != redis.Nil {
+			log.WithError(err).Warn("failed to release the migrations lock")
+		}
+	}
+}
+
+// ensureLedger makes sure every version can only be recorded once, even if multiple processes migrate at the same time
+func ensureLedger() {
+	err := helpers.MdbCollection(models.MigrationsTable).EnsureIndex(mgo.Index{
+		Key:    []string{"version"},
+		Unique: true,
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func getSortedMigrations() []Migration {
+	sorted := make([]Migration, len(migrations))
+	copy(sorted, migrations)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Version < sorted[j].Version
+	})
+	return sorted
+}
+
+func getMigrationName(migration Migration) string {
+	return fmt.Sprintf("m%d_%s", migration.Version, migration.Name)
 }
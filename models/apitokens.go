@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+const (
+	ApiTokensTable   MongoDbCollection = "api_tokens"
+	ApiAuditLogTable MongoDbCollection = "api_audit_log"
+)
+
+// ApiTokenEntry is a token for a client of the REST API, only the hash of the token is stored
+type ApiTokenEntry struct {
+	ID              bson.ObjectId `bson:"_id,omitempty"`
+	Name            string
+	TokenHash       string   // the sha256 hash of the token
+	GuildIDs        []string // the guilds the token can access, empty for all guilds
+	Groups          []string // the endpoint groups the token can access, empty for all groups
+	Write           bool     // if the token can call endpoints changing data
+	RateLimit       int      // requests per minute, 0 for the default
+	CreatedByUserID string
+	CreatedAt       time.Time
+	LastUsedAt      time.Time
+	Revoked         bool
+}
+
+// ApiAuditLogEntry is a call of the REST API
+type ApiAuditLogEntry struct {
+	ID        bson.ObjectId `bson:"_id,omitempty"`
+	TokenID   bson.ObjectId `bson:"tokenid,omitempty"`
+	UserID    string        // the user for session and OAuth2 calls, global for the webkey
+	Method    string
+	Path      string
+	Group     string
+	GuildID   string
+	Status    int
+	IP        string
+	CreatedAt time.Time
+}
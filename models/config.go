@@ -41,6 +41,9 @@ type Config struct {
 	LevelsNotificationCode        string
 	LevelsNotificationDeleteAfter int
 	LevelsMaxBadges               int
+	LevelsRepMinAccountAgeDays    int
+	LevelsRepMinExp               int64
+	LevelsRepLogChannelID         string
 
 	MutedMembers []string // deprecated
 
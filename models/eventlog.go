@@ -45,6 +45,7 @@ const (
 	EventlogTypeRobyulLevelsRoleDelete              = "Robyul_Levels_Role_Delete"              // EventlogTargetTypeRole
 	EventlogTypeRobyulLevelsRoleGrant               = "Robyul_Levels_Role_Grant"               // EventlogTargetTypeUser
 	EventlogTypeRobyulLevelsRoleDeny                = "Robyul_Levels_Role_Deny"                // EventlogTargetTypeUser
+	EventlogTypeRobyulLevelsImport                  = "Robyul_Levels_Import"                   // EventlogTargetTypeGuild
 	EventlogTypeRobyulNotificationsChannelIgnore    = "Robyul_Notifications_Channel_Ignore"    // EventlogTargetTypeChannel
 	EventlogTypeRobyulVliveFeedAdd                  = "Robyul_Vlive_Feed_Add"                  // EventlogTargetTypeRobyulVliveFeed
 	EventlogTypeRobyulVliveFeedRemove               = "Robyul_Vlive_Feed_Remove"               // EventlogTargetTypeRobyulVliveFeed
@@ -81,8 +82,10 @@ const (
 	EventlogTypeRobyulGuildAnnouncementsLeaveSet    = "Robyul_GuildAnnouncements_Leave_Set"    // EventlogTargetTypeChannel
 	EventlogTypeRobyulGuildAnnouncementsLeaveRemove = "Robyul_GuildAnnouncements_Leave_Remove" // EventlogTargetTypeChannel
 	EventlogTypeRobyulGuildAnnouncementsBanSet      = "Robyul_GuildAnnouncements_Ban_Set"      // EventlogTargetTypeChannel
+	EventlogTypeRobyulGuildAnnouncementsBanRemove   = "Robyul_GuildAnnouncements_Ban_Remove"   // EventlogTargetTypeChannel
 	EventlogTypeRobyulGalleryAdd                    = "Robyul_Gallery_Add"                     // EventlogTargetTypeRobyulGallery
 	EventlogTypeRobyulGalleryRemove                 = "Robyul_Gallery_Remove"                  // EventlogTargetTypeRobyulGallery
+	EventlogTypeRobyulGalleryUpdate                 = "Robyul_Gallery_Update"                  // EventlogTargetTypeRobyulGallery
 	EventlogTypeRobyulMirrorCreate                  = "Robyul_Mirror_Create"                   // EventlogTargetTypeRobyulMirror
 	EventlogTypeRobyulMirrorDelete                  = "Robyul_Mirror_Delete"                   // EventlogTargetTypeRobyulMirror
 	EventlogTypeRobyulMirrorUpdate                  = "Robyul_Mirror_Update"                   // EventlogTargetTypeRobyulMirror
@@ -99,6 +102,7 @@ const (
 	EventlogTypeRobyulCommandsJsonImport            = "Robyul_Commands_Json_Import"            // EventlogTargetTypeGuild
 	EventlogTypeRobyulTwitchFeedAdd                 = "Robyul_Twitch_Feed_Add"                 // EventlogTargetTypeRobyulTwitchFeed
 	EventlogTypeRobyulTwitchFeedRemove              = "Robyul_Twitch_Feed_Remove"              // EventlogTargetTypeRobyulTwitchFeed
+	EventlogTypeRobyulTwitchFeedUpdate              = "Robyul_Twitch_Feed_Update"              // EventlogTargetTypeRobyulTwitchFeed
 	EventlogTypeRobyulNukeParticipate               = "Robyul_Nuke_Participate"                // EventlogTargetTypeGuild
 	EventlogTypeRobyulTroublemakerParticipate       = "Robyul_Troublemaker_Participate"        // EventlogTargetTypeGuild
 	EventlogTypeRobyulTroublemakerReport            = "Robyul_Troublemaker_Report"             // EventlogTargetTypeUser
@@ -117,6 +121,15 @@ const (
 	EventlogTypeRobyulTwitterFeedAdd                = "Robyul_Twitter_Feed_Add"                // EventlogTargetTypeRobyulTwitterFeed
 	EventlogTypeRobyulTwitterFeedRemove             = "Robyul_Twitter_Feed_Remove"             // EventlogTargetTypeRobyulTwitterFeed
 	EventlogTypeRobyulActionRevert                  = "Robyul_Action_Revert"                   // EventlogTargetTypeRobyulEventlogItem
+	EventlogTypeRobyulReactionRolesCreate           = "Robyul_ReactionRoles_Create"            // EventlogTargetTypeRobyulReactionRolesMenu
+	EventlogTypeRobyulReactionRolesUpdate           = "Robyul_ReactionRoles_Update"            // EventlogTargetTypeRobyulReactionRolesMenu
+	EventlogTypeRobyulReactionRolesDelete           = "Robyul_ReactionRoles_Delete"            // EventlogTargetTypeRobyulReactionRolesMenu
+	EventlogTypeRobyulScheduleAdd                   = "Robyul_Schedule_Add"                    // EventlogTargetTypeRobyulSchedule
+	EventlogTypeRobyulScheduleDelete                = "Robyul_Schedule_Delete"                 // EventlogTargetTypeRobyulSchedule
+	EventlogTypeRobyulScheduleUpdate                = "Robyul_Schedule_Update"                 // EventlogTargetTypeRobyulSchedule
+	EventlogTypeRobyulFeedsAdd                      = "Robyul_Feeds_Add"                       // EventlogTargetTypeRobyulFeed
+	EventlogTypeRobyulFeedsRemove                   = "Robyul_Feeds_Remove"                    // EventlogTargetTypeRobyulFeed
+	EventlogTypeRobyulFeedsUpdate                   = "Robyul_Feeds_Update"                    // EventlogTargetTypeRobyulFeed
 
 	EventlogTargetTypeRobyulBadge               = "robyul-badge"
 	EventlogTargetTypeRobyulVliveFeed           = "robyul-vlive-feed"
@@ -132,6 +145,9 @@ const (
 	EventlogTargetTypeRobyulPublicObject        = "robyul-public-object"
 	EventlogTargetTypeRobyulMirrorType          = "robyul-mirror-type"
 	EventlogTargetTypeRobyulEventlogItem        = "robyul-eventlog-item"
+	EventlogTargetTypeRobyulReactionRolesMenu   = "robyul-reactionroles-menu"
+	EventlogTargetTypeRobyulSchedule            = "robyul-schedule"
+	EventlogTargetTypeRobyulFeed                = "robyul-feed"
 
 	AuditLogBackfillRedisList = "robyul-discord:eventlog:auditlog-backfills:v2"
 )
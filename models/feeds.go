@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+const (
+	FeedsTable      MongoDbCollection = "feeds"
+	FeedsStateTable MongoDbCollection = "feeds_state"
+)
+
+type FeedsEntry struct {
+	ID            bson.ObjectId `bson:"_id,omitempty"`
+	GuildID       string
+	ChannelID     string
+	URL           string
+	MentionRoleID string
+	EmbedCode     string // custom template, uses the default embed if empty
+	AddedByUserID string
+	AddedAt       time.Time
+}
+
+// FeedsStateEntry is shared by all FeedsEntry with the same URL
+type FeedsStateEntry struct {
+	ID            bson.ObjectId `bson:"_id,omitempty"`
+	URL           string
+	Title         string
+	ETag          string
+	LastModified  string
+	PostedGUIDs   []string
+	LastCheckAt   time.Time
+	LastSuccessAt time.Time
+	Failures      int
+	LastError     string
+}
@@ -1,11 +1,23 @@
 package models
 
 import (
+	"time"
+
 	"github.com/globalsign/mgo/bson"
 )
 
 const (
-	GalleryTable MongoDbCollection = "galleries"
+	GalleryTable           MongoDbCollection = "galleries"
+	GalleryImageHashTable  MongoDbCollection = "gallery_image_hashes"
+	GalleryDuplicatesTable MongoDbCollection = "gallery_duplicates"
+)
+
+type GalleryDuplicateMode string
+
+const (
+	GalleryDuplicateModeOff   GalleryDuplicateMode = ""      // repost all images
+	GalleryDuplicateModeSkip  GalleryDuplicateMode = "skip"  // do not repost near-duplicates
+	GalleryDuplicateModeReact GalleryDuplicateMode = "react" // do not repost near-duplicates, react to the source message instead
 )
 
 type GalleryEntry struct {
@@ -14,4 +26,33 @@ type GalleryEntry struct {
 	TargetChannelID string
 	GuildID         string
 	AddedByUserID   string
+	DuplicateMode   GalleryDuplicateMode
+	// DuplicateDistance is the maximum hamming distance between two image hashes to count as duplicates
+	DuplicateDistance int
+}
+
+// GalleryImageHashEntry is an image reposted by a gallery with duplicate detection,
+// HashBands are the bytes of the hash prefixed with their position, near-duplicates share at least one of them
+type GalleryImageHashEntry struct {
+	ID              bson.ObjectId `bson:"_id,omitempty"`
+	GalleryID       bson.ObjectId
+	Hash            string
+	HashBands       []string
+	URL             string
+	SourceMessageID string
+	AuthorID        string
+	CreatedAt       time.Time
+}
+
+// GalleryDuplicateEntry is an image a gallery did not repost because it was a near-duplicate of an earlier image
+type GalleryDuplicateEntry struct {
+	ID               bson.ObjectId `bson:"_id,omitempty"`
+	GalleryID        bson.ObjectId
+	URL              string
+	SourceMessageID  string
+	AuthorID         string
+	OriginalURL      string
+	OriginalAuthorID string
+	Distance         int
+	CreatedAt        time.Time
 }
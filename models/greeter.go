@@ -18,4 +18,8 @@ type GreeterEntry struct {
 	ChannelID string
 	EmbedCode string
 	Type      GreeterType
+	// CardEnabled attaches a generated image card to the message
+	CardEnabled bool
+	// CardBackground is the name of a profile background used for the card
+	CardBackground string
 }
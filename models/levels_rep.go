@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+const (
+	LevelsRepTable MongoDbCollection = "levels_rep"
+)
+
+type LevelsRepEntry struct {
+	ID             bson.ObjectId `bson:"_id,omitempty"`
+	GuildID        string
+	GiverUserID    string
+	ReceiverUserID string
+	CreatedAt      time.Time
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+const (
+	MigrationsTable MongoDbCollection = "migrations"
+)
+
+// MigrationEntry records a migration applied to the databases
+type MigrationEntry struct {
+	ID        bson.ObjectId `bson:"_id,omitempty"`
+	Version   int
+	Name      string
+	AppliedAt time.Time
+	Took      time.Duration
+}
@@ -11,12 +11,15 @@ type MirrorType int
 const (
 	MirrorTypeLink MirrorType = iota
 	MirrorTypeText
+	MirrorTypeFull // text with attachments uploaded to the webhooks
 )
 
 type MirrorEntry struct {
 	ID                bson.ObjectId `bson:"_id,omitempty"`
 	Type              MirrorType
 	ConnectedChannels []MirrorChannelEntry
+	// append the reactions on the source message to the mirrored messages
+	SummarizeReactions bool
 }
 
 type MirrorChannelEntry struct {
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+const (
+	ReactionRolesTable MongoDbCollection = "reactionroles"
+
+	// ReactionRolesModeToggle adds the role on reaction and removes it when the reaction is removed
+	ReactionRolesModeToggle = "toggle"
+	// ReactionRolesModeUnique allows only one role of the menu at the same time
+	ReactionRolesModeUnique = "unique"
+	// ReactionRolesModeVerify only adds roles, removing the reaction keeps the role
+	ReactionRolesModeVerify = "verify"
+)
+
+type ReactionRolesEntry struct {
+	ID              bson.ObjectId `bson:"_id,omitempty"`
+	GuildID         string
+	ChannelID       string
+	MessageID       string
+	CreatedByUserID string
+	CreatedAt       time.Time
+	Title           string
+	Mode            string
+	MaxRoles        int      // 0 = unlimited
+	RequiredRoleIDs []string // members need at least one of these roles to use the menu
+	Options         []ReactionRolesOption
+}
+
+type ReactionRolesOption struct {
+	Emoji     string // emoji API name, e.g. name:id for custom emoji
+	EmojiText string // emoji as typed, used to display the menu
+	RoleID    string
+}
@@ -20,4 +20,33 @@ type RedditSubredditEntry struct {
 	AddedAt         time.Time
 	PostDelay       int
 	PostDirectLinks bool
+	Filters         RedditFilters
+}
+
+type RedditFilterNSFW string
+
+const (
+	RedditFilterNSFWAllow RedditFilterNSFW = "" // NSFW submissions are posted to NSFW channels only
+	RedditFilterNSFWDeny  RedditFilterNSFW = "deny"
+	RedditFilterNSFWOnly  RedditFilterNSFW = "only"
+)
+
+type RedditFilterSpoilers string
+
+const (
+	RedditFilterSpoilersHide RedditFilterSpoilers = "" // spoiler submissions are posted without preview
+	RedditFilterSpoilersShow RedditFilterSpoilers = "show"
+	RedditFilterSpoilersDeny RedditFilterSpoilers = "deny"
+)
+
+// RedditFilters are the filters of a RedditSubredditEntry, submissions have to match all of them
+type RedditFilters struct {
+	Flairs          []string // link flairs to post, all flairs if empty
+	ExcludeFlairs   []string
+	MinScore        int
+	ScoreDelay      int      // minutes after submission the score is evaluated
+	Keywords        []string // the title has to contain one of the keywords, if any
+	ExcludeKeywords []string
+	NSFW            RedditFilterNSFW
+	Spoilers        RedditFilterSpoilers
 }
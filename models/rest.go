@@ -265,6 +265,59 @@ type Rest_Background struct {
 	Tags []string
 }
 
+type Rest_CustomCommand struct {
+	ID              string
+	Keyword         string
+	Content         string
+	HasFile         bool
+	CreatedByUserID string
+	CreatedAt       time.Time
+	Triggered       int
+}
+
+type Rest_Autorole struct {
+	RoleID       string
+	DelaySeconds int64
+}
+
+type Rest_Starboard struct {
+	ChannelID string
+	Minimum   int
+	Emoji     []string
+}
+
+type Rest_Greeter struct {
+	Type           string // join, leave or ban
+	ChannelID      string
+	EmbedCode      string
+	CardEnabled    bool
+	CardBackground string
+}
+
+type Rest_LevelsRole struct {
+	ID         string
+	RoleID     string
+	StartLevel int
+	LastLevel  int
+}
+
+type Rest_ModulePermission struct {
+	TargetType string // channel or role
+	TargetID   string
+	Allowed    []string
+	Denied     []string
+}
+
+type Rest_Feed struct {
+	ID            string
+	ChannelID     string
+	URL           string
+	MentionRoleID string
+	EmbedCode     string
+	AddedByUserID string
+	AddedAt       time.Time
+}
+
 const (
 	Redis_Key_Feature_Levels_Badges  = "robyul2-discord:feature:levels-badges:server:%s"
 	Redis_Key_Feature_RandomPictures = "robyul2-discord:feature:randompictures:server:%s"
@@ -6,3 +6,43 @@ type Rest_Receive_SetSettings struct {
 		Values []string
 	}
 }
+
+type Rest_Receive_CustomCommand struct {
+	Keyword string // only used when adding a command
+	Content string
+}
+
+type Rest_Receive_Autorole struct {
+	RoleID       string
+	DelaySeconds int64
+}
+
+// Rest_Receive_Starboard changes the starboard settings, fields which are not sent stay unchanged
+type Rest_Receive_Starboard struct {
+	ChannelID *string // an empty string disables the starboard
+	Minimum   *int
+	Emoji     []string
+}
+
+// Rest_Receive_Greeter sets a greeter, the card settings are kept if not sent
+type Rest_Receive_Greeter struct {
+	EmbedCode      string
+	CardEnabled    *bool
+	CardBackground *string
+}
+
+type Rest_Receive_LevelsRole struct {
+	RoleID     string
+	StartLevel int
+	LastLevel  int
+}
+
+type Rest_Receive_Feed struct {
+	ChannelID     string
+	URL           string
+	MentionRoleID string
+}
+
+type Rest_Receive_FeedTemplate struct {
+	EmbedCode string // an empty string resets the template
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
+
+const (
+	ScheduleTable MongoDbCollection = "schedule"
+)
+
+type ScheduleEntry struct {
+	ID              bson.ObjectId `bson:"_id,omitempty"`
+	GuildID         string
+	ChannelID       string
+	CreatedByUserID string
+	CreatedAt       time.Time
+	EmbedCode       string
+	CronExpression  string // empty for one-off messages
+	Timezone        string
+	NextRunAt       time.Time
+	LastRunAt       time.Time
+	RunCount        int
+	Paused          bool
+}
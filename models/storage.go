@@ -7,9 +7,11 @@ import (
 )
 
 const (
-	StorageTable MongoDbCollection = "storage"
+	StorageTable     MongoDbCollection = "storage"
+	StorageBlobTable MongoDbCollection = "storage_blobs"
 )
 
+// StorageEntry is a reference to a blob, every stored file gets its own entry even if the content is already stored
 type StorageEntry struct {
 	ID             bson.ObjectId `bson:"_id,omitempty"`
 	ObjectName     string
@@ -25,4 +27,21 @@ type StorageEntry struct {
 	Public         bool
 	Metadata       map[string]string
 	RetrievedCount int
+	ContentHash    string // the sha256 hash of the content, empty for files stored before content addressing
+}
+
+// StorageBlobEntry is content in the object storage, shared by all entries with the same content hash
+type StorageBlobEntry struct {
+	ID          bson.ObjectId `bson:"_id,omitempty"`
+	ContentHash string
+	ObjectName  string // the name of the object in the object storage
+	MimeType    string
+	Filesize    int // in bytes
+	UploadDate  time.Time
+	References  int
+	RetainedAt  time.Time // the last time a reference was added
+	// Pending is set until the content is uploaded, UploadClaimedAt is the time the upload was started,
+	// it is reset if the upload failed, so another caller can upload the content
+	Pending         bool
+	UploadClaimedAt time.Time
 }
@@ -1,6 +1,10 @@
 package models
 
-import "github.com/globalsign/mgo/bson"
+import (
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
 
 const (
 	TwitchTable MongoDbCollection = "twitch"
@@ -14,4 +18,20 @@ type TwitchEntry struct {
 	TwitchUserID      string
 	IsLive            bool
 	MentionRoleID     string
+	GameFilter        []string // only post streams playing one of these games, all streams if empty
+	EmbedCode         string   // custom template for the live message
+	Live              TwitchLiveState
+}
+
+// TwitchLiveState is the state of the latest stream of a TwitchEntry
+type TwitchLiveState struct {
+	StreamID    string
+	Posted      bool   // true once the stream has been handled, even if it has been filtered
+	MessageID   string // the live message, edited while live and turned into a summary when the stream ends
+	StartedAt   time.Time
+	EndedAt     time.Time
+	Title       string
+	Game        string
+	PeakViewers int
+	EditedAt    time.Time
 }
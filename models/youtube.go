@@ -1,9 +1,14 @@
 package models
 
-import "github.com/globalsign/mgo/bson"
+import (
+	"time"
+
+	"github.com/globalsign/mgo/bson"
+)
 
 const (
 	YoutubeChannelTable  MongoDbCollection = "youtube_channels"
+	YoutubeWebSubTable   MongoDbCollection = "youtube_websub"
 	YoutubeQuotaRedisKey                   = "robyul2-discord:youtube:quota"
 )
 
@@ -17,7 +22,7 @@ type YoutubeChannelEntry struct {
 
 	// Youtube channel specific fields.
 	YoutubeChannelID    string
-	YoutubePostedVideos []string
+	YoutubePostedVideos []string // deprecated, posted videos are stored with feeds.MarkHandled
 }
 
 type YoutubeQuota struct {
@@ -25,3 +30,24 @@ type YoutubeQuota struct {
 	Left      int64
 	ResetTime int64
 }
+
+type YoutubeWebSubStatus string
+
+const (
+	YoutubeWebSubStatusPending    YoutubeWebSubStatus = "pending"
+	YoutubeWebSubStatusSubscribed YoutubeWebSubStatus = "subscribed"
+	YoutubeWebSubStatusFailed     YoutubeWebSubStatus = "failed"
+)
+
+// YoutubeWebSubEntry is the WebSub subscription of a YouTube channel, channels without an active subscription are polled
+type YoutubeWebSubEntry struct {
+	ID               bson.ObjectId `bson:"_id,omitempty"`
+	YoutubeChannelID string
+	Status           YoutubeWebSubStatus
+	RequestedAt      time.Time
+	VerifiedAt       time.Time
+	LeaseExpiresAt   time.Time
+	Failures         int
+	NextAttemptAt    time.Time
+	LastError        string
+}
@@ -79,3 +79,123 @@ type ExtendedPlugin interface {
 		session *discordgo.Session,
 	)
 }
+
+// Plugins and extended plugins can implement the following handlers to receive additional events
+
+type MessageUpdateHandler interface {
+	OnMessageUpdate(
+		msg *discordgo.MessageUpdate,
+		session *discordgo.Session,
+	)
+}
+
+type VoiceStateHandler interface {
+	OnVoiceStateUpdate(
+		state *discordgo.VoiceStateUpdate,
+		session *discordgo.Session,
+	)
+}
+
+type ChannelCreateHandler interface {
+	OnChannelCreate(
+		channel *discordgo.ChannelCreate,
+		session *discordgo.Session,
+	)
+}
+
+type ChannelUpdateHandler interface {
+	OnChannelUpdate(
+		channel *discordgo.ChannelUpdate,
+		session *discordgo.Session,
+	)
+}
+
+type ChannelDeleteHandler interface {
+	OnChannelDelete(
+		channel *discordgo.ChannelDelete,
+		session *discordgo.Session,
+	)
+}
+
+type GuildUpdateHandler interface {
+	OnGuildUpdate(
+		guild *discordgo.GuildUpdate,
+		session *discordgo.Session,
+	)
+}
+
+type GuildRoleCreateHandler interface {
+	OnGuildRoleCreate(
+		role *discordgo.GuildRoleCreate,
+		session *discordgo.Session,
+	)
+}
+
+type GuildRoleUpdateHandler interface {
+	OnGuildRoleUpdate(
+		role *discordgo.GuildRoleUpdate,
+		session *discordgo.Session,
+	)
+}
+
+type GuildRoleDeleteHandler interface {
+	OnGuildRoleDelete(
+		role *discordgo.GuildRoleDelete,
+		session *discordgo.Session,
+	)
+}
+
+type GuildMemberUpdateHandler interface {
+	OnGuildMemberUpdate(
+		member *discordgo.GuildMemberUpdate,
+		session *discordgo.Session,
+	)
+}
+
+type PresenceUpdateHandler interface {
+	OnPresenceUpdate(
+		presence *discordgo.PresenceUpdate,
+		session *discordgo.Session,
+	)
+}
+
+type WebhooksUpdateHandler interface {
+	OnWebhooksUpdate(
+		webhooks *discordgo.WebhooksUpdate,
+		session *discordgo.Session,
+	)
+}
+
+type InviteCreateHandler interface {
+	OnInviteCreate(
+		invite *InviteCreate,
+		session *discordgo.Session,
+	)
+}
+
+type InviteDeleteHandler interface {
+	OnInviteDelete(
+		invite *InviteDelete,
+		session *discordgo.Session,
+	)
+}
+
+// InviteCreate is the data for an INVITE_CREATE event, not yet in discordgo
+type InviteCreate struct {
+	ChannelID string          `json:"channel_id"`
+	GuildID   string          `json:"guild_id"`
+	Code      string          `json:"code"`
+	CreatedAt string          `json:"created_at"`
+	Inviter   *discordgo.User `json:"inviter"`
+	MaxAge    int             `json:"max_age"`
+	MaxUses   int             `json:"max_uses"`
+	Temporary bool            `json:"temporary"`
+	Uses      int             `json:"uses"`
+}
+
+// InviteDelete is the data for an INVITE_DELETE event, not yet in discordgo
+type InviteDelete struct {
+	ChannelID string `json:"channel_id"`
+	GuildID   string `json:"guild_id"`
+	Code      string `json:"code"`
+}
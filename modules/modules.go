@@ -3,11 +3,14 @@ package modules
 import (
 	"github.com/Seklfreak/Robyul2/modules/plugins"
 	"github.com/Seklfreak/Robyul2/modules/plugins/biasgame"
+	"github.com/Seklfreak/Robyul2/modules/plugins/eventlog"
+	"github.com/Seklfreak/Robyul2/modules/plugins/feeds"
 	"github.com/Seklfreak/Robyul2/modules/plugins/idols"
 	"github.com/Seklfreak/Robyul2/modules/plugins/levels"
 	"github.com/Seklfreak/Robyul2/modules/plugins/mod"
 	"github.com/Seklfreak/Robyul2/modules/plugins/notifications"
 	"github.com/Seklfreak/Robyul2/modules/plugins/nugugame"
+	"github.com/Seklfreak/Robyul2/modules/plugins/schedule"
 	"github.com/Seklfreak/Robyul2/modules/plugins/youtube"
 )
 
@@ -15,7 +18,9 @@ var (
 	pluginCache         map[string]*Plugin
 	extendedPluginCache map[string]*ExtendedPlugin
 
+	// PluginList are all plugins, see registry.go for which are enabled
 	PluginList = []Plugin{
+		&PluginManager{},
 		&notifications.Handler{},
 		&plugins.About{},
 		&plugins.Stats{},
@@ -64,16 +69,21 @@ var (
 		&plugins.Steam{},
 		&plugins.Config{},
 		&plugins.Storage{},
+		&plugins.ApiTokens{},
 		&plugins.Mirror{},
+		&schedule.Schedule{},
+		&feeds.Feeds{},
+		&plugins.Spoiler{},
+		&plugins.Donators{},
+		&plugins.Names{},
+		&plugins.Facebook{},
 
-		// &plugins.Spoiler{},
-		// &plugins.Donators{},
-		// &plugins.Names{},
+		// not compatible with the shard manager yet
 		// &instagram.Handler{},
-		// &plugins.Facebook{},
 		// &google.Handler{},
 	}
 
+	// PluginExtendedList are all extended plugins, see registry.go for which are enabled
 	PluginExtendedList = []ExtendedPlugin{
 		&plugins.Bias{},
 		&plugins.GuildAnnouncements{},
@@ -81,17 +91,65 @@ var (
 		&plugins.Gallery{},
 		&plugins.CustomCommands{},
 		&plugins.ReactionPolls{},
+		&plugins.ReactionRoles{},
 		&mod.Mod{},
 		&plugins.AutoRoles{},
 		&plugins.Starboard{}, // Mongo performance
 		&plugins.Autoleaver{},
-		// &plugins.Persistency{}, // Mongo performance
+		&plugins.Persistency{}, // Mongo performance
 		&biasgame.Module{},
 		&nugugame.Module{},
 		&idols.Module{},
+		&plugins.Twitter{},
+		&eventlog.Handler{},
+		&plugins.Perspective{},
+	}
+
+	// plugins which have to be enabled in the config or by a feature flag
+	pluginsDisabledByDefault = map[string]bool{
+		"Spoiler":          true,
+		"Donators":         true,
+		"Names":            true,
+		"Facebook":         true,
+		"Persistency":      true, // Mongo performance
+		"Twitter":          true,
+		"eventlog.Handler": true,
+		"Perspective":      true,
+	}
+
+	// plugins which can not be disabled
+	pluginsRequired = map[string]bool{
+		"modules.PluginManager": true,
+	}
 
-		// &plugins.Twitter{},
-		// &eventlog.Handler{},
-		// &plugins.Perspective{},
+	// plugins which add handlers to the session or start loops in Init, and don't remove or stop them in Uninit,
+	// they can not be disabled or reloaded at runtime
+	pluginsNotReloadable = map[string]bool{
+		"notifications.Handler": true,
+		"VLive":                 true,
+		"LastFm":                true,
+		"Twitch":                true,
+		"Reminders":             true,
+		"RandomPictures":        true,
+		"youtube.Handler":       true,
+		"Reddit":                true,
+		"Ping":                  true,
+		"BotStatus":             true,
+		"DM":                    true,
+		"Storage":               true,
+		"Mirror":                true,
+		"schedule.Schedule":     true,
+		"feeds.Feeds":           true,
+		"Names":                 true,
+		"Facebook":              true,
+		"levels.Levels":         true,
+		"mod.Mod":               true,
+		"Persistency":           true,
+		"biasgame.Module":       true,
+		"nugugame.Module":       true,
+		"idols.Module":          true,
+		"Twitter":               true,
+		"eventlog.Handler":      true,
+		"Perspective":           true,
 	}
 )
@@ -0,0 +1,120 @@
+package modules
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/cluster"
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/shardmanager"
+	"github.com/bwmarrin/discordgo"
+)
+
+// PluginManager lets bot admins enable, disable and reload plugins at runtime, on all processes
+type PluginManager struct{}
+
+const pluginsClusterTopic = "plugins"
+
+type pluginsClusterRequest struct {
+	Action string
+	Name   string
+}
+
+func (pm *PluginManager) Commands() []string {
+	return []string{
+		"plugins",
+	}
+}
+
+func (pm *PluginManager) Init(session *shardmanager.Manager) {
+	cluster.Handle(pluginsClusterTopic, func(payload json.RawMessage) (interface{}, error) {
+		var request pluginsClusterRequest
+		err := json.Unmarshal(payload, &request)
+		if err != nil {
+			return nil, err
+		}
+
+		switch request.Action {
+		case "enable":
+			err = EnablePlugin(request.Name)
+		case "disable":
+			err = DisablePlugin(request.Name)
+		case "reload":
+			err = ReloadPlugin(request.Name)
+		default:
+			err = errors.New("invalid action " + request.Action)
+		}
+		return nil, err
+	})
+}
+
+func (pm *PluginManager) Action(command string, content string, msg *discordgo.Message, session *discordgo.Session) {
+	args := strings.Fields(content)
+
+	helpers.RequireBotAdmin(msg, func() {
+		if len(args) < 1 || args[0] == "list" { // [p]plugins [list]
+			var text string
+			for _, status := range GetPluginStatuses() {
+				statusText := "disabled"
+				if status.Enabled {
+					statusText = "enabled"
+				}
+				if status.Extended {
+					statusText += ", extended"
+				}
+				text += fmt.Sprintf("`%s` (%s): `%s`\n", status.Name, statusText, strings.Join(status.Commands, "`, `"))
+			}
+
+			for _, page := range helpers.Pagify(text, "\n") {
+				_, err := helpers.SendMessage(msg.ChannelID, page)
+				helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+			}
+			return
+		}
+
+		if len(args) < 2 {
+			_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.too-few"))
+			helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+			return
+		}
+
+		var successText string
+		switch args[0] {
+		case "enable": // [p]plugins enable <plugin name>
+			successText = "plugins.plugins.enable-success"
+		case "disable": // [p]plugins disable <plugin name>
+			successText = "plugins.plugins.disable-success"
+		case "reload": // [p]plugins reload <plugin name>
+			successText = "plugins.plugins.reload-success"
+		default:
+			_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+			helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+			return
+		}
+
+		// every process changes its own plugins
+		responses, err := cluster.Request(pluginsClusterTopic, pluginsClusterRequest{Action: args[0], Name: args[1]})
+		if err == nil {
+			for _, response := range responses {
+				if response.Error != "" {
+					err = errors.New(response.Error)
+					break
+				}
+			}
+		}
+		if err != nil {
+			_, err = helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.plugins.error", err.Error()))
+			helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+			return
+		}
+
+		cache.GetLogger().WithField("module", "modules").Infof("%s %s by %s (#%s)",
+			args[0], args[1], msg.Author.Username, msg.Author.ID)
+
+		_, err = helpers.SendMessage(msg.ChannelID, helpers.GetTextF(successText, args[1]))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+	})
+}
@@ -0,0 +1,216 @@
+package plugins
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/Seklfreak/Robyul2/shardmanager"
+	"github.com/bwmarrin/discordgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+// ApiTokens lets bot admins manage the tokens of clients of the REST API
+type ApiTokens struct{}
+
+const (
+	apiTokensAuditLogLimit = 25
+)
+
+func (m *ApiTokens) Commands() []string {
+	return []string{
+		"apitoken",
+		"apitokens",
+	}
+}
+
+func (m *ApiTokens) Init(session *shardmanager.Manager) {
+}
+
+func (m *ApiTokens) Action(command string, content string, msg *discordgo.Message, session *discordgo.Session) {
+	args := strings.Fields(content)
+
+	helpers.RequireBotAdmin(msg, func() {
+		if len(args) < 1 || args[0] == "list" { // [p]apitoken [list]
+			m.actionList(msg)
+			return
+		}
+
+		switch args[0] {
+		case "create", "add": // [p]apitoken create <name> [guild:<guild id>] [group:<group>] [write] [ratelimit:<per minute>]
+			m.actionCreate(args[1:], msg, session)
+		case "revoke", "delete": // [p]apitoken revoke <token id>
+			m.actionRevoke(args[1:], msg)
+		case "audit", "log": // [p]apitoken audit [<token id>]
+			m.actionAudit(args[1:], msg)
+		default:
+			_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+			helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		}
+	})
+}
+
+func (m *ApiTokens) actionList(msg *discordgo.Message) {
+	entries, err := helpers.GetApiTokens()
+	helpers.Relax(err)
+
+	var text string
+	for _, entry := range entries {
+		if entry.Revoked {
+			continue
+		}
+		text += fmt.Sprintf("`%s` **%s**: %s\n", helpers.MdbIdToHuman(entry.ID), entry.Name, m.getScopeText(entry))
+	}
+	if text == "" {
+		_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.apitokens.list-empty"))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	for _, page := range helpers.Pagify(text, "\n") {
+		_, err = helpers.SendMessage(msg.ChannelID, page)
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+	}
+}
+
+func (m *ApiTokens) actionCreate(args []string, msg *discordgo.Message, session *discordgo.Session) {
+	if len(args) < 1 {
+		_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.too-few"))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	entry := models.ApiTokenEntry{
+		Name:            args[0],
+		CreatedByUserID: msg.Author.ID,
+	}
+	for _, arg := range args[1:] {
+		var err error
+		switch {
+		case strings.HasPrefix(arg, "guild:"):
+			entry.GuildIDs = append(entry.GuildIDs, strings.TrimPrefix(arg, "guild:"))
+		case strings.HasPrefix(arg, "group:"):
+			entry.Groups = append(entry.Groups, strings.TrimPrefix(arg, "group:"))
+		case strings.HasPrefix(arg, "ratelimit:"):
+			entry.RateLimit, err = strconv.Atoi(strings.TrimPrefix(arg, "ratelimit:"))
+		case arg == "write":
+			entry.Write = true
+		default:
+			err = fmt.Errorf("unknown option %s", arg)
+		}
+		if err != nil {
+			_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+			helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+			return
+		}
+	}
+
+	token, entry, err := helpers.CreateApiToken(entry)
+	if err != nil {
+		_, err = helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.apitokens.create-error", err.Error(),
+			strings.Join(helpers.ApiTokenGroups, "`, `")))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	// the token is only shown once, and never in a public channel
+	dmChannel, err := session.UserChannelCreate(msg.Author.ID)
+	if err == nil {
+		_, err = helpers.SendMessage(dmChannel.ID, helpers.GetTextF("plugins.apitokens.create-token",
+			entry.Name, helpers.MdbIdToHuman(entry.ID), token))
+	}
+	if err != nil {
+		helpers.RelaxLog(helpers.RevokeApiToken(entry.ID))
+		_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.apitokens.create-dm-error"))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	_, err = helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.apitokens.create-success",
+		entry.Name, helpers.MdbIdToHuman(entry.ID), m.getScopeText(entry)))
+	helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+}
+
+func (m *ApiTokens) actionRevoke(args []string, msg *discordgo.Message) {
+	if len(args) < 1 {
+		_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.too-few"))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	id := helpers.HumanToMdbId(args[0])
+	if id == "" {
+		_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.apitokens.not-found"))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	err := helpers.RevokeApiToken(id)
+	if helpers.IsMdbNotFound(err) {
+		_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.apitokens.not-found"))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+	helpers.Relax(err)
+
+	_, err = helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.apitokens.revoke-success", args[0]))
+	helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+}
+
+func (m *ApiTokens) actionAudit(args []string, msg *discordgo.Message) {
+	var tokenID bson.ObjectId
+	if len(args) >= 1 {
+		tokenID = helpers.HumanToMdbId(args[0])
+		if tokenID == "" {
+			_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.apitokens.not-found"))
+			helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+			return
+		}
+	}
+
+	entries, err := helpers.GetApiAuditLog(tokenID, apiTokensAuditLogLimit)
+	helpers.Relax(err)
+
+	var text string
+	for _, entry := range entries {
+		client := entry.UserID
+		if entry.TokenID != "" {
+			client = "token " + helpers.MdbIdToHuman(entry.TokenID)
+		}
+		text += fmt.Sprintf("`%s` %d `%s %s` by %s from %s\n",
+			entry.CreatedAt.Format("2006-01-02 15:04:05"), entry.Status, entry.Method, entry.Path, client, entry.IP)
+	}
+	if text == "" {
+		_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.apitokens.audit-empty"))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	for _, page := range helpers.Pagify(text, "\n") {
+		_, err = helpers.SendMessage(msg.ChannelID, page)
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+	}
+}
+
+func (m *ApiTokens) getScopeText(entry models.ApiTokenEntry) string {
+	guildsText := "all guilds"
+	if len(entry.GuildIDs) > 0 {
+		guildsText = "guilds `" + strings.Join(entry.GuildIDs, "`, `") + "`"
+	}
+	groupsText := "all groups"
+	if len(entry.Groups) > 0 {
+		groupsText = "groups `" + strings.Join(entry.Groups, "`, `") + "`"
+	}
+	accessText := "read"
+	if entry.Write {
+		accessText = "read and write"
+	}
+	rateLimitText := "default rate limit"
+	if entry.RateLimit > 0 {
+		rateLimitText = fmt.Sprintf("%d requests per minute", entry.RateLimit)
+	}
+
+	return fmt.Sprintf("%s on %s, %s, %s", accessText, guildsText, groupsText, rateLimitText)
+}
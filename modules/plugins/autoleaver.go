@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/cluster"
 	"github.com/Seklfreak/Robyul2/helpers"
 	"github.com/Seklfreak/Robyul2/models"
 	"github.com/Seklfreak/Robyul2/shardmanager"
@@ -83,6 +84,11 @@ func (a *Autoleaver) checkExpiredGuildsLoop() {
 	for {
 		time.Sleep(5 * time.Second)
 
+		// only one process leaves the expired guilds
+		if !cluster.IsLeader("autoleaver") {
+			continue
+		}
+
 		err = a.removeExpiredGuilds()
 		helpers.RelaxLog(err)
 	}
@@ -100,53 +100,19 @@ func (a *AutoRoles) Action(command string, content string, msg *discordgo.Messag
 					return
 				}
 
-				settings := helpers.GuildSettingsGetCached(channel.GuildID)
-
-				for _, role := range settings.AutoRoleIDs {
-					if role == targetRole.ID {
-						_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.autorole.role-add-error-duplicate"))
-						helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
-						return
-					}
-				}
-				for _, delayedRole := range settings.DelayedAutoRoles {
-					if delayedRole.RoleID == targetRole.ID {
-						_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.autorole.role-add-error-duplicate"))
-						helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
-						return
-					}
-				}
-
-				var successText string
-				if delay <= 0 {
-					settings.AutoRoleIDs = append(settings.AutoRoleIDs, targetRole.ID)
-					successText = helpers.GetTextF("plugins.autorole.role-add-success", targetRole.Name)
-				} else {
-					settings.DelayedAutoRoles = append(settings.DelayedAutoRoles, models.DelayedAutoRole{
-						RoleID: targetRole.ID,
-						Delay:  delay,
-					})
-					successText = helpers.GetTextF("plugins.autorole.delayed-role-add-success", targetRole.Name, delay.String())
+				err = AutoroleAdd(channel.GuildID, msg.Author.ID, targetRole.ID, delay)
+				if helpers.IsInputError(err) {
+					_, err = helpers.SendMessage(msg.ChannelID, err.Error())
+					helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+					return
 				}
-
-				err = helpers.GuildSettingsSet(channel.GuildID, settings)
 				helpers.Relax(err)
 
-				options := make([]models.ElasticEventlogOption, 0)
+				successText := helpers.GetTextF("plugins.autorole.role-add-success", targetRole.Name)
 				if delay > 0 {
-					options = append(options, models.ElasticEventlogOption{
-						Key:   "autorole_delay",
-						Value: delay.String(),
-					})
+					successText = helpers.GetTextF("plugins.autorole.delayed-role-add-success", targetRole.Name, delay.String())
 				}
 
-				_, err = helpers.EventlogLog(time.Now(), channel.GuildID, targetRole.ID,
-					models.EventlogTargetTypeRole, msg.Author.ID,
-					models.EventlogTypeRobyulAutoroleAdd, "",
-					nil,
-					options, false)
-				helpers.RelaxLog(err)
-
 				_, err = helpers.SendMessage(msg.ChannelID, successText)
 				helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
 				return
@@ -227,62 +193,14 @@ func (a *AutoRoles) Action(command string, content string, msg *discordgo.Messag
 					targetRole.ID = roleNameToMatch
 				}
 
-				settings := helpers.GuildSettingsGetCached(channel.GuildID)
-
-				roleWasInList := false
-				newRoleIDs := make([]string, 0)
-				newDelayedRoles := make([]models.DelayedAutoRole, 0)
-
-				for _, role := range settings.AutoRoleIDs {
-					if role == targetRole.ID {
-						roleWasInList = true
-					} else {
-						newRoleIDs = append(newRoleIDs, role)
-					}
-				}
-
-				var delay time.Duration
-
-				if !roleWasInList {
-					for _, delayedRole := range settings.DelayedAutoRoles {
-						if delayedRole.RoleID == targetRole.ID {
-							delay = delayedRole.Delay
-							roleWasInList = true
-						} else {
-							newDelayedRoles = append(newDelayedRoles, delayedRole)
-						}
-					}
-				} else {
-					newDelayedRoles = settings.DelayedAutoRoles
-				}
-
-				if !roleWasInList {
-					_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.autorole.role-remove-error-not-found"))
+				err = AutoroleRemove(channel.GuildID, msg.Author.ID, targetRole.ID)
+				if helpers.IsInputError(err) {
+					_, err = helpers.SendMessage(msg.ChannelID, err.Error())
 					helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
 					return
 				}
-
-				settings.AutoRoleIDs = newRoleIDs
-				settings.DelayedAutoRoles = newDelayedRoles
-
-				err = helpers.GuildSettingsSet(channel.GuildID, settings)
 				helpers.Relax(err)
 
-				options := make([]models.ElasticEventlogOption, 0)
-				if delay > 0 {
-					options = append(options, models.ElasticEventlogOption{
-						Key:   "autorole_delay",
-						Value: delay.String(),
-					})
-				}
-
-				_, err = helpers.EventlogLog(time.Now(), channel.GuildID, targetRole.ID,
-					models.EventlogTargetTypeRole, msg.Author.ID,
-					models.EventlogTypeRobyulAutoroleRemove, "",
-					nil,
-					options, false)
-				helpers.RelaxLog(err)
-
 				_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.autorole.role-remove-success"))
 				helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
 				return
@@ -326,13 +244,11 @@ func (a *AutoRoles) Action(command string, content string, msg *discordgo.Messag
 					return
 				}
 
+				members, err := helpers.GetGuildMembers(channel.GuildID)
+				helpers.Relax(err)
 				users := make([]string, 0)
-				for _, botGuild := range session.State.Guilds {
-					if botGuild.ID == channel.GuildID {
-						for _, member := range botGuild.Members {
-							users = append(users, member.User.ID)
-						}
-					}
+				for _, member := range members {
+					users = append(users, member.User.ID)
 				}
 
 				if helpers.ConfirmEmbed(msg.GuildID, msg.ChannelID, msg.Author, helpers.GetTextF("plugins.autorole.apply-confirm",
@@ -404,6 +320,124 @@ func (a *AutoRoles) OnGuildMemberAdd(member *discordgo.Member, session *discordg
 	}()
 }
 
+// AutoroleAdd adds a role to the roles new members get, and logs it to the eventlog
+// delay	: the time after joining the role is assigned, 0 to assign it on join
+func AutoroleAdd(guildID, userID, roleID string, delay time.Duration) (err error) {
+	guild, err := helpers.GetGuild(guildID)
+	if err != nil {
+		return err
+	}
+	var roleExists bool
+	for _, role := range guild.Roles {
+		if role.ID == roleID {
+			roleExists = true
+		}
+	}
+	if !roleExists || delay < 0 {
+		return helpers.NewInputError("bot.arguments.invalid")
+	}
+
+	settings := helpers.GuildSettingsGetCached(guildID)
+
+	for _, role := range settings.AutoRoleIDs {
+		if role == roleID {
+			return helpers.NewInputError("plugins.autorole.role-add-error-duplicate")
+		}
+	}
+	for _, delayedRole := range settings.DelayedAutoRoles {
+		if delayedRole.RoleID == roleID {
+			return helpers.NewInputError("plugins.autorole.role-add-error-duplicate")
+		}
+	}
+
+	options := make([]models.ElasticEventlogOption, 0)
+	if delay <= 0 {
+		settings.AutoRoleIDs = append(settings.AutoRoleIDs, roleID)
+	} else {
+		settings.DelayedAutoRoles = append(settings.DelayedAutoRoles, models.DelayedAutoRole{
+			RoleID: roleID,
+			Delay:  delay,
+		})
+		options = append(options, models.ElasticEventlogOption{
+			Key:   "autorole_delay",
+			Value: delay.String(),
+		})
+	}
+
+	err = helpers.GuildSettingsSet(guildID, settings)
+	if err != nil {
+		return err
+	}
+
+	_, err = helpers.EventlogLog(time.Now(), guildID, roleID,
+		models.EventlogTargetTypeRole, userID,
+		models.EventlogTypeRobyulAutoroleAdd, "",
+		nil,
+		options, false)
+	helpers.RelaxLog(err)
+	return nil
+}
+
+// AutoroleRemove removes a role from the roles new members get, and logs it to the eventlog
+func AutoroleRemove(guildID, userID, roleID string) (err error) {
+	settings := helpers.GuildSettingsGetCached(guildID)
+
+	roleWasInList := false
+	newRoleIDs := make([]string, 0)
+	newDelayedRoles := make([]models.DelayedAutoRole, 0)
+
+	for _, role := range settings.AutoRoleIDs {
+		if role == roleID {
+			roleWasInList = true
+		} else {
+			newRoleIDs = append(newRoleIDs, role)
+		}
+	}
+
+	var delay time.Duration
+
+	if !roleWasInList {
+		for _, delayedRole := range settings.DelayedAutoRoles {
+			if delayedRole.RoleID == roleID {
+				delay = delayedRole.Delay
+				roleWasInList = true
+			} else {
+				newDelayedRoles = append(newDelayedRoles, delayedRole)
+			}
+		}
+	} else {
+		newDelayedRoles = settings.DelayedAutoRoles
+	}
+
+	if !roleWasInList {
+		return helpers.NewInputError("plugins.autorole.role-remove-error-not-found")
+	}
+
+	settings.AutoRoleIDs = newRoleIDs
+	settings.DelayedAutoRoles = newDelayedRoles
+
+	err = helpers.GuildSettingsSet(guildID, settings)
+	if err != nil {
+		return err
+	}
+
+	options := make([]models.ElasticEventlogOption, 0)
+	if delay > 0 {
+		options = append(options, models.ElasticEventlogOption{
+			Key:   "autorole_delay",
+			Value: delay.String(),
+		})
+	}
+
+	_, err = helpers.EventlogLog(time.Now(), guildID, roleID,
+		models.EventlogTargetTypeRole, userID,
+		models.EventlogTypeRobyulAutoroleRemove, "",
+		nil,
+		options, false)
+	helpers.RelaxLog(err)
+	return nil
+}
+
 func AutoroleApply(guildID string, userID string, roleID string) (err error) {
 	err = cache.GetSession().SessionForGuildS(guildID).GuildMemberRoleAdd(guildID, userID, roleID)
 	if err != nil {
@@ -301,14 +301,8 @@ func (m *Bias) Action(command string, content string, msg *discordgo.Message, se
 			guild, err := helpers.GetGuild(channel.GuildID)
 			helpers.Relax(err)
 
-			members := make([]*discordgo.Member, 0)
-			for _, botGuild := range session.State.Guilds {
-				if botGuild.ID == guild.ID {
-					for _, member := range guild.Members {
-						members = append(members, member)
-					}
-				}
-			}
+			members, err := helpers.GetGuildMembers(guild.ID)
+			helpers.Relax(err)
 
 			statsText := ""
 
@@ -120,7 +120,11 @@ func (bs *BotStatus) replaceText(text string) (result string) {
 	for _, shard := range cache.GetSession().Sessions {
 		for _, guild := range shard.State.Guilds {
 			guilds++
-			for _, u := range guild.Members {
+			members, err := helpers.GetGuildMembers(guild.ID)
+			if err != nil {
+				continue
+			}
+			for _, u := range members {
 				users[u.User.ID] = u.User.Username
 			}
 			for _, c := range guild.Channels {
@@ -129,26 +129,13 @@ func (cc *CustomCommands) Action(command string, content string, msg *discordgo.
 				return
 			}
 
-			if helpers.CommandExists(args[1]) {
-				_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.customcommands.add-command-already-exists"))
-				helpers.Relax(err)
-				return
-			}
-
-			var entryBucket models.CustomCommandsEntry
-			err = helpers.MdbOne(
-				helpers.MdbCollection(models.CustomCommandsTable).Find(bson.M{"guildid": channel.GuildID, "keyword": args[1]}),
-				&entryBucket,
-			)
-			if err == nil {
-				_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.customcommands.add-keyword-already-exists"))
-				helpers.Relax(err)
+			err = CustomCommandCheckKeyword(channel.GuildID, args[1])
+			if helpers.IsInputError(err) {
+				_, err = helpers.SendMessage(msg.ChannelID, err.Error())
+				helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
 				return
-			} else {
-				if !helpers.IsMdbNotFound(err) {
-					helpers.Relax(err)
-				}
 			}
+			helpers.Relax(err)
 
 			var objectName string
 			if len(msg.Attachments) > 0 {
@@ -177,55 +164,26 @@ func (cc *CustomCommands) Action(command string, content string, msg *discordgo.
 						UserID:             msg.Author.ID,
 						AdditionalMetadata: nil,
 					}, "customcommands", true)
+					if err == helpers.ErrStorageQuotaExceeded {
+						helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.errors.storage-quota-exceeded"))
+						return
+					}
 					helpers.Relax(err)
 				}
 			}
 
 			content := strings.TrimSpace(strings.Replace(content, strings.Join(args[:2], " "), "", 1))
 
-			if content == "" && objectName == "" {
-				helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+			_, err = CustomCommandAdd(channel.GuildID, msg.Author.ID, args[1], content, objectName)
+			if helpers.IsInputError(err) {
+				_, err = helpers.SendMessage(msg.ChannelID, err.Error())
+				helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
 				return
 			}
-
-			newEntry := models.CustomCommandsEntry{
-				GuildID:           channel.GuildID,
-				CreatedByUserID:   msg.Author.ID,
-				CreatedAt:         time.Now(),
-				Triggered:         0,
-				Keyword:           args[1],
-				StorageObjectName: objectName,
-				Content:           content,
-			}
-			_, err = helpers.MDbInsert(
-				models.CustomCommandsTable,
-				newEntry,
-			)
 			helpers.Relax(err)
 
-			addedContent, _, _ := cc.getCommandContent(newEntry)
-			_, err = helpers.EventlogLog(time.Now(), channel.GuildID, channel.GuildID,
-				models.EventlogTargetTypeGuild, msg.Author.ID,
-				models.EventlogTypeRobyulCommandsAdd, "",
-				nil,
-				[]models.ElasticEventlogOption{
-					{
-						Key:   "command_keyword",
-						Value: args[1],
-					},
-					{
-						Key:   "command_content",
-						Value: addedContent,
-					},
-				}, false)
-			helpers.RelaxLog(err)
-
 			_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.customcommands.add-success"))
 			helpers.Relax(err)
-			customCommandsCacheLock.Lock()
-			defer customCommandsCacheLock.Unlock()
-			customCommandsCache, err = cc.getAllCustomCommands()
-			helpers.Relax(err)
 			return
 		case "random": // [p]commands random
 			session.ChannelTyping(msg.ChannelID)
@@ -394,14 +352,10 @@ func (cc *CustomCommands) Action(command string, content string, msg *discordgo.
 			channel, err := helpers.GetChannel(msg.ChannelID)
 			helpers.Relax(err)
 
-			var entryBucket models.CustomCommandsEntry
-			err = helpers.MdbOne(
-				helpers.MdbCollection(models.CustomCommandsTable).Find(bson.M{"guildid": channel.GuildID, "keyword": args[1]}),
-				&entryBucket,
-			)
-			if helpers.IsMdbNotFound(err) {
-				_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.customcommands.delete-not-found"))
-				helpers.Relax(err)
+			entryBucket, err := CustomCommandGet(channel.GuildID, args[1])
+			if helpers.IsInputError(err) {
+				_, err = helpers.SendMessage(msg.ChannelID, err.Error())
+				helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
 				return
 			}
 			helpers.Relax(err)
@@ -411,37 +365,11 @@ func (cc *CustomCommands) Action(command string, content string, msg *discordgo.
 				return
 			}
 
-			err = helpers.MDbDelete(models.CustomCommandsTable, entryBucket.ID)
+			err = CustomCommandDelete(channel.GuildID, msg.Author.ID, args[1])
 			helpers.Relax(err)
 
-			if entryBucket.StorageObjectName != "" {
-				err = helpers.DeleteFile(entryBucket.StorageObjectName)
-				helpers.Relax(err)
-			}
-
-			removedContent, _, _ := cc.getCommandContent(entryBucket)
-			_, err = helpers.EventlogLog(time.Now(), channel.GuildID, channel.GuildID,
-				models.EventlogTargetTypeGuild, msg.Author.ID,
-				models.EventlogTypeRobyulCommandsDelete, "",
-				nil,
-				[]models.ElasticEventlogOption{
-					{
-						Key:   "command_keyword",
-						Value: entryBucket.Keyword,
-					},
-					{
-						Key:   "command_content",
-						Value: removedContent,
-					},
-				}, false)
-			helpers.RelaxLog(err)
-
 			_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.customcommands.delete-success"))
 			helpers.Relax(err)
-			customCommandsCacheLock.Lock()
-			defer customCommandsCacheLock.Unlock()
-			customCommandsCache, err = cc.getAllCustomCommands()
-			helpers.Relax(err)
 			return
 		case "replace", "edit": // [p]commands edit <command name> <new content>
 			session.ChannelTyping(msg.ChannelID)
@@ -452,14 +380,10 @@ func (cc *CustomCommands) Action(command string, content string, msg *discordgo.
 			channel, err := helpers.GetChannel(msg.ChannelID)
 			helpers.Relax(err)
 
-			var entryBucket models.CustomCommandsEntry
-			err = helpers.MdbOne(
-				helpers.MdbCollection(models.CustomCommandsTable).Find(bson.M{"guildid": channel.GuildID, "keyword": args[1]}),
-				&entryBucket,
-			)
-			if helpers.IsMdbNotFound(err) {
-				_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.customcommands.edit-not-found"))
-				helpers.Relax(err)
+			entryBucket, err := CustomCommandGet(channel.GuildID, args[1])
+			if helpers.IsInputError(err) {
+				_, err = helpers.SendMessage(msg.ChannelID, err.Error())
+				helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
 				return
 			}
 			helpers.Relax(err)
@@ -469,13 +393,6 @@ func (cc *CustomCommands) Action(command string, content string, msg *discordgo.
 				return
 			}
 
-			beforeContent, _, _ := cc.getCommandContent(entryBucket)
-
-			if entryBucket.StorageObjectName != "" {
-				err = helpers.DeleteFile(entryBucket.StorageObjectName)
-				helpers.Relax(err)
-			}
-
 			var objectName string
 			if len(msg.Attachments) > 0 {
 				data, err := helpers.NetGetUAWithError(msg.Attachments[0].URL, helpers.DEFAULT_UA)
@@ -503,59 +420,26 @@ func (cc *CustomCommands) Action(command string, content string, msg *discordgo.
 						UserID:             msg.Author.ID,
 						AdditionalMetadata: nil,
 					}, "customcommands", true)
+					if err == helpers.ErrStorageQuotaExceeded {
+						helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.errors.storage-quota-exceeded"))
+						return
+					}
 					helpers.Relax(err)
 				}
 			}
 
-			if entryBucket.StorageObjectName != "" {
-				err = helpers.DeleteFile(entryBucket.StorageObjectName)
-				helpers.RelaxLog(err)
-			}
-
 			content := strings.TrimSpace(strings.Replace(content, strings.Join(args[:2], " "), "", 1))
 
-			if content == "" && objectName == "" {
-				helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+			_, err = CustomCommandEdit(channel.GuildID, msg.Author.ID, args[1], content, objectName)
+			if helpers.IsInputError(err) {
+				_, err = helpers.SendMessage(msg.ChannelID, err.Error())
+				helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
 				return
 			}
-
-			entryBucket.CreatedByUserID = msg.Author.ID
-			entryBucket.CreatedAt = time.Now().UTC()
-			entryBucket.Triggered = 0
-			entryBucket.Content = content
-			entryBucket.StorageFilename = ""
-			entryBucket.StorageObjectName = objectName
-			entryBucket.StorageHash = ""
-			entryBucket.StorageMimeType = ""
-			err = helpers.MDbUpdate(models.CustomCommandsTable, entryBucket.ID, entryBucket)
 			helpers.Relax(err)
 
-			afterContent, _, _ := cc.getCommandContent(entryBucket)
-
-			_, err = helpers.EventlogLog(time.Now(), channel.GuildID, channel.GuildID,
-				models.EventlogTargetTypeGuild, msg.Author.ID,
-				models.EventlogTypeRobyulCommandsUpdate, "",
-				[]models.ElasticEventlogChange{
-					{
-						Key:      "command_content",
-						OldValue: beforeContent,
-						NewValue: afterContent,
-					},
-				},
-				[]models.ElasticEventlogOption{
-					{
-						Key:   "command_keyword",
-						Value: entryBucket.Keyword,
-					},
-				}, false)
-			helpers.RelaxLog(err)
-
 			_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.customcommands.edit-success"))
 			helpers.Relax(err)
-			customCommandsCacheLock.Lock()
-			defer customCommandsCacheLock.Unlock()
-			customCommandsCache, err = cc.getAllCustomCommands()
-			helpers.Relax(err)
 			return
 		case "refresh": // [p]commands refresh
 			helpers.RequireBotAdmin(msg, func() {
@@ -965,3 +849,191 @@ func (cc *CustomCommands) OnGuildBanRemove(user *discordgo.GuildBanRemove, sessi
 func (cc *CustomCommands) OnMessageDelete(msg *discordgo.MessageDelete, session *discordgo.Session) {
 
 }
+
+// CustomCommandsGet returns all custom commands of the guild, ordered by keyword
+func CustomCommandsGet(guildID string) (entries []models.CustomCommandsEntry, err error) {
+	err = helpers.MDbIter(helpers.MdbCollection(models.CustomCommandsTable).Find(bson.M{"guildid": guildID}).Sort("keyword")).All(&entries)
+	return entries, err
+}
+
+// CustomCommandGet returns the custom command with the keyword, an InputError if the guild has no such command
+func CustomCommandGet(guildID, keyword string) (entry models.CustomCommandsEntry, err error) {
+	err = helpers.MdbOne(
+		helpers.MdbCollection(models.CustomCommandsTable).Find(bson.M{"guildid": guildID, "keyword": keyword}),
+		&entry,
+	)
+	if helpers.IsMdbNotFound(err) {
+		return entry, helpers.NewInputError("plugins.customcommands.delete-not-found")
+	}
+	return entry, err
+}
+
+// CustomCommandCanEdit returns true if the user can add commands, or edit the command if it isn't nil
+func CustomCommandCanEdit(guildID, userID string, entry *models.CustomCommandsEntry) bool {
+	return (&CustomCommands{}).canAddCommand(guildID, userID, entry)
+}
+
+// CustomCommandCheckKeyword returns an InputError if the keyword can't be used for a new command on the guild
+func CustomCommandCheckKeyword(guildID, keyword string) (err error) {
+	if keyword == "" || len(strings.Fields(keyword)) != 1 {
+		return helpers.NewInputError("bot.arguments.invalid")
+	}
+
+	if helpers.CommandExists(keyword) {
+		return helpers.NewInputError("plugins.customcommands.add-command-already-exists")
+	}
+
+	_, err = CustomCommandGet(guildID, keyword)
+	if err == nil {
+		return helpers.NewInputError("plugins.customcommands.add-keyword-already-exists")
+	}
+	if helpers.IsInputError(err) {
+		return nil
+	}
+	return err
+}
+
+// CustomCommandAdd adds a custom command to the guild and logs it to the eventlog
+// objectName	: the uploaded file of the command, can be empty if there is content
+func CustomCommandAdd(guildID, userID, keyword, content, objectName string) (entry models.CustomCommandsEntry, err error) {
+	err = CustomCommandCheckKeyword(guildID, keyword)
+	if err != nil {
+		return entry, err
+	}
+
+	if content == "" && objectName == "" {
+		return entry, helpers.NewInputError("bot.arguments.invalid")
+	}
+
+	entry = models.CustomCommandsEntry{
+		GuildID:           guildID,
+		CreatedByUserID:   userID,
+		CreatedAt:         time.Now(),
+		Triggered:         0,
+		Keyword:           keyword,
+		StorageObjectName: objectName,
+		Content:           content,
+	}
+	entry.ID, err = helpers.MDbInsert(models.CustomCommandsTable, entry)
+	if err != nil {
+		return entry, err
+	}
+
+	addedContent, _, _ := (&CustomCommands{}).getCommandContent(entry)
+	_, err = helpers.EventlogLog(time.Now(), guildID, guildID,
+		models.EventlogTargetTypeGuild, userID,
+		models.EventlogTypeRobyulCommandsAdd, "",
+		nil,
+		[]models.ElasticEventlogOption{
+			{
+				Key:   "command_keyword",
+				Value: keyword,
+			},
+			{
+				Key:   "command_content",
+				Value: addedContent,
+			},
+		}, false)
+	helpers.RelaxLog(err)
+
+	return entry, customCommandsRefreshCache()
+}
+
+// CustomCommandEdit replaces the content and file of a custom command and logs it to the eventlog
+// objectName	: the uploaded file of the command, can be empty if there is content
+func CustomCommandEdit(guildID, userID, keyword, content, objectName string) (entry models.CustomCommandsEntry, err error) {
+	entry, err = CustomCommandGet(guildID, keyword)
+	if err != nil {
+		return entry, err
+	}
+
+	if content == "" && objectName == "" {
+		return entry, helpers.NewInputError("bot.arguments.invalid")
+	}
+
+	cc := &CustomCommands{}
+	beforeContent, _, _ := cc.getCommandContent(entry)
+	beforeObjectName := entry.StorageObjectName
+
+	entry.CreatedByUserID = userID
+	entry.CreatedAt = time.Now().UTC()
+	entry.Triggered = 0
+	entry.Content = content
+	entry.StorageFilename = ""
+	entry.StorageObjectName = objectName
+	entry.StorageHash = ""
+	entry.StorageMimeType = ""
+	err = helpers.MDbUpdate(models.CustomCommandsTable, entry.ID, entry)
+	if err != nil {
+		return entry, err
+	}
+
+	if beforeObjectName != "" && beforeObjectName != objectName {
+		helpers.RelaxLog(helpers.DeleteFile(beforeObjectName))
+	}
+
+	afterContent, _, _ := cc.getCommandContent(entry)
+	_, err = helpers.EventlogLog(time.Now(), guildID, guildID,
+		models.EventlogTargetTypeGuild, userID,
+		models.EventlogTypeRobyulCommandsUpdate, "",
+		[]models.ElasticEventlogChange{
+			{
+				Key:      "command_content",
+				OldValue: beforeContent,
+				NewValue: afterContent,
+			},
+		},
+		[]models.ElasticEventlogOption{
+			{
+				Key:   "command_keyword",
+				Value: entry.Keyword,
+			},
+		}, false)
+	helpers.RelaxLog(err)
+
+	return entry, customCommandsRefreshCache()
+}
+
+// CustomCommandDelete deletes a custom command and its file, and logs it to the eventlog
+func CustomCommandDelete(guildID, userID, keyword string) (err error) {
+	entry, err := CustomCommandGet(guildID, keyword)
+	if err != nil {
+		return err
+	}
+
+	removedContent, _, _ := (&CustomCommands{}).getCommandContent(entry)
+
+	err = helpers.MDbDelete(models.CustomCommandsTable, entry.ID)
+	if err != nil {
+		return err
+	}
+
+	if entry.StorageObjectName != "" {
+		helpers.RelaxLog(helpers.DeleteFile(entry.StorageObjectName))
+	}
+
+	_, err = helpers.EventlogLog(time.Now(), guildID, guildID,
+		models.EventlogTargetTypeGuild, userID,
+		models.EventlogTypeRobyulCommandsDelete, "",
+		nil,
+		[]models.ElasticEventlogOption{
+			{
+				Key:   "command_keyword",
+				Value: entry.Keyword,
+			},
+			{
+				Key:   "command_content",
+				Value: removedContent,
+			},
+		}, false)
+	helpers.RelaxLog(err)
+
+	return customCommandsRefreshCache()
+}
+
+func customCommandsRefreshCache() (err error) {
+	customCommandsCacheLock.Lock()
+	defer customCommandsCacheLock.Unlock()
+	customCommandsCache, err = (&CustomCommands{}).getAllCustomCommands()
+	return err
+}
@@ -17,7 +17,11 @@ import (
 
 	"strconv"
 
+	"time"
+
+	"github.com/Seklfreak/Robyul2/feeds"
 	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/metrics"
 	"github.com/Seklfreak/Robyul2/shardmanager"
 	"github.com/bwmarrin/discordgo"
 )
@@ -167,6 +171,60 @@ func (d *Debug) Action(command string, content string, msg *discordgo.Message, s
 			))
 			helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
 			return
+		case "feeds", "pollers":
+			pollers := feeds.Pollers()
+			if len(pollers) <= 0 {
+				_, err := helpers.SendMessage(msg.ChannelID, "No feed pollers running.")
+				helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+				return
+			}
+
+			var text string
+			for _, poller := range pollers {
+				health := poller.Health()
+				text += fmt.Sprintf("**%s**: %d keys, %d subscriptions, %d failing\n",
+					health.Source, health.Keys, health.Subscriptions, health.FailingKeys)
+				text += fmt.Sprintf("%d checks, %d errors", health.Checks, health.Errors)
+				if !health.LastRoundAt.IsZero() {
+					text += fmt.Sprintf(", last round %s ago in %s",
+						helpers.HumanizeDuration(time.Since(health.LastRoundAt)), health.LastRoundDuration.Round(time.Millisecond))
+				}
+				if health.BudgetLeft >= 0 {
+					text += fmt.Sprintf(", %d requests left in budget", health.BudgetLeft)
+				}
+				text += "\n"
+				if health.LastError != "" {
+					text += fmt.Sprintf("Last error %s ago: `%s`\n",
+						helpers.HumanizeDuration(time.Since(health.LastErrorAt)), health.LastError)
+				}
+				text += "\n"
+			}
+
+			for _, page := range helpers.Pagify(text, "\n") {
+				_, err := helpers.SendMessage(msg.ChannelID, page)
+				helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+			}
+			return
+		case "plugins":
+			pluginStats := metrics.GetPluginStats()
+			if len(pluginStats) <= 0 {
+				_, err := helpers.SendMessage(msg.ChannelID, "No plugin handler calls yet.")
+				helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+				return
+			}
+
+			var text string
+			for _, stats := range pluginStats {
+				text += fmt.Sprintf("**%s**: %d calls, %d panics, %d timeouts, p95 %s (slowest: `%s` p95 %s)\n",
+					stats.Plugin, stats.Calls, stats.Panics, stats.Timeouts, stats.P95,
+					stats.SlowestHandler, stats.SlowestHandlerP95)
+			}
+
+			for _, page := range helpers.Pagify(text, "\n") {
+				_, err := helpers.SendMessage(msg.ChannelID, page)
+				helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+			}
+			return
 		case "mock-discord-500-error":
 			session.ChannelTyping(msg.ChannelID)
 
@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 
 	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/cluster"
 	"github.com/Seklfreak/Robyul2/helpers"
 	"github.com/Seklfreak/Robyul2/metrics"
 	"github.com/Seklfreak/Robyul2/models"
@@ -30,6 +31,11 @@ func auditlogBackfillLoop() {
 			continue
 		}
 
+		// the backfill requests of all processes are in one list, only one process works on them
+		if !cluster.IsLeader("eventlog-backfill") {
+			continue
+		}
+
 		start := time.Now()
 
 		redis := cache.GetRedisClient()
@@ -209,7 +209,7 @@ func (h *Handler) OnReactionRemove(reaction *discordgo.MessageReactionRemove, se
 
 }
 
-func (h *Handler) OnChannelCreate(session *discordgo.Session, channel *discordgo.ChannelCreate) {
+func (h *Handler) OnChannelCreate(channel *discordgo.ChannelCreate, session *discordgo.Session) {
 	go func() {
 		defer helpers.Recover()
 
@@ -276,7 +276,7 @@ func (h *Handler) OnChannelCreate(session *discordgo.Session, channel *discordgo
 	}()
 }
 
-func (h *Handler) OnChannelDelete(session *discordgo.Session, channel *discordgo.ChannelDelete) {
+func (h *Handler) OnChannelDelete(channel *discordgo.ChannelDelete, session *discordgo.Session) {
 	go func() {
 		defer helpers.Recover()
 
@@ -349,7 +349,7 @@ func (h *Handler) OnChannelDelete(session *discordgo.Session, channel *discordgo
 	}()
 }
 
-func (h *Handler) OnGuildRoleCreate(session *discordgo.Session, role *discordgo.GuildRoleCreate) {
+func (h *Handler) OnGuildRoleCreate(role *discordgo.GuildRoleCreate, session *discordgo.Session) {
 	go func() {
 		defer helpers.Recover()
 
@@ -402,7 +402,7 @@ func (h *Handler) OnGuildRoleCreate(session *discordgo.Session, role *discordgo.
 	}()
 }
 
-func (h *Handler) OnGuildRoleDelete(session *discordgo.Session, role *discordgo.GuildRoleDelete) {
+func (h *Handler) OnGuildRoleDelete(role *discordgo.GuildRoleDelete, session *discordgo.Session) {
 	go func() {
 		defer helpers.Recover()
 
@@ -29,11 +29,6 @@ func (h *Handler) Init(session *shardmanager.Manager) {
 
 	Container.Init()
 
-	session.AddHandler(h.OnChannelCreate)
-	session.AddHandler(h.OnChannelDelete)
-	session.AddHandler(h.OnGuildRoleCreate)
-	session.AddHandler(h.OnGuildRoleDelete)
-
 	go auditlogBackfillLoop()
 	logger().Info("started auditlogBackfillLoop loop (1m)")
 }
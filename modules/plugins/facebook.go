@@ -81,6 +81,11 @@ func (m *Facebook) checkFacebookFeedsLoop() {
 		bundledEntries = make(map[string][]models.FacebookEntry, 0)
 
 		for _, entry := range entries {
+			// entries of guilds on other processes are checked by these processes
+			if !cache.GetSession().IsLocalGuild(entry.GuildID) {
+				continue
+			}
+
 			channel, err := helpers.GetChannelWithoutApi(entry.ChannelID)
 			if err != nil || channel == nil || channel.ID == "" {
 				//cache.GetLogger().WithField("module", "facebook").Warn(fmt.Sprintf("skipped facebook @%s for Channel #%s on Guild #%s: channel not found!",
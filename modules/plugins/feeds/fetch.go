@@ -0,0 +1,183 @@
+package feeds
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/helpers"
+)
+
+const (
+	feedsFetchTimeout   = time.Second * 15
+	feedsMaxBodySize    = 5 * 1024 * 1024
+	feedsBackoffInitial = time.Minute
+	feedsBackoffMax     = time.Hour * 6
+)
+
+var (
+	errHostBackoff = errors.New("host is backed off after previous errors")
+)
+
+type fetchResult struct {
+	Body         []byte
+	ETag         string
+	LastModified string
+	NotModified  bool
+}
+
+type hostBackoff struct {
+	failures int
+	until    time.Time
+}
+
+// feedFetcher downloads feeds with conditional GET, hosts returning errors are backed off exponentially
+type feedFetcher struct {
+	client    *http.Client
+	userAgent string
+	backoffs  map[string]*hostBackoff
+	sync.Mutex
+}
+
+func newFeedFetcher() *feedFetcher {
+	// the address is checked after the host has been resolved, for every connection including redirects
+	dialer := &net.Dialer{
+		Timeout: feedsFetchTimeout,
+		Control: helpers.PublicDialControl,
+	}
+
+	return &feedFetcher{
+		client: &http.Client{
+			Timeout: feedsFetchTimeout,
+			Transport: &http.Transport{
+				DialContext:         dialer.DialContext,
+				TLSHandshakeTimeout: feedsFetchTimeout,
+				MaxIdleConnsPerHost: 2,
+			},
+		},
+		userAgent: helpers.DEFAULT_UA,
+		backoffs:  make(map[string]*hostBackoff),
+	}
+}
+
+// Fetch downloads the feed, the ETag and Last-Modified of the previous fetch are sent to the host if set
+func (f *feedFetcher) Fetch(feedURL, etag, lastModified string) (result fetchResult, err error) {
+	parsedURL, err := url.Parse(feedURL)
+	if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") {
+		return result, fmt.Errorf("invalid feed url: %s", feedURL)
+	}
+	host := strings.ToLower(parsedURL.Host)
+
+	if f.BackedOffUntil(host).After(time.Now()) {
+		return result, errHostBackoff
+	}
+
+	request, err := http.NewRequest("GET", feedURL, nil)
+	if err != nil {
+		return result, err
+	}
+	request.Header.Set("User-Agent", f.userAgent)
+	request.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/feed+json, application/json, application/xml;q=0.9, text/xml;q=0.9, */*;q=0.8")
+	if etag != "" {
+		request.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		request.Header.Set("If-Modified-Since", lastModified)
+	}
+
+	response, err := f.client.Do(request)
+	if err != nil {
+		f.recordFailure(host, 0)
+		return result, err
+	}
+	defer response.Body.Close()
+
+	switch {
+	case response.StatusCode == http.StatusNotModified:
+		f.recordSuccess(host)
+		result.NotModified = true
+		result.ETag = etag
+		result.LastModified = lastModified
+		return result, nil
+	case response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= 500:
+		f.recordFailure(host, parseRetryAfter(response.Header.Get("Retry-After")))
+		return result, fmt.Errorf("unexpected status code: %d", response.StatusCode)
+	case response.StatusCode < 200 || response.StatusCode > 299:
+		return result, fmt.Errorf("unexpected status code: %d", response.StatusCode)
+	}
+
+	result.Body, err = ioutil.ReadAll(io.LimitReader(response.Body, feedsMaxBodySize))
+	if err != nil {
+		f.recordFailure(host, 0)
+		return result, err
+	}
+
+	f.recordSuccess(host)
+	result.ETag = response.Header.Get("ETag")
+	result.LastModified = response.Header.Get("Last-Modified")
+	return result, nil
+}
+
+// BackedOffUntil returns the time until no requests will be made to the host
+func (f *feedFetcher) BackedOffUntil(host string) time.Time {
+	f.Lock()
+	defer f.Unlock()
+
+	if backoff, ok := f.backoffs[strings.ToLower(host)]; ok {
+		return backoff.until
+	}
+	return time.Time{}
+}
+
+func (f *feedFetcher) recordFailure(host string, retryAfter time.Duration) {
+	f.Lock()
+	defer f.Unlock()
+
+	backoff, ok := f.backoffs[host]
+	if !ok {
+		backoff = new(hostBackoff)
+		f.backoffs[host] = backoff
+	}
+	backoff.failures++
+
+	wait := feedsBackoffInitial
+	for i := 1; i < backoff.failures && wait < feedsBackoffMax; i++ {
+		wait *= 2
+	}
+	if retryAfter > wait {
+		wait = retryAfter
+	}
+	if wait > feedsBackoffMax {
+		wait = feedsBackoffMax
+	}
+	backoff.until = time.Now().Add(wait)
+}
+
+func (f *feedFetcher) recordSuccess(host string) {
+	f.Lock()
+	defer f.Unlock()
+
+	delete(f.backoffs, host)
+}
+
+// parseRetryAfter parses a Retry-After header in seconds or as a HTTP date
+func parseRetryAfter(text string) time.Duration {
+	if text == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(text); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(text); err == nil {
+		return time.Until(at)
+	}
+	return 0
+}
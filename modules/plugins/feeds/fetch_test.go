@@ -0,0 +1,152 @@
+package feeds
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const (
+	testRSSFeed = `<?xml version="1.0" encoding="ISO-8859-1"?>
+<rss version="2.0" xmlns:media="http://search.yahoo.com/mrss/">
+<channel>
+	<title>Test RSS</title>
+	<link>https://example.com/</link>
+	<item>
+		<title>Second &amp; newest</title>
+		<link>https://example.com/2</link>
+		<guid>post-2</guid>
+		<description>&lt;p&gt;Caf` + "\xe9" + `&lt;/p&gt;</description>
+		<pubDate>Tue, 18 Dec 2018 10:00:00 +0000</pubDate>
+		<media:content url="https://example.com/2.jpg" medium="image" />
+	</item>
+	<item>
+		<title>First</title>
+		<link>https://example.com/1</link>
+		<pubDate>Mon, 17 Dec 2018 10:00:00 +0000</pubDate>
+	</item>
+</channel>
+</rss>`
+	testAtomFeed = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+	<title>Test Atom</title>
+	<link href="https://example.com/" rel="alternate" />
+	<entry>
+		<id>tag:example.com,2018:1</id>
+		<title>Atom entry</title>
+		<link href="https://example.com/atom/1" />
+		<updated>2018-12-17T10:00:00Z</updated>
+		<author><name>Robyul</name></author>
+		<summary>Summary</summary>
+	</entry>
+</feed>`
+	testJSONFeed = `{
+	"version": "https://jsonfeed.org/version/1",
+	"title": "Test JSON",
+	"items": [
+		{"id": 1, "url": "https://example.com/json/1", "title": "JSON item", "content_text": "Text", "image": "https://example.com/1.png", "date_published": "2018-12-17T10:00:00+09:00"}
+	]
+}`
+)
+
+func TestParseFeed(t *testing.T) {
+	rss, err := parseFeed([]byte(testRSSFeed))
+	if err != nil {
+		t.Fatalf("feeds.parseFeed() failed to parse RSS: %s", err.Error())
+	}
+	if rss.Title != "Test RSS" || len(rss.Items) != 2 {
+		t.Fatalf("feeds.parseFeed() returned wrong RSS feed: %+v", rss)
+	}
+	if rss.Items[0].GUID != "https://example.com/1" || rss.Items[1].GUID != "post-2" {
+		t.Fatalf("feeds.parseFeed() failed to sort RSS items or find GUIDs: %+v", rss.Items)
+	}
+	if rss.Items[1].Title != "Second & newest" || rss.Items[1].Description != "Café" ||
+		rss.Items[1].ImageURL != "https://example.com/2.jpg" {
+		t.Fatalf("feeds.parseFeed() returned wrong RSS item: %+v", rss.Items[1])
+	}
+
+	atom, err := parseFeed([]byte(testAtomFeed))
+	if err != nil {
+		t.Fatalf("feeds.parseFeed() failed to parse Atom: %s", err.Error())
+	}
+	if atom.Title != "Test Atom" || len(atom.Items) != 1 || atom.Items[0].GUID != "tag:example.com,2018:1" ||
+		atom.Items[0].Link != "https://example.com/atom/1" || atom.Items[0].Author != "Robyul" ||
+		atom.Items[0].PublishedAt.IsZero() {
+		t.Fatalf("feeds.parseFeed() returned wrong Atom feed: %+v", atom)
+	}
+
+	json, err := parseFeed([]byte(testJSONFeed))
+	if err != nil {
+		t.Fatalf("feeds.parseFeed() failed to parse JSON Feed: %s", err.Error())
+	}
+	if json.Title != "Test JSON" || len(json.Items) != 1 || json.Items[0].GUID != "1" ||
+		json.Items[0].Description != "Text" || json.Items[0].ImageURL != "https://example.com/1.png" {
+		t.Fatalf("feeds.parseFeed() returned wrong JSON Feed: %+v", json)
+	}
+
+	if _, err = parseFeed([]byte("<html><body>not a feed</body></html>")); err == nil {
+		t.Fatalf("feeds.parseFeed() failed to reject HTML")
+	}
+}
+
+func TestFeedFetcher(t *testing.T) {
+	var requests int
+	failing := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/feed.xml":
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"v1"`)
+			w.Write([]byte(testAtomFeed))
+		case "/broken.xml":
+			if failing {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte(testJSONFeed))
+		}
+	}))
+	defer server.Close()
+
+	if _, err := newFeedFetcher().Fetch(server.URL+"/feed.xml", "", ""); err == nil {
+		t.Fatalf("feeds.feedFetcher.Fetch() failed to refuse a feed on localhost")
+	}
+
+	// the test server is on localhost
+	fetcher := newFeedFetcher()
+	fetcher.client = server.Client()
+
+	result, err := fetcher.Fetch(server.URL+"/feed.xml", "", "")
+	if err != nil || result.NotModified || result.ETag != `"v1"` || len(result.Body) == 0 {
+		t.Fatalf("feeds.feedFetcher.Fetch() failed to fetch feed: %+v, %v", result, err)
+	}
+
+	result, err = fetcher.Fetch(server.URL+"/feed.xml", result.ETag, result.LastModified)
+	if err != nil || !result.NotModified || result.ETag != `"v1"` {
+		t.Fatalf("feeds.feedFetcher.Fetch() failed to use conditional GET: %+v, %v", result, err)
+	}
+
+	if _, err = fetcher.Fetch(server.URL+"/broken.xml", "", ""); err == nil || err == errHostBackoff {
+		t.Fatalf("feeds.feedFetcher.Fetch() failed to return error for status code 503: %v", err)
+	}
+
+	// the host is backed off now, no request should be made
+	failing = false
+	requestsBefore := requests
+	if _, err = fetcher.Fetch(server.URL+"/feed.xml", "", ""); err != errHostBackoff || requests != requestsBefore {
+		t.Fatalf("feeds.feedFetcher.Fetch() failed to back off host: %v", err)
+	}
+	if until := fetcher.BackedOffUntil(server.Listener.Addr().String()); until.Before(time.Now().Add(feedsBackoffInitial - time.Second)) {
+		t.Fatalf("feeds.feedFetcher.BackedOffUntil() returned wrong time: %s", until)
+	}
+
+	fetcher.recordSuccess(server.Listener.Addr().String())
+	if _, err = fetcher.Fetch(server.URL+"/broken.xml", "", ""); err != nil {
+		t.Fatalf("feeds.feedFetcher.Fetch() failed to fetch after back off: %v", err)
+	}
+}
@@ -0,0 +1,433 @@
+package feeds
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/Seklfreak/Robyul2/shardmanager"
+	"github.com/bwmarrin/discordgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	feedsCheckInterval        = time.Minute * 5
+	feedsMaxPostsPerCheck     = 5
+	feedsMinPostedGUIDs       = 200
+	feedsDescriptionMaxLength = 500
+	feedsColor                = "f26522"
+)
+
+type Feeds struct {
+	fetcher *feedFetcher
+}
+
+func (m *Feeds) Commands() []string {
+	return []string{
+		"feeds",
+		"feed",
+		"rss",
+	}
+}
+
+func (m *Feeds) Init(session *shardmanager.Manager) {
+	m.fetcher = newFeedFetcher()
+
+	go m.checkFeedsLoop()
+	m.logger().Info("Started checkFeedsLoop")
+}
+
+func (m *Feeds) Action(command string, content string, msg *discordgo.Message, session *discordgo.Session) {
+	if !helpers.ModuleIsAllowed(msg.ChannelID, msg.ID, msg.Author.ID, helpers.ModulePermFeeds) {
+		return
+	}
+
+	args := strings.Fields(content)
+	if len(args) < 1 {
+		args = []string{"list"}
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "add": // [p]feeds add <url> <#channel> [<role>]
+		helpers.RequireMod(msg, func() {
+			m.actionAdd(args, msg, session)
+		})
+		return
+	case "list": // [p]feeds list
+		helpers.RequireMod(msg, func() {
+			m.actionList(msg)
+		})
+		return
+	case "delete", "remove": // [p]feeds delete <id>
+		helpers.RequireMod(msg, func() {
+			m.actionDelete(args, msg)
+		})
+		return
+	case "template": // [p]feeds template <id> [<embed code>|reset]
+		helpers.RequireMod(msg, func() {
+			m.actionTemplate(args, content, msg)
+		})
+		return
+	}
+
+	_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+	helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+}
+
+// [p]feeds add <url> <#channel> [<role>]
+func (m *Feeds) actionAdd(args []string, msg *discordgo.Message, session *discordgo.Session) {
+	if len(args) < 3 {
+		helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.too-few"))
+		return
+	}
+
+	session.ChannelTyping(msg.ChannelID)
+
+	channel, err := helpers.GetChannel(msg.ChannelID)
+	helpers.Relax(err)
+
+	feedURL := strings.Trim(args[1], "<>")
+
+	targetChannel, err := helpers.GetChannelFromMention(msg, args[2])
+	if err != nil || targetChannel.ID == "" || targetChannel.GuildID != channel.GuildID {
+		helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+		return
+	}
+
+	var mentionRoleID string
+	if len(args) >= 4 {
+		guild, err := helpers.GetGuild(channel.GuildID)
+		helpers.Relax(err)
+
+		mentionRole := m.findRole(guild, strings.Join(args[3:], " "))
+		if mentionRole == nil {
+			_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.feeds.add-error-role"))
+			helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+			return
+		}
+		mentionRoleID = mentionRole.ID
+	}
+
+	entry, title, err := m.add(targetChannel.GuildID, targetChannel.ID, msg.Author.ID, feedURL, mentionRoleID)
+	if helpers.IsInputError(err) {
+		_, err = helpers.SendMessage(msg.ChannelID, err.Error())
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+	helpers.Relax(err)
+
+	_, err = helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.feeds.add-success",
+		title, entry.ChannelID, helpers.MdbIdToHuman(entry.ID)))
+	helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+}
+
+// [p]feeds list
+func (m *Feeds) actionList(msg *discordgo.Message) {
+	channel, err := helpers.GetChannel(msg.ChannelID)
+	helpers.Relax(err)
+
+	entries, err := FeedsGet(channel.GuildID)
+	helpers.Relax(err)
+
+	if len(entries) <= 0 {
+		_, err = helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.feeds.list-empty",
+			helpers.GetPrefixForServer(channel.GuildID)))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	guild, err := helpers.GetGuild(channel.GuildID)
+	helpers.Relax(err)
+
+	var message string
+	for _, entry := range entries {
+		message += fmt.Sprintf("`%s`: <%s> in <#%s>", helpers.MdbIdToHuman(entry.ID), entry.URL, entry.ChannelID)
+		if entry.MentionRoleID != "" {
+			roleName := "N/A"
+			if mentionRole := m.findRole(guild, entry.MentionRoleID); mentionRole != nil {
+				roleName = mentionRole.Name
+			}
+			message += fmt.Sprintf(", mentioning `@%s`", roleName)
+		}
+		if entry.EmbedCode != "" {
+			message += ", custom template"
+		}
+		state, err := m.getState(entry.URL)
+		if err == nil && state.Failures > 0 {
+			message += fmt.Sprintf(" ⚠ %d failed checks: `%s`", state.Failures, state.LastError)
+		}
+		message += "\n"
+	}
+	message += helpers.GetTextF("plugins.feeds.list-footer", len(entries))
+
+	for _, page := range helpers.Pagify(message, "\n") {
+		_, err = helpers.SendMessage(msg.ChannelID, page)
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+	}
+}
+
+// [p]feeds delete <id>
+func (m *Feeds) actionDelete(args []string, msg *discordgo.Message) {
+	entry, ok := m.getEntryFromArgs(args, msg)
+	if !ok {
+		return
+	}
+
+	err := FeedRemove(entry.GuildID, msg.Author.ID, entry.ID)
+	helpers.Relax(err)
+
+	_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.feeds.delete-success"))
+	helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+}
+
+// [p]feeds template <id> [<embed code>|reset]
+func (m *Feeds) actionTemplate(args []string, content string, msg *discordgo.Message) {
+	entry, ok := m.getEntryFromArgs(args, msg)
+	if !ok {
+		return
+	}
+
+	if len(args) < 3 {
+		if entry.EmbedCode == "" {
+			_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.feeds.template-none"))
+			helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+			return
+		}
+		_, err := helpers.SendMessageBoxed(msg.ChannelID, entry.EmbedCode)
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	embedCode := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(content), args[0]))
+	embedCode = strings.TrimSpace(strings.TrimPrefix(embedCode, args[1]))
+	if strings.ToLower(embedCode) == "reset" {
+		embedCode = ""
+	}
+	err := FeedSetTemplate(entry.GuildID, msg.Author.ID, entry.ID, embedCode)
+	if helpers.IsInputError(err) {
+		_, err = helpers.SendMessage(msg.ChannelID, err.Error())
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+	helpers.Relax(err)
+
+	_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.feeds.template-success"))
+	helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+}
+
+func (m *Feeds) getEntryFromArgs(args []string, msg *discordgo.Message) (entry models.FeedsEntry, ok bool) {
+	if len(args) < 2 {
+		helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.too-few"))
+		return entry, false
+	}
+
+	channel, err := helpers.GetChannel(msg.ChannelID)
+	helpers.Relax(err)
+
+	entry, err = FeedGet(channel.GuildID, helpers.HumanToMdbId(args[1]))
+	if helpers.IsInputError(err) {
+		_, err = helpers.SendMessage(msg.ChannelID, err.Error())
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return entry, false
+	}
+	helpers.Relax(err)
+
+	return entry, true
+}
+
+// FeedsGet returns all feeds of the guild
+func FeedsGet(guildID string) (entries []models.FeedsEntry, err error) {
+	err = helpers.MDbIter(helpers.MdbCollection(models.FeedsTable).Find(bson.M{"guildid": guildID})).All(&entries)
+	return entries, err
+}
+
+// FeedGet returns the feed with the ID, an InputError if the guild has no such feed
+func FeedGet(guildID string, id bson.ObjectId) (entry models.FeedsEntry, err error) {
+	if !id.Valid() {
+		return entry, helpers.NewInputError("plugins.feeds.not-found")
+	}
+
+	err = helpers.MdbOne(
+		helpers.MdbCollection(models.FeedsTable).Find(bson.M{
+			"_id":     id,
+			"guildid": guildID,
+		}),
+		&entry,
+	)
+	if helpers.IsMdbNotFound(err) {
+		return entry, helpers.NewInputError("plugins.feeds.not-found")
+	}
+	return entry, err
+}
+
+// FeedAdd checks the feed, adds it to the channel and logs it to the eventlog
+// mentionRoleID	: the role to mention in new posts, can be empty
+func FeedAdd(guildID, channelID, userID, feedURL, mentionRoleID string) (entry models.FeedsEntry, title string, err error) {
+	return (&Feeds{fetcher: newFeedFetcher()}).add(guildID, channelID, userID, feedURL, mentionRoleID)
+}
+
+func (m *Feeds) add(guildID, channelID, userID, feedURL, mentionRoleID string) (entry models.FeedsEntry, title string, err error) {
+	targetChannel, err := helpers.GetChannel(channelID)
+	if err != nil || targetChannel.GuildID != guildID || targetChannel.Type != discordgo.ChannelTypeGuildText {
+		return entry, "", helpers.NewInputError("bot.arguments.invalid")
+	}
+
+	if mentionRoleID != "" {
+		guild, err := helpers.GetGuild(guildID)
+		if err != nil {
+			return entry, "", err
+		}
+		if m.findRole(guild, mentionRoleID) == nil {
+			return entry, "", helpers.NewInputError("plugins.feeds.add-error-role")
+		}
+	}
+
+	// check the feed, and start tracking posted items if we don't know the feed yet
+	state, err := m.getState(feedURL)
+	if err != nil {
+		return entry, "", err
+	}
+	if state.LastSuccessAt.IsZero() {
+		fetched, err := m.fetcher.Fetch(feedURL, "", "")
+		if err == nil {
+			var parsedFeed feed
+			parsedFeed, err = parseFeed(fetched.Body)
+			if err == nil {
+				m.applyFetched(&state, fetched, parsedFeed)
+				err = m.saveState(&state)
+				if err != nil {
+					return entry, "", err
+				}
+			}
+		}
+		if err != nil {
+			return entry, "", helpers.NewInputError("plugins.feeds.add-error-feed", err.Error())
+		}
+	}
+
+	entry = models.FeedsEntry{
+		GuildID:       guildID,
+		ChannelID:     channelID,
+		URL:           feedURL,
+		MentionRoleID: mentionRoleID,
+		AddedByUserID: userID,
+		AddedAt:       time.Now(),
+	}
+	entry.ID, err = helpers.MDbInsert(models.FeedsTable, entry)
+	if err != nil {
+		return entry, "", err
+	}
+
+	_, err = helpers.EventlogLog(time.Now(), entry.GuildID, helpers.MdbIdToHuman(entry.ID),
+		models.EventlogTargetTypeRobyulFeed, userID,
+		models.EventlogTypeRobyulFeedsAdd, "",
+		nil,
+		[]models.ElasticEventlogOption{
+			{
+				Key:   "feed_url",
+				Value: entry.URL,
+			},
+			{
+				Key:   "feed_channelid",
+				Value: entry.ChannelID,
+				Type:  models.EventlogTargetTypeChannel,
+			},
+			{
+				Key:   "feed_mention_roleid",
+				Value: entry.MentionRoleID,
+				Type:  models.EventlogTargetTypeRole,
+			},
+		}, false)
+	helpers.RelaxLog(err)
+
+	return entry, state.Title, nil
+}
+
+// FeedRemove removes a feed from the guild and logs it to the eventlog
+func FeedRemove(guildID, userID string, id bson.ObjectId) (err error) {
+	entry, err := FeedGet(guildID, id)
+	if err != nil {
+		return err
+	}
+
+	err = helpers.MDbDelete(models.FeedsTable, entry.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = helpers.EventlogLog(time.Now(), entry.GuildID, helpers.MdbIdToHuman(entry.ID),
+		models.EventlogTargetTypeRobyulFeed, userID,
+		models.EventlogTypeRobyulFeedsRemove, "",
+		nil,
+		[]models.ElasticEventlogOption{
+			{
+				Key:   "feed_url",
+				Value: entry.URL,
+			},
+			{
+				Key:   "feed_channelid",
+				Value: entry.ChannelID,
+				Type:  models.EventlogTargetTypeChannel,
+			},
+		}, false)
+	helpers.RelaxLog(err)
+	return nil
+}
+
+// FeedSetTemplate sets the embed code of the posts of a feed and logs it to the eventlog
+// embedCode	: an empty embed code resets the feed to the default message
+func FeedSetTemplate(guildID, userID string, id bson.ObjectId, embedCode string) (err error) {
+	entry, err := FeedGet(guildID, id)
+	if err != nil {
+		return err
+	}
+
+	if helpers.IsEmbedCode(embedCode) {
+		_, _, err = helpers.ParseEmbedCode(embedCode)
+		if err != nil {
+			return helpers.NewInputError("bot.arguments.invalid")
+		}
+	}
+
+	beforeEmbedCode := entry.EmbedCode
+	entry.EmbedCode = embedCode
+	err = helpers.MDbUpdate(models.FeedsTable, entry.ID, entry)
+	if err != nil {
+		return err
+	}
+
+	_, err = helpers.EventlogLog(time.Now(), entry.GuildID, helpers.MdbIdToHuman(entry.ID),
+		models.EventlogTargetTypeRobyulFeed, userID,
+		models.EventlogTypeRobyulFeedsUpdate, "",
+		[]models.ElasticEventlogChange{
+			{
+				Key:      "feed_template",
+				OldValue: beforeEmbedCode,
+				NewValue: entry.EmbedCode,
+			},
+		},
+		nil, false)
+	helpers.RelaxLog(err)
+	return nil
+}
+
+// findRole finds a role on the guild by mention, ID or name
+func (m *Feeds) findRole(guild *discordgo.Guild, roleText string) *discordgo.Role {
+	roleText = strings.TrimSpace(roleText)
+	roleID := strings.TrimSuffix(strings.TrimPrefix(roleText, "<@&"), ">")
+
+	for _, role := range guild.Roles {
+		if role.ID == roleID || strings.ToLower(role.Name) == strings.ToLower(roleText) {
+			return role
+		}
+	}
+	return nil
+}
+
+func (m *Feeds) logger() *logrus.Entry {
+	return cache.GetLogger().WithField("module", "feeds")
+}
@@ -0,0 +1,260 @@
+package feeds
+
+import (
+	"strings"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/cluster"
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/bwmarrin/discordgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+func (m *Feeds) checkFeedsLoop() {
+	defer helpers.Recover()
+	defer func() {
+		go func() {
+			m.logger().Error("The checkFeedsLoop died. Please investigate! Will be restarted in 60 seconds")
+			time.Sleep(60 * time.Second)
+			m.checkFeedsLoop()
+		}()
+	}()
+
+	var entries []models.FeedsEntry
+	var bundledEntries map[string][]models.FeedsEntry
+
+	for {
+		// only one process checks the feeds
+		if !cluster.IsLeader("feeds") {
+			time.Sleep(time.Minute)
+			continue
+		}
+
+		err := helpers.MDbIterWithoutLogging(helpers.MdbCollection(models.FeedsTable).Find(nil)).All(&entries)
+		helpers.Relax(err)
+
+		bundledEntries = make(map[string][]models.FeedsEntry)
+		for _, entry := range entries {
+			// channels of guilds on other processes are not in the state
+			if cache.GetSession().IsLocalGuild(entry.GuildID) {
+				channel, err := helpers.GetChannelWithoutApi(entry.ChannelID)
+				if err != nil || channel == nil || channel.ID == "" {
+					continue
+				}
+			}
+
+			bundledEntries[entry.URL] = append(bundledEntries[entry.URL], entry)
+		}
+
+		m.logger().Infof("checking %d feeds for %d entries", len(bundledEntries), len(entries))
+
+		for feedURL, feedEntries := range bundledEntries {
+			err = m.checkFeed(feedURL, feedEntries)
+			if err != nil && err != errHostBackoff {
+				m.logger().Warnf("checking feed %s failed: %s", feedURL, err.Error())
+			}
+		}
+
+		time.Sleep(feedsCheckInterval)
+	}
+}
+
+// checkFeed fetches the feed, and posts new items to all entries
+func (m *Feeds) checkFeed(feedURL string, entries []models.FeedsEntry) (err error) {
+	state, err := m.getState(feedURL)
+	if err != nil {
+		return err
+	}
+
+	firstCheck := state.LastSuccessAt.IsZero()
+	state.LastCheckAt = time.Now()
+
+	fetched, err := m.fetcher.Fetch(feedURL, state.ETag, state.LastModified)
+	var parsedFeed feed
+	if err == nil && !fetched.NotModified {
+		parsedFeed, err = parseFeed(fetched.Body)
+	}
+	if err != nil {
+		if err != errHostBackoff {
+			state.Failures++
+			state.LastError = err.Error()
+			helpers.RelaxLog(m.saveState(&state))
+		}
+		return err
+	}
+	if fetched.NotModified {
+		state.LastSuccessAt = time.Now()
+		state.Failures = 0
+		state.LastError = ""
+		return m.saveState(&state)
+	}
+
+	newItems := m.applyFetched(&state, fetched, parsedFeed)
+	err = m.saveState(&state)
+	if err != nil {
+		return err
+	}
+
+	// the first check only remembers the items already in the feed
+	if firstCheck {
+		return nil
+	}
+
+	if len(newItems) > feedsMaxPostsPerCheck {
+		newItems = newItems[len(newItems)-feedsMaxPostsPerCheck:]
+	}
+	for _, item := range newItems {
+		for _, entry := range entries {
+			err = m.postItem(entry, parsedFeed, item)
+			if err != nil {
+				if errD, ok := err.(*discordgo.RESTError); ok && errD.Message != nil {
+					if errD.Message.Code != discordgo.ErrCodeMissingPermissions &&
+						errD.Message.Code != discordgo.ErrCodeUnknownChannel &&
+						errD.Message.Code != discordgo.ErrCodeMissingAccess {
+						helpers.RelaxLog(err)
+					}
+				} else {
+					helpers.RelaxLog(err)
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// applyFetched updates the state with a successful fetch, returns the items which haven't been posted yet
+func (m *Feeds) applyFetched(state *models.FeedsStateEntry, fetched fetchResult, parsedFeed feed) (newItems []feedItem) {
+	state.Title = parsedFeed.Title
+	state.ETag = fetched.ETag
+	state.LastModified = fetched.LastModified
+	state.LastSuccessAt = time.Now()
+	state.Failures = 0
+	state.LastError = ""
+
+	posted := make(map[string]bool, len(state.PostedGUIDs))
+	for _, guid := range state.PostedGUIDs {
+		posted[guid] = true
+	}
+
+	var newGUIDs []string
+	for _, item := range parsedFeed.Items {
+		if posted[item.GUID] {
+			continue
+		}
+		posted[item.GUID] = true
+		newItems = append(newItems, item)
+		newGUIDs = append(newGUIDs, item.GUID)
+	}
+
+	// keep the latest GUIDs, at least as many as the feed contains
+	state.PostedGUIDs = append(newGUIDs, state.PostedGUIDs...)
+	maxPostedGUIDs := feedsMinPostedGUIDs
+	if len(parsedFeed.Items)*2 > maxPostedGUIDs {
+		maxPostedGUIDs = len(parsedFeed.Items) * 2
+	}
+	if len(state.PostedGUIDs) > maxPostedGUIDs {
+		state.PostedGUIDs = state.PostedGUIDs[:maxPostedGUIDs]
+	}
+
+	return newItems
+}
+
+func (m *Feeds) getState(feedURL string) (state models.FeedsStateEntry, err error) {
+	err = helpers.MdbOneWithoutLogging(
+		helpers.MdbCollection(models.FeedsStateTable).Find(bson.M{"url": feedURL}),
+		&state,
+	)
+	if helpers.IsMdbNotFound(err) {
+		return models.FeedsStateEntry{URL: feedURL}, nil
+	}
+	return state, err
+}
+
+func (m *Feeds) saveState(state *models.FeedsStateEntry) (err error) {
+	if state.ID == "" {
+		state.ID, err = helpers.MDbInsertWithoutLogging(models.FeedsStateTable, state)
+		return err
+	}
+	return helpers.MDbUpdateWithoutLogging(models.FeedsStateTable, state.ID, state)
+}
+
+func (m *Feeds) postItem(entry models.FeedsEntry, parsedFeed feed, item feedItem) (err error) {
+	messageSend := getItemMessageSend(entry, parsedFeed, item)
+	if entry.MentionRoleID != "" {
+		messageSend.Content = strings.TrimSpace("<@&" + entry.MentionRoleID + "> " + messageSend.Content)
+	}
+
+	_, err = helpers.SendComplex(entry.ChannelID, messageSend)
+	return err
+}
+
+// getItemMessageSend builds the message for a feed item, from the custom template of the entry or the default embed
+func getItemMessageSend(entry models.FeedsEntry, parsedFeed feed, item feedItem) *discordgo.MessageSend {
+	description := item.Description
+	if len([]rune(description)) > feedsDescriptionMaxLength {
+		description = string([]rune(description)[:feedsDescriptionMaxLength-1]) + "…"
+	}
+	title := item.Title
+	if len([]rune(title)) > 256 {
+		title = string([]rune(title)[:255]) + "…"
+	}
+
+	if entry.EmbedCode != "" {
+		messageSend := &discordgo.MessageSend{
+			Content: entry.EmbedCode,
+		}
+		if helpers.IsEmbedCode(entry.EmbedCode) {
+			ptext, embed, err := helpers.ParseEmbedCode(entry.EmbedCode)
+			if err == nil {
+				messageSend.Content = ptext
+				messageSend.Embed = embed
+			}
+		}
+
+		var published string
+		if !item.PublishedAt.IsZero() {
+			published = item.PublishedAt.Format(time.RFC3339)
+		}
+
+		return helpers.ReplaceMessageSend(messageSend, []*helpers.ReplaceValues{
+			{Before: "{FEED_TITLE}", After: parsedFeed.Title},
+			{Before: "{FEED_LINK}", After: parsedFeed.Link},
+			{Before: "{ITEM_TITLE}", After: title},
+			{Before: "{ITEM_LINK}", After: item.Link},
+			{Before: "{ITEM_DESCRIPTION}", After: description},
+			{Before: "{ITEM_AUTHOR}", After: item.Author},
+			{Before: "{ITEM_IMAGE}", After: item.ImageURL},
+			{Before: "{ITEM_PUBLISHED}", After: published},
+		})
+	}
+
+	messageSend := &discordgo.MessageSend{
+		Content: "<" + item.Link + ">",
+		Embed: &discordgo.MessageEmbed{
+			Title:       title,
+			URL:         item.Link,
+			Description: description,
+			Color:       helpers.GetDiscordColorFromHex(feedsColor),
+			Footer: &discordgo.MessageEmbedFooter{
+				Text: helpers.GetText("plugins.feeds.embed-footer") + " | " + parsedFeed.Title,
+			},
+		},
+	}
+	if item.Link == "" {
+		messageSend.Content = ""
+	}
+	if item.Author != "" {
+		messageSend.Embed.Author = &discordgo.MessageEmbedAuthor{Name: item.Author}
+	}
+	if item.ImageURL != "" {
+		messageSend.Embed.Image = &discordgo.MessageEmbedImage{URL: item.ImageURL}
+	}
+	if !item.PublishedAt.IsZero() {
+		messageSend.Embed.Timestamp = item.PublishedAt.Format(time.RFC3339)
+	}
+
+	return messageSend
+}
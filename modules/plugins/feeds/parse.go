@@ -0,0 +1,352 @@
+package feeds
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kennygrant/sanitize"
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+var errUnsupportedFeed = errors.New("unsupported feed format")
+
+var feedTimeLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC3339,
+	time.RFC3339Nano,
+	time.RFC822Z,
+	time.RFC822,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"Mon, 02 Jan 2006 15:04 -0700",
+	"2 Jan 2006 15:04:05 -0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// feed is a parsed RSS, Atom, or JSON Feed
+type feed struct {
+	Title string
+	Link  string
+	Items []feedItem
+}
+
+type feedItem struct {
+	GUID        string
+	Title       string
+	Link        string
+	Description string
+	Author      string
+	ImageURL    string
+	PublishedAt time.Time
+}
+
+type rssFeed struct {
+	Channel struct {
+		Title string    `xml:"title"`
+		Link  string    `xml:"link"`
+		Items []rssItem `xml:"item"`
+	} `xml:"channel"`
+}
+
+type rssItem struct {
+	GUID        string `xml:"guid"`
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	Description string `xml:"description"`
+	Content     string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	Author      string `xml:"author"`
+	Creator     string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	PubDate     string `xml:"pubDate"`
+	Date        string `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Enclosures  []struct {
+		URL  string `xml:"url,attr"`
+		Type string `xml:"type,attr"`
+	} `xml:"enclosure"`
+	MediaContents []struct {
+		URL    string `xml:"url,attr"`
+		Medium string `xml:"medium,attr"`
+		Type   string `xml:"type,attr"`
+	} `xml:"http://search.yahoo.com/mrss/ content"`
+	MediaThumbnail struct {
+		URL string `xml:"url,attr"`
+	} `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+}
+
+type atomFeed struct {
+	Title   string     `xml:"title"`
+	Links   []atomLink `xml:"link"`
+	Entries []struct {
+		ID        string     `xml:"id"`
+		Title     string     `xml:"title"`
+		Links     []atomLink `xml:"link"`
+		Summary   string     `xml:"summary"`
+		Content   string     `xml:"content"`
+		Published string     `xml:"published"`
+		Updated   string     `xml:"updated"`
+		Authors   []struct {
+			Name string `xml:"name"`
+		} `xml:"author"`
+		MediaThumbnail struct {
+			URL string `xml:"url,attr"`
+		} `xml:"http://search.yahoo.com/mrss/ group>thumbnail"`
+	} `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type jsonFeed struct {
+	Version     string `json:"version"`
+	Title       string `json:"title"`
+	HomePageURL string `json:"home_page_url"`
+	Items       []struct {
+		ID            json.RawMessage `json:"id"`
+		URL           string          `json:"url"`
+		Title         string          `json:"title"`
+		ContentText   string          `json:"content_text"`
+		ContentHTML   string          `json:"content_html"`
+		Summary       string          `json:"summary"`
+		Image         string          `json:"image"`
+		BannerImage   string          `json:"banner_image"`
+		DatePublished string          `json:"date_published"`
+		Author        struct {
+			Name string `json:"name"`
+		} `json:"author"`
+		Authors []struct {
+			Name string `json:"name"`
+		} `json:"authors"`
+	} `json:"items"`
+}
+
+// parseFeed parses RSS 2.0, Atom, and JSON Feed documents, items are sorted from oldest to newest
+func parseFeed(data []byte) (result feed, err error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		result, err = parseJSONFeed(trimmed)
+	} else {
+		result, err = parseXMLFeed(trimmed)
+	}
+	if err != nil {
+		return result, err
+	}
+
+	for i := range result.Items {
+		result.Items[i].Title = strings.TrimSpace(sanitize.HTML(result.Items[i].Title))
+		result.Items[i].Description = strings.TrimSpace(sanitize.HTML(result.Items[i].Description))
+		result.Items[i].Link = strings.TrimSpace(result.Items[i].Link)
+		if result.Items[i].GUID == "" {
+			result.Items[i].GUID = result.Items[i].Link
+		}
+		if result.Items[i].GUID == "" {
+			result.Items[i].GUID = result.Items[i].Title
+		}
+	}
+	result.Title = strings.TrimSpace(sanitize.HTML(result.Title))
+
+	// feeds are usually newest first, sort by date only if all items have one
+	allDated := true
+	for i, j := 0, len(result.Items)-1; i < j; i, j = i+1, j-1 {
+		result.Items[i], result.Items[j] = result.Items[j], result.Items[i]
+	}
+	for _, item := range result.Items {
+		if item.PublishedAt.IsZero() {
+			allDated = false
+		}
+	}
+	if allDated {
+		sort.SliceStable(result.Items, func(i, j int) bool {
+			return result.Items[i].PublishedAt.Before(result.Items[j].PublishedAt)
+		})
+	}
+
+	return result, nil
+}
+
+func parseXMLFeed(data []byte) (result feed, err error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.CharsetReader = feedCharsetReader
+	decoder.Strict = false
+
+	var root xml.StartElement
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return result, errUnsupportedFeed
+		}
+		if startElement, ok := token.(xml.StartElement); ok {
+			root = startElement
+			break
+		}
+	}
+
+	switch root.Name.Local {
+	case "rss":
+		var rss rssFeed
+		err = decoder.DecodeElement(&rss, &root)
+		if err != nil {
+			return result, err
+		}
+
+		result.Title = rss.Channel.Title
+		result.Link = rss.Channel.Link
+		for _, rssItem := range rss.Channel.Items {
+			item := feedItem{
+				GUID:        strings.TrimSpace(rssItem.GUID),
+				Title:       rssItem.Title,
+				Link:        rssItem.Link,
+				Description: rssItem.Description,
+				Author:      rssItem.Author,
+				ImageURL:    rssItem.MediaThumbnail.URL,
+				PublishedAt: parseFeedTime(rssItem.PubDate),
+			}
+			if item.Description == "" {
+				item.Description = rssItem.Content
+			}
+			if item.Author == "" {
+				item.Author = rssItem.Creator
+			}
+			if item.PublishedAt.IsZero() {
+				item.PublishedAt = parseFeedTime(rssItem.Date)
+			}
+			for _, enclosure := range rssItem.Enclosures {
+				if item.ImageURL == "" && strings.HasPrefix(enclosure.Type, "image/") {
+					item.ImageURL = enclosure.URL
+				}
+			}
+			for _, mediaContent := range rssItem.MediaContents {
+				if item.ImageURL == "" && (mediaContent.Medium == "image" || strings.HasPrefix(mediaContent.Type, "image/")) {
+					item.ImageURL = mediaContent.URL
+				}
+			}
+			result.Items = append(result.Items, item)
+		}
+	case "feed":
+		var atom atomFeed
+		err = decoder.DecodeElement(&atom, &root)
+		if err != nil {
+			return result, err
+		}
+
+		result.Title = atom.Title
+		result.Link = getAtomLink(atom.Links)
+		for _, entry := range atom.Entries {
+			item := feedItem{
+				GUID:        strings.TrimSpace(entry.ID),
+				Title:       entry.Title,
+				Link:        getAtomLink(entry.Links),
+				Description: entry.Summary,
+				ImageURL:    entry.MediaThumbnail.URL,
+				PublishedAt: parseFeedTime(entry.Published),
+			}
+			if item.Description == "" {
+				item.Description = entry.Content
+			}
+			if item.PublishedAt.IsZero() {
+				item.PublishedAt = parseFeedTime(entry.Updated)
+			}
+			if len(entry.Authors) > 0 {
+				item.Author = entry.Authors[0].Name
+			}
+			for _, link := range entry.Links {
+				if item.ImageURL == "" && link.Rel == "enclosure" && strings.HasPrefix(link.Type, "image/") {
+					item.ImageURL = link.Href
+				}
+			}
+			result.Items = append(result.Items, item)
+		}
+	default:
+		return result, errUnsupportedFeed
+	}
+
+	return result, nil
+}
+
+func parseJSONFeed(data []byte) (result feed, err error) {
+	var jsonFeed jsonFeed
+	err = json.Unmarshal(data, &jsonFeed)
+	if err != nil {
+		return result, err
+	}
+	if !strings.Contains(jsonFeed.Version, "jsonfeed.org") {
+		return result, errUnsupportedFeed
+	}
+
+	result.Title = jsonFeed.Title
+	result.Link = jsonFeed.HomePageURL
+	for _, jsonItem := range jsonFeed.Items {
+		item := feedItem{
+			GUID:        strings.Trim(string(jsonItem.ID), "\" "),
+			Title:       jsonItem.Title,
+			Link:        jsonItem.URL,
+			Description: jsonItem.Summary,
+			Author:      jsonItem.Author.Name,
+			ImageURL:    jsonItem.Image,
+			PublishedAt: parseFeedTime(jsonItem.DatePublished),
+		}
+		if item.Description == "" {
+			item.Description = jsonItem.ContentText
+		}
+		if item.Description == "" {
+			item.Description = jsonItem.ContentHTML
+		}
+		if item.Author == "" && len(jsonItem.Authors) > 0 {
+			item.Author = jsonItem.Authors[0].Name
+		}
+		if item.ImageURL == "" {
+			item.ImageURL = jsonItem.BannerImage
+		}
+		result.Items = append(result.Items, item)
+	}
+
+	return result, nil
+}
+
+// getAtomLink returns the alternate link, or the first link without a rel
+func getAtomLink(links []atomLink) string {
+	for _, link := range links {
+		if link.Rel == "alternate" {
+			return link.Href
+		}
+	}
+	for _, link := range links {
+		if link.Rel == "" {
+			return link.Href
+		}
+	}
+	return ""
+}
+
+func parseFeedTime(text string) time.Time {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return time.Time{}
+	}
+
+	for _, layout := range feedTimeLayouts {
+		parsed, err := time.Parse(layout, text)
+		if err == nil {
+			return parsed
+		}
+	}
+	return time.Time{}
+}
+
+func feedCharsetReader(label string, input io.Reader) (io.Reader, error) {
+	encoding, err := htmlindex.Get(label)
+	if err != nil {
+		return nil, err
+	}
+	return encoding.NewDecoder().Reader(input), nil
+}
@@ -128,8 +128,13 @@ func (g *Gallery) Action(command string, content string, msg *discordgo.Message,
 
 			resultMessage := ":frame_photo: Galleries on this server:\n"
 			for _, entry := range entryBucket {
-				resultMessage += fmt.Sprintf("`%s`: posting from <#%s> to <#%s>\n",
+				resultMessage += fmt.Sprintf("`%s`: posting from <#%s> to <#%s>",
 					helpers.MdbIdToHuman(entry.ID), entry.SourceChannelID, entry.TargetChannelID)
+				if entry.DuplicateMode != models.GalleryDuplicateModeOff {
+					resultMessage += fmt.Sprintf(", near-duplicates: %s (distance %d)",
+						getGalleryDuplicateModeText(entry.DuplicateMode), getGalleryDuplicateDistance(entry))
+				}
+				resultMessage += "\n"
 			}
 			resultMessage += fmt.Sprintf("Found **%d** Galleries in total.", len(entryBucket))
 
@@ -162,6 +167,11 @@ func (g *Gallery) Action(command string, content string, msg *discordgo.Message,
 				err = helpers.MDbDelete(models.GalleryTable, entryBucket.ID)
 				helpers.Relax(err)
 
+				_, err = helpers.MdbCollection(models.GalleryImageHashTable).RemoveAll(bson.M{"galleryid": entryBucket.ID})
+				helpers.RelaxLog(err)
+				_, err = helpers.MdbCollection(models.GalleryDuplicatesTable).RemoveAll(bson.M{"galleryid": entryBucket.ID})
+				helpers.RelaxLog(err)
+
 				_, err = helpers.EventlogLog(time.Now(), entryBucket.GuildID, helpers.MdbIdToHuman(entryBucket.ID),
 					models.EventlogTargetTypeRobyulGallery, msg.Author.ID,
 					models.EventlogTypeRobyulGalleryRemove, "",
@@ -187,6 +197,14 @@ func (g *Gallery) Action(command string, content string, msg *discordgo.Message,
 				helpers.RelaxLog(err)
 				return
 			})
+		case "duplicates": // [p]gallery duplicates <gallery id>
+			helpers.RequireMod(msg, func() {
+				g.actionDuplicates(args, msg, session)
+			})
+		case "duplicate-settings": // [p]gallery duplicate-settings <gallery id> <off|skip|react> [<distance>]
+			helpers.RequireMod(msg, func() {
+				g.actionDuplicateSettings(args, msg, session)
+			})
 		case "refresh": // [p]gallery refresh
 			helpers.RequireBotAdmin(msg, func() {
 				session.ChannelTyping(msg.ChannelID)
@@ -250,6 +268,24 @@ func (g *Gallery) OnMessage(content string, msg *discordgo.Message, session *dis
 				// post mirror links
 				if len(linksToRepost) > 0 {
 					for _, linkToRepost := range linksToRepost {
+						// skip near-duplicates of images reposted before
+						var imageHash string
+						if gallery.DuplicateMode != models.GalleryDuplicateModeOff && isGalleryImageURL(linkToRepost) {
+							imageHash, err = getGalleryImageHash(linkToRepost)
+							if err != nil {
+								cache.GetLogger().WithField("module", "gallery").Debug(
+									"hashing image failed: ", linkToRepost, ": ", err.Error())
+								imageHash = ""
+							} else {
+								original, distance, err := findGalleryDuplicate(gallery, imageHash)
+								helpers.RelaxLog(err)
+								if original != nil {
+									suppressGalleryDuplicate(gallery, msg, linkToRepost, original, distance, session)
+									continue
+								}
+							}
+						}
+
 						var newMessage *discordgo.Message
 						if webhook != nil && webhook.ID != "" && webhook.Token != "" {
 							newMessage, err = helpers.WebhookExecuteWithResult(
@@ -276,6 +312,10 @@ func (g *Gallery) OnMessage(content string, msg *discordgo.Message, session *dis
 						}
 						err = g.rememberPostedMessage(msg, newMessage)
 						helpers.RelaxLog(err)
+						if imageHash != "" {
+							err = rememberGalleryImageHash(gallery, msg, linkToRepost, imageHash)
+							helpers.RelaxLog(err)
+						}
 						metrics.GalleryPostsSent.Add(1)
 					}
 				}
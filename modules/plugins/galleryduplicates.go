@@ -0,0 +1,400 @@
+package plugins
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/metrics"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/bwmarrin/discordgo"
+	"github.com/corona10/goimagehash"
+	"github.com/globalsign/mgo/bson"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	galleryDuplicateDefaultDistance = 5
+	galleryDuplicateMaxDistance     = 32 // hashes are 64 bits, everything above matches unrelated images
+	galleryDuplicateReaction        = "🔁"
+	// images are only compared against images reposted in this time
+	galleryDuplicateHashRetention = time.Hour * 24 * 90
+	galleryDuplicateListLimit     = 50
+	// hashes are split into 8 bands of 8 bits, two hashes with a distance below 8 share at least one band
+	galleryDuplicateHashBands = 8
+	// galleries with a larger distance compare against the most recent hashes only
+	galleryDuplicateScanLimit = 10000
+	// larger images are not checked for duplicates
+	galleryDuplicateMaxImageSize   = 8 * 1024 * 1024
+	galleryDuplicateMaxImagePixels = 40 * 1000 * 1000
+	// images hashed at the same time
+	galleryDuplicateHashWorkers = 4
+)
+
+var (
+	errGalleryImageTooLarge = errors.New("image is too large")
+	// limits the images downloaded and decoded at the same time
+	galleryDuplicateHashSlots = make(chan struct{}, galleryDuplicateHashWorkers)
+)
+
+var galleryImageExtensions = []string{".jpg", ".jpeg", ".png", ".gif"}
+
+// isGalleryImageURL returns true if the link points to an image we are able to hash
+func isGalleryImageURL(link string) bool {
+	parsedLink, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+
+	extension := strings.ToLower(path.Ext(parsedLink.Path))
+	for _, imageExtension := range galleryImageExtensions {
+		if extension == imageExtension {
+			return true
+		}
+	}
+	return false
+}
+
+func parseGalleryDuplicateMode(text string) (mode models.GalleryDuplicateMode, ok bool) {
+	switch strings.ToLower(text) {
+	case "off", "disable", "disabled":
+		return models.GalleryDuplicateModeOff, true
+	case "skip":
+		return models.GalleryDuplicateModeSkip, true
+	case "react":
+		return models.GalleryDuplicateModeReact, true
+	}
+	return models.GalleryDuplicateModeOff, false
+}
+
+func getGalleryDuplicateModeText(mode models.GalleryDuplicateMode) string {
+	if mode == models.GalleryDuplicateModeOff {
+		return "off"
+	}
+	return string(mode)
+}
+
+// getGalleryDuplicateDistance returns the maximum distance for near-duplicates of the gallery
+func getGalleryDuplicateDistance(gallery models.GalleryEntry) int {
+	if gallery.DuplicateDistance <= 0 {
+		return galleryDuplicateDefaultDistance
+	}
+	return gallery.DuplicateDistance
+}
+
+// getGalleryImageHash downloads the image and returns its perceptual hash, the link is posted by users,
+// so only public addresses are requested, and images above the size limits are not decoded
+func getGalleryImageHash(link string) (hash string, err error) {
+	galleryDuplicateHashSlots <- struct{}{}
+	defer func() {
+		<-galleryDuplicateHashSlots
+	}()
+
+	request, err := http.NewRequest("GET", link, nil)
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("User-Agent", helpers.DEFAULT_UA)
+
+	response, err := helpers.PublicClient.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return "", errors.New("expected status 200; got " + strconv.Itoa(response.StatusCode))
+	}
+	if response.ContentLength > galleryDuplicateMaxImageSize {
+		return "", errGalleryImageTooLarge
+	}
+
+	imageData, err := ioutil.ReadAll(io.LimitReader(response.Body, galleryDuplicateMaxImageSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(imageData) > galleryDuplicateMaxImageSize {
+		return "", errGalleryImageTooLarge
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(imageData))
+	if err != nil {
+		return "", err
+	}
+	if config.Width*config.Height > galleryDuplicateMaxImagePixels {
+		return "", errGalleryImageTooLarge
+	}
+
+	img, _, err := helpers.DecodeImageBytes(imageData)
+	if err != nil {
+		return "", err
+	}
+
+	return helpers.GetImageHashString(img)
+}
+
+// getGalleryImageHashBands returns the bands of the hash, see galleryDuplicateHashBands
+func getGalleryImageHashBands(hash string) (bands []string, err error) {
+	imageHash, err := goimagehash.ImageHashFromString(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	value := imageHash.GetHash()
+	for i := 0; i < galleryDuplicateHashBands; i++ {
+		bands = append(bands, fmt.Sprintf("%d:%02x", i, byte(value>>(uint(i)*8))))
+	}
+	return bands, nil
+}
+
+// findGalleryDuplicate returns the closest image reposted by the gallery within the duplicate distance, or nil if there is none
+func findGalleryDuplicate(gallery models.GalleryEntry, hash string) (original *models.GalleryImageHashEntry, distance int, err error) {
+	maxDistance := getGalleryDuplicateDistance(gallery)
+
+	query := bson.M{
+		"galleryid": gallery.ID,
+		"createdat": bson.M{"$gt": time.Now().Add(-galleryDuplicateHashRetention)},
+	}
+	if maxDistance < galleryDuplicateHashBands {
+		bands, err := getGalleryImageHashBands(hash)
+		if err != nil {
+			return nil, -1, err
+		}
+		// hashes stored before the bands were added are compared as well, until they expire
+		query["$or"] = []bson.M{
+			{"hashbands": bson.M{"$in": bands}},
+			{"hashbands": nil},
+		}
+	}
+
+	var hashEntries []models.GalleryImageHashEntry
+	err = helpers.MDbIterWithoutLogging(helpers.MdbCollection(models.GalleryImageHashTable).Find(query).
+		Sort("-createdat").Limit(galleryDuplicateScanLimit)).All(&hashEntries)
+	if err != nil {
+		return nil, -1, err
+	}
+
+	for i := range hashEntries {
+		entryDistance, err := helpers.ImageHashStringComparison(hash, hashEntries[i].Hash)
+		if err != nil || entryDistance > maxDistance {
+			continue
+		}
+		if original == nil || entryDistance < distance {
+			original = &hashEntries[i]
+			distance = entryDistance
+		}
+	}
+
+	return original, distance, nil
+}
+
+func rememberGalleryImageHash(gallery models.GalleryEntry, msg *discordgo.Message, link, hash string) error {
+	bands, err := getGalleryImageHashBands(hash)
+	if err != nil {
+		return err
+	}
+
+	_, err = helpers.MDbInsertWithoutLogging(models.GalleryImageHashTable, models.GalleryImageHashEntry{
+		GalleryID:       gallery.ID,
+		Hash:            hash,
+		HashBands:       bands,
+		URL:             link,
+		SourceMessageID: msg.ID,
+		AuthorID:        msg.Author.ID,
+		CreatedAt:       time.Now(),
+	})
+	return err
+}
+
+// suppressGalleryDuplicate records the near-duplicate, and reacts to the source message if the gallery is set up to do so
+func suppressGalleryDuplicate(gallery models.GalleryEntry, msg *discordgo.Message, link string,
+	original *models.GalleryImageHashEntry, distance int, session *discordgo.Session) {
+	_, err := helpers.MDbInsertWithoutLogging(models.GalleryDuplicatesTable, models.GalleryDuplicateEntry{
+		GalleryID:        gallery.ID,
+		URL:              link,
+		SourceMessageID:  msg.ID,
+		AuthorID:         msg.Author.ID,
+		OriginalURL:      original.URL,
+		OriginalAuthorID: original.AuthorID,
+		Distance:         distance,
+		CreatedAt:        time.Now(),
+	})
+	helpers.RelaxLog(err)
+
+	metrics.GalleryDuplicatesSuppressed.Add(1)
+
+	cache.GetLogger().WithFields(logrus.Fields{
+		"module":          "gallery",
+		"galleryID":       helpers.MdbIdToHuman(gallery.ID),
+		"sourceMessageID": msg.ID,
+		"distance":        distance,
+	}).Debug("suppressed near-duplicate image ", link)
+
+	if gallery.DuplicateMode == models.GalleryDuplicateModeReact {
+		err = session.MessageReactionAdd(msg.ChannelID, msg.ID, galleryDuplicateReaction)
+		if err != nil {
+			if errD, ok := err.(*discordgo.RESTError); !ok || errD.Message == nil ||
+				errD.Message.Code != discordgo.ErrCodeMissingPermissions {
+				helpers.RelaxLog(err)
+			}
+		}
+	}
+}
+
+// getGalleryDuplicatesText lists the most recent near-duplicates suppressed by the gallery
+func getGalleryDuplicatesText(gallery models.GalleryEntry) (text string, err error) {
+	var duplicates []models.GalleryDuplicateEntry
+	err = helpers.MDbIter(helpers.MdbCollection(models.GalleryDuplicatesTable).Find(
+		bson.M{"galleryid": gallery.ID},
+	).Sort("-createdat").Limit(galleryDuplicateListLimit)).All(&duplicates)
+	if err != nil {
+		return "", err
+	}
+
+	if len(duplicates) <= 0 {
+		return "", nil
+	}
+
+	text = fmt.Sprintf(":recycle: Near-duplicates not reposted by gallery `%s` from <#%s>:\n",
+		helpers.MdbIdToHuman(gallery.ID), gallery.SourceChannelID)
+	for _, duplicate := range duplicates {
+		text += fmt.Sprintf("`%s` by `#%s`: <%s> (distance %d to <%s> by `#%s`) %s\n",
+			duplicate.CreatedAt.Format(time.ANSIC), duplicate.AuthorID, duplicate.URL, duplicate.Distance,
+			duplicate.OriginalURL, duplicate.OriginalAuthorID,
+			"<"+helpers.MessageDeeplink(gallery.SourceChannelID, duplicate.SourceMessageID)+">")
+	}
+	text += fmt.Sprintf("Showing the last **%d** near-duplicates.", len(duplicates))
+	return text, nil
+}
+
+func (g *Gallery) actionDuplicates(args []string, msg *discordgo.Message, session *discordgo.Session) {
+	if len(args) < 2 {
+		helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.too-few"))
+		return
+	}
+
+	session.ChannelTyping(msg.ChannelID)
+
+	gallery, err := getGalleryForChannel(msg.ChannelID, args[1])
+	if helpers.IsMdbNotFound(err) {
+		helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.gallery.delete-not-found"))
+		return
+	}
+	helpers.Relax(err)
+
+	text, err := getGalleryDuplicatesText(gallery)
+	helpers.Relax(err)
+
+	if text == "" {
+		_, err = helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.gallery.duplicates-none",
+			getGalleryDuplicateModeText(gallery.DuplicateMode)))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	for _, page := range helpers.Pagify(text, "\n") {
+		_, err = helpers.SendMessage(msg.ChannelID, page)
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+	}
+}
+
+func (g *Gallery) actionDuplicateSettings(args []string, msg *discordgo.Message, session *discordgo.Session) {
+	if len(args) < 3 {
+		helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.too-few"))
+		return
+	}
+
+	session.ChannelTyping(msg.ChannelID)
+
+	gallery, err := getGalleryForChannel(msg.ChannelID, args[1])
+	if helpers.IsMdbNotFound(err) {
+		helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.gallery.delete-not-found"))
+		return
+	}
+	helpers.Relax(err)
+
+	mode, ok := parseGalleryDuplicateMode(args[2])
+	if !ok {
+		helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+		return
+	}
+
+	distance := gallery.DuplicateDistance
+	if len(args) >= 4 {
+		distance, err = strconv.Atoi(args[3])
+		if err != nil || distance < 1 || distance > galleryDuplicateMaxDistance {
+			helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.gallery.duplicates-error-distance",
+				galleryDuplicateMaxDistance))
+			return
+		}
+	}
+
+	beforeMode := gallery.DuplicateMode
+	beforeDistance := getGalleryDuplicateDistance(gallery)
+
+	gallery.DuplicateMode = mode
+	gallery.DuplicateDistance = distance
+	err = helpers.MDbUpdate(models.GalleryTable, gallery.ID, gallery)
+	helpers.Relax(err)
+
+	_, err = helpers.EventlogLog(time.Now(), gallery.GuildID, helpers.MdbIdToHuman(gallery.ID),
+		models.EventlogTargetTypeRobyulGallery, msg.Author.ID,
+		models.EventlogTypeRobyulGalleryUpdate, "",
+		[]models.ElasticEventlogChange{
+			{
+				Key:      "gallery_duplicatemode",
+				OldValue: getGalleryDuplicateModeText(beforeMode),
+				NewValue: getGalleryDuplicateModeText(gallery.DuplicateMode),
+			},
+			{
+				Key:      "gallery_duplicatedistance",
+				OldValue: strconv.Itoa(beforeDistance),
+				NewValue: strconv.Itoa(getGalleryDuplicateDistance(gallery)),
+			},
+		},
+		[]models.ElasticEventlogOption{
+			{
+				Key:   "gallery_sourcechannelid",
+				Value: gallery.SourceChannelID,
+				Type:  models.EventlogTargetTypeChannel,
+			},
+			{
+				Key:   "gallery_targetchannelid",
+				Value: gallery.TargetChannelID,
+				Type:  models.EventlogTargetTypeChannel,
+			},
+		}, false)
+	helpers.RelaxLog(err)
+
+	_, err = helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.gallery.duplicates-settings-success",
+		getGalleryDuplicateModeText(gallery.DuplicateMode), getGalleryDuplicateDistance(gallery)))
+	helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+
+	galleries, err = g.GetGalleries()
+	helpers.RelaxLog(err)
+}
+
+// getGalleryForChannel returns the gallery with the given ID on the server of the channel
+func getGalleryForChannel(channelID, galleryID string) (gallery models.GalleryEntry, err error) {
+	channel, err := helpers.GetChannel(channelID)
+	if err != nil {
+		return gallery, err
+	}
+
+	err = helpers.MdbOne(
+		helpers.MdbCollection(models.GalleryTable).Find(bson.M{"guildid": channel.GuildID, "_id": helpers.HumanToMdbId(galleryID)}),
+		&gallery,
+	)
+	return gallery, err
+}
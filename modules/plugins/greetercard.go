@@ -0,0 +1,166 @@
+package plugins
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/bwmarrin/discordgo"
+	"github.com/globalsign/mgo/bson"
+	"github.com/ungerik/go-cairo"
+)
+
+const (
+	greeterCardWidth        = 1000
+	greeterCardHeight       = 300
+	greeterCardAvatarSize   = 200
+	greeterCardFont         = "Roboto" // _assets/Roboto/
+	greeterCardFilename     = "greeter-card.png"
+	greeterCardDownloadTime = time.Second * 10
+)
+
+// getGreeterCardBackgroundUrl returns the link to a profile background by name
+func getGreeterCardBackgroundUrl(backgroundName string) (link string, err error) {
+	var entryBucket models.ProfileBackgroundEntry
+	err = helpers.MdbOne(
+		helpers.MdbCollection(models.ProfileBackgroundsTable).Find(bson.M{"name": strings.ToLower(backgroundName)}),
+		&entryBucket,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	if entryBucket.URL != "" {
+		return entryBucket.URL, nil
+	}
+
+	return helpers.GetFileLink(entryBucket.ObjectName)
+}
+
+func greeterCardDownloadImage(link string) (image.Image, error) {
+	data, err := helpers.NetGetUAWithErrorAndTimeout(link, helpers.DEFAULT_UA, greeterCardDownloadTime)
+	if err != nil {
+		return nil, err
+	}
+
+	decodedImage, _, err := image.Decode(bytes.NewReader(data))
+	return decodedImage, err
+}
+
+// paintGreeterCardImage paints the image scaled to cover the given area
+func paintGreeterCardImage(surface *cairo.Surface, sourceImage image.Image, x, y, width, height float64) {
+	sourceSurface := cairo.NewSurfaceFromImage(sourceImage)
+	defer sourceSurface.Finish()
+
+	bounds := sourceImage.Bounds()
+	scale := math.Max(width/float64(bounds.Dx()), height/float64(bounds.Dy()))
+
+	surface.Save()
+	surface.Rectangle(x, y, width, height)
+	surface.Clip()
+	surface.Translate(
+		x+(width-float64(bounds.Dx())*scale)/2,
+		y+(height-float64(bounds.Dy())*scale)/2,
+	)
+	surface.Scale(scale, scale)
+	surface.SetSourceSurface(sourceSurface, 0, 0)
+	surface.Paint()
+	surface.Restore()
+}
+
+// showGreeterCardText draws a line of text, the font size shrinks until the text fits into maxWidth
+func showGreeterCardText(surface *cairo.Surface, text string, x, y, maxWidth, fontSize float64, weight int) {
+	surface.SelectFontFace(greeterCardFont, cairo.FONT_SLANT_NORMAL, weight)
+	for {
+		surface.SetFontSize(fontSize)
+		if surface.TextExtents(text).Width <= maxWidth || fontSize <= 12 {
+			break
+		}
+		fontSize--
+	}
+
+	// draw a dark outline to keep the text readable on bright backgrounds
+	surface.MoveTo(x, y)
+	surface.TextPath(text)
+	surface.SetSourceRGBA(0, 0, 0, 0.6)
+	surface.SetLineWidth(4)
+	surface.Stroke()
+
+	surface.MoveTo(x, y)
+	surface.SetSourceRGB(1, 1, 1)
+	surface.ShowText(text)
+}
+
+// renderGreeterCard renders a PNG welcome card with the avatar, name and member number of the member
+func renderGreeterCard(greeterType models.GreeterType, member *discordgo.Member, guild *discordgo.Guild, userNumber int, backgroundName string) (data []byte, err error) {
+	surface := cairo.NewSurface(cairo.FORMAT_ARGB32, greeterCardWidth, greeterCardHeight)
+	defer surface.Finish()
+
+	// background, falls back to a plain colour if there is no background or it failed to load
+	surface.SetSourceRGB(0.06, 0.68, 0.93) // 0x0FADED
+	surface.Paint()
+	if backgroundName != "" {
+		backgroundUrl, err := getGreeterCardBackgroundUrl(backgroundName)
+		if err == nil && backgroundUrl != "" {
+			backgroundImage, err := greeterCardDownloadImage(backgroundUrl)
+			if err == nil {
+				paintGreeterCardImage(surface, backgroundImage, 0, 0, greeterCardWidth, greeterCardHeight)
+			}
+			helpers.RelaxLog(err)
+		}
+	}
+	surface.SetSourceRGBA(0, 0, 0, 0.35)
+	surface.Rectangle(0, 0, greeterCardWidth, greeterCardHeight)
+	surface.Fill()
+
+	// round avatar with a white border
+	avatarX := float64(50)
+	avatarY := float64(greeterCardHeight-greeterCardAvatarSize) / 2
+	avatarRadius := float64(greeterCardAvatarSize) / 2
+	surface.Arc(avatarX+avatarRadius, avatarY+avatarRadius, avatarRadius+6, 0, 2*math.Pi)
+	surface.SetSourceRGB(1, 1, 1)
+	surface.Fill()
+	avatarImage, err := greeterCardDownloadImage(member.User.AvatarURL("256"))
+	if err == nil {
+		surface.Save()
+		surface.Arc(avatarX+avatarRadius, avatarY+avatarRadius, avatarRadius, 0, 2*math.Pi)
+		surface.Clip()
+		paintGreeterCardImage(surface, avatarImage, avatarX, avatarY, greeterCardAvatarSize, greeterCardAvatarSize)
+		surface.Restore()
+	}
+	helpers.RelaxLog(err)
+
+	// text
+	var titleText, detailsText string
+	switch greeterType {
+	case models.GreeterTypeJoin:
+		titleText = "Welcome"
+		detailsText = fmt.Sprintf("Member #%d of %s", userNumber, guild.Name)
+	case models.GreeterTypeLeave:
+		titleText = "Goodbye"
+		detailsText = fmt.Sprintf("%s has %d members now", guild.Name, userNumber)
+	default:
+		titleText = "Banned"
+		detailsText = fmt.Sprintf("%s has %d members now", guild.Name, userNumber)
+	}
+	textX := avatarX + greeterCardAvatarSize + 50
+	textWidth := greeterCardWidth - textX - 40
+	showGreeterCardText(surface, titleText, textX, 105, textWidth, 44, cairo.FONT_WEIGHT_NORMAL)
+	showGreeterCardText(surface, member.User.Username+"#"+member.User.Discriminator, textX, 175, textWidth, 60, cairo.FONT_WEIGHT_BOLD)
+	showGreeterCardText(surface, detailsText, textX, 230, textWidth, 32, cairo.FONT_WEIGHT_NORMAL)
+
+	data, status := surface.WriteToPNGStream()
+	if status != cairo.STATUS_SUCCESS {
+		return nil, errors.New("failed to write greeter card: " + status.String())
+	}
+	return data, nil
+}
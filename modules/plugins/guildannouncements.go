@@ -1,6 +1,7 @@
 package plugins
 
 import (
+	"bytes"
 	"fmt"
 	"strconv"
 	"strings"
@@ -44,14 +45,16 @@ func (m *GuildAnnouncements) Action(command string, content string, msg *discord
 	}
 
 	switch args[0] {
-	// [p]greeter join <#channel or channel id> <embed code>
-	case "guild_join", "join":
+	// [p]greeter <join|leave|ban> <#channel or channel id> <embed code>
+	case "guild_join", "join", "guild_leave", "leave", "ban":
 		helpers.RequireAdmin(msg, func() {
 			if len(args) < 2 {
 				helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.too-few"))
 				return
 			}
 
+			greeterType, _ := m.getGreeterType(args[0])
+
 			targetChannel, err := helpers.GetChannelFromMention(msg, args[1])
 			if err != nil || targetChannel.ID == "" {
 				helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
@@ -64,177 +67,111 @@ func (m *GuildAnnouncements) Action(command string, content string, msg *discord
 				embedCode = strings.TrimSpace(strings.Replace(content, strings.Join(args[:2], " "), "", 1))
 			}
 
-			if embedCode == "" {
-				var entryBucket models.GreeterEntry
-				err = helpers.MdbOne(
-					helpers.MdbCollection(models.GreeterTable).Find(bson.M{
-						"type": models.GreeterTypeJoin, "guildid": targetChannel.GuildID, "channelid": targetChannel.ID,
-					}),
-					&entryBucket,
-				)
-				if err == nil {
-					helpers.MDbDelete(models.GreeterTable, entryBucket.Id)
-				}
+			err = GreeterSet(greeterType, targetChannel.GuildID, targetChannel.ID, msg.Author.ID, embedCode)
+			if helpers.IsInputError(err) {
+				_, err = helpers.SendMessage(msg.ChannelID, err.Error())
+				helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+				return
+			}
+			helpers.Relax(err)
 
+			if embedCode == "" {
 				_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.guildannouncements.message-disabled"))
 				helpers.Relax(err)
 				return
 			}
 
-			err = helpers.MDbUpsert(
-				models.GreeterTable,
-				bson.M{"type": models.GreeterTypeJoin, "guildid": targetChannel.GuildID, "channelid": targetChannel.ID},
-				models.GreeterEntry{
-					GuildID:   targetChannel.GuildID,
-					ChannelID: targetChannel.ID,
-					Type:      models.GreeterTypeJoin,
-					EmbedCode: embedCode,
-				},
-			)
-			helpers.Relax(err)
-
-			_, err = helpers.EventlogLog(time.Now(), targetChannel.GuildID, targetChannel.ID,
-				models.EventlogTargetTypeChannel, msg.Author.ID,
-				models.EventlogTypeRobyulGuildAnnouncementsJoinSet, "",
-				nil,
-				[]models.ElasticEventlogOption{
-					{
-						Key:   "join_text",
-						Value: embedCode,
-					},
-				}, false)
-			helpers.RelaxLog(err)
-
 			_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.guildannouncements.message-edited"))
 			helpers.Relax(err)
 		})
-		// [p]greeter leave <#channel or channel id> <embed code>
-	case "guild_leave", "leave":
+	case "card": // [p]greeter card <join|leave|ban> <#channel or channel id> [<background name>|off]
 		helpers.RequireAdmin(msg, func() {
-			if len(args) < 2 {
+			if len(args) < 3 {
 				helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.too-few"))
 				return
 			}
 
-			targetChannel, err := helpers.GetChannelFromMention(msg, args[1])
-			if err != nil || targetChannel.ID == "" {
+			greeterType, ok := m.getGreeterType(args[1])
+			if !ok {
 				helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
 				return
 			}
 
-			var embedCode string
-
-			if len(args) >= 3 {
-				embedCode = strings.TrimSpace(strings.Replace(content, strings.Join(args[:2], " "), "", 1))
+			targetChannel, err := helpers.GetChannelFromMention(msg, args[2])
+			if err != nil || targetChannel.ID == "" {
+				helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+				return
 			}
 
-			if embedCode == "" {
-				var entryBucket models.GreeterEntry
-				err = helpers.MdbOne(
-					helpers.MdbCollection(models.GreeterTable).Find(bson.M{
-						"type": models.GreeterTypeLeave, "guildid": targetChannel.GuildID, "channelid": targetChannel.ID,
-					}),
-					&entryBucket,
-				)
-				if err == nil {
-					helpers.MDbDelete(models.GreeterTable, entryBucket.Id)
+			cardEnabled := true
+			var cardBackground string
+			message := helpers.GetTextF("plugins.guildannouncements.card-enabled",
+				helpers.GetPrefixForServer(targetChannel.GuildID), args[1])
+			if len(args) >= 4 {
+				if strings.ToLower(args[3]) == "off" {
+					cardEnabled = false
+					message = helpers.GetText("plugins.guildannouncements.card-disabled")
+				} else {
+					cardBackground = strings.ToLower(args[3])
 				}
+			}
 
-				_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.guildannouncements.message-disabled"))
-				helpers.Relax(err)
+			err = GreeterSetCard(greeterType, targetChannel.GuildID, targetChannel.ID, msg.Author.ID, cardEnabled, cardBackground)
+			if helpers.IsInputError(err) {
+				_, err = helpers.SendMessage(msg.ChannelID, err.Error())
+				helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
 				return
 			}
-
-			err = helpers.MDbUpsert(
-				models.GreeterTable,
-				bson.M{"type": models.GreeterTypeLeave, "guildid": targetChannel.GuildID, "channelid": targetChannel.ID},
-				models.GreeterEntry{
-					GuildID:   targetChannel.GuildID,
-					ChannelID: targetChannel.ID,
-					Type:      models.GreeterTypeLeave,
-					EmbedCode: embedCode,
-				},
-			)
 			helpers.Relax(err)
 
-			_, err = helpers.EventlogLog(time.Now(), targetChannel.GuildID, targetChannel.ID,
-				models.EventlogTargetTypeChannel, msg.Author.ID,
-				models.EventlogTypeRobyulGuildAnnouncementsLeaveSet, "",
-				nil,
-				[]models.ElasticEventlogOption{
-					{
-						Key:   "leave_text",
-						Value: embedCode,
-					},
-				}, false)
-			helpers.RelaxLog(err)
-
-			_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.guildannouncements.message-edited"))
-			helpers.Relax(err)
+			_, err = helpers.SendMessage(msg.ChannelID, message)
+			helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
 		})
-	case "ban": // [p]greeter ban <#channel or channel id> <embed code>
-		helpers.RequireAdmin(msg, func() {
+		return
+	case "test": // [p]greeter test <join|leave|ban>
+		helpers.RequireMod(msg, func() {
 			if len(args) < 2 {
 				helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.too-few"))
 				return
 			}
 
-			targetChannel, err := helpers.GetChannelFromMention(msg, args[1])
-			if err != nil || targetChannel.ID == "" {
+			greeterType, ok := m.getGreeterType(args[1])
+			if !ok {
 				helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
 				return
 			}
 
-			var embedCode string
-
-			if len(args) >= 3 {
-				embedCode = strings.TrimSpace(strings.Replace(content, strings.Join(args[:2], " "), "", 1))
-			}
+			session.ChannelTyping(msg.ChannelID)
 
-			if embedCode == "" {
-				var entryBucket models.GreeterEntry
-				err = helpers.MdbOne(
-					helpers.MdbCollection(models.GreeterTable).Find(bson.M{
-						"type": models.GreeterTypeBan, "guildid": targetChannel.GuildID, "channelid": targetChannel.ID,
-					}),
-					&entryBucket,
-				)
-				if err == nil {
-					helpers.MDbDelete(models.GreeterTable, entryBucket.Id)
-				}
+			channel, err := helpers.GetChannel(msg.ChannelID)
+			helpers.Relax(err)
 
-				_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.guildannouncements.message-disabled"))
-				helpers.Relax(err)
-				return
-			}
+			member, err := helpers.GetGuildMember(channel.GuildID, msg.Author.ID)
+			helpers.Relax(err)
 
-			err = helpers.MDbUpsert(
-				models.GreeterTable,
-				bson.M{"type": models.GreeterTypeBan, "guildid": targetChannel.GuildID, "channelid": targetChannel.ID},
-				models.GreeterEntry{
-					GuildID:   targetChannel.GuildID,
-					ChannelID: targetChannel.ID,
-					Type:      models.GreeterTypeBan,
-					EmbedCode: embedCode,
-				},
-			)
+			var entryBucket []models.GreeterEntry
+			err = helpers.MDbIter(helpers.MdbCollection(models.GreeterTable).
+				Find(bson.M{"guildid": channel.GuildID, "type": greeterType})).All(&entryBucket)
 			helpers.Relax(err)
 
-			_, err = helpers.EventlogLog(time.Now(), targetChannel.GuildID, targetChannel.ID,
-				models.EventlogTargetTypeChannel, msg.Author.ID,
-				models.EventlogTypeRobyulGuildAnnouncementsBanSet, "",
-				nil,
-				[]models.ElasticEventlogOption{
-					{
-						Key:   "ban_text",
-						Value: embedCode,
-					},
-				}, false)
-			helpers.RelaxLog(err)
+			if len(entryBucket) <= 0 {
+				_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.guildannouncements.card-error-no-greeter"))
+				helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+				return
+			}
 
-			_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.guildannouncements.message-edited"))
-			helpers.Relax(err)
+			for _, entry := range entryBucket {
+				messageSend := m.getGreeterMessageSend(entry, member)
+				if messageSend == nil {
+					continue
+				}
+				messageSend.Content = helpers.GetTextF("plugins.guildannouncements.test-prefix", entry.ChannelID) +
+					"\n" + messageSend.Content
+				_, err = helpers.SendComplex(msg.ChannelID, messageSend)
+				helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+			}
 		})
+		return
 	case "list":
 		helpers.RequireMod(msg, func() {
 			session.ChannelTyping(msg.ChannelID)
@@ -255,15 +192,23 @@ func (m *GuildAnnouncements) Action(command string, content string, msg *discord
 			for _, greeting := range entryBucket {
 				switch greeting.Type {
 				case models.GreeterTypeJoin:
-					message += "on join in <#" + greeting.ChannelID + ">: `" + greeting.EmbedCode + "`\n"
+					message += "on join in <#" + greeting.ChannelID + ">: `" + greeting.EmbedCode + "`"
 					break
 				case models.GreeterTypeLeave:
-					message += "on leave in <#" + greeting.ChannelID + ">: `" + greeting.EmbedCode + "`\n"
+					message += "on leave in <#" + greeting.ChannelID + ">: `" + greeting.EmbedCode + "`"
 					break
 				case models.GreeterTypeBan:
-					message += "on ban in <#" + greeting.ChannelID + ">: `" + greeting.EmbedCode + "`\n"
+					message += "on ban in <#" + greeting.ChannelID + ">: `" + greeting.EmbedCode + "`"
 					break
 				}
+				if greeting.CardEnabled {
+					if greeting.CardBackground != "" {
+						message += " with card (background `" + greeting.CardBackground + "`)"
+					} else {
+						message += " with card"
+					}
+				}
+				message += "\n"
 			}
 			message += fmt.Sprintf("_found %d greeter configs in total_\n_To change a config just set a new config for the specific channel, it will replace the old config._", len(entryBucket))
 
@@ -302,17 +247,7 @@ func (m *GuildAnnouncements) OnGuildMemberAdd(member *discordgo.Member, session
 			ourSetting := guildAnnouncementSetting
 			go func() {
 				defer helpers.Recover()
-				messageSend := &discordgo.MessageSend{
-					Content: ourSetting.EmbedCode,
-				}
-				if helpers.IsEmbedCode(ourSetting.EmbedCode) {
-					ptext, embed, err := helpers.ParseEmbedCode(ourSetting.EmbedCode)
-					if err == nil {
-						messageSend.Content = ptext
-						messageSend.Embed = embed
-					}
-				}
-				messageSend = m.ReplaceMemberText(messageSend, member)
+				messageSend := m.getGreeterMessageSend(ourSetting, member)
 				if messageSend == nil {
 					return
 				}
@@ -345,18 +280,7 @@ func (m *GuildAnnouncements) OnGuildMemberRemove(member *discordgo.Member, sessi
 			ourSetting := guildAnnouncementSetting
 			go func() {
 				defer helpers.Recover()
-
-				messageSend := &discordgo.MessageSend{
-					Content: ourSetting.EmbedCode,
-				}
-				if helpers.IsEmbedCode(ourSetting.EmbedCode) {
-					ptext, embed, err := helpers.ParseEmbedCode(ourSetting.EmbedCode)
-					if err == nil {
-						messageSend.Content = ptext
-						messageSend.Embed = embed
-					}
-				}
-				messageSend = m.ReplaceMemberText(messageSend, member)
+				messageSend := m.getGreeterMessageSend(ourSetting, member)
 				if messageSend == nil {
 					return
 				}
@@ -379,15 +303,7 @@ func (m *GuildAnnouncements) ReplaceMemberText(message *discordgo.MessageSend, m
 		helpers.Relax(err)
 	}
 
-	userNumber := -1
-	if guild != nil {
-		if guild.Members != nil {
-			userNumber = len(guild.Members)
-		}
-		if guild.MemberCount > userNumber {
-			userNumber = guild.MemberCount
-		}
-	}
+	userNumber := m.getUserNumber(guild)
 
 	return helpers.ReplaceMessageSend(
 		message,
@@ -404,6 +320,227 @@ func (m *GuildAnnouncements) ReplaceMemberText(message *discordgo.MessageSend, m
 	)
 }
 
+func (m *GuildAnnouncements) getUserNumber(guild *discordgo.Guild) (userNumber int) {
+	userNumber = -1
+	if guild != nil {
+		if guild.Members != nil {
+			userNumber = len(guild.Members)
+		}
+		if guild.MemberCount > userNumber {
+			userNumber = guild.MemberCount
+		}
+	}
+	return userNumber
+}
+
+// getGreeterMessageSend builds the message for a greeter entry, including the card if enabled
+func (m *GuildAnnouncements) getGreeterMessageSend(entry models.GreeterEntry, member *discordgo.Member) *discordgo.MessageSend {
+	messageSend := &discordgo.MessageSend{
+		Content: entry.EmbedCode,
+	}
+	if helpers.IsEmbedCode(entry.EmbedCode) {
+		ptext, embed, err := helpers.ParseEmbedCode(entry.EmbedCode)
+		if err == nil {
+			messageSend.Content = ptext
+			messageSend.Embed = embed
+		}
+	}
+	messageSend = m.ReplaceMemberText(messageSend, member)
+	if messageSend == nil {
+		return nil
+	}
+
+	if entry.CardEnabled {
+		guild, err := helpers.GetGuild(member.GuildID)
+		helpers.Relax(err)
+
+		cardData, err := renderGreeterCard(entry.Type, member, guild, m.getUserNumber(guild), entry.CardBackground)
+		if err != nil {
+			helpers.RelaxLog(err)
+			return messageSend
+		}
+
+		messageSend.Files = append(messageSend.Files, &discordgo.File{
+			Name:        greeterCardFilename,
+			ContentType: "image/png",
+			Reader:      bytes.NewReader(cardData),
+		})
+		if messageSend.Embed != nil && messageSend.Embed.Image == nil {
+			messageSend.Embed.Image = &discordgo.MessageEmbedImage{URL: "attachment://" + greeterCardFilename}
+		}
+	}
+
+	return messageSend
+}
+
+func (m *GuildAnnouncements) getGreeterEntry(greeterType models.GreeterType, guildID, channelID string) (entry models.GreeterEntry, err error) {
+	err = helpers.MdbOne(
+		helpers.MdbCollection(models.GreeterTable).Find(bson.M{
+			"type": greeterType, "guildid": guildID, "channelid": channelID,
+		}),
+		&entry,
+	)
+	return entry, err
+}
+
+func (m *GuildAnnouncements) getGreeterType(text string) (greeterType models.GreeterType, ok bool) {
+	switch strings.ToLower(text) {
+	case "guild_join", "join":
+		return models.GreeterTypeJoin, true
+	case "guild_leave", "leave":
+		return models.GreeterTypeLeave, true
+	case "ban":
+		return models.GreeterTypeBan, true
+	}
+	return greeterType, false
+}
+
+// GreeterParseType returns the greeter type for join, leave or ban
+func GreeterParseType(text string) (greeterType models.GreeterType, ok bool) {
+	return (&GuildAnnouncements{}).getGreeterType(text)
+}
+
+// GreeterGet returns all greeters of the guild
+func GreeterGet(guildID string) (entries []models.GreeterEntry, err error) {
+	err = helpers.MDbIter(helpers.MdbCollection(models.GreeterTable).Find(bson.M{"guildid": guildID})).All(&entries)
+	return entries, err
+}
+
+// greeterEventlogTypes returns the eventlog types and the option key for a greeter type
+func greeterEventlogTypes(greeterType models.GreeterType) (setType, removeType, textKey string) {
+	switch greeterType {
+	case models.GreeterTypeLeave:
+		return models.EventlogTypeRobyulGuildAnnouncementsLeaveSet, models.EventlogTypeRobyulGuildAnnouncementsLeaveRemove, "leave_text"
+	case models.GreeterTypeBan:
+		return models.EventlogTypeRobyulGuildAnnouncementsBanSet, models.EventlogTypeRobyulGuildAnnouncementsBanRemove, "ban_text"
+	}
+	return models.EventlogTypeRobyulGuildAnnouncementsJoinSet, models.EventlogTypeRobyulGuildAnnouncementsJoinRemove, "join_text"
+}
+
+// GreeterSet sets the message of a greeter and logs it to the eventlog, an empty embed code removes the greeter
+func GreeterSet(greeterType models.GreeterType, guildID, channelID, userID, embedCode string) (err error) {
+	targetChannel, err := helpers.GetChannel(channelID)
+	if err != nil || targetChannel.GuildID != guildID || targetChannel.Type != discordgo.ChannelTypeGuildText {
+		return helpers.NewInputError("bot.arguments.invalid")
+	}
+
+	setType, removeType, textKey := greeterEventlogTypes(greeterType)
+	previousEntry, err := (&GuildAnnouncements{}).getGreeterEntry(greeterType, guildID, channelID)
+	if err != nil && !helpers.IsMdbNotFound(err) {
+		return err
+	}
+
+	if embedCode == "" {
+		if previousEntry.Id == "" {
+			return nil
+		}
+
+		err = helpers.MDbDelete(models.GreeterTable, previousEntry.Id)
+		if err != nil {
+			return err
+		}
+
+		_, err = helpers.EventlogLog(time.Now(), guildID, channelID,
+			models.EventlogTargetTypeChannel, userID,
+			removeType, "",
+			nil,
+			[]models.ElasticEventlogOption{
+				{
+					Key:   textKey,
+					Value: previousEntry.EmbedCode,
+				},
+			}, false)
+		helpers.RelaxLog(err)
+		return nil
+	}
+
+	if helpers.IsEmbedCode(embedCode) {
+		_, _, err = helpers.ParseEmbedCode(embedCode)
+		if err != nil {
+			return helpers.NewInputError("bot.arguments.invalid")
+		}
+	}
+
+	err = helpers.MDbUpsert(
+		models.GreeterTable,
+		bson.M{"type": greeterType, "guildid": guildID, "channelid": channelID},
+		models.GreeterEntry{
+			GuildID:        guildID,
+			ChannelID:      channelID,
+			Type:           greeterType,
+			EmbedCode:      embedCode,
+			CardEnabled:    previousEntry.CardEnabled,
+			CardBackground: previousEntry.CardBackground,
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = helpers.EventlogLog(time.Now(), guildID, channelID,
+		models.EventlogTargetTypeChannel, userID,
+		setType, "",
+		nil,
+		[]models.ElasticEventlogOption{
+			{
+				Key:   textKey,
+				Value: embedCode,
+			},
+		}, false)
+	helpers.RelaxLog(err)
+	return nil
+}
+
+// GreeterSetCard enables or disables the card of a greeter and logs it to the eventlog
+// background	: the name of the profile background of the card, the default background if empty
+func GreeterSetCard(greeterType models.GreeterType, guildID, channelID, userID string, enabled bool, background string) (err error) {
+	entry, err := (&GuildAnnouncements{}).getGreeterEntry(greeterType, guildID, channelID)
+	if helpers.IsMdbNotFound(err) {
+		return helpers.NewInputError("plugins.guildannouncements.card-error-no-greeter")
+	}
+	if err != nil {
+		return err
+	}
+
+	if !enabled {
+		background = ""
+	}
+	if background != "" {
+		_, err = getGreeterCardBackgroundUrl(background)
+		if err != nil {
+			return helpers.NewInputError("plugins.guildannouncements.card-error-background-not-found")
+		}
+	}
+
+	beforeEnabled, beforeBackground := entry.CardEnabled, entry.CardBackground
+	entry.CardEnabled = enabled
+	entry.CardBackground = background
+	err = helpers.MDbUpdate(models.GreeterTable, entry.Id, entry)
+	if err != nil {
+		return err
+	}
+
+	setType, _, _ := greeterEventlogTypes(greeterType)
+	_, err = helpers.EventlogLog(time.Now(), guildID, channelID,
+		models.EventlogTargetTypeChannel, userID,
+		setType, "",
+		[]models.ElasticEventlogChange{
+			{
+				Key:      "card_enabled",
+				OldValue: strconv.FormatBool(beforeEnabled),
+				NewValue: strconv.FormatBool(entry.CardEnabled),
+			},
+			{
+				Key:      "card_background",
+				OldValue: beforeBackground,
+				NewValue: entry.CardBackground,
+			},
+		},
+		nil, false)
+	helpers.RelaxLog(err)
+	return nil
+}
+
 func (m *GuildAnnouncements) OnGuildBanAdd(user *discordgo.GuildBanAdd, session *discordgo.Session) {
 	go func() {
 		defer helpers.Recover()
@@ -429,17 +566,7 @@ func (m *GuildAnnouncements) OnGuildBanAdd(user *discordgo.GuildBanAdd, session
 				member := new(discordgo.Member)
 				member.User = user.User
 				member.GuildID = user.GuildID
-				messageSend := &discordgo.MessageSend{
-					Content: ourSetting.EmbedCode,
-				}
-				if helpers.IsEmbedCode(ourSetting.EmbedCode) {
-					ptext, embed, err := helpers.ParseEmbedCode(ourSetting.EmbedCode)
-					if err == nil {
-						messageSend.Content = ptext
-						messageSend.Embed = embed
-					}
-				}
-				messageSend = m.ReplaceMemberText(messageSend, member)
+				messageSend := m.getGreeterMessageSend(ourSetting, member)
 				if messageSend == nil {
 					return
 				}
@@ -184,18 +184,8 @@ func (m *LastFm) generateDiscordStats() {
 				newCombinedGuildStat.GuildID = guild.ID
 				newCombinedGuildStat.NumberOfUsers = 0
 
-				members := make([]*discordgo.Member, 0)
-				for _, subShard := range cache.GetSession().Sessions {
-					for _, botGuild := range subShard.State.Guilds {
-						if botGuild.ID == guild.ID {
-							for _, member := range guild.Members {
-								members = append(members, member)
-							}
-						}
-					}
-				}
-
-				if len(members) <= 0 {
+				members, err := helpers.GetGuildMembers(guild.ID)
+				if err != nil || len(members) <= 0 {
 					continue
 				}
 				for _, member := range members {
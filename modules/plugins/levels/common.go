@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/cluster"
 	"github.com/Seklfreak/Robyul2/helpers"
 	"github.com/Seklfreak/Robyul2/metrics"
 	"github.com/Seklfreak/Robyul2/models"
@@ -140,7 +141,13 @@ func cacheTopLoop() {
 		var keyByUser string
 		var rankData Levels_Cache_Ranking_Item
 		cacheCodec := cache.GetRedisCacheCodec()
+		// every process caches the rankings of its guilds, the global ranking is the same on all processes
+		globalRankingLeader := cluster.IsLeader("levels-global-ranking")
 		for _, guildCache := range newTopCache {
+			if guildCache.GuildID == "global" && !globalRankingLeader {
+				continue
+			}
+
 			i := 0
 			for _, level := range guildCache.Levels {
 				if level.Value > 0 {
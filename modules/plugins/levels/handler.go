@@ -67,7 +67,8 @@ var (
 	// How many keys may drop at a time
 	DROP_SIZE int8 = 1
 
-	temporaryIgnoredGuilds []string
+	temporaryIgnoredGuilds     []string
+	temporaryIgnoredGuildsLock sync.RWMutex
 
 	expStack = lane.NewStack()
 )
@@ -150,6 +151,29 @@ func (m *Levels) Action(command string, content string, msg *discordgo.Message,
 		session.ChannelTyping(msg.ChannelID)
 		args := strings.Fields(content)
 
+		channel, err := helpers.GetChannel(msg.ChannelID)
+		helpers.Relax(err)
+
+		if len(args) >= 1 && channel.GuildID != "" {
+			switch args[0] {
+			case "top", "leaderboard": // [p]rep top
+				guild, err := helpers.GetGuild(channel.GuildID)
+				helpers.Relax(err)
+				m.actionRepTop(msg, guild)
+				return
+			case "history": // [p]rep history [<user id/mention>]
+				guild, err := helpers.GetGuild(channel.GuildID)
+				helpers.Relax(err)
+				m.actionRepHistory(msg, guild, args)
+				return
+			case "config": // [p]rep config [min-age <days>|min-exp <exp>|log [<channel>]]
+				guild, err := helpers.GetGuild(channel.GuildID)
+				helpers.Relax(err)
+				m.actionRepConfig(msg, guild, args)
+				return
+			}
+		}
+
 		m.lockRepUser(msg.Author.ID)
 		defer m.unlockRepUser(msg.Author.ID)
 
@@ -175,6 +199,13 @@ func (m *Levels) Action(command string, content string, msg *discordgo.Message,
 			return
 		}
 
+		// the rules and the ledger are per guild
+		if channel.GuildID == "" {
+			_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.levels.rep-error-dm"))
+			helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+			return
+		}
+
 		if time.Since(userData.LastRepped).Hours() < 12 {
 			timeUntil := time.Until(userData.LastRepped.Add(time.Hour * 12))
 			if timeUntil.Minutes() < 1 {
@@ -213,6 +244,12 @@ func (m *Levels) Action(command string, content string, msg *discordgo.Message,
 			return
 		}
 
+		if ruleMessage := m.checkRepRules(channel.GuildID, msg.Author); ruleMessage != "" {
+			_, err := helpers.SendMessage(msg.ChannelID, ruleMessage)
+			helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+			return
+		}
+
 		targetUserData, err := helpers.GetUserUserdata(targetUser.ID)
 		helpers.Relax(err)
 		targetUserData.Rep += 1
@@ -223,6 +260,10 @@ func (m *Levels) Action(command string, content string, msg *discordgo.Message,
 		err = helpers.MDbUpdate(models.ProfileUserdataTable, userData.ID, userData)
 		helpers.Relax(err)
 
+		repEntry, err := m.createRepEntry(channel.GuildID, msg.Author.ID, targetUser.ID)
+		helpers.Relax(err)
+		go m.checkReciprocalRep(repEntry)
+
 		_, err = helpers.SendMessage(msg.ChannelID,
 			helpers.GetTextF("plugins.levels.rep-success", targetUser.Username))
 		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
@@ -380,6 +421,10 @@ func (m *Levels) Action(command string, content string, msg *discordgo.Message,
 						ChannelID: msg.ChannelID,
 						UserID:    msg.Author.ID,
 					}, "levels", true)
+					if err == helpers.ErrStorageQuotaExceeded {
+						helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.errors.storage-quota-exceeded"))
+						return
+					}
 					if err != nil {
 						helpers.RelaxLog(err)
 						_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.levels.user-background-upload-failed"))
@@ -459,6 +504,10 @@ func (m *Levels) Action(command string, content string, msg *discordgo.Message,
 							ChannelID: msg.ChannelID,
 							UserID:    msg.Author.ID,
 						}, "levels", true)
+						if err == helpers.ErrStorageQuotaExceeded {
+							helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.errors.storage-quota-exceeded"))
+							return
+						}
 						if err != nil {
 							helpers.RelaxLog(err)
 							_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.levels.user-background-upload-failed"))
@@ -2243,7 +2292,8 @@ func (m *Levels) Action(command string, content string, msg *discordgo.Message,
 					_, err = helpers.SendMessage(msg.ChannelID, fmt.Sprintf("<@%s> Check your DMs.", msg.Author.ID))
 					helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
 					// pause new message processing for that guild
-					temporaryIgnoredGuilds = append(temporaryIgnoredGuilds, channel.GuildID)
+					addTemporaryIgnoredGuild(channel.GuildID)
+					defer removeTemporaryIgnoredGuild(channel.GuildID)
 					_, err = helpers.SendMessage(dmChannel.ID, fmt.Sprintf("Temporary disabled EXP Processing for `%s` while processing the Message History.", guild.Name))
 					helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
 					// reset accounts on this server
@@ -2326,14 +2376,6 @@ func (m *Levels) Action(command string, content string, msg *discordgo.Message,
 					}
 					//fmt.Println("Waiting for all channels")
 					//wg.Wait()
-					// enable new message processing again
-					var newTemporaryIgnoredGuilds []string
-					for _, temporaryIgnoredGuild := range temporaryIgnoredGuilds {
-						if temporaryIgnoredGuild != channel.GuildID {
-							newTemporaryIgnoredGuilds = append(newTemporaryIgnoredGuilds, temporaryIgnoredGuild)
-						}
-					}
-					temporaryIgnoredGuilds = newTemporaryIgnoredGuilds
 					_, err = helpers.SendMessage(dmChannel.ID, fmt.Sprintf("Enabled EXP Processing for `%s` again.", guild.Name))
 					helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
 
@@ -2349,6 +2391,110 @@ func (m *Levels) Action(command string, content string, msg *discordgo.Message,
 					return
 				})
 				return
+			case "import": // [p]levels import [level|exp] + attached JSON/CSV export
+				helpers.RequireAdmin(msg, func() {
+					if len(msg.Attachments) <= 0 {
+						_, err := helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.levels.import-help",
+							helpers.GetPrefixForServer(channel.GuildID), helpers.GetPrefixForServer(channel.GuildID)))
+						helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+						return
+					}
+
+					mode := levelsImportModeLevel
+					if len(args) >= 2 {
+						switch strings.ToLower(args[1]) {
+						case "level", "levels":
+							mode = levelsImportModeLevel
+						case "exp", "xp":
+							mode = levelsImportModeExp
+						default:
+							_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+							helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+							return
+						}
+					}
+
+					importData, err := helpers.NetGetUAWithError(msg.Attachments[0].URL, helpers.DEFAULT_UA)
+					helpers.Relax(err)
+
+					importUsers, err := parseLevelsImport(importData)
+					if err != nil {
+						_, err := helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.levels.import-error-parsing", err.Error()))
+						helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+						return
+					}
+					importUsers = rankImportedLevelsUsers(importUsers, mode)
+
+					var previewText string
+					for i, importUser := range importUsers {
+						if i >= 10 {
+							break
+						}
+						previewText += fmt.Sprintf("#%d: <@%s> Level %d (%s EXP)\n",
+							i+1, importUser.UserID, importUser.Level, humanize.Comma(importUser.Exp))
+					}
+
+					if !helpers.ConfirmEmbed(msg.GuildID, msg.ChannelID, msg.Author,
+						helpers.GetTextF("plugins.levels.import-confirm", len(importUsers), guild.Name, previewText),
+						"✅", "🚫") {
+						return
+					}
+
+					_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.levels.import-start"))
+					helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+
+					// pause new message processing for that guild
+					addTemporaryIgnoredGuild(channel.GuildID)
+					defer removeTemporaryIgnoredGuild(channel.GuildID)
+
+					var imported, skipped int
+					rolesErrors := make([]error, 0)
+					for _, importUser := range importUsers {
+						levelsServerUser, err := m.getLevelsServerUserOrCreateNew(channel.GuildID, importUser.UserID)
+						helpers.Relax(err)
+
+						// never lower the EXP of users that already earned more on Robyul
+						if levelsServerUser.Exp >= importUser.Exp {
+							skipped++
+							continue
+						}
+
+						levelsServerUser.Exp = importUser.Exp
+						err = helpers.MDbUpdateWithoutLogging(models.LevelsServerusersTable, levelsServerUser.ID, levelsServerUser)
+						helpers.Relax(err)
+						imported++
+
+						errRole := applyLevelsRoles(channel.GuildID, importUser.UserID, importUser.Level)
+						if errRole != nil {
+							rolesErrors = append(rolesErrors, errRole)
+						}
+					}
+
+					_, err = helpers.EventlogLog(time.Now(), channel.GuildID, channel.GuildID,
+						models.EventlogTargetTypeGuild, msg.Author.ID,
+						models.EventlogTypeRobyulLevelsImport, "",
+						nil,
+						[]models.ElasticEventlogOption{
+							{
+								Key:   "levels_import_file",
+								Value: msg.Attachments[0].Filename,
+							},
+							{
+								Key:   "levels_import_imported",
+								Value: strconv.Itoa(imported),
+							},
+							{
+								Key:   "levels_import_skipped",
+								Value: strconv.Itoa(skipped),
+							},
+						}, false)
+					helpers.RelaxLog(err)
+
+					_, err = helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.levels.import-result",
+						msg.Author.ID, imported, skipped, len(rolesErrors)))
+					helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+				})
+				return
 			case "role", "roles":
 				if len(args) < 2 {
 					_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.too-few"))
@@ -2407,35 +2553,19 @@ func (m *Levels) Action(command string, content string, msg *discordgo.Message,
 							}
 						}
 
-						if targetRole == nil || targetRole.ID == "" || startLevel < 0 || (lastLevel < 0 && lastLevel != -1) || (lastLevel != -1 && startLevel > lastLevel) {
+						if targetRole == nil || targetRole.ID == "" {
 							_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
 							helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
 							return
 						}
 
-						_, err = m.createLevelsRoleEntry(channel.GuildID, targetRole.ID, startLevel, lastLevel)
-						helpers.Relax(err)
-
-						options := []models.ElasticEventlogOption{
-							{
-								Key:   "role_startlevel",
-								Value: strconv.Itoa(startLevel),
-							},
-						}
-
-						if lastLevel >= 0 {
-							options = append(options, models.ElasticEventlogOption{
-								Key:   "role_lastlevel",
-								Value: strconv.Itoa(lastLevel),
-							})
+						_, err = LevelsRoleAdd(channel.GuildID, msg.Author.ID, targetRole.ID, startLevel, lastLevel)
+						if helpers.IsInputError(err) {
+							_, err = helpers.SendMessage(msg.ChannelID, err.Error())
+							helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+							return
 						}
-
-						_, err = helpers.EventlogLog(time.Now(), channel.GuildID, targetRole.ID,
-							models.EventlogTargetTypeRole, msg.Author.ID,
-							models.EventlogTypeRobyulLevelsRoleAdd, "",
-							nil,
-							options, false)
-						helpers.RelaxLog(err)
+						helpers.Relax(err)
 
 						_, err = helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.levels.levels-role-add-success", targetRole.Name))
 						helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
@@ -2567,17 +2697,12 @@ func (m *Levels) Action(command string, content string, msg *discordgo.Message,
 							return
 						}
 
-						entry, err := m.getLevelsRoleEntryByID(helpers.HumanToMdbId(args[2]))
-						if err != nil {
-							if !strings.Contains(err.Error(), "no levels role entry") {
-								helpers.Relax(err)
-							}
-							_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+						entry, err := LevelsRoleRemove(channel.GuildID, msg.Author.ID, helpers.HumanToMdbId(args[2]))
+						if helpers.IsInputError(err) {
+							_, err = helpers.SendMessage(msg.ChannelID, err.Error())
 							helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
 							return
 						}
-
-						err = m.deleteLevelsRoleEntry(entry)
 						helpers.Relax(err)
 
 						role, err := session.State.Role(channel.GuildID, entry.RoleID)
@@ -2586,27 +2711,6 @@ func (m *Levels) Action(command string, content string, msg *discordgo.Message,
 							role.Name = "N/A"
 						}
 
-						options := []models.ElasticEventlogOption{
-							{
-								Key:   "role_startlevel",
-								Value: strconv.Itoa(entry.StartLevel),
-							},
-						}
-
-						if entry.LastLevel >= 0 {
-							options = append(options, models.ElasticEventlogOption{
-								Key:   "role_lastlevel",
-								Value: strconv.Itoa(entry.LastLevel),
-							})
-						}
-
-						_, err = helpers.EventlogLog(time.Now(), channel.GuildID, entry.RoleID,
-							models.EventlogTargetTypeRole, msg.Author.ID,
-							models.EventlogTypeRobyulLevelsRoleDelete, "",
-							nil,
-							options, false)
-						helpers.RelaxLog(err)
-
 						_, err = helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.levels.levels-role-delete-success",
 							role.Name, entry.RoleID))
 						helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
@@ -3520,10 +3624,8 @@ func (m *Levels) ProcessMessage(msg *discordgo.Message, session *discordgo.Sessi
 	channel, err := helpers.GetChannel(msg.ChannelID)
 	helpers.Relax(err)
 	// ignore temporary ignored guilds
-	for _, temporaryIgnoredGuild := range temporaryIgnoredGuilds {
-		if temporaryIgnoredGuild == channel.GuildID {
-			return
-		}
+	if isTemporaryIgnoredGuild(channel.GuildID) {
+		return
 	}
 	// ignore bot messages
 	if msg.Author.Bot == true {
@@ -3968,3 +4070,34 @@ func (m *Levels) logUserBackgroundSet(targetChannelID, sourceChannelID, userID,
 
 	return nil
 }
+
+// addTemporaryIgnoredGuild pauses the message processing for the guild
+func addTemporaryIgnoredGuild(guildID string) {
+	temporaryIgnoredGuildsLock.Lock()
+	defer temporaryIgnoredGuildsLock.Unlock()
+	temporaryIgnoredGuilds = append(temporaryIgnoredGuilds, guildID)
+}
+
+// removeTemporaryIgnoredGuild enables the message processing for the guild again
+func removeTemporaryIgnoredGuild(guildID string) {
+	temporaryIgnoredGuildsLock.Lock()
+	defer temporaryIgnoredGuildsLock.Unlock()
+	var newTemporaryIgnoredGuilds []string
+	for _, temporaryIgnoredGuild := range temporaryIgnoredGuilds {
+		if temporaryIgnoredGuild != guildID {
+			newTemporaryIgnoredGuilds = append(newTemporaryIgnoredGuilds, temporaryIgnoredGuild)
+		}
+	}
+	temporaryIgnoredGuilds = newTemporaryIgnoredGuilds
+}
+
+func isTemporaryIgnoredGuild(guildID string) bool {
+	temporaryIgnoredGuildsLock.RLock()
+	defer temporaryIgnoredGuildsLock.RUnlock()
+	for _, temporaryIgnoredGuild := range temporaryIgnoredGuilds {
+		if temporaryIgnoredGuild == guildID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,240 @@
+package levels
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// importedLevelsUser is a single row of a leaderboard export from another bot
+type importedLevelsUser struct {
+	UserID string
+	Exp    int64
+	Level  int
+}
+
+type levelsImportMode int
+
+const (
+	// levelsImportModeLevel converts the imported level to Robyul EXP using GetExpForLevel
+	levelsImportModeLevel levelsImportMode = iota
+	// levelsImportModeExp uses the imported EXP as it is
+	levelsImportModeExp
+)
+
+// levelsImportMaxLevel is the highest level that can be imported, imported EXP counts towards the global ranking
+const levelsImportMaxLevel = 100
+
+var (
+	levelsImportUserIDKeys = []string{"id", "user_id", "userid", "user", "discord_id"}
+	levelsImportExpKeys    = []string{"xp", "exp", "experience", "points"}
+	levelsImportLevelKeys  = []string{"level", "lvl"}
+)
+
+// parseLevelsImport parses a JSON or CSV leaderboard export
+// supported JSON formats are a list of user objects, or an object containing a list of user objects (for example Mee6's "players")
+// CSV files have to have a header row, columns are matched by name
+func parseLevelsImport(data []byte) (users []importedLevelsUser, err error) {
+	data = bytes.TrimSpace(data)
+	if len(data) <= 0 {
+		return nil, errors.New("empty import file")
+	}
+
+	if data[0] == '[' || data[0] == '{' {
+		users, err = parseLevelsImportJSON(data)
+	} else {
+		users, err = parseLevelsImportCSV(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(users) <= 0 {
+		return nil, errors.New("no users found in import file")
+	}
+
+	return users, nil
+}
+
+func parseLevelsImportJSON(data []byte) (users []importedLevelsUser, err error) {
+	var rows []map[string]interface{}
+
+	if data[0] == '{' {
+		var container map[string]json.RawMessage
+		err = json.Unmarshal(data, &container)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range []string{"players", "users", "members", "leaderboard", "data"} {
+			if list, ok := container[key]; ok {
+				err = decodeLevelsImportJSON(list, &rows)
+				if err != nil {
+					return nil, err
+				}
+				break
+			}
+		}
+		if rows == nil {
+			return nil, errors.New("unable to find a list of users in the import file")
+		}
+	} else {
+		err = decodeLevelsImportJSON(data, &rows)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, row := range rows {
+		values := make(map[string]string)
+		for key, value := range row {
+			switch typedValue := value.(type) {
+			case string:
+				values[normalizeLevelsImportKey(key)] = typedValue
+			case json.Number:
+				values[normalizeLevelsImportKey(key)] = typedValue.String()
+			}
+		}
+
+		user, err := newImportedLevelsUser(values)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, nil
+}
+
+// decodeLevelsImportJSON decodes numbers as json.Number, numeric user IDs don't fit into a float64
+func decodeLevelsImportJSON(data []byte, rows *[]map[string]interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(rows)
+}
+
+func parseLevelsImportCSV(data []byte) (users []importedLevelsUser, err error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	for i := range header {
+		header[i] = normalizeLevelsImportKey(header[i])
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		values := make(map[string]string)
+		for i, value := range record {
+			if i < len(header) {
+				values[header[i]] = strings.TrimSpace(value)
+			}
+		}
+
+		user, err := newImportedLevelsUser(values)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, nil
+}
+
+func newImportedLevelsUser(values map[string]string) (user importedLevelsUser, err error) {
+	user.UserID = levelsImportLookup(values, levelsImportUserIDKeys)
+	if _, err = strconv.ParseUint(user.UserID, 10, 64); err != nil {
+		return user, errors.New("invalid user id in import file: " + user.UserID)
+	}
+
+	expText := levelsImportLookup(values, levelsImportExpKeys)
+	levelText := levelsImportLookup(values, levelsImportLevelKeys)
+	if expText == "" && levelText == "" {
+		return user, errors.New("no exp or level in import file for user " + user.UserID)
+	}
+
+	if expText != "" {
+		exp, err := strconv.ParseFloat(expText, 64)
+		if err != nil || exp < 0 {
+			return user, errors.New("invalid exp in import file for user " + user.UserID)
+		}
+		user.Exp = int64(exp)
+	}
+	if levelText != "" {
+		user.Level, err = strconv.Atoi(levelText)
+		if err != nil || user.Level < 0 {
+			return user, errors.New("invalid level in import file for user " + user.UserID)
+		}
+	}
+
+	return user, nil
+}
+
+func normalizeLevelsImportKey(key string) string {
+	key = strings.ToLower(strings.TrimSpace(key))
+	return strings.NewReplacer(" ", "_", "-", "_").Replace(key)
+}
+
+func levelsImportLookup(values map[string]string, keys []string) string {
+	for _, key := range keys {
+		if value, ok := values[key]; ok && value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// getImportedExp returns the Robyul EXP for an imported user, capped at the EXP of levelsImportMaxLevel
+// in level mode the level is converted using GetExpForLevel, users without a level fall back to their imported EXP
+func getImportedExp(user importedLevelsUser, mode levelsImportMode) int64 {
+	exp := user.Exp
+	if mode == levelsImportModeLevel && user.Level > 0 {
+		if user.Level > levelsImportMaxLevel {
+			user.Level = levelsImportMaxLevel
+		}
+		exp = GetExpForLevel(user.Level)
+	}
+	if maxExp := GetExpForLevel(levelsImportMaxLevel); exp > maxExp {
+		return maxExp
+	}
+	return exp
+}
+
+// rankImportedLevelsUsers merges duplicate user IDs, converts the imported values to EXP,
+// and returns the users sorted by EXP, highest first
+func rankImportedLevelsUsers(users []importedLevelsUser, mode levelsImportMode) (ranked []importedLevelsUser) {
+	indexes := make(map[string]int)
+	for _, user := range users {
+		user.Exp = getImportedExp(user, mode)
+		user.Level = GetLevelFromExp(user.Exp)
+
+		if i, ok := indexes[user.UserID]; ok {
+			if user.Exp > ranked[i].Exp {
+				ranked[i] = user
+			}
+			continue
+		}
+		indexes[user.UserID] = len(ranked)
+		ranked = append(ranked, user)
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Exp > ranked[j].Exp
+	})
+
+	return ranked
+}
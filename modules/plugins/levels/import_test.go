@@ -0,0 +1,68 @@
+package levels
+
+import "testing"
+
+func TestParseLevelsImport(t *testing.T) {
+	users, err := parseLevelsImport([]byte(`{"players": [{"id": "116620585638821891", "xp": 1500, "level": 5}, {"id": "157834823594016768", "xp": 20}]}`))
+	if err != nil || len(users) != 2 {
+		t.Fatalf("levels.parseLevelsImport() failed to parse JSON export: %v", err)
+	}
+	if users[0].UserID != "116620585638821891" || users[0].Exp != 1500 || users[0].Level != 5 {
+		t.Fatalf("levels.parseLevelsImport() parsed wrong values from JSON export")
+	}
+
+	users, err = parseLevelsImport([]byte("User ID, Level\n116620585638821891, 3\n157834823594016768, 10\n"))
+	if err != nil || len(users) != 2 {
+		t.Fatalf("levels.parseLevelsImport() failed to parse CSV export: %v", err)
+	}
+	if users[1].UserID != "157834823594016768" || users[1].Level != 10 {
+		t.Fatalf("levels.parseLevelsImport() parsed wrong values from CSV export")
+	}
+
+	users, err = parseLevelsImport([]byte(`[{"id": 116620585638821891, "xp": 1.5e3}]`))
+	if err != nil || len(users) != 1 {
+		t.Fatalf("levels.parseLevelsImport() failed to parse JSON export with numeric ids: %v", err)
+	}
+	if users[0].UserID != "116620585638821891" || users[0].Exp != 1500 {
+		t.Fatalf("levels.parseLevelsImport() failed to keep the precision of numeric ids, got %s", users[0].UserID)
+	}
+
+	_, err = parseLevelsImport([]byte(`[{"id": "not a user", "xp": 10}]`))
+	if err == nil {
+		t.Fatalf("levels.parseLevelsImport() accepted an invalid user id")
+	}
+}
+
+func TestRankImportedLevelsUsers(t *testing.T) {
+	users := []importedLevelsUser{
+		{UserID: "1", Exp: 50, Level: 2},
+		{UserID: "2", Exp: 900000, Level: 0},
+		{UserID: "1", Exp: 10, Level: 30},
+	}
+
+	ranked := rankImportedLevelsUsers(users, levelsImportModeLevel)
+	if len(ranked) != 2 {
+		t.Fatalf("levels.rankImportedLevelsUsers() failed to merge duplicate users")
+	}
+	if ranked[0].UserID != "2" || ranked[0].Exp != 900000 {
+		t.Fatalf("levels.rankImportedLevelsUsers() failed to fall back to exp for users without level")
+	}
+	if ranked[1].Exp != GetExpForLevel(30) || ranked[1].Level != 30 {
+		t.Fatalf("levels.rankImportedLevelsUsers() failed to convert level to exp")
+	}
+
+	ranked = rankImportedLevelsUsers(users, levelsImportModeExp)
+	if ranked[1].UserID != "1" || ranked[1].Exp != 50 {
+		t.Fatalf("levels.rankImportedLevelsUsers() failed to use imported exp")
+	}
+
+	ranked = rankImportedLevelsUsers([]importedLevelsUser{
+		{UserID: "1", Exp: 1 << 40, Level: 0},
+		{UserID: "2", Exp: 0, Level: 1 << 20},
+	}, levelsImportModeLevel)
+	for _, user := range ranked {
+		if user.Exp != GetExpForLevel(levelsImportMaxLevel) || user.Level != levelsImportMaxLevel {
+			t.Fatalf("levels.rankImportedLevelsUsers() failed to cap imported exp, got %d", user.Exp)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package levels
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/bwmarrin/discordgo"
+	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+const (
+	// reps in both directions within this duration are flagged to the rep log
+	repReciprocalWindow = time.Hour * 24 * 7
+	repHistoryLimit     = 10
+	repTopLimit         = 10
+)
+
+type repTopItem struct {
+	UserID string `bson:"_id"`
+	Count  int    `bson:"count"`
+}
+
+func (m *Levels) createRepEntry(guildID, giverUserID, receiverUserID string) (entry models.LevelsRepEntry, err error) {
+	entry = models.LevelsRepEntry{
+		GuildID:        guildID,
+		GiverUserID:    giverUserID,
+		ReceiverUserID: receiverUserID,
+		CreatedAt:      time.Now(),
+	}
+	entry.ID, err = helpers.MDbInsertWithoutLogging(models.LevelsRepTable, entry)
+	return entry, err
+}
+
+// checkRepRules checks the guild rules for giving rep, returns a message to show to the user if the rep is not allowed
+func (m *Levels) checkRepRules(guildID string, giver *discordgo.User) (message string) {
+	guildConfig := helpers.GuildSettingsGetCached(guildID)
+
+	if guildConfig.LevelsRepMinAccountAgeDays > 0 {
+		accountAge := time.Since(helpers.GetTimeFromSnowflake(giver.ID))
+		if accountAge < time.Hour*24*time.Duration(guildConfig.LevelsRepMinAccountAgeDays) {
+			return helpers.GetTextF("plugins.levels.rep-error-account-age", guildConfig.LevelsRepMinAccountAgeDays)
+		}
+	}
+
+	if guildConfig.LevelsRepMinExp > 0 {
+		var levelsServerUser models.LevelsServerusersEntry
+		err := helpers.MdbOne(
+			helpers.MdbCollection(models.LevelsServerusersTable).Find(bson.M{"userid": giver.ID, "guildid": guildID}),
+			&levelsServerUser,
+		)
+		if err != nil && err != mgo.ErrNotFound {
+			helpers.RelaxLog(err)
+			return ""
+		}
+		if levelsServerUser.Exp < guildConfig.LevelsRepMinExp {
+			return helpers.GetTextF("plugins.levels.rep-error-exp", guildConfig.LevelsRepMinExp)
+		}
+	}
+
+	return ""
+}
+
+// checkReciprocalRep looks for rep given in the opposite direction, and posts the pair to the rep log of the guild
+func (m *Levels) checkReciprocalRep(entry models.LevelsRepEntry) {
+	defer helpers.Recover()
+
+	if entry.GuildID == "" {
+		return
+	}
+
+	guildConfig := helpers.GuildSettingsGetCached(entry.GuildID)
+	if guildConfig.LevelsRepLogChannelID == "" {
+		return
+	}
+
+	var reciprocalEntry models.LevelsRepEntry
+	err := helpers.MdbOne(
+		helpers.MdbCollection(models.LevelsRepTable).Find(bson.M{
+			"guildid":        entry.GuildID,
+			"giveruserid":    entry.ReceiverUserID,
+			"receiveruserid": entry.GiverUserID,
+			"createdat":      bson.M{"$gte": entry.CreatedAt.Add(-repReciprocalWindow)},
+		}).Sort("-createdat"),
+		&reciprocalEntry,
+	)
+	if err == mgo.ErrNotFound {
+		return
+	}
+	helpers.Relax(err)
+
+	giver, err := helpers.GetUserWithoutAPI(entry.GiverUserID)
+	helpers.Relax(err)
+	receiver, err := helpers.GetUserWithoutAPI(entry.ReceiverUserID)
+	helpers.Relax(err)
+
+	_, err = helpers.SendEmbed(guildConfig.LevelsRepLogChannelID, &discordgo.MessageEmbed{
+		Title: "Reciprocal rep detected ⚠",
+		Color: 0xFFAA00,
+		Fields: []*discordgo.MessageEmbedField{
+			{
+				Name:   "Rep",
+				Value:  fmt.Sprintf("%s (<@%s>) ➡ %s (<@%s>)", giver.String(), giver.ID, receiver.String(), receiver.ID),
+				Inline: false,
+			},
+			{
+				Name: "Reciprocal Rep",
+				Value: fmt.Sprintf("%s (<@%s>) ➡ %s (<@%s>) %s ago", receiver.String(), receiver.ID, giver.String(), giver.ID,
+					helpers.HumanizeDuration(entry.CreatedAt.Sub(reciprocalEntry.CreatedAt))),
+				Inline: false,
+			},
+		},
+		Footer: &discordgo.MessageEmbedFooter{
+			Text: fmt.Sprintf("Giver account created %s ago, receiver account created %s ago",
+				helpers.HumanizeDuration(time.Since(helpers.GetTimeFromSnowflake(giver.ID))),
+				helpers.HumanizeDuration(time.Since(helpers.GetTimeFromSnowflake(receiver.ID)))),
+		},
+	})
+	if err != nil {
+		cache.GetLogger().WithField("module", "levels").Warnf("failed to post reciprocal rep to log channel #%s: %s",
+			guildConfig.LevelsRepLogChannelID, err.Error())
+	}
+}
+
+// [p]rep top
+func (m *Levels) actionRepTop(msg *discordgo.Message, guild *discordgo.Guild) {
+	var topItems []repTopItem
+	err := helpers.MdbCollection(models.LevelsRepTable).Pipe([]bson.M{
+		{"$match": bson.M{"guildid": guild.ID}},
+		{"$group": bson.M{"_id": "$receiveruserid", "count": bson.M{"$sum": 1}}},
+		{"$sort": bson.M{"count": -1}},
+		{"$limit": repTopLimit},
+	}).All(&topItems)
+	helpers.Relax(err)
+
+	if len(topItems) <= 0 {
+		_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.levels.rep-top-empty"))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	topRepEmbed := &discordgo.MessageEmbed{
+		Color:  0x0FADED,
+		Title:  helpers.GetTextF("plugins.levels.rep-top-embed-title", guild.Name),
+		Fields: []*discordgo.MessageEmbedField{},
+	}
+
+	for i, topItem := range topItems {
+		username := "N/A"
+		topUser, err := helpers.GetUserWithoutAPI(topItem.UserID)
+		if err == nil {
+			username = topUser.Username
+		}
+
+		topRepEmbed.Fields = append(topRepEmbed.Fields, &discordgo.MessageEmbedField{
+			Name:   fmt.Sprintf("%d. %s", i+1, username),
+			Value:  fmt.Sprintf("Rep: %d", topItem.Count),
+			Inline: false,
+		})
+	}
+
+	if guild.Icon != "" {
+		topRepEmbed.Thumbnail = &discordgo.MessageEmbedThumbnail{URL: guild.IconURL()}
+	}
+
+	_, err = helpers.SendEmbed(msg.ChannelID, topRepEmbed)
+	helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+}
+
+// [p]rep history [<user>]
+func (m *Levels) actionRepHistory(msg *discordgo.Message, guild *discordgo.Guild, args []string) {
+	targetUser := msg.Author
+	if len(args) >= 2 {
+		var err error
+		targetUser, err = helpers.GetUserFromMention(args[1])
+		if err != nil || targetUser == nil || targetUser.ID == "" {
+			_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+			helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+			return
+		}
+	}
+
+	var entries []models.LevelsRepEntry
+	err := helpers.MDbIter(helpers.MdbCollection(models.LevelsRepTable).Find(bson.M{
+		"guildid": guild.ID,
+		"$or": []bson.M{
+			{"giveruserid": targetUser.ID},
+			{"receiveruserid": targetUser.ID},
+		},
+	}).Sort("-createdat").Limit(repHistoryLimit)).All(&entries)
+	helpers.Relax(err)
+
+	if len(entries) <= 0 {
+		_, err := helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.levels.rep-history-empty", targetUser.Username))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	var historyText string
+	for _, entry := range entries {
+		if entry.GiverUserID == targetUser.ID {
+			historyText += fmt.Sprintf("➡ gave rep to <@%s>", entry.ReceiverUserID)
+		} else {
+			historyText += fmt.Sprintf("⬅ received rep from <@%s>", entry.GiverUserID)
+		}
+		historyText += fmt.Sprintf(", %s ago\n", helpers.HumanizeDuration(time.Since(entry.CreatedAt)))
+	}
+
+	_, err = helpers.SendEmbed(msg.ChannelID, &discordgo.MessageEmbed{
+		Color:       0x0FADED,
+		Title:       helpers.GetTextF("plugins.levels.rep-history-embed-title", targetUser.Username, guild.Name),
+		Description: historyText,
+	})
+	helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+}
+
+// [p]rep config [min-age <days>|min-exp <exp>|log [<channel>]]
+func (m *Levels) actionRepConfig(msg *discordgo.Message, guild *discordgo.Guild, args []string) {
+	helpers.RequireAdmin(msg, func() {
+		guildConfig := helpers.GuildSettingsGetCached(guild.ID)
+
+		if len(args) < 2 {
+			logChannelText := "None"
+			if guildConfig.LevelsRepLogChannelID != "" {
+				logChannelText = "<#" + guildConfig.LevelsRepLogChannelID + ">"
+			}
+			_, err := helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.levels.rep-config-status",
+				guildConfig.LevelsRepMinAccountAgeDays, guildConfig.LevelsRepMinExp, logChannelText))
+			helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+			return
+		}
+
+		var err error
+		switch strings.ToLower(args[1]) {
+		case "min-age":
+			var days int
+			if len(args) >= 3 {
+				days, err = strconv.Atoi(args[2])
+			}
+			if len(args) < 3 || err != nil || days < 0 {
+				_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+				helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+				return
+			}
+			guildConfig.LevelsRepMinAccountAgeDays = days
+		case "min-exp":
+			var exp int64
+			if len(args) >= 3 {
+				exp, err = strconv.ParseInt(args[2], 10, 64)
+			}
+			if len(args) < 3 || err != nil || exp < 0 {
+				_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+				helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+				return
+			}
+			guildConfig.LevelsRepMinExp = exp
+		case "log":
+			guildConfig.LevelsRepLogChannelID = ""
+			if len(args) >= 3 {
+				targetChannel, err := helpers.GetChannelFromMention(msg, args[2])
+				if err != nil || targetChannel.GuildID != guild.ID {
+					_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+					helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+					return
+				}
+				guildConfig.LevelsRepLogChannelID = targetChannel.ID
+			}
+		default:
+			_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+			helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+			return
+		}
+
+		err = helpers.GuildSettingsSet(guild.ID, guildConfig)
+		helpers.Relax(err)
+
+		_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.levels.rep-config-saved"))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+	})
+}
@@ -0,0 +1,95 @@
+package levels
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/globalsign/mgo/bson"
+)
+
+// LevelsRolesGet returns the roles tied to levels on the guild
+func LevelsRolesGet(guildID string) (entries []models.LevelsRoleEntry, err error) {
+	err = helpers.MDbIter(helpers.MdbCollection(models.LevelsRolesTable).Find(bson.M{"guildid": guildID})).All(&entries)
+	return entries, err
+}
+
+// LevelsRoleAdd ties a role to a level range on the guild, and logs it to the eventlog
+// lastLevel	: the last level members keep the role, -1 to keep it for all following levels
+func LevelsRoleAdd(guildID, userID, roleID string, startLevel, lastLevel int) (entry models.LevelsRoleEntry, err error) {
+	guild, err := helpers.GetGuild(guildID)
+	if err != nil {
+		return entry, err
+	}
+	var roleExists bool
+	for _, role := range guild.Roles {
+		if role.ID == roleID {
+			roleExists = true
+		}
+	}
+	if !roleExists || startLevel < 0 || (lastLevel < 0 && lastLevel != -1) || (lastLevel != -1 && startLevel > lastLevel) {
+		return entry, helpers.NewInputError("bot.arguments.invalid")
+	}
+
+	entry, err = (&Levels{}).createLevelsRoleEntry(guildID, roleID, startLevel, lastLevel)
+	if err != nil {
+		return entry, err
+	}
+
+	_, err = helpers.EventlogLog(time.Now(), guildID, roleID,
+		models.EventlogTargetTypeRole, userID,
+		models.EventlogTypeRobyulLevelsRoleAdd, "",
+		nil,
+		levelsRoleEventlogOptions(entry), false)
+	helpers.RelaxLog(err)
+	return entry, nil
+}
+
+// LevelsRoleRemove removes a role tied to levels from the guild, and logs it to the eventlog
+func LevelsRoleRemove(guildID, userID string, id bson.ObjectId) (entry models.LevelsRoleEntry, err error) {
+	if !id.Valid() {
+		return entry, helpers.NewInputError("bot.arguments.invalid")
+	}
+
+	err = helpers.MdbOne(
+		helpers.MdbCollection(models.LevelsRolesTable).Find(bson.M{"_id": id, "guildid": guildID}),
+		&entry,
+	)
+	if helpers.IsMdbNotFound(err) {
+		return entry, helpers.NewInputError("bot.arguments.invalid")
+	}
+	if err != nil {
+		return entry, err
+	}
+
+	err = (&Levels{}).deleteLevelsRoleEntry(entry)
+	if err != nil {
+		return entry, err
+	}
+
+	_, err = helpers.EventlogLog(time.Now(), guildID, entry.RoleID,
+		models.EventlogTargetTypeRole, userID,
+		models.EventlogTypeRobyulLevelsRoleDelete, "",
+		nil,
+		levelsRoleEventlogOptions(entry), false)
+	helpers.RelaxLog(err)
+	return entry, nil
+}
+
+func levelsRoleEventlogOptions(entry models.LevelsRoleEntry) []models.ElasticEventlogOption {
+	options := []models.ElasticEventlogOption{
+		{
+			Key:   "role_startlevel",
+			Value: strconv.Itoa(entry.StartLevel),
+		},
+	}
+
+	if entry.LastLevel >= 0 {
+		options = append(options, models.ElasticEventlogOption{
+			Key:   "role_lastlevel",
+			Value: strconv.Itoa(entry.LastLevel),
+		})
+	}
+	return options
+}
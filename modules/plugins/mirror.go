@@ -1,6 +1,7 @@
 package plugins
 
 import (
+	"bytes"
 	"fmt"
 	"strings"
 
@@ -38,6 +39,21 @@ var (
 	whitelistedBotIDs = []string{
 		"470154919463354370", // redvelvet-feed (turtles)
 	}
+
+	// source message IDs with a pending reactions update
+	mirrorReactionUpdates     = make(map[string]bool)
+	mirrorReactionUpdatesLock sync.Mutex
+)
+
+const (
+	// posted messages are remembered this long to delete or edit them
+	mirrorRememberDuration     = time.Hour * 24
+	mirrorReactionsUpdateDelay = time.Second * 5
+	mirrorMaxQuoteLength       = 100
+	// larger attachments are posted as links in full mode
+	mirrorMaxAttachmentSize = 8 * 1024 * 1024
+	// not yet in discordgo
+	mirrorMessageTypeReply discordgo.MessageType = 19
 )
 
 func (m *Mirror) Init(session *shardmanager.Manager) {
@@ -47,6 +63,9 @@ func (m *Mirror) Init(session *shardmanager.Manager) {
 
 	session.AddHandler(m.OnMessage)
 	session.AddHandler(m.OnMessageDelete)
+	session.AddHandler(m.OnMessageReactionAdd)
+	session.AddHandler(m.OnMessageReactionRemove)
+	session.AddHandler(m.OnMessageReactionRemoveAll)
 }
 
 func (m *Mirror) Uninit(session *shardmanager.Manager) {
@@ -119,6 +138,10 @@ func (m *Mirror) Action(command string, content string, msg *discordgo.Message,
 					mirrorEntry.Type = models.MirrorTypeText
 					typeText = "text"
 					break
+				case models.MirrorTypeText:
+					mirrorEntry.Type = models.MirrorTypeFull
+					typeText = "full"
+					break
 				default:
 					mirrorEntry.Type = models.MirrorTypeLink
 					typeText = "link"
@@ -149,6 +172,59 @@ func (m *Mirror) Action(command string, content string, msg *discordgo.Message,
 				return
 			})
 			return
+		case "toggle-reactions": // [p]mirror toggle-reactions <mirror id>
+			session.ChannelTyping(msg.ChannelID)
+			helpers.RequireRobyulMod(msg, func() {
+				if len(args) < 2 {
+					helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.too-few"))
+					return
+				}
+
+				channel, err := helpers.GetChannel(msg.ChannelID)
+				helpers.Relax(err)
+
+				var mirrorEntry models.MirrorEntry
+				err = helpers.MdbOne(
+					helpers.MdbCollection(models.MirrorsTable).Find(bson.M{"_id": helpers.HumanToMdbId(args[1])}),
+					&mirrorEntry,
+				)
+				if helpers.IsMdbNotFound(err) {
+					helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+					return
+				}
+				helpers.Relax(err)
+
+				beforeSummarizeReactions := mirrorEntry.SummarizeReactions
+				mirrorEntry.SummarizeReactions = !mirrorEntry.SummarizeReactions
+
+				err = helpers.MDbUpdate(models.MirrorsTable, mirrorEntry.ID, mirrorEntry)
+				helpers.Relax(err)
+
+				mirrors, err = m.GetMirrors()
+				helpers.Relax(err)
+
+				_, err = helpers.EventlogLog(time.Now(), channel.GuildID, helpers.MdbIdToHuman(mirrorEntry.ID),
+					models.EventlogTargetTypeRobyulMirror, msg.Author.ID,
+					models.EventlogTypeRobyulMirrorUpdate, "",
+					[]models.ElasticEventlogChange{
+						{
+							Key:      "mirror_summarizereactions",
+							OldValue: helpers.StoreBoolAsString(beforeSummarizeReactions),
+							NewValue: helpers.StoreBoolAsString(mirrorEntry.SummarizeReactions),
+						},
+					},
+					nil, false)
+				helpers.RelaxLog(err)
+
+				if mirrorEntry.SummarizeReactions {
+					_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.mirror.toggle-reactions-enabled"))
+				} else {
+					_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.mirror.toggle-reactions-disabled"))
+				}
+				helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+				return
+			})
+			return
 		case "add-channel": // [p]mirror add-channel <mirror id> <channel> [<webhook id> <webhook token>]
 			session.ChannelTyping(msg.ChannelID)
 			// @TODO: more secure way to exchange token: create own webhook if no arguments passed
@@ -242,6 +318,11 @@ func (m *Mirror) Action(command string, content string, msg *discordgo.Message,
 						entryTypeText = "link"
 					case models.MirrorTypeText:
 						entryTypeText = "text"
+					case models.MirrorTypeFull:
+						entryTypeText = "full"
+					}
+					if entry.SummarizeReactions {
+						entryTypeText += "`, `reactions"
 					}
 					resultMessage += fmt.Sprintf(":satellite: Mirror `%s` (Mode: `%s`, %d channels):\n",
 						helpers.MdbIdToHuman(entry.ID), entryTypeText, len(entry.ConnectedChannels))
@@ -335,82 +416,184 @@ func (m *Mirror) Action(command string, content string, msg *discordgo.Message,
 func (m *Mirror) OnMessage(session *discordgo.Session, msg *discordgo.MessageCreate) {
 	defer helpers.Recover()
 
-	// ignore bot messages except whitelisted bots
-	if msg.Author.Bot {
-		var isWhitelisted bool
-		for _, whitelistedBotID := range whitelistedBotIDs {
-			if msg.Author.ID == whitelistedBotID {
-				isWhitelisted = true
+	if !m.isAllowedAuthor(msg.Author) {
+		return
+	}
+
+	for _, mirrorEntry := range m.getMirrorsForChannel(msg.ChannelID) {
+		sourceChannel, err := helpers.GetChannel(msg.ChannelID)
+		helpers.Relax(err)
+		// ignore commands
+		prefix := helpers.GetPrefixForServer(sourceChannel.GuildID)
+		if prefix != "" {
+			if strings.HasPrefix(msg.Content, prefix) {
+				return
 			}
 		}
-		if !isWhitelisted {
-			return
+
+		contents, err := m.getMirrorMessageContents(mirrorEntry, msg.Message, sourceChannel)
+		helpers.Relax(err)
+
+		var attachments []mirrorAttachment
+		if mirrorEntry.Type == models.MirrorTypeFull {
+			attachments = m.downloadAttachments(msg.Message)
+		}
+
+		for i, content := range contents {
+			if i == len(contents)-1 {
+				m.postMirrorMessage(mirrorEntry, msg.Message, msg.Author, i, content, attachments)
+				continue
+			}
+			m.postMirrorMessage(mirrorEntry, msg.Message, msg.Author, i, content, nil)
+		}
+	}
+}
+
+// isAllowedAuthor returns false for bots, except whitelisted bots
+func (m *Mirror) isAllowedAuthor(author *discordgo.User) bool {
+	if author == nil {
+		return false
+	}
+	if !author.Bot {
+		return true
+	}
+
+	for _, whitelistedBotID := range whitelistedBotIDs {
+		if author.ID == whitelistedBotID {
+			return true
 		}
 	}
+	return false
+}
 
+func (m *Mirror) getMirrorsForChannel(channelID string) (mirrorEntries []models.MirrorEntry) {
 	for _, mirrorEntry := range mirrors {
 		for _, mirroredChannelEntry := range mirrorEntry.ConnectedChannels {
-			if mirroredChannelEntry.ChannelID == msg.ChannelID {
-				sourceChannel, err := helpers.GetChannel(msg.ChannelID)
-				helpers.Relax(err)
-				// ignore commands
-				prefix := helpers.GetPrefixForServer(sourceChannel.GuildID)
-				if prefix != "" {
-					if strings.HasPrefix(msg.Content, prefix) {
-						return
-					}
-				}
-				var linksToRepost []string
-				// get mirror attachements
-				if len(msg.Attachments) > 0 {
-					for _, attachement := range msg.Attachments {
-						linksToRepost = append(linksToRepost, attachement.URL)
-					}
-				}
-				// get mirror links
-				if strings.Contains(msg.Content, "http") {
-					linksFound := galleryUrlRegex.FindAllString(msg.Content, -1)
-					if len(linksFound) > 0 {
-						for _, linkFound := range linksFound {
-							if strings.HasPrefix(linkFound, "<") == false && strings.HasSuffix(linkFound, ">") == false {
-								linksToRepost = append(linksToRepost, linkFound)
-							}
-						}
-					}
-				}
-				// get full content message
-				newContent := msg.Content
-				if len(msg.Attachments) > 0 {
-					for _, attachement := range msg.Attachments {
-						newContent += "\n" + attachement.URL
-					}
-				}
-				switch mirrorEntry.Type {
-				case models.MirrorTypeText:
-					m.postMirrorMessage(mirrorEntry, msg.Message, msg.Author, newContent)
-					break
-				default:
-					// post mirror links
-					if len(linksToRepost) > 0 {
-						sourceGuild, err := helpers.GetGuild(sourceChannel.GuildID)
-						helpers.Relax(err)
-						for _, linkToRepost := range linksToRepost {
-							m.postMirrorMessage(mirrorEntry, msg.Message, msg.Author,
-								fmt.Sprintf("posted %s in `#%s` on the `%s` server (<#%s>)",
-									linkToRepost, sourceChannel.Name, sourceGuild.Name, sourceChannel.ID,
-								),
-							)
-						}
-					}
-					break
+			if mirroredChannelEntry.ChannelID == channelID {
+				mirrorEntries = append(mirrorEntries, mirrorEntry)
+				break
+			}
+		}
+	}
+	return mirrorEntries
+}
+
+// getMirrorMessageContents returns the content of every message posted for the source message, in order
+func (m *Mirror) getMirrorMessageContents(mirrorEntry models.MirrorEntry, msg *discordgo.Message, sourceChannel *discordgo.Channel) (contents []string, err error) {
+	var reactionsText string
+	if mirrorEntry.SummarizeReactions {
+		reactionsText = m.getReactionsSummary(msg.Reactions)
+		if reactionsText != "" {
+			reactionsText = "\n" + reactionsText
+		}
+	}
+
+	switch mirrorEntry.Type {
+	case models.MirrorTypeText, models.MirrorTypeFull:
+		// get full content message
+		newContent := m.getReplyQuote(msg) + msg.Content
+		for _, attachement := range msg.Attachments {
+			if mirrorEntry.Type == models.MirrorTypeFull && attachement.Size <= mirrorMaxAttachmentSize {
+				continue
+			}
+			newContent += "\n" + attachement.URL
+		}
+		return []string{newContent + reactionsText}, nil
+	default:
+		var linksToRepost []string
+		// get mirror attachements
+		for _, attachement := range msg.Attachments {
+			linksToRepost = append(linksToRepost, attachement.URL)
+		}
+		// get mirror links
+		if strings.Contains(msg.Content, "http") {
+			linksFound := galleryUrlRegex.FindAllString(msg.Content, -1)
+			for _, linkFound := range linksFound {
+				if strings.HasPrefix(linkFound, "<") == false && strings.HasSuffix(linkFound, ">") == false {
+					linksToRepost = append(linksToRepost, linkFound)
 				}
 			}
 		}
+		if len(linksToRepost) <= 0 {
+			return contents, nil
+		}
+
+		sourceGuild, err := helpers.GetGuild(sourceChannel.GuildID)
+		if err != nil {
+			return contents, err
+		}
+		for _, linkToRepost := range linksToRepost {
+			contents = append(contents, fmt.Sprintf("posted %s in `#%s` on the `%s` server (<#%s>)",
+				linkToRepost, sourceChannel.Name, sourceGuild.Name, sourceChannel.ID,
+			)+reactionsText)
+		}
+		return contents, nil
+	}
+}
+
+// getReplyQuote returns a quote of the message the source message replies to, or an empty string
+func (m *Mirror) getReplyQuote(msg *discordgo.Message) string {
+	if msg.Type != mirrorMessageTypeReply || msg.MessageReference == nil || msg.MessageReference.MessageID == "" {
+		return ""
+	}
+
+	referencedMessage, err := helpers.GetMessage(msg.MessageReference.ChannelID, msg.MessageReference.MessageID)
+	if err != nil || referencedMessage.Author == nil {
+		return ""
 	}
 
+	quotedContent := referencedMessage.Content
+	if quotedContent == "" && len(referencedMessage.Attachments) > 0 {
+		quotedContent = referencedMessage.Attachments[0].URL
+	}
+	quotedContent = strings.Replace(quotedContent, "\n", " ", -1)
+	if len([]rune(quotedContent)) > mirrorMaxQuoteLength {
+		quotedContent = string([]rune(quotedContent)[:mirrorMaxQuoteLength]) + "…"
+	}
+
+	return fmt.Sprintf("> **%s**: %s\n", referencedMessage.Author.Username, quotedContent)
+}
+
+func (m *Mirror) getReactionsSummary(reactions []*discordgo.MessageReactions) string {
+	reactionTexts := make([]string, 0)
+	for _, reaction := range reactions {
+		if reaction == nil || reaction.Emoji == nil || reaction.Count <= 0 {
+			continue
+		}
+		reactionTexts = append(reactionTexts, fmt.Sprintf("%s %d", reaction.Emoji.MessageFormat(), reaction.Count))
+	}
+	return strings.Join(reactionTexts, "  ")
+}
+
+type mirrorAttachment struct {
+	Name string
+	Data []byte
 }
 
-func (m *Mirror) postMirrorMessage(mirrorEntry models.MirrorEntry, sourceMessage *discordgo.Message, author *discordgo.User, message string) {
+// downloadAttachments downloads the attachments of the message to upload them to the webhooks,
+// attachments too large to upload are posted as links instead
+func (m *Mirror) downloadAttachments(msg *discordgo.Message) (attachments []mirrorAttachment) {
+	for _, attachement := range msg.Attachments {
+		if attachement.Size > mirrorMaxAttachmentSize {
+			continue
+		}
+
+		data, err := helpers.NetGetUAWithError(attachement.URL, helpers.DEFAULT_UA)
+		if err != nil {
+			cache.GetLogger().WithField("module", "mirror").Warnf("downloading attachment %s failed: %s",
+				attachement.URL, err.Error())
+			continue
+		}
+
+		attachments = append(attachments, mirrorAttachment{
+			Name: attachement.Filename,
+			Data: data,
+		})
+	}
+	return attachments
+}
+
+func (m *Mirror) postMirrorMessage(mirrorEntry models.MirrorEntry, sourceMessage *discordgo.Message, author *discordgo.User, index int, message string, attachments []mirrorAttachment) {
 	for _, channelToMirrorToEntry := range mirrorEntry.ConnectedChannels {
 		if channelToMirrorToEntry.ChannelID != sourceMessage.ChannelID {
 			robyulIsOnTargetGuild := false
@@ -426,16 +609,28 @@ func (m *Mirror) postMirrorMessage(mirrorEntry models.MirrorEntry, sourceMessage
 				if err != nil {
 					continue
 				}
-				result, err := helpers.WebhookExecuteWithResult(
+
+				files := make([]*discordgo.File, 0)
+				for _, attachment := range attachments {
+					files = append(files, &discordgo.File{
+						Name:   attachment.Name,
+						Reader: bytes.NewReader(attachment.Data),
+					})
+				}
+
+				result, err := helpers.WebhookExecuteWithFilesWithResult(
 					webhook.ID, webhook.Token,
 					&discordgo.WebhookParams{
 						Content:   message,
 						Username:  author.Username,
 						AvatarURL: helpers.GetAvatarUrl(author),
-					})
-				helpers.RelaxLog(err)
+					}, files)
+				if err != nil {
+					helpers.RelaxLog(err)
+					continue
+				}
 				metrics.MirrorsPostsSent.Add(1)
-				err = m.rememberPostedMessage(sourceMessage, result)
+				err = m.rememberPostedMessage(mirrorEntry.ID, sourceMessage, result, index, webhook)
 				helpers.RelaxLog(err)
 			}
 		}
@@ -450,19 +645,28 @@ func (m *Mirror) GetMirrors() (entryBucket []models.MirrorEntry, err error) {
 type Mirror_PostedMessage struct {
 	ChannelID string
 	MessageID string
+	// the position of the message in the messages posted for the source message
+	Index        int
+	WebhookID    string
+	WebhookToken string
 }
 
-func (m *Mirror) getRememberedMessageKey(sourceMessageID string) (key string) {
-	return fmt.Sprintf("robyul2-discord:mirror:postedmessage:%s", sourceMessageID)
+// getRememberedMessageKey returns the key of the messages posted by a mirror for a source message,
+// a channel can be part of multiple mirrors, each of them has their own indexes
+func (m *Mirror) getRememberedMessageKey(mirrorID bson.ObjectId, sourceMessageID string) (key string) {
+	return fmt.Sprintf("robyul2-discord:mirror:postedmessage:%s:%s", mirrorID.Hex(), sourceMessageID)
 }
 
-func (m *Mirror) rememberPostedMessage(sourceMessage *discordgo.Message, mirroredMessage *discordgo.Message) error {
+func (m *Mirror) rememberPostedMessage(mirrorID bson.ObjectId, sourceMessage *discordgo.Message, mirroredMessage *discordgo.Message, index int, webhook *discordgo.Webhook) error {
 	redis := cache.GetRedisClient()
-	key := m.getRememberedMessageKey(sourceMessage.ID)
+	key := m.getRememberedMessageKey(mirrorID, sourceMessage.ID)
 
 	item := new(Mirror_PostedMessage)
 	item.ChannelID = mirroredMessage.ChannelID
 	item.MessageID = mirroredMessage.ID
+	item.Index = index
+	item.WebhookID = webhook.ID
+	item.WebhookToken = webhook.Token
 
 	itemBytes, err := msgpack.Marshal(&item)
 	if err != nil {
@@ -474,13 +678,23 @@ func (m *Mirror) rememberPostedMessage(sourceMessage *discordgo.Message, mirrore
 		return err
 	}
 
-	_, err = redis.Expire(key, time.Hour*1).Result()
+	_, err = redis.Expire(key, mirrorRememberDuration).Result()
 	return err
 }
 
-func (m *Mirror) getRememberedMessages(sourceMessage *discordgo.Message) ([]Mirror_PostedMessage, error) {
+// forgetPostedMessage removes a message that got deleted from the messages posted for the source message
+func (m *Mirror) forgetPostedMessage(mirrorID bson.ObjectId, sourceMessageID string, item Mirror_PostedMessage) error {
+	itemBytes, err := msgpack.Marshal(&item)
+	if err != nil {
+		return err
+	}
+
+	return cache.GetRedisClient().LRem(m.getRememberedMessageKey(mirrorID, sourceMessageID), 0, itemBytes).Err()
+}
+
+func (m *Mirror) getRememberedMessages(mirrorID bson.ObjectId, sourceMessage *discordgo.Message) ([]Mirror_PostedMessage, error) {
 	redis := cache.GetRedisClient()
-	key := m.getRememberedMessageKey(sourceMessage.ID)
+	key := m.getRememberedMessageKey(mirrorID, sourceMessage.ID)
 
 	length, err := redis.LLen(key).Result()
 	if err != nil {
@@ -518,7 +732,7 @@ func (m *Mirror) OnMessageDelete(session *discordgo.Session, msg *discordgo.Mess
 	for _, mirror := range mirrors {
 		for _, mirrorChannel := range mirror.ConnectedChannels {
 			if mirrorChannel.ChannelID == msg.ChannelID {
-				rememberedMessages, err = m.getRememberedMessages(msg.Message)
+				rememberedMessages, err = m.getRememberedMessages(mirror.ID, msg.Message)
 				helpers.Relax(err)
 
 				for _, messageData := range rememberedMessages {
@@ -540,3 +754,161 @@ func (m *Mirror) OnMessageDelete(session *discordgo.Session, msg *discordgo.Mess
 		}
 	}
 }
+
+func (m *Mirror) OnMessageUpdate(msg *discordgo.MessageUpdate, session *discordgo.Session) {
+	defer helpers.Recover()
+
+	// updates without author are embeds added by discord
+	if msg.Author == nil || msg.EditedTimestamp == "" || !m.isAllowedAuthor(msg.Author) {
+		return
+	}
+
+	for _, mirrorEntry := range m.getMirrorsForChannel(msg.ChannelID) {
+		m.updateMirroredMessages(mirrorEntry, msg.Message)
+	}
+}
+
+func (m *Mirror) OnMessageReactionAdd(session *discordgo.Session, reaction *discordgo.MessageReactionAdd) {
+	defer helpers.Recover()
+
+	m.queueReactionsUpdate(reaction.ChannelID, reaction.MessageID)
+}
+
+func (m *Mirror) OnMessageReactionRemove(session *discordgo.Session, reaction *discordgo.MessageReactionRemove) {
+	defer helpers.Recover()
+
+	m.queueReactionsUpdate(reaction.ChannelID, reaction.MessageID)
+}
+
+func (m *Mirror) OnMessageReactionRemoveAll(session *discordgo.Session, reaction *discordgo.MessageReactionRemoveAll) {
+	defer helpers.Recover()
+
+	m.queueReactionsUpdate(reaction.ChannelID, reaction.MessageID)
+}
+
+// queueReactionsUpdate updates the reactions summary of the mirrored messages after a delay,
+// to update the messages only once for multiple reactions in a short time
+func (m *Mirror) queueReactionsUpdate(channelID, messageID string) {
+	var mirrorEntries []models.MirrorEntry
+	for _, mirrorEntry := range m.getMirrorsForChannel(channelID) {
+		if mirrorEntry.SummarizeReactions {
+			mirrorEntries = append(mirrorEntries, mirrorEntry)
+		}
+	}
+	if len(mirrorEntries) <= 0 {
+		return
+	}
+
+	mirrorReactionUpdatesLock.Lock()
+	defer mirrorReactionUpdatesLock.Unlock()
+	if mirrorReactionUpdates[messageID] {
+		return
+	}
+	mirrorReactionUpdates[messageID] = true
+
+	time.AfterFunc(mirrorReactionsUpdateDelay, func() {
+		defer helpers.Recover()
+
+		mirrorReactionUpdatesLock.Lock()
+		delete(mirrorReactionUpdates, messageID)
+		mirrorReactionUpdatesLock.Unlock()
+
+		var rememberedMirrorEntries []models.MirrorEntry
+		for _, mirrorEntry := range mirrorEntries {
+			length, err := cache.GetRedisClient().LLen(m.getRememberedMessageKey(mirrorEntry.ID, messageID)).Result()
+			helpers.Relax(err)
+			if length > 0 {
+				rememberedMirrorEntries = append(rememberedMirrorEntries, mirrorEntry)
+			}
+		}
+		if len(rememberedMirrorEntries) <= 0 {
+			return
+		}
+
+		// get the message from discord to get the current reactions
+		sourceMessage, err := cache.GetSession().Session(0).ChannelMessage(channelID, messageID)
+		if err != nil {
+			return
+		}
+
+		for _, mirrorEntry := range rememberedMirrorEntries {
+			m.updateMirroredMessages(mirrorEntry, sourceMessage)
+		}
+	})
+}
+
+// updateMirroredMessages edits the messages posted for the source message, messages no longer needed are deleted,
+// parts the source message has grown by are posted to every channel the message was mirrored to
+func (m *Mirror) updateMirroredMessages(mirrorEntry models.MirrorEntry, sourceMessage *discordgo.Message) {
+	rememberedMessages, err := m.getRememberedMessages(mirrorEntry.ID, sourceMessage)
+	helpers.Relax(err)
+	if len(rememberedMessages) <= 0 {
+		return
+	}
+
+	sourceChannel, err := helpers.GetChannel(sourceMessage.ChannelID)
+	helpers.Relax(err)
+
+	contents, err := m.getMirrorMessageContents(mirrorEntry, sourceMessage, sourceChannel)
+	helpers.Relax(err)
+
+	// channel ID => the indexes posted to that channel
+	postedIndexes := make(map[string]map[int]bool)
+	webhooks := make(map[string]*discordgo.Webhook)
+	for _, messageData := range rememberedMessages {
+		// messages posted before edits were supported
+		if messageData.WebhookID == "" || messageData.WebhookToken == "" {
+			continue
+		}
+
+		if messageData.Index >= len(contents) {
+			err = cache.GetSession().Session(0).ChannelMessageDelete(messageData.ChannelID, messageData.MessageID)
+			if err == nil {
+				err = m.forgetPostedMessage(mirrorEntry.ID, sourceMessage.ID, messageData)
+			}
+		} else {
+			if postedIndexes[messageData.ChannelID] == nil {
+				postedIndexes[messageData.ChannelID] = make(map[int]bool)
+				webhooks[messageData.ChannelID] = &discordgo.Webhook{ID: messageData.WebhookID, Token: messageData.WebhookToken}
+			}
+			postedIndexes[messageData.ChannelID][messageData.Index] = true
+
+			_, err = helpers.WebhookMessageEdit(messageData.WebhookID, messageData.WebhookToken,
+				messageData.MessageID, contents[messageData.Index])
+		}
+		if err != nil {
+			cache.GetLogger().WithFields(logrus.Fields{
+				"module":            "mirror",
+				"sourceChannelID":   sourceMessage.ChannelID,
+				"sourceMessageID":   sourceMessage.ID,
+				"mirroredChannelID": messageData.ChannelID,
+				"mirroredMessageID": messageData.MessageID,
+			}).Warn(
+				"Updating mirrored message failed:", err.Error(),
+			)
+		}
+	}
+
+	// post the parts the message has grown by
+	for channelID, indexes := range postedIndexes {
+		for index, content := range contents {
+			if indexes[index] {
+				continue
+			}
+
+			result, err := helpers.WebhookExecuteWithResult(webhooks[channelID].ID, webhooks[channelID].Token,
+				&discordgo.WebhookParams{
+					Content:   content,
+					Username:  sourceMessage.Author.Username,
+					AvatarURL: helpers.GetAvatarUrl(sourceMessage.Author),
+				})
+			if err != nil {
+				helpers.RelaxLog(err)
+				break
+			}
+			metrics.MirrorsPostsSent.Add(1)
+			err = m.rememberPostedMessage(mirrorEntry.ID, sourceMessage, result, index, webhooks[channelID])
+			helpers.RelaxLog(err)
+		}
+	}
+}
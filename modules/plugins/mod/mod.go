@@ -667,10 +667,6 @@ func (m *Mod) Action(command string, content string, msg *discordgo.Message, ses
 
 				var row string
 				for i, guild := range helpers.AllGuilds() {
-					users := make(map[string]string)
-					for _, u := range guild.Members {
-						users[u.User.ID] = u.User.Username
-					}
 					owner, err := helpers.GetUser(guild.OwnerID)
 					if err != nil || owner == nil {
 						owner = new(discordgo.User)
@@ -681,7 +677,7 @@ func (m *Mod) Action(command string, content string, msg *discordgo.Message, ses
 
 					xlsx.SetCellValue(sheetname, "A"+row, guild.Name)
 					xlsx.SetCellValue(sheetname, "B"+row, "#"+guild.ID)
-					xlsx.SetCellValue(sheetname, "C"+row, len(users))
+					xlsx.SetCellValue(sheetname, "C"+row, guild.MemberCount)
 					xlsx.SetCellValue(sheetname, "D"+row, len(guild.Channels))
 					xlsx.SetCellValue(sheetname, "E"+row, guild.Region)
 					xlsx.SetCellValue(sheetname, "F"+row, "@"+owner.Username)
@@ -708,16 +704,12 @@ func (m *Mod) Action(command string, content string, msg *discordgo.Message, ses
 			resultText := ""
 			totalMembers := 0
 			totalChannels := 0
+			// members of large guilds are not kept in the state, the member count is sent by discord
 			for _, guild := range helpers.AllGuilds() {
-				users := make(map[string]string)
-				for _, u := range guild.Members {
-					users[u.User.ID] = u.User.Username
-				}
-
 				resultText += fmt.Sprintf("`%s` (`#%s`): Channels `%d`, Members: `%d`, Region: `%s`\n",
-					guild.Name, guild.ID, len(guild.Channels), len(users), guild.Region)
+					guild.Name, guild.ID, len(guild.Channels), guild.MemberCount, guild.Region)
 				totalChannels += len(guild.Channels)
-				totalMembers += len(users)
+				totalMembers += guild.MemberCount
 			}
 			resultText += fmt.Sprintf("Total Stats: Servers `%d`, Channels: `%d`, Members: `%d`", len(helpers.AllGuilds()), totalChannels, totalMembers)
 
@@ -1479,14 +1471,8 @@ func (m *Mod) Action(command string, content string, msg *discordgo.Message, ses
 				usersMatched := make([]*discordgo.User, 0)
 				for _, serverGuild := range helpers.AllGuilds() {
 					if serverGuild.ID == currentChannel.GuildID {
-						members := make([]*discordgo.Member, 0)
-						for _, botGuild := range session.State.Guilds {
-							if botGuild.ID == serverGuild.ID {
-								for _, member := range botGuild.Members {
-									members = append(members, member)
-								}
-							}
-						}
+						members, err := helpers.GetGuildMembers(serverGuild.ID)
+						helpers.Relax(err)
 
 						for _, serverMember := range members {
 							fullUserNameToSearch := serverMember.User.Username + "#" + serverMember.User.Discriminator + " ~ " + serverMember.Nick + " ~ " + serverMember.User.ID
@@ -17,6 +17,19 @@ type modulePermissionsAction func(args []string, in *discordgo.Message, out **di
 
 type ModulePermissions struct{}
 
+var (
+	modulePermissionsEventlogTypes = map[string]string{
+		"allow_channel_added":   models.EventlogTypeRobyulModuleAllowChannelAdd,
+		"allow_channel_removed": models.EventlogTypeRobyulModuleAllowChannelRemove,
+		"allow_role_added":      models.EventlogTypeRobyulModuleAllowRoleAdd,
+		"allow_role_removed":    models.EventlogTypeRobyulModuleAllowRoleRemove,
+		"deny_channel_added":    models.EventlogTypeRobyulModuleDenyChannelAdd,
+		"deny_channel_removed":  models.EventlogTypeRobyulModuleDenyChannelRemove,
+		"deny_role_added":       models.EventlogTypeRobyulModuleDenyRoleAdd,
+		"deny_role_removed":     models.EventlogTypeRobyulModuleDenyRoleRemove,
+	}
+)
+
 func (mp *ModulePermissions) Commands() []string {
 	return []string{
 		"module",
@@ -193,6 +206,15 @@ func (mp *ModulePermissions) actionStatus(args []string, in *discordgo.Message,
 }
 
 func (mp *ModulePermissions) actionAllow(args []string, in *discordgo.Message, out **discordgo.MessageSend) modulePermissionsAction {
+	return mp.actionSet(args, in, out, false)
+}
+
+func (mp *ModulePermissions) actionDeny(args []string, in *discordgo.Message, out **discordgo.MessageSend) modulePermissionsAction {
+	return mp.actionSet(args, in, out, true)
+}
+
+// [p]module <allow|deny> <module> <#channel or role>
+func (mp *ModulePermissions) actionSet(args []string, in *discordgo.Message, out **discordgo.MessageSend, deny bool) modulePermissionsAction {
 	if !helpers.IsMod(in) {
 		*out = mp.newMsg("mod.no_permission")
 		return mp.actionFinish
@@ -203,19 +225,9 @@ func (mp *ModulePermissions) actionAllow(args []string, in *discordgo.Message, o
 		return mp.actionFinish
 	}
 
-	var permToAdd models.ModulePermissionsModule
-	if "all" == strings.ToLower(args[1]) {
-		permToAdd = helpers.ModulePermAll | helpers.ModulePermAllPlaceholder
-	}
-	for _, module := range helpers.Modules {
-		for _, moduleName := range module.Names {
-			if strings.ToLower(moduleName) == strings.ToLower(args[1]) {
-				permToAdd = module.Permission
-			}
-		}
-	}
-	if permToAdd == 0 {
-		*out = mp.newMsg("plugins.modulepermissions.module-not-found")
+	permToAdd, err := ModulePermissionParseModule(args[1])
+	if helpers.IsInputError(err) {
+		*out = mp.newMsg(err.Error())
 		return mp.actionFinish
 	}
 
@@ -224,247 +236,172 @@ func (mp *ModulePermissions) actionAllow(args []string, in *discordgo.Message, o
 	guild, err := helpers.GetGuild(channel.GuildID)
 	helpers.Relax(err)
 
+	var targetType, targetID string
 	targetChannel, err := helpers.GetChannelOfAnyTypeFromMention(in, args[2])
 	if err == nil && targetChannel != nil && targetChannel.ID != "" {
-		previousPerms := helpers.GetAllowedForChannel(targetChannel.GuildID, targetChannel.ID)
-		if previousPerms&permToAdd == permToAdd {
-			err = helpers.SetAllowedForChannel(
-				targetChannel.GuildID, targetChannel.ID, (previousPerms&^permToAdd)&^helpers.ModulePermAllPlaceholder)
-			helpers.Relax(err)
-
-			_, err = helpers.EventlogLog(time.Now(), channel.GuildID, targetChannel.ID,
-				models.EventlogTargetTypeChannel, in.Author.ID,
-				models.EventlogTypeRobyulModuleAllowChannelRemove, "",
-				nil,
-				[]models.ElasticEventlogOption{
-					{
-						Key:   "module_allow_channel_removed",
-						Value: helpers.GetModuleNameById(permToAdd),
-					},
-				}, false)
-			helpers.RelaxLog(err)
-
-			*out = mp.newMsg("plugins.modulepermissions.set-allow-removed")
-			return mp.actionFinish
+		targetType = "channel"
+		targetID = targetChannel.ID
+	} else {
+		for _, guildRole := range guild.Roles {
+			if guildRole.ID == args[2] ||
+				strings.ToLower(guildRole.Name) == strings.ToLower(args[2]) ||
+				(guildRole.ID == guild.ID && strings.ToLower(args[2]) == "everyone") {
+				targetType = "role"
+				targetID = guildRole.ID
+			}
 		}
-
-		err = helpers.SetAllowedForChannel(
-			targetChannel.GuildID, targetChannel.ID, previousPerms|permToAdd)
-		helpers.Relax(err)
-
-		_, err = helpers.EventlogLog(time.Now(), channel.GuildID, targetChannel.ID,
-			models.EventlogTargetTypeChannel, in.Author.ID,
-			models.EventlogTypeRobyulModuleAllowChannelAdd, "",
-			nil,
-			[]models.ElasticEventlogOption{
-				{
-					Key:   "module_allow_channel_added",
-					Value: helpers.GetModuleNameById(permToAdd),
-				},
-			}, false)
-		helpers.RelaxLog(err)
-
-		*out = mp.newMsg("plugins.modulepermissions.set-allow-added")
-		return mp.actionFinish
 	}
-
-	var targetRole *discordgo.Role
-	for _, guildRole := range guild.Roles {
-		if guildRole.ID == args[2] ||
-			strings.ToLower(guildRole.Name) == strings.ToLower(args[2]) ||
-			(guildRole.ID == guild.ID && strings.ToLower(args[2]) == "everyone") {
-			targetRole = guildRole
-		}
+	if targetID == "" {
+		*out = mp.newMsg("bot.arguments.invalid")
+		return mp.actionFinish
 	}
-	if targetRole != nil && targetRole.ID != "" {
-		previousPerms := helpers.GetAllowedForRole(guild.ID, targetRole.ID)
-		if previousPerms&permToAdd == permToAdd {
-			err = helpers.SetAllowedForRole(
-				guild.ID, targetRole.ID, (previousPerms&^permToAdd)&^helpers.ModulePermAllPlaceholder)
-			helpers.Relax(err)
-
-			_, err = helpers.EventlogLog(time.Now(), channel.GuildID, targetRole.ID,
-				models.EventlogTargetTypeRole, in.Author.ID,
-				models.EventlogTypeRobyulModuleAllowRoleRemove, "",
-				nil,
-				[]models.ElasticEventlogOption{
-					{
-						Key:   "module_allow_role_removed",
-						Value: helpers.GetModuleNameById(permToAdd),
-					},
-				}, false)
-			helpers.RelaxLog(err)
-
-			*out = mp.newMsg("plugins.modulepermissions.set-allow-removed")
-			return mp.actionFinish
-		}
 
-		err = helpers.SetAllowedForRole(
-			guild.ID, targetRole.ID, previousPerms|permToAdd)
-		helpers.Relax(err)
-
-		_, err = helpers.EventlogLog(time.Now(), channel.GuildID, targetRole.ID,
-			models.EventlogTargetTypeRole, in.Author.ID,
-			models.EventlogTypeRobyulModuleAllowRoleAdd, "",
-			nil,
-			[]models.ElasticEventlogOption{
-				{
-					Key:   "module_allow_role_added",
-					Value: helpers.GetModuleNameById(permToAdd),
-				},
-			}, false)
-		helpers.RelaxLog(err)
+	// the module is removed from the list if it's already on it
+	enabled := modulePermissionsGet(guild.ID, targetType, targetID, deny)&permToAdd != permToAdd
 
-		*out = mp.newMsg("plugins.modulepermissions.set-allow-added")
+	err = ModulePermissionSet(guild.ID, in.Author.ID, targetType, targetID, permToAdd, deny, enabled)
+	if helpers.IsInputError(err) {
+		*out = mp.newMsg(err.Error())
 		return mp.actionFinish
 	}
+	helpers.Relax(err)
 
-	*out = mp.newMsg("bot.arguments.invalid")
+	switch {
+	case !deny && enabled:
+		*out = mp.newMsg("plugins.modulepermissions.set-allow-added")
+	case !deny:
+		*out = mp.newMsg("plugins.modulepermissions.set-allow-removed")
+	case enabled:
+		*out = mp.newMsg("plugins.modulepermissions.set-deny-added")
+	default:
+		*out = mp.newMsg("plugins.modulepermissions.set-deny-removed")
+	}
 	return mp.actionFinish
 }
 
-func (mp *ModulePermissions) actionDeny(args []string, in *discordgo.Message, out **discordgo.MessageSend) modulePermissionsAction {
-	if !helpers.IsMod(in) {
-		*out = mp.newMsg("mod.no_permission")
-		return mp.actionFinish
-	}
+func (mp *ModulePermissions) actionFinish(args []string, in *discordgo.Message, out **discordgo.MessageSend) modulePermissionsAction {
+	_, err := helpers.SendComplex(in.ChannelID, *out)
+	helpers.RelaxMessage(err, in.ChannelID, in.ID)
 
-	if len(args) < 3 {
-		*out = mp.newMsg("bot.arguments.too-few")
-		return mp.actionFinish
-	}
+	return nil
+}
 
-	var permToAdd models.ModulePermissionsModule
-	if "all" == strings.ToLower(args[1]) {
-		permToAdd = helpers.ModulePermAll | helpers.ModulePermAllPlaceholder
+func (mp *ModulePermissions) newMsg(content string) *discordgo.MessageSend {
+	return &discordgo.MessageSend{Content: helpers.GetText(content)}
+}
+
+func (mp *ModulePermissions) logger() *logrus.Entry {
+	return cache.GetLogger().WithField("module", "modulepermissions")
+}
+
+// ModulePermissionParseModule returns the module with the name, or all modules for all
+func ModulePermissionParseModule(name string) (module models.ModulePermissionsModule, err error) {
+	if "all" == strings.ToLower(name) {
+		module = helpers.ModulePermAll | helpers.ModulePermAllPlaceholder
 	}
-	for _, module := range helpers.Modules {
-		for _, moduleName := range module.Names {
-			if strings.ToLower(moduleName) == strings.ToLower(args[1]) {
-				permToAdd = module.Permission
+	for _, moduleInfo := range helpers.Modules {
+		for _, moduleName := range moduleInfo.Names {
+			if strings.ToLower(moduleName) == strings.ToLower(name) {
+				module = moduleInfo.Permission
 			}
 		}
 	}
-	if permToAdd == 0 {
-		*out = mp.newMsg("plugins.modulepermissions.module-not-found")
-		return mp.actionFinish
+	if module == 0 {
+		return module, helpers.NewInputError("plugins.modulepermissions.module-not-found")
 	}
+	return module, nil
+}
 
-	channel, err := helpers.GetChannel(in.ChannelID)
-	helpers.Relax(err)
-	guild, err := helpers.GetGuild(channel.GuildID)
-	helpers.Relax(err)
-
-	targetChannel, err := helpers.GetChannelOfAnyTypeFromMention(in, args[2])
-	if err == nil && targetChannel != nil && targetChannel.ID != "" {
-		previousPerms := helpers.GetDeniedForChannel(targetChannel.GuildID, targetChannel.ID)
-		if previousPerms&permToAdd == permToAdd {
-			err = helpers.SetDeniedForChannel(
-				targetChannel.GuildID, targetChannel.ID, (previousPerms&^permToAdd)&^helpers.ModulePermAllPlaceholder)
-			helpers.Relax(err)
-
-			_, err = helpers.EventlogLog(time.Now(), channel.GuildID, targetChannel.ID,
-				models.EventlogTargetTypeChannel, in.Author.ID,
-				models.EventlogTypeRobyulModuleDenyChannelRemove, "",
-				nil,
-				[]models.ElasticEventlogOption{
-					{
-						Key:   "module_deny_channel_removed",
-						Value: helpers.GetModuleNameById(permToAdd),
-					},
-				}, false)
-			helpers.RelaxLog(err)
-
-			*out = mp.newMsg("plugins.modulepermissions.set-deny-removed")
-			return mp.actionFinish
-		}
+func modulePermissionsGet(guildID, targetType, targetID string, deny bool) models.ModulePermissionsModule {
+	switch {
+	case targetType == "channel" && deny:
+		return helpers.GetDeniedForChannel(guildID, targetID)
+	case targetType == "channel":
+		return helpers.GetAllowedForChannel(guildID, targetID)
+	case deny:
+		return helpers.GetDeniedForRole(guildID, targetID)
+	}
+	return helpers.GetAllowedForRole(guildID, targetID)
+}
 
-		err = helpers.SetDeniedForChannel(
-			targetChannel.GuildID, targetChannel.ID, previousPerms|permToAdd)
-		helpers.Relax(err)
-
-		_, err = helpers.EventlogLog(time.Now(), channel.GuildID, targetChannel.ID,
-			models.EventlogTargetTypeChannel, in.Author.ID,
-			models.EventlogTypeRobyulModuleDenyChannelAdd, "",
-			nil,
-			[]models.ElasticEventlogOption{
-				{
-					Key:   "module_deny_channel_added",
-					Value: helpers.GetModuleNameById(permToAdd),
-				},
-			}, false)
-		helpers.RelaxLog(err)
+func modulePermissionsSet(guildID, targetType, targetID string, deny bool, permissions models.ModulePermissionsModule) error {
+	switch {
+	case targetType == "channel" && deny:
+		return helpers.SetDeniedForChannel(guildID, targetID, permissions)
+	case targetType == "channel":
+		return helpers.SetAllowedForChannel(guildID, targetID, permissions)
+	case deny:
+		return helpers.SetDeniedForRole(guildID, targetID, permissions)
+	}
+	return helpers.SetAllowedForRole(guildID, targetID, permissions)
+}
 
-		*out = mp.newMsg("plugins.modulepermissions.set-deny-added")
-		return mp.actionFinish
+// ModulePermissionSet adds a module to or removes it from the allowed or denied modules of a channel or role,
+// and logs it to the eventlog
+// targetType	: channel or role
+// deny			: changes the denied modules instead of the allowed modules
+// enabled		: adds the module if true, removes it if false
+func ModulePermissionSet(guildID, userID, targetType, targetID string, module models.ModulePermissionsModule, deny, enabled bool) (err error) {
+	if module == 0 {
+		return helpers.NewInputError("plugins.modulepermissions.module-not-found")
 	}
 
-	var targetRole *discordgo.Role
-	for _, guildRole := range guild.Roles {
-		if guildRole.ID == args[2] ||
-			strings.ToLower(guildRole.Name) == strings.ToLower(args[2]) ||
-			(guildRole.ID == guild.ID && strings.ToLower(args[2]) == "everyone") {
-			targetRole = guildRole
+	eventlogTargetType := models.EventlogTargetTypeChannel
+	switch targetType {
+	case "channel":
+		targetChannel, err := helpers.GetChannel(targetID)
+		if err != nil || targetChannel.GuildID != guildID {
+			return helpers.NewInputError("bot.arguments.invalid")
 		}
-	}
-	if targetRole != nil && targetRole.ID != "" {
-		previousPerms := helpers.GetDeniedForRole(guild.ID, targetRole.ID)
-		if previousPerms&permToAdd == permToAdd {
-			err = helpers.SetDeniedForRole(
-				guild.ID, targetRole.ID, (previousPerms&^permToAdd)&^helpers.ModulePermAllPlaceholder)
-			helpers.Relax(err)
-
-			_, err = helpers.EventlogLog(time.Now(), channel.GuildID, targetRole.ID,
-				models.EventlogTargetTypeRole, in.Author.ID,
-				models.EventlogTypeRobyulModuleDenyRoleRemove, "",
-				nil,
-				[]models.ElasticEventlogOption{
-					{
-						Key:   "module_deny_role_removed",
-						Value: helpers.GetModuleNameById(permToAdd),
-					},
-				}, false)
-			helpers.RelaxLog(err)
-
-			*out = mp.newMsg("plugins.modulepermissions.set-deny-removed")
-			return mp.actionFinish
+	case "role":
+		guild, err := helpers.GetGuild(guildID)
+		if err != nil {
+			return err
 		}
-
-		err = helpers.SetDeniedForRole(
-			guild.ID, targetRole.ID, previousPerms|permToAdd)
-		helpers.Relax(err)
-
-		_, err = helpers.EventlogLog(time.Now(), channel.GuildID, targetRole.ID,
-			models.EventlogTargetTypeRole, in.Author.ID,
-			models.EventlogTypeRobyulModuleDenyRoleAdd, "",
-			nil,
-			[]models.ElasticEventlogOption{
-				{
-					Key:   "module_deny_role_added",
-					Value: helpers.GetModuleNameById(permToAdd),
-				},
-			}, false)
-		helpers.RelaxLog(err)
-
-		*out = mp.newMsg("plugins.modulepermissions.set-deny-added")
-		return mp.actionFinish
+		var roleExists bool
+		for _, role := range guild.Roles {
+			if role.ID == targetID {
+				roleExists = true
+			}
+		}
+		if !roleExists {
+			return helpers.NewInputError("bot.arguments.invalid")
+		}
+		eventlogTargetType = models.EventlogTargetTypeRole
+	default:
+		return helpers.NewInputError("bot.arguments.invalid")
 	}
 
-	*out = mp.newMsg("bot.arguments.invalid")
-	return mp.actionFinish
-}
+	previousPerms := modulePermissionsGet(guildID, targetType, targetID, deny)
+	if enabled {
+		err = modulePermissionsSet(guildID, targetType, targetID, deny, previousPerms|module)
+	} else {
+		err = modulePermissionsSet(guildID, targetType, targetID, deny, (previousPerms&^module)&^helpers.ModulePermAllPlaceholder)
+	}
+	if err != nil {
+		return err
+	}
 
-func (mp *ModulePermissions) actionFinish(args []string, in *discordgo.Message, out **discordgo.MessageSend) modulePermissionsAction {
-	_, err := helpers.SendComplex(in.ChannelID, *out)
-	helpers.RelaxMessage(err, in.ChannelID, in.ID)
+	change := "allow_" + targetType
+	if deny {
+		change = "deny_" + targetType
+	}
+	if enabled {
+		change += "_added"
+	} else {
+		change += "_removed"
+	}
 
+	_, err = helpers.EventlogLog(time.Now(), guildID, targetID,
+		eventlogTargetType, userID,
+		modulePermissionsEventlogTypes[change], "",
+		nil,
+		[]models.ElasticEventlogOption{
+			{
+				Key:   "module_" + change,
+				Value: helpers.GetModuleNameById(module),
+			},
+		}, false)
+	helpers.RelaxLog(err)
 	return nil
 }
-
-func (mp *ModulePermissions) newMsg(content string) *discordgo.MessageSend {
-	return &discordgo.MessageSend{Content: helpers.GetText(content)}
-}
-
-func (mp *ModulePermissions) logger() *logrus.Entry {
-	return cache.GetLogger().WithField("module", "modulepermissions")
-}
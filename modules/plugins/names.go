@@ -45,8 +45,6 @@ func (n *Names) Init(session *shardmanager.Manager) {
 	previousUsernames = make(map[string]string, 0)
 	previousUsernamesMutex.Unlock()
 	session.AddHandler(n.OnGuildMemberListChunk)
-	session.AddHandler(n.OnPresenceUpdate)
-	session.AddHandler(n.OnGuildMemberUpdate)
 }
 
 func (n *Names) Action(command string, content string, msg *discordgo.Message, session *discordgo.Session) {
@@ -151,7 +149,7 @@ func (n *Names) actionNames(args []string, in *discordgo.Message, out **discordg
 	return nil
 }
 
-func (n *Names) OnPresenceUpdate(session *discordgo.Session, presence *discordgo.PresenceUpdate) {
+func (n *Names) OnPresenceUpdate(presence *discordgo.PresenceUpdate, session *discordgo.Session) {
 	if presence.GuildID == "" || presence.User == nil || presence.User.ID == "" {
 		return
 	}
@@ -165,7 +163,7 @@ func (n *Names) OnPresenceUpdate(session *discordgo.Session, presence *discordgo
 	}()
 }
 
-func (n *Names) OnGuildMemberUpdate(session *discordgo.Session, member *discordgo.GuildMemberUpdate) {
+func (n *Names) OnGuildMemberUpdate(member *discordgo.GuildMemberUpdate, session *discordgo.Session) {
 	if member.Member == nil {
 		return
 	}
@@ -29,7 +29,6 @@ func (p *Persistency) Commands() []string {
 
 func (p *Persistency) Init(session *shardmanager.Manager) {
 	session.AddHandler(p.OnGuildMemberListChunk)
-	session.AddHandler(p.OnGuildMemberUpdate)
 }
 
 func (p *Persistency) Uninit(session *shardmanager.Manager) {
@@ -381,7 +380,7 @@ func (p *Persistency) OnGuildMemberListChunk(session *discordgo.Session, members
 	}
 }
 
-func (p *Persistency) OnGuildMemberUpdate(session *discordgo.Session, member *discordgo.GuildMemberUpdate) {
+func (p *Persistency) OnGuildMemberUpdate(member *discordgo.GuildMemberUpdate, session *discordgo.Session) {
 	go func() {
 		defer helpers.Recover()
 
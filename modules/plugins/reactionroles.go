@@ -0,0 +1,835 @@
+package plugins
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/Seklfreak/Robyul2/shardmanager"
+	"github.com/bwmarrin/discordgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+type ReactionRoles struct{}
+
+const (
+	reactionRolesMaxOptions = 20
+)
+
+var (
+	// message ID => menu ID
+	reactionRolesMenusCache     = make(map[string]bson.ObjectId)
+	reactionRolesMenusCacheLock sync.RWMutex
+	// reactions removed by the bot itself that should not remove a role again
+	reactionRolesIgnoredRemovals     = make(map[string]bool)
+	reactionRolesIgnoredRemovalsLock sync.Mutex
+	reactionRolesUserLocks           = make(map[string]*reactionRolesUserLock)
+	reactionRolesUserLocksLock       sync.Mutex
+	// roles with these permissions can't be given by a menu
+	reactionRolesDangerousPermissions = discordgo.PermissionAdministrator | discordgo.PermissionManageServer | discordgo.PermissionManageRoles
+)
+
+// reactionRolesUserLock is removed from reactionRolesUserLocks once no reaction of the user is handled anymore
+type reactionRolesUserLock struct {
+	sync.Mutex
+	users int
+}
+
+func (rr *ReactionRoles) Commands() []string {
+	return []string{
+		"reactionroles",
+		"reactionrole",
+	}
+}
+
+func (rr *ReactionRoles) Init(session *shardmanager.Manager) {
+	err := rr.refreshMenusCache()
+	helpers.Relax(err)
+}
+
+func (rr *ReactionRoles) Uninit(session *shardmanager.Manager) {
+
+}
+
+func (rr *ReactionRoles) Action(command string, content string, msg *discordgo.Message, session *discordgo.Session) {
+	if !helpers.ModuleIsAllowed(msg.ChannelID, msg.ID, msg.Author.ID, helpers.ModulePermReactionRoles) {
+		return
+	}
+
+	args, err := helpers.ToArgv(content)
+	helpers.Relax(err)
+
+	if len(args) <= 0 {
+		_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.too-few"))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	switch args[0] {
+	case "create", "add": // [p]reactionroles create <#channel> "<title>" [mode=toggle|unique|verify] [max=<n>] [required=<role>,…] <emoji> <role> [<emoji> <role> …]
+		helpers.RequireAdmin(msg, func() {
+			rr.actionCreate(args, msg, session)
+		})
+		return
+	case "edit", "update": // [p]reactionroles edit <menu id> <title|mode|max|required|add|remove> …
+		helpers.RequireAdmin(msg, func() {
+			rr.actionEdit(args, msg, session)
+		})
+		return
+	case "delete", "remove": // [p]reactionroles delete <menu id>
+		helpers.RequireAdmin(msg, func() {
+			rr.actionDelete(args, msg, session)
+		})
+		return
+	case "list": // [p]reactionroles list
+		helpers.RequireMod(msg, func() {
+			rr.actionList(msg, session)
+		})
+		return
+	case "refresh": // [p]reactionroles refresh
+		helpers.RequireBotAdmin(msg, func() {
+			session.ChannelTyping(msg.ChannelID)
+			err := rr.refreshMenusCache()
+			helpers.Relax(err)
+			_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.reactionroles.refreshed-menus"))
+			helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		})
+		return
+	}
+
+	_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+	helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+}
+
+func (rr *ReactionRoles) actionCreate(args []string, msg *discordgo.Message, session *discordgo.Session) {
+	session.ChannelTyping(msg.ChannelID)
+
+	if len(args) < 5 {
+		_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.too-few"))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	sourceChannel, err := helpers.GetChannel(msg.ChannelID)
+	helpers.Relax(err)
+
+	targetChannel, err := helpers.GetChannelFromMention(msg, args[1])
+	if err != nil || targetChannel.GuildID != sourceChannel.GuildID {
+		_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	guild, err := helpers.GetGuild(targetChannel.GuildID)
+	helpers.Relax(err)
+
+	entry := models.ReactionRolesEntry{
+		GuildID:         guild.ID,
+		ChannelID:       targetChannel.ID,
+		CreatedByUserID: msg.Author.ID,
+		CreatedAt:       time.Now(),
+		Title:           args[2],
+		Mode:            models.ReactionRolesModeToggle,
+	}
+
+	optionArgs := make([]string, 0)
+	for _, arg := range args[3:] {
+		if !strings.Contains(arg, "=") {
+			optionArgs = append(optionArgs, arg)
+			continue
+		}
+		errText := rr.applySetting(&entry, guild, arg)
+		if errText != "" {
+			_, err := helpers.SendMessage(msg.ChannelID, errText)
+			helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+			return
+		}
+	}
+
+	if len(optionArgs) <= 0 || len(optionArgs)%2 != 0 {
+		_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.reactionroles.create-error-options"))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+	for i := 0; i < len(optionArgs); i += 2 {
+		errText := rr.addOption(&entry, guild, session, msg.Author.ID, optionArgs[i], optionArgs[i+1])
+		if errText != "" {
+			_, err := helpers.SendMessage(msg.ChannelID, errText)
+			helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+			return
+		}
+	}
+
+	menuMessages, err := helpers.SendEmbed(targetChannel.ID, &discordgo.MessageEmbed{
+		Color:       0x0FADED,
+		Description: "**Menu is being created...** :construction_site:",
+	})
+	helpers.RelaxEmbed(err, msg.ChannelID, msg.ID)
+	if len(menuMessages) <= 0 {
+		helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.errors.generic-nomessage"))
+		return
+	}
+	entry.MessageID = menuMessages[0].ID
+
+	entry.ID, err = helpers.MDbInsert(models.ReactionRolesTable, entry)
+	helpers.Relax(err)
+
+	err = rr.updateMenuMessage(entry, session)
+	helpers.Relax(err)
+
+	err = rr.refreshMenusCache()
+	helpers.Relax(err)
+
+	_, err = helpers.EventlogLog(time.Now(), guild.ID, helpers.MdbIdToHuman(entry.ID),
+		models.EventlogTargetTypeRobyulReactionRolesMenu, msg.Author.ID,
+		models.EventlogTypeRobyulReactionRolesCreate, "",
+		nil,
+		[]models.ElasticEventlogOption{
+			{
+				Key:   "reactionroles_channelid",
+				Value: entry.ChannelID,
+				Type:  models.EventlogTargetTypeChannel,
+			},
+			{
+				Key:   "reactionroles_title",
+				Value: entry.Title,
+			},
+			{
+				Key:   "reactionroles_mode",
+				Value: entry.Mode,
+			},
+		}, false)
+	helpers.RelaxLog(err)
+
+	_, err = helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.reactionroles.create-success",
+		helpers.MdbIdToHuman(entry.ID), entry.ChannelID))
+	helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+}
+
+func (rr *ReactionRoles) actionEdit(args []string, msg *discordgo.Message, session *discordgo.Session) {
+	session.ChannelTyping(msg.ChannelID)
+
+	if len(args) < 3 {
+		_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.too-few"))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	channel, err := helpers.GetChannel(msg.ChannelID)
+	helpers.Relax(err)
+	guild, err := helpers.GetGuild(channel.GuildID)
+	helpers.Relax(err)
+
+	entry, err := rr.getMenuByID(args[1], guild.ID)
+	if err != nil {
+		_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.reactionroles.menu-not-found"))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	var errText string
+	switch strings.ToLower(args[2]) {
+	case "title":
+		if len(args) < 4 {
+			errText = helpers.GetText("bot.arguments.too-few")
+			break
+		}
+		entry.Title = args[3]
+	case "mode", "max":
+		if len(args) < 4 {
+			errText = helpers.GetText("bot.arguments.too-few")
+			break
+		}
+		errText = rr.applySetting(&entry, guild, strings.ToLower(args[2])+"="+args[3])
+	case "required":
+		errText = rr.applySetting(&entry, guild, "required="+strings.Join(args[3:], ","))
+	case "add":
+		if len(args) < 5 {
+			errText = helpers.GetText("bot.arguments.too-few")
+			break
+		}
+		errText = rr.addOption(&entry, guild, session, msg.Author.ID, args[3], args[4])
+	case "remove", "delete":
+		if len(args) < 4 {
+			errText = helpers.GetText("bot.arguments.too-few")
+			break
+		}
+		emoji := rr.emojiAPIName(args[3])
+		newOptions := make([]models.ReactionRolesOption, 0)
+		for _, option := range entry.Options {
+			if option.Emoji != emoji {
+				newOptions = append(newOptions, option)
+			}
+		}
+		if len(newOptions) == len(entry.Options) {
+			errText = helpers.GetText("plugins.reactionroles.option-not-found")
+			break
+		}
+		if len(newOptions) <= 0 {
+			errText = helpers.GetText("plugins.reactionroles.edit-error-last-option")
+			break
+		}
+		entry.Options = newOptions
+		err = session.MessageReactionRemove(entry.ChannelID, entry.MessageID, emoji, session.State.User.ID)
+		helpers.RelaxLog(err)
+	default:
+		errText = helpers.GetText("bot.arguments.invalid")
+	}
+	if errText != "" {
+		_, err := helpers.SendMessage(msg.ChannelID, errText)
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	err = helpers.MDbUpdate(models.ReactionRolesTable, entry.ID, entry)
+	helpers.Relax(err)
+
+	err = rr.updateMenuMessage(entry, session)
+	helpers.Relax(err)
+
+	_, err = helpers.EventlogLog(time.Now(), guild.ID, helpers.MdbIdToHuman(entry.ID),
+		models.EventlogTargetTypeRobyulReactionRolesMenu, msg.Author.ID,
+		models.EventlogTypeRobyulReactionRolesUpdate, "",
+		nil,
+		[]models.ElasticEventlogOption{
+			{
+				Key:   "reactionroles_edit",
+				Value: strings.Join(args[2:], " "),
+			},
+		}, false)
+	helpers.RelaxLog(err)
+
+	_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.reactionroles.edit-success"))
+	helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+}
+
+func (rr *ReactionRoles) actionDelete(args []string, msg *discordgo.Message, session *discordgo.Session) {
+	session.ChannelTyping(msg.ChannelID)
+
+	if len(args) < 2 {
+		_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.too-few"))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	channel, err := helpers.GetChannel(msg.ChannelID)
+	helpers.Relax(err)
+
+	entry, err := rr.getMenuByID(args[1], channel.GuildID)
+	if err != nil {
+		_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.reactionroles.menu-not-found"))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	err = helpers.MDbDelete(models.ReactionRolesTable, entry.ID)
+	helpers.Relax(err)
+
+	err = rr.refreshMenusCache()
+	helpers.Relax(err)
+
+	err = session.ChannelMessageDelete(entry.ChannelID, entry.MessageID)
+	helpers.RelaxLog(err)
+
+	_, err = helpers.EventlogLog(time.Now(), channel.GuildID, helpers.MdbIdToHuman(entry.ID),
+		models.EventlogTargetTypeRobyulReactionRolesMenu, msg.Author.ID,
+		models.EventlogTypeRobyulReactionRolesDelete, "",
+		nil,
+		[]models.ElasticEventlogOption{
+			{
+				Key:   "reactionroles_channelid",
+				Value: entry.ChannelID,
+				Type:  models.EventlogTargetTypeChannel,
+			},
+			{
+				Key:   "reactionroles_title",
+				Value: entry.Title,
+			},
+		}, false)
+	helpers.RelaxLog(err)
+
+	_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.reactionroles.delete-success"))
+	helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+}
+
+func (rr *ReactionRoles) actionList(msg *discordgo.Message, session *discordgo.Session) {
+	session.ChannelTyping(msg.ChannelID)
+
+	channel, err := helpers.GetChannel(msg.ChannelID)
+	helpers.Relax(err)
+
+	var entries []models.ReactionRolesEntry
+	err = helpers.MDbIter(helpers.MdbCollection(models.ReactionRolesTable).Find(bson.M{"guildid": channel.GuildID})).All(&entries)
+	helpers.Relax(err)
+
+	if len(entries) <= 0 {
+		_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.reactionroles.list-empty"))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	listText := ":performing_arts: Reaction role menus on this server:\n"
+	for _, entry := range entries {
+		listText += fmt.Sprintf("`%s`: **%s** in <#%s>, mode `%s`, %d role(s)\n",
+			helpers.MdbIdToHuman(entry.ID), entry.Title, entry.ChannelID, entry.Mode, len(entry.Options))
+	}
+	listText += fmt.Sprintf("Found **%d** Menus in total.", len(entries))
+
+	_, err = helpers.SendMessage(msg.ChannelID, listText)
+	helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+}
+
+// applySetting applies a key=value setting to a menu, returns a message for the user if the setting is invalid
+func (rr *ReactionRoles) applySetting(entry *models.ReactionRolesEntry, guild *discordgo.Guild, setting string) (errText string) {
+	parts := strings.SplitN(setting, "=", 2)
+	key := strings.ToLower(strings.TrimSpace(parts[0]))
+	value := strings.TrimSpace(parts[1])
+
+	switch key {
+	case "mode":
+		switch strings.ToLower(value) {
+		case models.ReactionRolesModeToggle, "normal":
+			entry.Mode = models.ReactionRolesModeToggle
+		case models.ReactionRolesModeUnique:
+			entry.Mode = models.ReactionRolesModeUnique
+		case models.ReactionRolesModeVerify:
+			entry.Mode = models.ReactionRolesModeVerify
+		default:
+			return helpers.GetText("plugins.reactionroles.error-invalid-mode")
+		}
+	case "max":
+		maxRoles, err := strconv.Atoi(value)
+		if err != nil || maxRoles < 0 {
+			return helpers.GetText("bot.arguments.invalid")
+		}
+		entry.MaxRoles = maxRoles
+	case "required":
+		entry.RequiredRoleIDs = make([]string, 0)
+		for _, roleText := range strings.Split(value, ",") {
+			if strings.TrimSpace(roleText) == "" {
+				continue
+			}
+			role := rr.findRole(guild, roleText)
+			if role == nil {
+				return helpers.GetTextF("plugins.reactionroles.error-role-not-found", roleText)
+			}
+			entry.RequiredRoleIDs = append(entry.RequiredRoleIDs, role.ID)
+		}
+	default:
+		return helpers.GetText("bot.arguments.invalid")
+	}
+
+	return ""
+}
+
+// addOption adds an emoji and role pair to a menu, returns a message for the user if the option is invalid
+func (rr *ReactionRoles) addOption(entry *models.ReactionRolesEntry, guild *discordgo.Guild, session *discordgo.Session, userID, emojiText, roleText string) (errText string) {
+	if len(entry.Options) >= reactionRolesMaxOptions {
+		return helpers.GetText("plugins.reactionroles.error-too-many-options")
+	}
+
+	emoji := rr.emojiAPIName(emojiText)
+	emojiParts := strings.Split(emoji, ":")
+	if len(emojiParts) >= 2 {
+		_, err := session.State.Emoji(guild.ID, emojiParts[1])
+		if err != nil {
+			return helpers.GetText("plugins.reactionroles.error-external-emoji")
+		}
+	}
+
+	role := rr.findRole(guild, roleText)
+	if role == nil {
+		return helpers.GetTextF("plugins.reactionroles.error-role-not-found", roleText)
+	}
+	if errText = rr.checkOptionRole(guild, session, userID, role); errText != "" {
+		return errText
+	}
+
+	for _, option := range entry.Options {
+		if option.Emoji == emoji || option.RoleID == role.ID {
+			return helpers.GetText("plugins.reactionroles.error-duplicate-option")
+		}
+	}
+
+	entry.Options = append(entry.Options, models.ReactionRolesOption{
+		Emoji:     emoji,
+		EmojiText: emojiText,
+		RoleID:    role.ID,
+	})
+	return ""
+}
+
+// checkOptionRole returns a message for the user if the role can't be given by a menu
+// managed roles, roles with dangerous permissions, and roles not below the highest role of the user and the bot are refused
+func (rr *ReactionRoles) checkOptionRole(guild *discordgo.Guild, session *discordgo.Session, userID string, role *discordgo.Role) (errText string) {
+	if role.Managed || role.ID == guild.ID {
+		return helpers.GetText("plugins.reactionroles.error-role-managed")
+	}
+	if role.Permissions&reactionRolesDangerousPermissions != 0 {
+		return helpers.GetText("plugins.reactionroles.error-role-permissions")
+	}
+
+	botPosition, err := rr.highestRolePosition(guild, session.State.User.ID)
+	if err != nil || role.Position >= botPosition {
+		return helpers.GetText("plugins.reactionroles.error-role-above-bot")
+	}
+	if guild.OwnerID == userID {
+		return ""
+	}
+	userPosition, err := rr.highestRolePosition(guild, userID)
+	if err != nil || role.Position >= userPosition {
+		return helpers.GetText("plugins.reactionroles.error-role-above-user")
+	}
+	return ""
+}
+
+// highestRolePosition returns the position of the highest role of the member on the guild
+func (rr *ReactionRoles) highestRolePosition(guild *discordgo.Guild, userID string) (position int, err error) {
+	member, err := helpers.GetGuildMember(guild.ID, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, role := range guild.Roles {
+		if role.Position > position && helpers.SliceContains(member.Roles, role.ID) {
+			position = role.Position
+		}
+	}
+	return position, nil
+}
+
+// emojiAPIName converts <:name:id> and <a:name:id> to name:id, unicode emoji stay the same
+func (rr *ReactionRoles) emojiAPIName(emojiText string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(emojiText, "<a:"), "<:"), ">")
+}
+
+// findRole finds a role on the guild by mention, ID or name
+func (rr *ReactionRoles) findRole(guild *discordgo.Guild, roleText string) *discordgo.Role {
+	roleText = strings.TrimSpace(roleText)
+	roleID := strings.TrimSuffix(strings.TrimPrefix(roleText, "<@&"), ">")
+
+	for _, role := range guild.Roles {
+		if role.ID == roleID {
+			return role
+		}
+	}
+	for _, role := range guild.Roles {
+		if strings.ToLower(role.Name) == strings.ToLower(roleText) {
+			return role
+		}
+	}
+	return nil
+}
+
+func (rr *ReactionRoles) getMenuEmbed(entry models.ReactionRolesEntry) *discordgo.MessageEmbed {
+	var description string
+	for _, option := range entry.Options {
+		description += fmt.Sprintf("%s <@&%s>\n", option.EmojiText, option.RoleID)
+	}
+	if len(entry.RequiredRoleIDs) > 0 {
+		requiredRoles := make([]string, 0)
+		for _, roleID := range entry.RequiredRoleIDs {
+			requiredRoles = append(requiredRoles, "<@&"+roleID+">")
+		}
+		description += "\nRequires one of: " + strings.Join(requiredRoles, ", ")
+	}
+
+	footerText := fmt.Sprintf("Mode: %s", entry.Mode)
+	if entry.MaxRoles > 0 && entry.Mode != models.ReactionRolesModeUnique {
+		footerText += fmt.Sprintf(" | Max %d role(s)", entry.MaxRoles)
+	}
+	footerText += " | Menu #" + helpers.MdbIdToHuman(entry.ID)
+
+	return &discordgo.MessageEmbed{
+		Color:       0x0FADED,
+		Title:       entry.Title,
+		Description: description,
+		Footer:      &discordgo.MessageEmbedFooter{Text: footerText},
+	}
+}
+
+// updateMenuMessage updates the menu embed and makes sure all reactions are present
+func (rr *ReactionRoles) updateMenuMessage(entry models.ReactionRolesEntry, session *discordgo.Session) (err error) {
+	_, err = helpers.EditEmbed(entry.ChannelID, entry.MessageID, rr.getMenuEmbed(entry))
+	if err != nil {
+		return err
+	}
+
+	for _, option := range entry.Options {
+		err = session.MessageReactionAdd(entry.ChannelID, entry.MessageID, option.Emoji)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (rr *ReactionRoles) getMenuByID(id string, guildID string) (entry models.ReactionRolesEntry, err error) {
+	err = helpers.MdbOne(
+		helpers.MdbCollection(models.ReactionRolesTable).Find(bson.M{"_id": helpers.HumanToMdbId(id), "guildid": guildID}),
+		&entry,
+	)
+	return entry, err
+}
+
+func (rr *ReactionRoles) refreshMenusCache() (err error) {
+	var entries []models.ReactionRolesEntry
+	err = helpers.MDbIter(
+		helpers.MdbCollection(models.ReactionRolesTable).
+			Find(nil).
+			Select(bson.M{"_id": 1, "messageid": 1}),
+	).All(&entries)
+	if err != nil {
+		return err
+	}
+
+	newCache := make(map[string]bson.ObjectId)
+	for _, entry := range entries {
+		newCache[entry.MessageID] = entry.ID
+	}
+
+	reactionRolesMenusCacheLock.Lock()
+	reactionRolesMenusCache = newCache
+	reactionRolesMenusCacheLock.Unlock()
+	return nil
+}
+
+// getMenuForMessage returns the menu for the message, if the message is a menu
+func (rr *ReactionRoles) getMenuForMessage(messageID string) (entry models.ReactionRolesEntry, isMenu bool) {
+	reactionRolesMenusCacheLock.RLock()
+	menuID, ok := reactionRolesMenusCache[messageID]
+	reactionRolesMenusCacheLock.RUnlock()
+	if !ok {
+		return entry, false
+	}
+
+	err := helpers.MdbOneWithoutLogging(
+		helpers.MdbCollection(models.ReactionRolesTable).Find(bson.M{"_id": menuID}),
+		&entry,
+	)
+	if err != nil {
+		helpers.RelaxLog(err)
+		return entry, false
+	}
+	return entry, true
+}
+
+// removeReaction removes the reaction of an user without removing the role in OnReactionRemove
+func (rr *ReactionRoles) removeReaction(session *discordgo.Session, channelID, messageID, emoji, userID string) {
+	reactionRolesIgnoredRemovalsLock.Lock()
+	reactionRolesIgnoredRemovals[messageID+emoji+userID] = true
+	reactionRolesIgnoredRemovalsLock.Unlock()
+
+	err := session.MessageReactionRemove(channelID, messageID, emoji, userID)
+	if err != nil {
+		reactionRolesIgnoredRemovalsLock.Lock()
+		delete(reactionRolesIgnoredRemovals, messageID+emoji+userID)
+		reactionRolesIgnoredRemovalsLock.Unlock()
+	}
+}
+
+func (rr *ReactionRoles) isIgnoredRemoval(messageID, emoji, userID string) bool {
+	reactionRolesIgnoredRemovalsLock.Lock()
+	defer reactionRolesIgnoredRemovalsLock.Unlock()
+
+	if _, ok := reactionRolesIgnoredRemovals[messageID+emoji+userID]; ok {
+		delete(reactionRolesIgnoredRemovals, messageID+emoji+userID)
+		return true
+	}
+	return false
+}
+
+func (rr *ReactionRoles) lockUser(guildID, userID string) {
+	reactionRolesUserLocksLock.Lock()
+	lock, ok := reactionRolesUserLocks[guildID+userID]
+	if !ok {
+		lock = new(reactionRolesUserLock)
+		reactionRolesUserLocks[guildID+userID] = lock
+	}
+	lock.users++
+	reactionRolesUserLocksLock.Unlock()
+
+	lock.Lock()
+}
+
+func (rr *ReactionRoles) unlockUser(guildID, userID string) {
+	reactionRolesUserLocksLock.Lock()
+	lock, ok := reactionRolesUserLocks[guildID+userID]
+	if ok {
+		lock.users--
+		if lock.users <= 0 {
+			delete(reactionRolesUserLocks, guildID+userID)
+		}
+	}
+	reactionRolesUserLocksLock.Unlock()
+
+	if ok {
+		lock.Unlock()
+	}
+}
+
+func (rr *ReactionRoles) OnReactionAdd(reaction *discordgo.MessageReactionAdd, session *discordgo.Session) {
+	// skip reactions by the bot
+	if reaction.UserID == session.State.User.ID {
+		return
+	}
+
+	entry, isMenu := rr.getMenuForMessage(reaction.MessageID)
+	if !isMenu {
+		return
+	}
+
+	rr.lockUser(entry.GuildID, reaction.UserID)
+	defer rr.unlockUser(entry.GuildID, reaction.UserID)
+
+	emoji := reaction.Emoji.APIName()
+
+	var targetOption *models.ReactionRolesOption
+	for i := range entry.Options {
+		if entry.Options[i].Emoji == emoji {
+			targetOption = &entry.Options[i]
+		}
+	}
+	if targetOption == nil {
+		rr.removeReaction(session, reaction.ChannelID, reaction.MessageID, emoji, reaction.UserID)
+		return
+	}
+
+	member, err := helpers.GetGuildMember(entry.GuildID, reaction.UserID)
+	if err != nil {
+		helpers.RelaxLog(err)
+		return
+	}
+
+	if len(entry.RequiredRoleIDs) > 0 {
+		hasRequiredRole := false
+		for _, requiredRoleID := range entry.RequiredRoleIDs {
+			if helpers.SliceContains(member.Roles, requiredRoleID) {
+				hasRequiredRole = true
+				break
+			}
+		}
+		if !hasRequiredRole {
+			rr.removeReaction(session, reaction.ChannelID, reaction.MessageID, emoji, reaction.UserID)
+			return
+		}
+	}
+
+	if helpers.SliceContains(member.Roles, targetOption.RoleID) {
+		return
+	}
+
+	switch entry.Mode {
+	case models.ReactionRolesModeUnique:
+		for _, option := range entry.Options {
+			if option.Emoji == targetOption.Emoji {
+				continue
+			}
+			if !helpers.SliceContains(member.Roles, option.RoleID) {
+				continue
+			}
+			err = session.GuildMemberRoleRemove(entry.GuildID, reaction.UserID, option.RoleID)
+			if err != nil {
+				cache.GetLogger().WithField("module", "reactionroles").Warnf("failed to remove role #%s from user #%s: %s",
+					option.RoleID, reaction.UserID, err.Error())
+			}
+			rr.removeReaction(session, reaction.ChannelID, reaction.MessageID, option.Emoji, reaction.UserID)
+		}
+	default:
+		if entry.MaxRoles > 0 {
+			var menuRoles int
+			for _, option := range entry.Options {
+				if helpers.SliceContains(member.Roles, option.RoleID) {
+					menuRoles++
+				}
+			}
+			if menuRoles >= entry.MaxRoles {
+				rr.removeReaction(session, reaction.ChannelID, reaction.MessageID, emoji, reaction.UserID)
+				return
+			}
+		}
+	}
+
+	err = session.GuildMemberRoleAdd(entry.GuildID, reaction.UserID, targetOption.RoleID)
+	if err != nil {
+		cache.GetLogger().WithField("module", "reactionroles").Warnf("failed to add role #%s to user #%s: %s",
+			targetOption.RoleID, reaction.UserID, err.Error())
+	}
+}
+
+func (rr *ReactionRoles) OnReactionRemove(reaction *discordgo.MessageReactionRemove, session *discordgo.Session) {
+	// skip reactions by the bot
+	if reaction.UserID == session.State.User.ID {
+		return
+	}
+
+	entry, isMenu := rr.getMenuForMessage(reaction.MessageID)
+	if !isMenu {
+		return
+	}
+
+	emoji := reaction.Emoji.APIName()
+
+	if rr.isIgnoredRemoval(reaction.MessageID, emoji, reaction.UserID) {
+		return
+	}
+
+	if entry.Mode == models.ReactionRolesModeVerify {
+		return
+	}
+
+	rr.lockUser(entry.GuildID, reaction.UserID)
+	defer rr.unlockUser(entry.GuildID, reaction.UserID)
+
+	for _, option := range entry.Options {
+		if option.Emoji != emoji {
+			continue
+		}
+
+		err := session.GuildMemberRoleRemove(entry.GuildID, reaction.UserID, option.RoleID)
+		if err != nil {
+			cache.GetLogger().WithField("module", "reactionroles").Warnf("failed to remove role #%s from user #%s: %s",
+				option.RoleID, reaction.UserID, err.Error())
+		}
+	}
+}
+
+func (rr *ReactionRoles) OnMessageDelete(msg *discordgo.MessageDelete, session *discordgo.Session) {
+	reactionRolesMenusCacheLock.RLock()
+	menuID, ok := reactionRolesMenusCache[msg.ID]
+	reactionRolesMenusCacheLock.RUnlock()
+	if !ok {
+		return
+	}
+
+	err := helpers.MDbDelete(models.ReactionRolesTable, menuID)
+	helpers.RelaxLog(err)
+
+	err = rr.refreshMenusCache()
+	helpers.RelaxLog(err)
+}
+
+func (rr *ReactionRoles) OnMessage(content string, msg *discordgo.Message, session *discordgo.Session) {
+
+}
+
+func (rr *ReactionRoles) OnGuildMemberAdd(member *discordgo.Member, session *discordgo.Session) {
+
+}
+
+func (rr *ReactionRoles) OnGuildMemberRemove(member *discordgo.Member, session *discordgo.Session) {
+
+}
+
+func (rr *ReactionRoles) OnGuildBanAdd(user *discordgo.GuildBanAdd, session *discordgo.Session) {
+
+}
+
+func (rr *ReactionRoles) OnGuildBanRemove(user *discordgo.GuildBanRemove, session *discordgo.Session) {
+
+}
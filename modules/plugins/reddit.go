@@ -13,6 +13,7 @@ import (
 	"html"
 
 	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/feeds"
 	"github.com/Seklfreak/Robyul2/helpers"
 	"github.com/Seklfreak/Robyul2/models"
 	"github.com/Seklfreak/Robyul2/shardmanager"
@@ -38,6 +39,10 @@ var (
 const (
 	RedditBaseUrl = "https://www.reddit.com"
 	RedditColor   = "ff4500"
+	// reddit allows 60 requests per minute for OAuth clients, keep some for commands
+	redditRequestsPerMinute = 45
+	// the maximum for a single request, submissions have to be in the listing until their delay passed
+	redditSubmissionsLimit = 100
 )
 
 func (r *Reddit) Commands() []string {
@@ -68,119 +73,135 @@ func (r *Reddit) Init(session *shardmanager.Manager) {
 		return
 	}
 	r.redditLoggedIn = true
-	go r.checkSubredditLoop()
-	r.logger().Info("Started checkSubredditLoop loop (0s)")
+	feeds.NewPoller(&redditFeedsSource{reddit: r}, feeds.Options{
+		Interval: time.Minute,
+		Jitter:   0.2,
+		Budget:   feeds.NewBudget(redditRequestsPerMinute, time.Minute),
+	}).Start()
 }
 
-func (r *Reddit) checkSubredditLoop() {
-	defer helpers.Recover()
-	defer func() {
-		go func() {
-			r.logger().Error("The checkSubredditLoop died. Please investigate! Will be restarted in 60 seconds")
-			time.Sleep(60 * time.Second)
-			r.checkSubredditLoop()
-		}()
-	}()
-
-	var entries []models.RedditSubredditEntry
-	var bundledEntries map[string][]models.RedditSubredditEntry
-	var newPost bool
+// redditFeedsSource polls the new submissions of all subreddits with feeds
+type redditFeedsSource struct {
+	reddit *Reddit
+}
 
-	for {
-		err := helpers.MDbIterWithoutLogging(helpers.MdbCollection(models.RedditSubredditsTable).Find(nil)).All(&entries)
-		helpers.Relax(err)
+func (s *redditFeedsSource) Name() string {
+	return "reddit"
+}
 
-		bundledEntries = make(map[string][]models.RedditSubredditEntry, 0)
+func (s *redditFeedsSource) Subscriptions() (bundled map[string][]feeds.Subscription, err error) {
+	var entries []models.RedditSubredditEntry
+	err = helpers.MDbIterWithoutLogging(helpers.MdbCollection(models.RedditSubredditsTable).Find(nil)).All(&entries)
+	if err != nil {
+		return nil, err
+	}
 
-		for _, entry := range entries {
+	bundled = make(map[string][]feeds.Subscription)
+	for _, entry := range entries {
+		// channels of guilds on other processes are not in the state
+		if cache.GetSession().IsLocalGuild(entry.GuildID) {
 			channel, err := helpers.GetChannelWithoutApi(entry.ChannelID)
 			if err != nil || channel == nil || channel.ID == "" {
-				//r.logger().Warn(fmt.Sprintf("skipped subreddit r/%s for Channel #%s on Guild #%s: channel not found!",
-				//	entry.SubredditName, entry.ChannelID, entry.GuildID))
 				continue
 			}
+		}
 
-			if _, ok := bundledEntries[entry.SubredditName]; ok {
-				bundledEntries[entry.SubredditName] = append(bundledEntries[entry.SubredditName], entry)
-			} else {
-				bundledEntries[entry.SubredditName] = []models.RedditSubredditEntry{entry}
-			}
+		bundled[entry.SubredditName] = append(bundled[entry.SubredditName], entry)
+	}
+
+	return bundled, nil
+}
+
+func (s *redditFeedsSource) Fetch(subredditName string) (items []feeds.Item, err error) {
+	newSubmissions, err := redditSession.SubredditSubmissions(subredditName, geddit.NewSubmissions, geddit.ListingOptions{
+		Limit: redditSubmissionsLimit,
+	})
+	if err != nil && strings.Contains(err.Error(), "oauth2: token expired and refresh token is not set") {
+		// login when token expired
+		err = redditSession.LoginAuth(
+			helpers.GetConfig().Path("reddit.username").Data().(string),
+			helpers.GetConfig().Path("reddit.password").Data().(string),
+		)
+		if err != nil {
+			return nil, err
 		}
+		s.reddit.logger().Warn("logged in again after token expired")
 
-		r.logger().Infof("checking %d subreddits for %d feeds", len(bundledEntries), len(entries))
+		newSubmissions, err = redditSession.SubredditSubmissions(subredditName, geddit.NewSubmissions, geddit.ListingOptions{
+			Limit: redditSubmissionsLimit,
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
 
-		for subredditName, entries := range bundledEntries {
-		BundleStart:
-			// r.logger().Info(fmt.Sprintf("checking subreddit r/%s for %d channels", subredditName, len(entries)))
-			newSubmissions, err := redditSession.SubredditSubmissions(subredditName, geddit.NewSubmissions, geddit.ListingOptions{
-				Limit: 30,
-			})
-			if err != nil {
-				if strings.Contains(err.Error(), "oauth2: token expired and refresh token is not set") {
-					// login when token expired
-					err = redditSession.LoginAuth(
-						helpers.GetConfig().Path("reddit.username").Data().(string),
-						helpers.GetConfig().Path("reddit.password").Data().(string),
-					)
-					helpers.Relax(err)
-					r.logger().Warn("logged in again after token expired")
-					goto BundleStart
-				}
-				r.logger().Warnf("updating subreddit r/%s failed: %s", subredditName, err.Error())
-				time.Sleep(2 * time.Second)
+	for _, submission := range newSubmissions {
+		items = append(items, feeds.Item{
+			ID:   submission.ID,
+			Time: time.Unix(int64(submission.DateCreated), 0),
+			Data: submission,
+		})
+	}
+	return items, nil
+}
+
+func (s *redditFeedsSource) Handle(subredditName string, items []feeds.Item, subscriptions []feeds.Subscription) (err error) {
+	for _, subscription := range subscriptions {
+		entry := subscription.(models.RedditSubredditEntry)
+
+		var channelNSFW bool
+		channel, err := helpers.GetChannelWithoutApi(entry.ChannelID)
+		if err == nil && channel != nil {
+			channelNSFW = channel.NSFW
+		}
+
+		newPost := false
+		hasToBeBefore := time.Now().Add(-getRedditEntryDelay(entry))
+		hasToBeAfter := entry.LastChecked
+
+		for _, item := range items {
+			if !item.Time.Before(hasToBeBefore) || !item.Time.After(hasToBeAfter) {
 				continue
 			}
-			for _, entry := range entries {
-				newPost = false
-				hasToBeBefore := time.Now().Add(-(time.Duration(entry.PostDelay) * time.Minute))
-				hasToBeAfter := entry.LastChecked
-
-				for _, submission := range newSubmissions {
-					submissionTime := time.Unix(int64(submission.DateCreated), 0)
-					if !submissionTime.Before(hasToBeBefore) || !submissionTime.After(hasToBeAfter) {
-						continue
-					}
-					newPost = true
-
-					postSubmission := submission
-					postChannelID := entry.ChannelID
-					go func() {
-						defer helpers.Recover()
-
-						// r.logger().Info(fmt.Sprintf("posting submission: #%s (%s) on r/%s (%s) to #%s",
-						// 	postSubmission.ID, submissionTime.Format(time.ANSIC), subredditName,
-						// 	RedditBaseUrl+"/r/"+subredditName+"/comments/"+postSubmission.ID+"/", entry.ChannelID))
-
-						err = r.postSubmission(postChannelID, postSubmission, entry.PostDirectLinks)
-						if err != nil {
-							if errD, ok := err.(*discordgo.RESTError); ok && errD.Message != nil {
-								if errD.Message.Code != discordgo.ErrCodeMissingPermissions &&
-									errD.Message.Code != discordgo.ErrCodeUnknownChannel &&
-									errD.Message.Code != discordgo.ErrCodeMissingAccess {
-									helpers.Relax(err)
-								}
-							} else {
-								helpers.Relax(err)
-							}
+			newPost = true
+
+			postSubmission := item.Data.(*geddit.Submission)
+			if !redditFiltersMatch(entry.Filters, postSubmission, channelNSFW) {
+				continue
+			}
+
+			postEntry := entry
+			go func() {
+				defer helpers.Recover()
+
+				hidePreview := isRedditSpoiler(postSubmission) && postEntry.Filters.Spoilers != models.RedditFilterSpoilersShow
+				err := s.reddit.postSubmission(postEntry.ChannelID, postSubmission, postEntry.PostDirectLinks, hidePreview)
+				if err != nil {
+					if errD, ok := err.(*discordgo.RESTError); ok && errD.Message != nil {
+						if errD.Message.Code != discordgo.ErrCodeMissingPermissions &&
+							errD.Message.Code != discordgo.ErrCodeUnknownChannel &&
+							errD.Message.Code != discordgo.ErrCodeMissingAccess {
+							helpers.Relax(err)
 						}
-					}()
-				}
-				if newPost {
-					entry.LastChecked = hasToBeBefore
-					err = helpers.MDbUpdateWithoutLogging(models.RedditSubredditsTable, entry.ID, entry)
-					helpers.Relax(err)
+					} else {
+						helpers.Relax(err)
+					}
 				}
-			}
-			time.Sleep(2 * time.Second)
+			}()
 		}
-
-		if len(entries) <= 10 {
-			time.Sleep(time.Second * 60)
+		if newPost {
+			entry.LastChecked = hasToBeBefore
+			err = helpers.MDbUpdateWithoutLogging(models.RedditSubredditsTable, entry.ID, entry)
+			if err != nil {
+				return err
+			}
 		}
 	}
+
+	return nil
 }
 
-func (r *Reddit) postSubmission(channelID string, submission *geddit.Submission, postDirectLinks bool) (err error) {
+func (r *Reddit) postSubmission(channelID string, submission *geddit.Submission, postDirectLinks, hidePreview bool) (err error) {
 	data := &discordgo.MessageSend{}
 
 	data.Content = "<" + RedditBaseUrl + submission.Permalink + ">"
@@ -214,14 +235,20 @@ func (r *Reddit) postSubmission(channelID string, submission *geddit.Submission,
 		textModeTitle = textModeTitle[0:127] + "…"
 	}
 	textModeTitle += "**"
-	if submission.Selftext != "" {
+	if hidePreview {
+		data.Embed.Title = "[Spoiler] " + data.Embed.Title
+		textModeTitle = "`[Spoiler]` " + textModeTitle
+	}
+	if submission.Selftext != "" && !hidePreview {
 		data.Embed.Description = html.UnescapeString(submission.Selftext)
 		if len(data.Embed.Description) > 500 {
 			data.Embed.Description = data.Embed.Description[0:499] + "…"
 		}
 		textModeSelftext = data.Embed.Description
 	}
-	if strings.HasSuffix(strings.ToLower(submission.URL), ".jpg") ||
+	if hidePreview {
+		// no image previews for spoilers
+	} else if strings.HasSuffix(strings.ToLower(submission.URL), ".jpg") ||
 		strings.HasSuffix(strings.ToLower(submission.URL), ".jpeg") ||
 		strings.HasSuffix(strings.ToLower(submission.URL), ".gif") ||
 		strings.HasSuffix(strings.ToLower(submission.URL), ".png") {
@@ -236,7 +263,7 @@ func (r *Reddit) postSubmission(channelID string, submission *geddit.Submission,
 		if textModeSelftext != "" {
 			content += textModeSelftext + "\n"
 		}
-		if submission.URL != RedditBaseUrl+submission.Permalink {
+		if submission.URL != RedditBaseUrl+submission.Permalink && !hidePreview {
 			content += submission.URL
 		}
 		data.Content = content
@@ -283,6 +310,8 @@ func (r *Reddit) actionStart(args []string, in *discordgo.Message, out **discord
 		return r.actionList
 	case "toggle-direct-link", "toggle-direct-links":
 		return r.actionToggleDirectLinks
+	case "filter", "filters":
+		return r.actionFilter
 	default:
 		return r.actionInfo
 	}
@@ -308,6 +337,17 @@ func (r *Reddit) actionAdd(args []string, in *discordgo.Message, out **discordgo
 		}
 	}
 
+	// [p]reddit add <subreddit> <channel> [<delay>] [direct link mode] [<filter>=<value> …]
+	filters, err := parseRedditFilters(helpers.ParseKeyValueString(getRedditOptionsText(args[3:])), models.RedditFilters{})
+	if err != nil {
+		*out = r.newMsg("plugins.reddit.filter-error-invalid", err.Error())
+		return r.actionFinish
+	}
+	if filters.NSFW == models.RedditFilterNSFWOnly && !targetChannelIsNSFW(in, args[2]) {
+		*out = r.newMsg("plugins.reddit.filter-error-nsfw-channel")
+		return r.actionFinish
+	}
+
 	targetChannel, err := helpers.GetChannelFromMention(in, args[2])
 	if err != nil {
 		*out = r.newMsg("bot.arguments.invalid")
@@ -323,12 +363,16 @@ func (r *Reddit) actionAdd(args []string, in *discordgo.Message, out **discordgo
 	}
 
 	var linkMode bool
-	if strings.HasSuffix(in.Content, " direct link mode") ||
-		strings.HasSuffix(in.Content, " link mode") ||
-		strings.HasSuffix(in.Content, " links") {
+	contentWithoutOptions := strings.Join(getRedditArgsWithoutOptions(args), " ")
+	if strings.HasSuffix(contentWithoutOptions, " direct link mode") ||
+		strings.HasSuffix(contentWithoutOptions, " link mode") ||
+		strings.HasSuffix(contentWithoutOptions, " links") {
 		linkMode = true
 		specialText += " using direct links"
 	}
+	if filtersText := getRedditFiltersText(filters); filtersText != "" {
+		specialText += " filtered by " + filtersText
+	}
 
 	subredditData, err := redditSession.AboutSubreddit(subredditName)
 	helpers.Relax(err)
@@ -348,6 +392,7 @@ func (r *Reddit) actionAdd(args []string, in *discordgo.Message, out **discordgo
 			AddedAt:         time.Now(),
 			PostDelay:       postDelay,
 			PostDirectLinks: linkMode,
+			Filters:         filters,
 		})
 	helpers.Relax(err)
 
@@ -373,6 +418,10 @@ func (r *Reddit) actionAdd(args []string, in *discordgo.Message, out **discordgo
 				Key:   "reddit_subredditname",
 				Value: subredditData.Name,
 			},
+			{
+				Key:   "reddit_filters",
+				Value: getRedditFiltersText(filters),
+			},
 		}, false)
 	helpers.RelaxLog(err)
 
@@ -402,14 +451,21 @@ func (r *Reddit) actionList(args []string, in *discordgo.Message, out **discordg
 			directLinkModeText = ", direct link mode"
 		}
 
+		if filtersText := getRedditFiltersText(subredditEntry.Filters); filtersText != "" {
+			directLinkModeText += ", filtered by " + filtersText
+		}
+
 		subredditListText += fmt.Sprintf("`%s`: Subreddit `r/%s` posting to <#%s> (Delay: %d minutes%s)\n",
 			helpers.MdbIdToHuman(subredditEntry.ID), subredditEntry.SubredditName, subredditEntry.ChannelID,
 			subredditEntry.PostDelay, directLinkModeText)
 	}
 	subredditListText += fmt.Sprintf("Found **%d** Subreddits in total.", len(subredditEntries))
 
-	*out = r.newMsg(subredditListText)
-	return r.actionFinish
+	for _, page := range helpers.Pagify(subredditListText, "\n") {
+		_, err = helpers.SendMessage(in.ChannelID, page)
+		helpers.RelaxMessage(err, in.ChannelID, in.ID)
+	}
+	return nil
 }
 
 func (r *Reddit) actionRemove(args []string, in *discordgo.Message, out **discordgo.MessageSend) redditAction {
@@ -557,6 +613,120 @@ func (r *Reddit) actionToggleDirectLinks(args []string, in *discordgo.Message, o
 	return r.actionFinish
 }
 
+// [p]reddit filter <id> [<filter>=<value> …]
+func (r *Reddit) actionFilter(args []string, in *discordgo.Message, out **discordgo.MessageSend) redditAction {
+	if !helpers.IsMod(in) {
+		*out = r.newMsg(helpers.GetText("mod.no_permission"))
+		return r.actionFinish
+	}
+
+	if len(args) < 2 {
+		*out = r.newMsg("bot.arguments.too-few")
+		return r.actionFinish
+	}
+
+	channel, err := helpers.GetChannel(in.ChannelID)
+	helpers.Relax(err)
+
+	var subredditEntry models.RedditSubredditEntry
+	err = helpers.MdbOne(
+		helpers.MdbCollection(models.RedditSubredditsTable).Find(bson.M{"guildid": channel.GuildID, "_id": helpers.HumanToMdbId(args[1])}),
+		&subredditEntry,
+	)
+	if helpers.IsMdbNotFound(err) {
+		*out = r.newMsg("plugins.reddit.filter-error-not-found")
+		return r.actionFinish
+	}
+	helpers.Relax(err)
+
+	beforeFiltersText := getRedditFiltersText(subredditEntry.Filters)
+
+	if len(args) < 3 {
+		if beforeFiltersText == "" {
+			*out = r.newMsg("plugins.reddit.filter-none", subredditEntry.SubredditName)
+			return r.actionFinish
+		}
+		*out = r.newMsg("plugins.reddit.filter-status", subredditEntry.SubredditName, beforeFiltersText)
+		return r.actionFinish
+	}
+
+	var filters models.RedditFilters
+	if strings.ToLower(args[2]) != "reset" {
+		filters, err = parseRedditFilters(helpers.ParseKeyValueString(getRedditOptionsText(args[2:])), subredditEntry.Filters)
+		if err != nil {
+			*out = r.newMsg("plugins.reddit.filter-error-invalid", err.Error())
+			return r.actionFinish
+		}
+		if filters.NSFW == models.RedditFilterNSFWOnly && !targetChannelIsNSFW(in, subredditEntry.ChannelID) {
+			*out = r.newMsg("plugins.reddit.filter-error-nsfw-channel")
+			return r.actionFinish
+		}
+	}
+	subredditEntry.Filters = filters
+
+	err = helpers.MDbUpdate(models.RedditSubredditsTable, subredditEntry.ID, subredditEntry)
+	helpers.Relax(err)
+
+	_, err = helpers.EventlogLog(time.Now(), channel.GuildID, helpers.MdbIdToHuman(subredditEntry.ID),
+		models.EventlogTargetTypeRobyulRedditFeed, in.Author.ID,
+		models.EventlogTypeRobyulRedditFeedUpdate, "",
+		[]models.ElasticEventlogChange{
+			{
+				Key:      "reddit_filters",
+				OldValue: beforeFiltersText,
+				NewValue: getRedditFiltersText(subredditEntry.Filters),
+			},
+		},
+		[]models.ElasticEventlogOption{
+			{
+				Key:   "reddit_channelid",
+				Value: subredditEntry.ChannelID,
+				Type:  models.EventlogTargetTypeChannel,
+			},
+			{
+				Key:   "reddit_subredditname",
+				Value: subredditEntry.SubredditName,
+			},
+		}, false)
+	helpers.RelaxLog(err)
+
+	filtersText := getRedditFiltersText(subredditEntry.Filters)
+	if filtersText == "" {
+		*out = r.newMsg("plugins.reddit.filter-none", subredditEntry.SubredditName)
+		return r.actionFinish
+	}
+	*out = r.newMsg("plugins.reddit.filter-success", subredditEntry.SubredditName, filtersText)
+	return r.actionFinish
+}
+
+// getRedditOptionsText returns the key=value options of the arguments
+func getRedditOptionsText(args []string) string {
+	for i, arg := range args {
+		if strings.Contains(arg, "=") {
+			return strings.Join(args[i:], " ")
+		}
+	}
+	return ""
+}
+
+// getRedditArgsWithoutOptions returns the arguments before the first key=value option
+func getRedditArgsWithoutOptions(args []string) []string {
+	for i, arg := range args {
+		if strings.Contains(arg, "=") {
+			return args[:i]
+		}
+	}
+	return args
+}
+
+func targetChannelIsNSFW(in *discordgo.Message, mention string) bool {
+	targetChannel, err := helpers.GetChannelFromMention(in, mention)
+	if err != nil || targetChannel == nil {
+		return false
+	}
+	return targetChannel.NSFW
+}
+
 func (r *Reddit) getSubredditInfo(subreddit string) (data *discordgo.MessageSend) {
 	subredditData, err := redditSession.AboutSubreddit(subreddit)
 	if err != nil {
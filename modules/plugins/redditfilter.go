@@ -0,0 +1,181 @@
+package plugins
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/jzelinskie/geddit"
+	"github.com/pkg/errors"
+)
+
+// parseRedditFilters applies key=value options, like flairs="Fan Art,News" min-score=50 score-after=60, to the filters
+func parseRedditFilters(data map[string]string, filters models.RedditFilters) (models.RedditFilters, error) {
+	for key, value := range data {
+		value = strings.Trim(value, "\"'“”")
+
+		var err error
+		switch strings.ToLower(key) {
+		case "flair", "flairs":
+			filters.Flairs = splitRedditFilterList(value)
+		case "exclude-flair", "exclude-flairs":
+			filters.ExcludeFlairs = splitRedditFilterList(value)
+		case "keyword", "keywords":
+			filters.Keywords = splitRedditFilterList(value)
+		case "exclude-keyword", "exclude-keywords":
+			filters.ExcludeKeywords = splitRedditFilterList(value)
+		case "min-score":
+			filters.MinScore, err = strconv.Atoi(value)
+		case "score-after":
+			filters.ScoreDelay, err = strconv.Atoi(value)
+		case "nsfw":
+			switch strings.ToLower(value) {
+			case "allow", "":
+				filters.NSFW = models.RedditFilterNSFWAllow
+			case "deny", "no":
+				filters.NSFW = models.RedditFilterNSFWDeny
+			case "only":
+				filters.NSFW = models.RedditFilterNSFWOnly
+			default:
+				err = errors.New("invalid nsfw mode")
+			}
+		case "spoiler", "spoilers":
+			switch strings.ToLower(value) {
+			case "hide", "":
+				filters.Spoilers = models.RedditFilterSpoilersHide
+			case "show":
+				filters.Spoilers = models.RedditFilterSpoilersShow
+			case "deny", "no":
+				filters.Spoilers = models.RedditFilterSpoilersDeny
+			default:
+				err = errors.New("invalid spoilers mode")
+			}
+		default:
+			continue
+		}
+		if err != nil || filters.MinScore < 0 || filters.ScoreDelay < 0 {
+			return filters, errors.New(key + "=" + value)
+		}
+	}
+
+	return filters, nil
+}
+
+func splitRedditFilterList(value string) (list []string) {
+	if strings.ToLower(value) == "none" {
+		return nil
+	}
+
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// getRedditFiltersText describes the filters, returns an empty string if no filters are set
+func getRedditFiltersText(filters models.RedditFilters) string {
+	texts := make([]string, 0)
+	if len(filters.Flairs) > 0 {
+		texts = append(texts, "flairs `"+strings.Join(filters.Flairs, "`, `")+"`")
+	}
+	if len(filters.ExcludeFlairs) > 0 {
+		texts = append(texts, "excluding flairs `"+strings.Join(filters.ExcludeFlairs, "`, `")+"`")
+	}
+	if len(filters.Keywords) > 0 {
+		texts = append(texts, "keywords `"+strings.Join(filters.Keywords, "`, `")+"`")
+	}
+	if len(filters.ExcludeKeywords) > 0 {
+		texts = append(texts, "excluding keywords `"+strings.Join(filters.ExcludeKeywords, "`, `")+"`")
+	}
+	if filters.MinScore > 0 {
+		texts = append(texts, fmt.Sprintf("a score of at least %d after %d minutes", filters.MinScore, filters.ScoreDelay))
+	}
+	switch filters.NSFW {
+	case models.RedditFilterNSFWDeny:
+		texts = append(texts, "no NSFW")
+	case models.RedditFilterNSFWOnly:
+		texts = append(texts, "NSFW only")
+	}
+	switch filters.Spoilers {
+	case models.RedditFilterSpoilersShow:
+		texts = append(texts, "spoilers shown")
+	case models.RedditFilterSpoilersDeny:
+		texts = append(texts, "no spoilers")
+	}
+	return strings.Join(texts, ", ")
+}
+
+// getRedditEntryDelay returns the time after submission the entry posts submissions,
+// submissions with a score filter are evaluated after the score delay
+func getRedditEntryDelay(entry models.RedditSubredditEntry) time.Duration {
+	delay := entry.PostDelay
+	if entry.Filters.MinScore > 0 && entry.Filters.ScoreDelay > delay {
+		delay = entry.Filters.ScoreDelay
+	}
+	return time.Duration(delay) * time.Minute
+}
+
+// isRedditSpoiler returns true if the submission is marked as spoiler, reddit replaces the thumbnail of spoilers
+func isRedditSpoiler(submission *geddit.Submission) bool {
+	return submission.ThumbnailURL == "spoiler"
+}
+
+// redditFiltersMatch returns true if the submission should be posted, NSFW submissions are only posted to NSFW channels
+func redditFiltersMatch(filters models.RedditFilters, submission *geddit.Submission, channelNSFW bool) bool {
+	if submission.IsNSFW && (!channelNSFW || filters.NSFW == models.RedditFilterNSFWDeny) {
+		return false
+	}
+	if !submission.IsNSFW && filters.NSFW == models.RedditFilterNSFWOnly {
+		return false
+	}
+
+	if isRedditSpoiler(submission) && filters.Spoilers == models.RedditFilterSpoilersDeny {
+		return false
+	}
+
+	if len(filters.Flairs) > 0 && !redditFilterListContains(filters.Flairs, submission.LinkFlairText) {
+		return false
+	}
+	if redditFilterListContains(filters.ExcludeFlairs, submission.LinkFlairText) {
+		return false
+	}
+
+	title := strings.ToLower(html.UnescapeString(submission.Title))
+	if len(filters.Keywords) > 0 && !redditFilterTitleContains(filters.Keywords, title) {
+		return false
+	}
+	if redditFilterTitleContains(filters.ExcludeKeywords, title) {
+		return false
+	}
+
+	if filters.MinScore > 0 && submission.Score < filters.MinScore {
+		return false
+	}
+
+	return true
+}
+
+func redditFilterListContains(list []string, value string) bool {
+	value = strings.TrimSpace(html.UnescapeString(value))
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func redditFilterTitleContains(keywords []string, title string) bool {
+	for _, keyword := range keywords {
+		if strings.Contains(title, strings.ToLower(keyword)) {
+			return true
+		}
+	}
+	return false
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 
 	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/cluster"
 	"github.com/Seklfreak/Robyul2/helpers"
 	"github.com/Seklfreak/Robyul2/models"
 	"github.com/Seklfreak/Robyul2/shardmanager"
@@ -43,6 +44,12 @@ func (r *Reminders) Init(session *shardmanager.Manager) {
 		defer helpers.Recover()
 
 		for {
+			// only one process sends the reminders
+			if !cluster.IsLeader("reminders") {
+				time.Sleep(10 * time.Second)
+				continue
+			}
+
 			reminderBucket := make([]models.RemindersEntry, 0)
 			err := helpers.MDbIterWithoutLogging(helpers.MdbCollection(models.RemindersTable).Find(nil)).All(&reminderBucket)
 			if err != nil {
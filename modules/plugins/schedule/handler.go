@@ -0,0 +1,326 @@
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/Seklfreak/Robyul2/shardmanager"
+	"github.com/bwmarrin/discordgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+const (
+	scheduleTimeFormat        = "Mon, 02 Jan 2006 15:04 MST"
+	scheduleListPreviewLength = 50
+)
+
+type Schedule struct{}
+
+func (m *Schedule) Commands() []string {
+	return []string{
+		"schedule",
+		"schedules",
+	}
+}
+
+func (m *Schedule) Init(session *shardmanager.Manager) {
+	go m.catchUpScheduledMessagesLoop()
+}
+
+func (m *Schedule) Action(command string, content string, msg *discordgo.Message, session *discordgo.Session) {
+	if !helpers.ModuleIsAllowed(msg.ChannelID, msg.ID, msg.Author.ID, helpers.ModulePermSchedule) {
+		return
+	}
+
+	args := strings.Fields(content)
+	if len(args) < 1 {
+		args = []string{"list"}
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "add", "create": // [p]schedule add <#channel> <when|cron> [<timezone>] <text or embed code>
+		helpers.RequireMod(msg, func() {
+			m.actionAdd(args, content, msg)
+		})
+		return
+	case "list": // [p]schedule list
+		helpers.RequireMod(msg, func() {
+			m.actionList(msg)
+		})
+		return
+	case "delete", "remove": // [p]schedule delete <id>
+		helpers.RequireMod(msg, func() {
+			m.actionDelete(args, msg)
+		})
+		return
+	case "pause", "resume", "unpause": // [p]schedule pause <id>
+		helpers.RequireMod(msg, func() {
+			m.actionPause(args, msg)
+		})
+		return
+	}
+
+	_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+	helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+}
+
+// [p]schedule add <#channel> <when|cron> [<timezone>] <text or embed code>
+func (m *Schedule) actionAdd(args []string, content string, msg *discordgo.Message) {
+	if len(args) < 4 {
+		helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.too-few"))
+		return
+	}
+
+	channel, err := helpers.GetChannel(msg.ChannelID)
+	helpers.Relax(err)
+
+	targetChannel, err := helpers.GetChannelFromMention(msg, args[1])
+	if err != nil || targetChannel.ID == "" || targetChannel.GuildID != channel.GuildID {
+		helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+		return
+	}
+
+	loc := time.UTC
+	userData, err := helpers.GetUserUserdata(msg.Author.ID)
+	if err == nil && userData.Timezone != "" {
+		userLocation, err := time.LoadLocation(userData.Timezone)
+		if err == nil {
+			loc = userLocation
+		}
+	}
+
+	spec, used, err := parseScheduleSpec(args[2:], time.Now().In(loc))
+	if err != nil {
+		_, err = helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.schedule.add-error-schedule",
+			helpers.GetPrefixForServer(channel.GuildID)))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	embedCode := content
+	for _, arg := range args[:2+used] {
+		embedCode = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(embedCode), arg))
+	}
+	if embedCode == "" {
+		helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.too-few"))
+		return
+	}
+	if helpers.IsEmbedCode(embedCode) {
+		_, _, err = helpers.ParseEmbedCode(embedCode)
+		if err != nil {
+			helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+			return
+		}
+	}
+
+	entry := models.ScheduleEntry{
+		GuildID:         targetChannel.GuildID,
+		ChannelID:       targetChannel.ID,
+		CreatedByUserID: msg.Author.ID,
+		CreatedAt:       time.Now(),
+		EmbedCode:       embedCode,
+		CronExpression:  spec.CronExpression,
+		Timezone:        spec.Location.String(),
+		NextRunAt:       spec.NextRunAt.Truncate(time.Second),
+	}
+	entry.ID, err = helpers.MDbInsert(models.ScheduleTable, entry)
+	helpers.Relax(err)
+
+	err = queueScheduledMessage(entry)
+	helpers.Relax(err)
+
+	_, err = helpers.EventlogLog(time.Now(), targetChannel.GuildID, helpers.MdbIdToHuman(entry.ID),
+		models.EventlogTargetTypeRobyulSchedule, msg.Author.ID,
+		models.EventlogTypeRobyulScheduleAdd, "",
+		nil,
+		[]models.ElasticEventlogOption{
+			{
+				Key:   "schedule_channelid",
+				Value: targetChannel.ID,
+				Type:  models.EventlogTargetTypeChannel,
+			},
+			{
+				Key:   "schedule_cron",
+				Value: entry.CronExpression,
+			},
+			{
+				Key:   "schedule_timezone",
+				Value: entry.Timezone,
+			},
+			{
+				Key:   "schedule_text",
+				Value: entry.EmbedCode,
+			},
+		}, false)
+	helpers.RelaxLog(err)
+
+	_, err = helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.schedule.add-success",
+		helpers.MdbIdToHuman(entry.ID), targetChannel.ID, m.describeSchedule(entry)))
+	helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+}
+
+// [p]schedule list
+func (m *Schedule) actionList(msg *discordgo.Message) {
+	channel, err := helpers.GetChannel(msg.ChannelID)
+	helpers.Relax(err)
+
+	var entries []models.ScheduleEntry
+	err = helpers.MDbIter(helpers.MdbCollection(models.ScheduleTable).Find(bson.M{"guildid": channel.GuildID}).
+		Sort("nextrunat")).All(&entries)
+	helpers.Relax(err)
+
+	if len(entries) <= 0 {
+		_, err = helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.schedule.list-empty",
+			helpers.GetPrefixForServer(channel.GuildID)))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return
+	}
+
+	var message string
+	for _, entry := range entries {
+		preview := strings.Replace(entry.EmbedCode, "\n", " ", -1)
+		if len([]rune(preview)) > scheduleListPreviewLength {
+			preview = string([]rune(preview)[:scheduleListPreviewLength]) + "…"
+		}
+		message += fmt.Sprintf("`%s` in <#%s>: %s\n\t`%s`\n",
+			helpers.MdbIdToHuman(entry.ID), entry.ChannelID, m.describeSchedule(entry), preview)
+	}
+	message += helpers.GetTextF("plugins.schedule.list-footer", len(entries))
+
+	for _, page := range helpers.Pagify(message, "\n") {
+		_, err = helpers.SendMessage(msg.ChannelID, page)
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+	}
+}
+
+// [p]schedule delete <id>
+func (m *Schedule) actionDelete(args []string, msg *discordgo.Message) {
+	entry, ok := m.getScheduleFromArgs(args, msg)
+	if !ok {
+		return
+	}
+
+	err := helpers.MDbDelete(models.ScheduleTable, entry.ID)
+	helpers.Relax(err)
+
+	_, err = helpers.EventlogLog(time.Now(), entry.GuildID, helpers.MdbIdToHuman(entry.ID),
+		models.EventlogTargetTypeRobyulSchedule, msg.Author.ID,
+		models.EventlogTypeRobyulScheduleDelete, "",
+		nil,
+		[]models.ElasticEventlogOption{
+			{
+				Key:   "schedule_channelid",
+				Value: entry.ChannelID,
+				Type:  models.EventlogTargetTypeChannel,
+			},
+			{
+				Key:   "schedule_text",
+				Value: entry.EmbedCode,
+			},
+		}, false)
+	helpers.RelaxLog(err)
+
+	_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.schedule.delete-success"))
+	helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+}
+
+// [p]schedule pause <id>, [p]schedule resume <id>
+func (m *Schedule) actionPause(args []string, msg *discordgo.Message) {
+	entry, ok := m.getScheduleFromArgs(args, msg)
+	if !ok {
+		return
+	}
+
+	pause := strings.ToLower(args[0]) == "pause"
+	if entry.Paused == pause {
+		helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+		return
+	}
+
+	entry.Paused = pause
+	if !entry.Paused {
+		// skip runs missed while paused, one-off messages which are overdue are posted now
+		if entry.CronExpression != "" {
+			entry.NextRunAt = getNextRunAt(entry, time.Now())
+		} else if entry.NextRunAt.Before(time.Now()) {
+			entry.NextRunAt = time.Now().Truncate(time.Second)
+		}
+	}
+
+	err := helpers.MDbUpdate(models.ScheduleTable, entry.ID, entry)
+	helpers.Relax(err)
+
+	if !entry.Paused {
+		err = queueScheduledMessage(entry)
+		helpers.Relax(err)
+	}
+
+	_, err = helpers.EventlogLog(time.Now(), entry.GuildID, helpers.MdbIdToHuman(entry.ID),
+		models.EventlogTargetTypeRobyulSchedule, msg.Author.ID,
+		models.EventlogTypeRobyulScheduleUpdate, "",
+		[]models.ElasticEventlogChange{
+			{
+				Key:      "schedule_paused",
+				OldValue: helpers.StoreBoolAsString(!entry.Paused),
+				NewValue: helpers.StoreBoolAsString(entry.Paused),
+			},
+		},
+		nil, false)
+	helpers.RelaxLog(err)
+
+	if entry.Paused {
+		_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.schedule.pause-success"))
+	} else {
+		_, err = helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.schedule.resume-success",
+			m.describeSchedule(entry)))
+	}
+	helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+}
+
+func (m *Schedule) getScheduleFromArgs(args []string, msg *discordgo.Message) (entry models.ScheduleEntry, ok bool) {
+	if len(args) < 2 {
+		helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.too-few"))
+		return entry, false
+	}
+
+	channel, err := helpers.GetChannel(msg.ChannelID)
+	helpers.Relax(err)
+
+	err = helpers.MdbOne(
+		helpers.MdbCollection(models.ScheduleTable).Find(bson.M{
+			"_id":     helpers.HumanToMdbId(args[1]),
+			"guildid": channel.GuildID,
+		}),
+		&entry,
+	)
+	if helpers.IsMdbNotFound(err) {
+		_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.schedule.not-found"))
+		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		return entry, false
+	}
+	helpers.Relax(err)
+
+	return entry, true
+}
+
+// describeSchedule returns a human readable description of the schedule, including the next run
+func (m *Schedule) describeSchedule(entry models.ScheduleEntry) (text string) {
+	loc, err := time.LoadLocation(entry.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	if entry.CronExpression != "" {
+		text = fmt.Sprintf("every `%s` (%s), next post at %s", entry.CronExpression, loc.String(),
+			entry.NextRunAt.In(loc).Format(scheduleTimeFormat))
+	} else {
+		text = fmt.Sprintf("once at %s", entry.NextRunAt.In(loc).Format(scheduleTimeFormat))
+	}
+	if entry.Paused {
+		text += " **(paused)**"
+	}
+	return text
+}
@@ -0,0 +1,385 @@
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maximum time to search for the next run of a cron expression
+const cronSearchLimit = time.Hour * 24 * 366 * 5
+
+var (
+	errInvalidCron = errors.New("invalid cron expression")
+
+	// common timezone abbreviations, everything else has to be an IANA timezone name like Europe/Berlin
+	timezoneAbbreviations = map[string]string{
+		"UTC":  "UTC",
+		"GMT":  "UTC",
+		"KST":  "Asia/Seoul",
+		"JST":  "Asia/Tokyo",
+		"CST":  "America/Chicago",
+		"CDT":  "America/Chicago",
+		"EST":  "America/New_York",
+		"EDT":  "America/New_York",
+		"MST":  "America/Denver",
+		"MDT":  "America/Denver",
+		"PST":  "America/Los_Angeles",
+		"PDT":  "America/Los_Angeles",
+		"BST":  "Europe/London",
+		"CET":  "Europe/Berlin",
+		"CEST": "Europe/Berlin",
+		"SGT":  "Asia/Singapore",
+		"PHT":  "Asia/Manila",
+		"AEST": "Australia/Sydney",
+		"AEDT": "Australia/Sydney",
+	}
+
+	weekdayNames = map[string]string{
+		"sunday": "0", "sun": "0", "sundays": "0",
+		"monday": "1", "mon": "1", "mondays": "1",
+		"tuesday": "2", "tue": "2", "tuesdays": "2",
+		"wednesday": "3", "wed": "3", "wednesdays": "3",
+		"thursday": "4", "thu": "4", "thursdays": "4",
+		"friday": "5", "fri": "5", "fridays": "5",
+		"saturday": "6", "sat": "6", "saturdays": "6",
+		"day": "*", "days": "*",
+		"weekday": "1-5", "weekdays": "1-5",
+		"weekend": "0,6", "weekends": "0,6",
+	}
+)
+
+// cronSchedule is a parsed five field cron expression (minute hour day-of-month month day-of-week)
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domRestricted, dowRestricted  bool
+}
+
+// parseCron parses a five field cron expression, fields support *, lists, ranges and steps
+func parseCron(expression string) (schedule cronSchedule, err error) {
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return schedule, errInvalidCron
+	}
+
+	if schedule.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return schedule, err
+	}
+	if schedule.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return schedule, err
+	}
+	if schedule.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+		return schedule, err
+	}
+	if schedule.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return schedule, err
+	}
+	if schedule.dow, err = parseCronField(fields[4], 0, 7); err != nil {
+		return schedule, err
+	}
+	// 7 is sunday as well
+	if schedule.dow&(1<<7) != 0 {
+		schedule.dow |= 1
+	}
+	schedule.domRestricted = fields[2] != "*"
+	schedule.dowRestricted = fields[4] != "*"
+
+	return schedule, nil
+}
+
+func parseCronField(field string, min, max int) (bits uint64, err error) {
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step <= 0 {
+				return 0, errInvalidCron
+			}
+			part = part[:i]
+		}
+
+		start, end := min, max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			rangeParts := strings.SplitN(part, "-", 2)
+			start, err = strconv.Atoi(rangeParts[0])
+			if err != nil {
+				return 0, errInvalidCron
+			}
+			end, err = strconv.Atoi(rangeParts[1])
+			if err != nil {
+				return 0, errInvalidCron
+			}
+		default:
+			start, err = strconv.Atoi(part)
+			if err != nil {
+				return 0, errInvalidCron
+			}
+			end = start
+			if step > 1 {
+				end = max
+			}
+		}
+
+		if start < min || end > max || start > end {
+			return 0, errInvalidCron
+		}
+
+		for i := start; i <= end; i += step {
+			bits |= 1 << uint(i)
+		}
+	}
+
+	return bits, nil
+}
+
+func (s cronSchedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domRestricted && s.dowRestricted {
+		return domMatch || dowMatch
+	}
+	return domMatch && dowMatch
+}
+
+// Next returns the first time after the given time matching the schedule, in the location of the given time
+// returns a zero time if there is no matching time within the next years
+func (s cronSchedule) Next(after time.Time) time.Time {
+	loc := after.Location()
+	t := after.Truncate(time.Second).Add(time.Minute - time.Duration(after.Second())*time.Second)
+	limit := after.Add(cronSearchLimit)
+
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = t.Add(time.Duration(60-t.Minute()) * time.Minute)
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+
+	return time.Time{}
+}
+
+// parseTimezone returns the location for a timezone abbreviation or IANA timezone name
+func parseTimezone(text string) (loc *time.Location, ok bool) {
+	if name, ok := timezoneAbbreviations[strings.ToUpper(text)]; ok {
+		text = name
+	} else if !strings.Contains(text, "/") {
+		return nil, false
+	}
+
+	loc, err := time.LoadLocation(text)
+	if err != nil {
+		return nil, false
+	}
+	return loc, true
+}
+
+// parseClock parses a time of day like 18:00, 6pm or 6:30pm
+func parseClock(text string) (hour, minute int, err error) {
+	text = strings.ToLower(text)
+	var pm, am bool
+	if strings.HasSuffix(text, "pm") {
+		pm = true
+		text = strings.TrimSuffix(text, "pm")
+	} else if strings.HasSuffix(text, "am") {
+		am = true
+		text = strings.TrimSuffix(text, "am")
+	}
+
+	parts := strings.SplitN(text, ":", 2)
+	hour, err = strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(parts) == 2 {
+		minute, err = strconv.Atoi(parts[1])
+		if err != nil {
+			return 0, 0, err
+		}
+	} else if !am && !pm {
+		return 0, 0, fmt.Errorf("invalid time: %s", text)
+	}
+
+	if am || pm {
+		if hour < 1 || hour > 12 {
+			return 0, 0, fmt.Errorf("invalid time: %s", text)
+		}
+		if hour == 12 {
+			hour = 0
+		}
+		if pm {
+			hour += 12
+		}
+	}
+
+	if hour < 0 || hour > 23 || minute < 0 || minute > 59 {
+		return 0, 0, fmt.Errorf("invalid time: %s", text)
+	}
+	return hour, minute, nil
+}
+
+// parseEvery converts a recurring schedule like "every friday 18:00" into a cron expression
+// returns the cron expression and the number of arguments used
+func parseEvery(args []string) (expression string, used int, err error) {
+	if len(args) < 2 || strings.ToLower(args[0]) != "every" {
+		return "", 0, errInvalidCron
+	}
+
+	switch strings.ToLower(args[1]) {
+	case "hour":
+		return "0 * * * *", 2, nil
+	}
+
+	var days []string
+	for _, dayName := range strings.Split(strings.ToLower(args[1]), ",") {
+		day, ok := weekdayNames[dayName]
+		if !ok {
+			return "", 0, errInvalidCron
+		}
+		days = append(days, day)
+	}
+	daysField := strings.Join(days, ",")
+	if strings.Contains(daysField, "*") {
+		daysField = "*"
+	}
+
+	if len(args) < 3 {
+		return "", 0, errInvalidCron
+	}
+	hour, minute, err := parseClock(args[2])
+	if err != nil {
+		return "", 0, errInvalidCron
+	}
+
+	return fmt.Sprintf("%d %d * * %s", minute, hour, daysField), 3, nil
+}
+
+// parseCronArgs parses a cron expression at the beginning of the args
+// returns the cron expression and the number of arguments used
+func parseCronArgs(args []string) (expression string, used int, err error) {
+	if len(args) < 5 {
+		return "", 0, errInvalidCron
+	}
+
+	expression = strings.Join(args[:5], " ")
+	_, err = parseCron(expression)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return expression, 5, nil
+}
+
+// parseDateTime parses a one-off time like "2018-12-24 18:00", "18:00" or "in 2h" at the beginning of the args
+// a time of day without a date is the next occurrence of that time
+// returns the time and the number of arguments used
+func parseDateTime(args []string, now time.Time) (at time.Time, used int, err error) {
+	if len(args) < 1 {
+		return at, 0, errors.New("no time")
+	}
+
+	loc := now.Location()
+	if strings.ToLower(args[0]) == "in" && len(args) >= 2 {
+		duration, err := time.ParseDuration(args[1])
+		if err != nil || duration <= 0 {
+			return at, 0, errors.New("invalid duration")
+		}
+		return now.Add(duration).Truncate(time.Second), 2, nil
+	}
+
+	if len(args) >= 2 {
+		date, err := time.ParseInLocation("2006-01-02", args[0], loc)
+		if err == nil {
+			hour, minute, err := parseClock(args[1])
+			if err != nil {
+				return at, 0, err
+			}
+			return time.Date(date.Year(), date.Month(), date.Day(), hour, minute, 0, 0, loc), 2, nil
+		}
+	}
+
+	hour, minute, err := parseClock(args[0])
+	if err != nil {
+		return at, 0, err
+	}
+	at = time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, loc)
+	if !at.After(now) {
+		at = time.Date(now.Year(), now.Month(), now.Day()+1, hour, minute, 0, 0, loc)
+	}
+	return at, 1, nil
+}
+
+// scheduleSpec is a parsed schedule, either recurring with a cron expression, or one-off at a time
+type scheduleSpec struct {
+	CronExpression string
+	Location       *time.Location
+	NextRunAt      time.Time
+}
+
+// parseScheduleSpec parses the schedule at the beginning of the args, followed by an optional timezone
+// the location of now is used if there is no timezone
+// returns the schedule and the number of arguments used
+func parseScheduleSpec(args []string, now time.Time) (spec scheduleSpec, used int, err error) {
+	spec, used, err = parseScheduleSpecInLocation(args, now)
+	if err != nil {
+		return spec, 0, err
+	}
+
+	if used < len(args) {
+		if loc, ok := parseTimezone(args[used]); ok {
+			spec, _, err = parseScheduleSpecInLocation(args, now.In(loc))
+			if err != nil {
+				return spec, 0, err
+			}
+			used++
+		}
+	}
+
+	return spec, used, nil
+}
+
+func parseScheduleSpecInLocation(args []string, now time.Time) (spec scheduleSpec, used int, err error) {
+	if len(args) < 1 {
+		return spec, 0, errors.New("no schedule")
+	}
+
+	spec.Location = now.Location()
+
+	if strings.ToLower(args[0]) == "every" {
+		spec.CronExpression, used, err = parseEvery(args)
+	} else if spec.CronExpression, used, err = parseCronArgs(args); err != nil {
+		spec.CronExpression = ""
+		spec.NextRunAt, used, err = parseDateTime(args, now)
+	}
+	if err != nil {
+		return spec, 0, err
+	}
+
+	if spec.CronExpression != "" {
+		cronSchedule, _ := parseCron(spec.CronExpression)
+		spec.NextRunAt = cronSchedule.Next(now)
+		if spec.NextRunAt.IsZero() {
+			return spec, 0, errInvalidCron
+		}
+	} else if !spec.NextRunAt.After(now) {
+		return spec, 0, errors.New("time is in the past")
+	}
+
+	return spec, used, nil
+}
@@ -0,0 +1,78 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	after := time.Date(2018, 12, 20, 18, 30, 15, 0, time.UTC) // thursday
+
+	cases := []struct {
+		expression string
+		expected   time.Time
+	}{
+		{"* * * * *", time.Date(2018, 12, 20, 18, 31, 0, 0, time.UTC)},
+		{"0 18 * * 5", time.Date(2018, 12, 21, 18, 0, 0, 0, time.UTC)},
+		{"*/15 9-17 * * 1-5", time.Date(2018, 12, 21, 9, 0, 0, 0, time.UTC)},
+		{"0 0 1 1 *", time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"30 12 29 2 *", time.Date(2020, 2, 29, 12, 30, 0, 0, time.UTC)},
+		{"0 10 24 * 0", time.Date(2018, 12, 23, 10, 0, 0, 0, time.UTC)},
+	}
+
+	for _, c := range cases {
+		schedule, err := parseCron(c.expression)
+		if err != nil {
+			t.Fatalf("schedule.parseCron() failed to parse %s: %s", c.expression, err.Error())
+		}
+		if next := schedule.Next(after); !next.Equal(c.expected) {
+			t.Fatalf("schedule.cronSchedule.Next() returned %s for %s, expected %s", next, c.expression, c.expected)
+		}
+	}
+
+	for _, expression := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "5-1 * * * *", "*/0 * * * *"} {
+		if _, err := parseCron(expression); err == nil {
+			t.Fatalf("schedule.parseCron() failed to reject %s", expression)
+		}
+	}
+}
+
+func TestParseScheduleSpec(t *testing.T) {
+	now := time.Date(2018, 12, 20, 18, 30, 0, 0, time.UTC) // thursday
+
+	spec, used, err := parseScheduleSpec([]string{"every", "friday", "18:00", "KST", "weekly", "post"}, now)
+	if err != nil || used != 4 || spec.CronExpression != "0 18 * * 5" || spec.Location.String() != "Asia/Seoul" {
+		t.Fatalf("schedule.parseScheduleSpec() failed to parse every with timezone: %+v, %d, %v", spec, used, err)
+	}
+	if !spec.NextRunAt.Equal(time.Date(2018, 12, 21, 9, 0, 0, 0, time.UTC)) {
+		t.Fatalf("schedule.parseScheduleSpec() returned wrong next run: %s", spec.NextRunAt)
+	}
+
+	spec, used, err = parseScheduleSpec([]string{"*/30", "9-17", "*", "*", "1-5", "hello"}, now)
+	if err != nil || used != 5 || spec.CronExpression != "*/30 9-17 * * 1-5" {
+		t.Fatalf("schedule.parseScheduleSpec() failed to parse cron: %+v, %d, %v", spec, used, err)
+	}
+
+	spec, used, err = parseScheduleSpec([]string{"2018-12-24", "6pm", "merry", "christmas"}, now)
+	if err != nil || used != 2 || spec.CronExpression != "" ||
+		!spec.NextRunAt.Equal(time.Date(2018, 12, 24, 18, 0, 0, 0, time.UTC)) {
+		t.Fatalf("schedule.parseScheduleSpec() failed to parse date: %+v, %d, %v", spec, used, err)
+	}
+
+	spec, used, err = parseScheduleSpec([]string{"18:00", "hello"}, now)
+	if err != nil || used != 1 || !spec.NextRunAt.Equal(time.Date(2018, 12, 21, 18, 0, 0, 0, time.UTC)) {
+		t.Fatalf("schedule.parseScheduleSpec() failed to parse time of day: %+v, %d, %v", spec, used, err)
+	}
+
+	spec, used, err = parseScheduleSpec([]string{"in", "90m", "hello"}, now)
+	if err != nil || used != 2 || !spec.NextRunAt.Equal(now.Add(90*time.Minute)) {
+		t.Fatalf("schedule.parseScheduleSpec() failed to parse duration: %+v, %d, %v", spec, used, err)
+	}
+
+	if _, _, err = parseScheduleSpec([]string{"2018-01-01", "12:00", "hello"}, now); err == nil {
+		t.Fatalf("schedule.parseScheduleSpec() failed to reject time in the past")
+	}
+	if _, _, err = parseScheduleSpec([]string{"hello", "world"}, now); err == nil {
+		t.Fatalf("schedule.parseScheduleSpec() failed to reject invalid schedule")
+	}
+}
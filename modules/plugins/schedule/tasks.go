@@ -0,0 +1,172 @@
+package schedule
+
+import (
+	"time"
+
+	"github.com/RichardKnop/machinery/v1/tasks"
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/bwmarrin/discordgo"
+	"github.com/globalsign/mgo/bson"
+)
+
+const (
+	// recurring messages missed by more than this are skipped to their next run
+	scheduleMissedLimit = time.Hour
+	scheduleCatchUpWait = time.Minute * 5
+)
+
+func PostScheduledMessageSignature(scheduleID string, runAt int64) (signature *tasks.Signature) {
+	signature = &tasks.Signature{
+		Name: "post_scheduled_message",
+		Args: []tasks.Arg{
+			{
+				Type:  "string",
+				Value: scheduleID,
+			},
+			{
+				Type:  "int64",
+				Value: runAt,
+			},
+		},
+	}
+	signature.RetryCount = 3
+	signature.OnError = []*tasks.Signature{{Name: "log_error"}}
+	return signature
+}
+
+// queueScheduledMessage sends the task for the next run of the scheduled message to machinery
+func queueScheduledMessage(entry models.ScheduleEntry) (err error) {
+	signature := PostScheduledMessageSignature(helpers.MdbIdToHuman(entry.ID), entry.NextRunAt.Unix())
+	if entry.NextRunAt.After(time.Now()) {
+		runAt := entry.NextRunAt
+		signature.ETA = &runAt
+	}
+
+	_, err = cache.GetMachineryServer().SendTask(signature)
+	return err
+}
+
+// getNextRunAt returns the next run after the given time for recurring messages, or a zero time for one-off messages
+func getNextRunAt(entry models.ScheduleEntry, after time.Time) time.Time {
+	if entry.CronExpression == "" {
+		return time.Time{}
+	}
+
+	cronSchedule, err := parseCron(entry.CronExpression)
+	if err != nil {
+		return time.Time{}
+	}
+
+	loc, err := time.LoadLocation(entry.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	return cronSchedule.Next(after.In(loc))
+}
+
+func getScheduleMessageSend(embedCode string) *discordgo.MessageSend {
+	messageSend := &discordgo.MessageSend{
+		Content: embedCode,
+	}
+	if helpers.IsEmbedCode(embedCode) {
+		ptext, embed, err := helpers.ParseEmbedCode(embedCode)
+		if err == nil {
+			messageSend.Content = ptext
+			messageSend.Embed = embed
+		}
+	}
+	return messageSend
+}
+
+// PostScheduledMessage posts a scheduled message, and queues the next run for recurring messages
+// tasks for deleted, paused, or rescheduled messages are ignored
+func PostScheduledMessage(scheduleID string, runAt int64) (err error) {
+	var entry models.ScheduleEntry
+	err = helpers.MdbOneWithoutLogging(
+		helpers.MdbCollection(models.ScheduleTable).Find(bson.M{"_id": helpers.HumanToMdbId(scheduleID)}),
+		&entry,
+	)
+	if helpers.IsMdbNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if entry.Paused || entry.NextRunAt.Unix() != runAt {
+		return nil
+	}
+
+	// claim the run, so duplicate tasks for the same run will not post the message again
+	nextRunAt := getNextRunAt(entry, time.Now())
+	err = helpers.MDbUpdateQueryWithoutLogging(models.ScheduleTable,
+		bson.M{"_id": entry.ID, "nextrunat": entry.NextRunAt, "paused": false},
+		bson.M{"$set": bson.M{"nextrunat": nextRunAt, "lastrunat": time.Now(), "runcount": entry.RunCount + 1}},
+	)
+	if helpers.IsMdbNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = helpers.SendComplex(entry.ChannelID, getScheduleMessageSend(entry.EmbedCode))
+	if err != nil {
+		cache.GetLogger().WithField("module", "schedule").Warnf("failed to post scheduled message #%s to #%s: %s",
+			scheduleID, entry.ChannelID, err.Error())
+	}
+
+	if entry.CronExpression == "" || nextRunAt.IsZero() {
+		return helpers.MDbDeleteWithoutLogging(models.ScheduleTable, entry.ID)
+	}
+
+	entry.NextRunAt = nextRunAt
+	return queueScheduledMessage(entry)
+}
+
+// catchUpScheduledMessagesLoop queues runs which have been missed, for example because machinery was unavailable
+func (m *Schedule) catchUpScheduledMessagesLoop() {
+	defer helpers.Recover()
+	defer func() {
+		go func() {
+			cache.GetLogger().WithField("module", "schedule").Error("The catchUpScheduledMessagesLoop died. Please investigate! Will be restarted in 60 seconds")
+			time.Sleep(60 * time.Second)
+			m.catchUpScheduledMessagesLoop()
+		}()
+	}()
+
+	for {
+		time.Sleep(scheduleCatchUpWait)
+
+		var entries []models.ScheduleEntry
+		err := helpers.MDbIterWithoutLogging(helpers.MdbCollection(models.ScheduleTable).Find(bson.M{
+			"paused":    false,
+			"nextrunat": bson.M{"$lt": time.Now().Add(-scheduleCatchUpWait)},
+		})).All(&entries)
+		if err != nil {
+			helpers.RelaxLog(err)
+			continue
+		}
+
+		for _, entry := range entries {
+			if entry.CronExpression != "" && time.Since(entry.NextRunAt) > scheduleMissedLimit {
+				nextRunAt := getNextRunAt(entry, time.Now())
+				err = helpers.MDbUpdateQueryWithoutLogging(models.ScheduleTable,
+					bson.M{"_id": entry.ID, "nextrunat": entry.NextRunAt},
+					bson.M{"$set": bson.M{"nextrunat": nextRunAt}},
+				)
+				if err != nil {
+					helpers.RelaxLog(err)
+					continue
+				}
+				entry.NextRunAt = nextRunAt
+			}
+
+			err = queueScheduledMessage(entry)
+			helpers.RelaxLog(err)
+		}
+	}
+}
@@ -185,37 +185,15 @@ func (s *Starboard) actionSet(args []string, in *discordgo.Message, out **discor
 	channel, err := helpers.GetChannel(in.ChannelID)
 	helpers.Relax(err)
 
-	guildSettings := helpers.GuildSettingsGetCached(channel.GuildID)
-
 	if len(args) < 2 {
-		if guildSettings.StarboardChannelID != "" {
-			beforeChannelID := guildSettings.StarboardChannelID
-			guildSettings.StarboardChannelID = ""
-			err = helpers.GuildSettingsSet(channel.GuildID, guildSettings)
-			helpers.Relax(err)
-
-			_, err = helpers.EventlogLog(time.Now(), channel.GuildID, beforeChannelID,
-				models.EventlogTargetTypeChannel, in.Author.ID,
-				models.EventlogTypeRobyulStarboardDelete, "",
-				nil,
-				[]models.ElasticEventlogOption{
-					{
-						Key:   "starboard_emoji",
-						Value: strings.Join(s.getEmoji(channel.GuildID), ";"),
-						Type:  models.EventlogTargetTypeEmoji,
-					},
-					{
-						Key:   "starboard_minimum",
-						Value: strconv.Itoa(s.getMinimum(channel.GuildID)),
-					},
-				}, false)
-			helpers.RelaxLog(err)
-
-			*out = s.newMsg(helpers.GetText("plugins.starboard.reset-success"))
+		err = StarboardSetChannel(channel.GuildID, in.Author.ID, "")
+		if helpers.IsInputError(err) {
+			*out = s.newMsg(err.Error())
 			return s.actionFinish
-		} else {
-			*out = s.newMsg(helpers.GetText("plugins.starboard.status-none"))
 		}
+		helpers.Relax(err)
+
+		*out = s.newMsg(helpers.GetText("plugins.starboard.reset-success"))
 		return s.actionFinish
 	}
 
@@ -227,42 +205,15 @@ func (s *Starboard) actionSet(args []string, in *discordgo.Message, out **discor
 		}
 		helpers.Relax(err)
 	}
-	previousChannelID := guildSettings.StarboardChannelID
-	guildSettings.StarboardChannelID = targetChannel.ID
-	err = helpers.GuildSettingsSet(channel.GuildID, guildSettings)
-	helpers.Relax(err)
 
-	changes := make([]models.ElasticEventlogChange, 0)
-
-	if previousChannelID != "" {
-		changes = []models.ElasticEventlogChange{
-			{
-				Key:      "starboard_channelid",
-				OldValue: previousChannelID,
-				NewValue: guildSettings.StarboardChannelID,
-				Type:     models.EventlogTargetTypeChannel,
-			},
-		}
+	err = StarboardSetChannel(channel.GuildID, in.Author.ID, targetChannel.ID)
+	if helpers.IsInputError(err) {
+		*out = s.newMsg(err.Error())
+		return s.actionFinish
 	}
+	helpers.Relax(err)
 
-	_, err = helpers.EventlogLog(time.Now(), channel.GuildID, targetChannel.ID,
-		models.EventlogTargetTypeChannel, in.Author.ID,
-		models.EventlogTypeRobyulStarboardCreate, "",
-		changes,
-		[]models.ElasticEventlogOption{
-			{
-				Key:   "starboard_emoji",
-				Value: strings.Join(s.getEmoji(channel.GuildID), ";"),
-				Type:  models.EventlogTargetTypeEmoji,
-			},
-			{
-				Key:   "starboard_minimum",
-				Value: strconv.Itoa(s.getMinimum(channel.GuildID)),
-			},
-		}, false)
-	helpers.RelaxLog(err)
-
-	*out = s.newMsg(helpers.GetTextF("plugins.starboard.set-success", guildSettings.StarboardChannelID))
+	*out = s.newMsg(helpers.GetTextF("plugins.starboard.set-success", targetChannel.ID))
 	return s.actionFinish
 }
 
@@ -284,34 +235,17 @@ func (s *Starboard) actionMinimum(args []string, in *discordgo.Message, out **di
 		return s.actionFinish
 	}
 
-	if newMinimum < 1 {
-		*out = s.newMsg(helpers.GetText("bot.arguments.invalid"))
-		return s.actionFinish
-	}
-
 	channel, err := helpers.GetChannel(in.ChannelID)
 	helpers.Relax(err)
 
-	guildSettings := helpers.GuildSettingsGetCached(channel.GuildID)
-	oldMinimum := guildSettings.StarboardMinimum
-	guildSettings.StarboardMinimum = newMinimum
-	err = helpers.GuildSettingsSet(channel.GuildID, guildSettings)
+	err = StarboardSetMinimum(channel.GuildID, in.Author.ID, newMinimum)
+	if helpers.IsInputError(err) {
+		*out = s.newMsg(err.Error())
+		return s.actionFinish
+	}
 	helpers.Relax(err)
 
-	_, err = helpers.EventlogLog(time.Now(), channel.GuildID, guildSettings.StarboardChannelID,
-		models.EventlogTargetTypeChannel, in.Author.ID,
-		models.EventlogTypeRobyulStarboardUpdate, "",
-		[]models.ElasticEventlogChange{
-			{
-				Key:      "starboard_minimum",
-				OldValue: strconv.Itoa(oldMinimum),
-				NewValue: strconv.Itoa(guildSettings.StarboardMinimum),
-			},
-		},
-		nil, false)
-	helpers.RelaxLog(err)
-
-	*out = s.newMsg(helpers.GetTextF("plugins.starboard.minimum-success", guildSettings.StarboardMinimum))
+	*out = s.newMsg(helpers.GetTextF("plugins.starboard.minimum-success", newMinimum))
 	return s.actionFinish
 }
 
@@ -326,28 +260,18 @@ func (s *Starboard) actionEmoji(args []string, in *discordgo.Message, out **disc
 		return s.actionFinish
 	}
 
-	newEmoji := args[1]
-
-	if !helpers.IsEmoji(newEmoji) {
-		*out = s.newMsg(helpers.GetText("bot.arguments.invalid"))
-		return s.actionFinish
-	}
-
 	channel, err := helpers.GetChannel(in.ChannelID)
 	helpers.Relax(err)
 
-	if helpers.IsDiscordEmoji(newEmoji) {
-		discordEmoji, err := helpers.GetDiscordEmojiFromText(channel.GuildID, newEmoji)
-		if err != nil || discordEmoji == nil || discordEmoji.Name == "" {
-			*out = s.newMsg(helpers.GetText("bot.arguments.invalid"))
-			return s.actionFinish
-		}
-		newEmoji = discordEmoji.Name
+	newEmoji, err := s.parseEmoji(channel.GuildID, args[1])
+	if helpers.IsInputError(err) {
+		*out = s.newMsg(err.Error())
+		return s.actionFinish
 	}
+	helpers.Relax(err)
 
 	guildSettings := helpers.GuildSettingsGetCached(channel.GuildID)
 
-	options := make([]models.ElasticEventlogOption, 0)
 	removed := false
 	newEmojiList := make([]string, 0)
 	for _, emoji := range guildSettings.StarboardEmoji {
@@ -357,46 +281,17 @@ func (s *Starboard) actionEmoji(args []string, in *discordgo.Message, out **disc
 			newEmojiList = append(newEmojiList, emoji)
 		}
 	}
-
 	if !removed {
 		newEmojiList = append(newEmojiList, newEmoji)
-		options = []models.ElasticEventlogOption{
-			{
-				Key:   "starboard_emoji_added",
-				Value: newEmoji,
-				Type:  models.EventlogTargetTypeEmoji,
-			},
-		}
-	} else {
-		options = []models.ElasticEventlogOption{
-			{
-				Key:   "starboard_emoji_removed",
-				Value: newEmoji,
-				Type:  models.EventlogTargetTypeEmoji,
-			},
-		}
 	}
 
-	emojiBefore := s.getEmoji(channel.GuildID)
-
-	guildSettings.StarboardEmoji = newEmojiList
-
-	err = helpers.GuildSettingsSet(channel.GuildID, guildSettings)
+	err = StarboardSetEmoji(channel.GuildID, in.Author.ID, newEmojiList)
+	if helpers.IsInputError(err) {
+		*out = s.newMsg(err.Error())
+		return s.actionFinish
+	}
 	helpers.Relax(err)
 
-	_, err = helpers.EventlogLog(time.Now(), channel.GuildID, guildSettings.StarboardChannelID,
-		models.EventlogTargetTypeChannel, in.Author.ID,
-		models.EventlogTypeRobyulStarboardUpdate, "",
-		[]models.ElasticEventlogChange{
-			{
-				Key:      "starboard_emoji",
-				OldValue: strings.Join(emojiBefore, ";"),
-				NewValue: strings.Join(s.getEmoji(channel.GuildID), ";"),
-			},
-		},
-		options, false)
-	helpers.RelaxLog(err)
-
 	if !removed {
 		*out = s.newMsg(helpers.GetTextF("plugins.starboard.emoji-add-success", newEmoji))
 	} else {
@@ -1074,3 +969,199 @@ func (s *Starboard) OnGuildBanAdd(user *discordgo.GuildBanAdd, session *discordg
 func (s *Starboard) OnGuildBanRemove(user *discordgo.GuildBanRemove, session *discordgo.Session) {
 
 }
+
+// parseEmoji returns the emoji as it is stored in the settings, the name for custom emoji
+func (s *Starboard) parseEmoji(guildID, text string) (emoji string, err error) {
+	if !helpers.IsEmoji(text) {
+		return "", helpers.NewInputError("bot.arguments.invalid")
+	}
+
+	if helpers.IsDiscordEmoji(text) {
+		discordEmoji, err := helpers.GetDiscordEmojiFromText(guildID, text)
+		if err != nil || discordEmoji == nil || discordEmoji.Name == "" {
+			return "", helpers.NewInputError("bot.arguments.invalid")
+		}
+		return discordEmoji.Name, nil
+	}
+	return text, nil
+}
+
+// StarboardGetMinimum returns the reactions required for a starboard post
+func StarboardGetMinimum(guildID string) int {
+	return (&Starboard{}).getMinimum(guildID)
+}
+
+// StarboardGetEmoji returns the emoji accepted for starboard posts
+func StarboardGetEmoji(guildID string) []string {
+	return (&Starboard{}).getEmoji(guildID)
+}
+
+// StarboardSetChannel sets the starboard channel and logs it to the eventlog, an empty channel disables the starboard
+func StarboardSetChannel(guildID, userID, channelID string) (err error) {
+	s := &Starboard{}
+	guildSettings := helpers.GuildSettingsGetCached(guildID)
+
+	if channelID == "" {
+		if guildSettings.StarboardChannelID == "" {
+			return helpers.NewInputError("plugins.starboard.status-none")
+		}
+
+		beforeChannelID := guildSettings.StarboardChannelID
+		guildSettings.StarboardChannelID = ""
+		err = helpers.GuildSettingsSet(guildID, guildSettings)
+		if err != nil {
+			return err
+		}
+
+		_, err = helpers.EventlogLog(time.Now(), guildID, beforeChannelID,
+			models.EventlogTargetTypeChannel, userID,
+			models.EventlogTypeRobyulStarboardDelete, "",
+			nil,
+			[]models.ElasticEventlogOption{
+				{
+					Key:   "starboard_emoji",
+					Value: strings.Join(s.getEmoji(guildID), ";"),
+					Type:  models.EventlogTargetTypeEmoji,
+				},
+				{
+					Key:   "starboard_minimum",
+					Value: strconv.Itoa(s.getMinimum(guildID)),
+				},
+			}, false)
+		helpers.RelaxLog(err)
+		return nil
+	}
+
+	targetChannel, err := helpers.GetChannel(channelID)
+	if err != nil || targetChannel.GuildID != guildID || targetChannel.Type != discordgo.ChannelTypeGuildText {
+		return helpers.NewInputError("bot.arguments.invalid")
+	}
+
+	previousChannelID := guildSettings.StarboardChannelID
+	guildSettings.StarboardChannelID = targetChannel.ID
+	err = helpers.GuildSettingsSet(guildID, guildSettings)
+	if err != nil {
+		return err
+	}
+
+	changes := make([]models.ElasticEventlogChange, 0)
+
+	if previousChannelID != "" {
+		changes = []models.ElasticEventlogChange{
+			{
+				Key:      "starboard_channelid",
+				OldValue: previousChannelID,
+				NewValue: guildSettings.StarboardChannelID,
+				Type:     models.EventlogTargetTypeChannel,
+			},
+		}
+	}
+
+	_, err = helpers.EventlogLog(time.Now(), guildID, targetChannel.ID,
+		models.EventlogTargetTypeChannel, userID,
+		models.EventlogTypeRobyulStarboardCreate, "",
+		changes,
+		[]models.ElasticEventlogOption{
+			{
+				Key:   "starboard_emoji",
+				Value: strings.Join(s.getEmoji(guildID), ";"),
+				Type:  models.EventlogTargetTypeEmoji,
+			},
+			{
+				Key:   "starboard_minimum",
+				Value: strconv.Itoa(s.getMinimum(guildID)),
+			},
+		}, false)
+	helpers.RelaxLog(err)
+	return nil
+}
+
+// StarboardSetMinimum sets the reactions required for a starboard post and logs it to the eventlog
+func StarboardSetMinimum(guildID, userID string, minimum int) (err error) {
+	if minimum < 1 {
+		return helpers.NewInputError("bot.arguments.invalid")
+	}
+
+	guildSettings := helpers.GuildSettingsGetCached(guildID)
+	oldMinimum := guildSettings.StarboardMinimum
+	guildSettings.StarboardMinimum = minimum
+	err = helpers.GuildSettingsSet(guildID, guildSettings)
+	if err != nil {
+		return err
+	}
+
+	_, err = helpers.EventlogLog(time.Now(), guildID, guildSettings.StarboardChannelID,
+		models.EventlogTargetTypeChannel, userID,
+		models.EventlogTypeRobyulStarboardUpdate, "",
+		[]models.ElasticEventlogChange{
+			{
+				Key:      "starboard_minimum",
+				OldValue: strconv.Itoa(oldMinimum),
+				NewValue: strconv.Itoa(guildSettings.StarboardMinimum),
+			},
+		},
+		nil, false)
+	helpers.RelaxLog(err)
+	return nil
+}
+
+// StarboardSetEmoji sets the emoji accepted for starboard posts and logs it to the eventlog
+// emoji	: unicode or custom emoji, an empty list accepts the default emoji
+func StarboardSetEmoji(guildID, userID string, emoji []string) (err error) {
+	s := &Starboard{}
+	guildSettings := helpers.GuildSettingsGetCached(guildID)
+
+	newEmojiList := make([]string, 0)
+	for _, emojiText := range emoji {
+		newEmoji, err := s.parseEmoji(guildID, emojiText)
+		if err != nil {
+			return err
+		}
+		if !helpers.SliceContains(newEmojiList, newEmoji) {
+			newEmojiList = append(newEmojiList, newEmoji)
+		}
+	}
+
+	options := make([]models.ElasticEventlogOption, 0)
+	for _, newEmoji := range newEmojiList {
+		if !helpers.SliceContains(guildSettings.StarboardEmoji, newEmoji) {
+			options = append(options, models.ElasticEventlogOption{
+				Key:   "starboard_emoji_added",
+				Value: newEmoji,
+				Type:  models.EventlogTargetTypeEmoji,
+			})
+		}
+	}
+	for _, oldEmoji := range guildSettings.StarboardEmoji {
+		if !helpers.SliceContains(newEmojiList, oldEmoji) {
+			options = append(options, models.ElasticEventlogOption{
+				Key:   "starboard_emoji_removed",
+				Value: oldEmoji,
+				Type:  models.EventlogTargetTypeEmoji,
+			})
+		}
+	}
+
+	emojiBefore := s.getEmoji(guildID)
+
+	guildSettings.StarboardEmoji = newEmojiList
+
+	err = helpers.GuildSettingsSet(guildID, guildSettings)
+	if err != nil {
+		return err
+	}
+
+	_, err = helpers.EventlogLog(time.Now(), guildID, guildSettings.StarboardChannelID,
+		models.EventlogTargetTypeChannel, userID,
+		models.EventlogTypeRobyulStarboardUpdate, "",
+		[]models.ElasticEventlogChange{
+			{
+				Key:      "starboard_emoji",
+				OldValue: strings.Join(emojiBefore, ";"),
+				NewValue: strings.Join(s.getEmoji(guildID), ";"),
+			},
+		},
+		options, false)
+	helpers.RelaxLog(err)
+	return nil
+}
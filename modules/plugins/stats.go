@@ -81,18 +81,9 @@ func (s *Stats) Action(command string, content string, msg *discordgo.Message, s
 	switch command {
 	case "stats":
 		session.ChannelTyping(msg.ChannelID)
-		// Count guilds, channels and users
-		users := make(map[string]string)
-		channels := 0
-		guilds := helpers.AllGuilds()
-
-		for _, guild := range guilds {
-			channels += len(guild.Channels)
-
-			for _, u := range guild.Members {
-				users[u.User.ID] = u.User.Username
-			}
-		}
+		// Count guilds, channels and users of all processes
+		clusterStats, err := helpers.GetClusterStats()
+		helpers.Relax(err)
 
 		// Get RAM stats
 		var ram runtime.MemStats
@@ -227,9 +218,9 @@ func (s *Stats) Action(command string, content string, msg *discordgo.Message, s
 				{Name: "Running coroutines", Value: strconv.Itoa(runtime.NumGoroutine()), Inline: true},
 
 				// Discord
-				{Name: "Connected servers", Value: strconv.Itoa(len(guilds)), Inline: true},
-				{Name: "Watching channels", Value: strconv.Itoa(channels), Inline: true},
-				{Name: "Users", Value: strconv.Itoa(len(users)), Inline: true},
+				{Name: "Connected servers", Value: strconv.Itoa(clusterStats.Guilds), Inline: true},
+				{Name: "Watching channels", Value: strconv.Itoa(clusterStats.Channels), Inline: true},
+				{Name: "Users", Value: strconv.Itoa(clusterStats.Users), Inline: true},
 
 				// Machinery
 				{Name: "Machinery", Value: machineryText, Inline: true},
@@ -294,6 +285,9 @@ func (s *Stats) Action(command string, content string, msg *discordgo.Message, s
 		}
 
 		usersCount := len(guild.Members)
+		if members, err := helpers.GetGuildMembers(guild.ID); err == nil {
+			usersCount = len(members)
+		}
 
 		textChannels := 0
 		voiceChannels := 0
@@ -509,7 +503,8 @@ func (s *Stats) Action(command string, content string, msg *discordgo.Message, s
 
 		}
 
-		allMembers := currentGuild.Members
+		allMembers, err := helpers.GetGuildMembers(currentGuild.ID)
+		helpers.Relax(err)
 		slice.Sort(allMembers[:], func(i, j int) bool {
 			defer helpers.Recover()
 			if allMembers[i].JoinedAt == "" || allMembers[j].JoinedAt == "" {
@@ -975,14 +970,17 @@ func (s *Stats) Action(command string, content string, msg *discordgo.Message, s
 			}
 		}
 
-		allMembers := guild.Members
+		guildMembers, err := helpers.GetGuildMembers(guild.ID)
+		helpers.Relax(err)
+
+		allMembers := guildMembers
 		kind := "guild"
 		var kindTitle string
 		if role.ID != "" {
 			kind = "role"
 			kindTitle = role.Name
 			allMembers = make([]*discordgo.Member, 0)
-			for _, member := range guild.Members {
+			for _, member := range guildMembers {
 				for _, memberRole := range member.Roles {
 					if memberRole == role.ID {
 						allMembers = append(allMembers, member)
@@ -992,7 +990,7 @@ func (s *Stats) Action(command string, content string, msg *discordgo.Message, s
 		}
 
 		if len(allMembers) <= 0 {
-			allMembers = guild.Members
+			allMembers = guildMembers
 			kind = "guild"
 			kindTitle = ""
 		}
@@ -1118,7 +1116,7 @@ func (s *Stats) Action(command string, content string, msg *discordgo.Message, s
 		guild, err := helpers.GetGuild(invite.Guild.ID)
 		if err == nil {
 			invite.Guild.Channels = guild.Channels
-			invite.Guild.Members = guild.Members
+			invite.Guild.Members, _ = helpers.GetGuildMembers(guild.ID)
 			guildInvites, err := session.GuildInvites(invite.Guild.ID)
 			if err == nil {
 				for _, guildInvite := range guildInvites {
@@ -1546,15 +1544,48 @@ func (s *Stats) Action(command string, content string, msg *discordgo.Message, s
 		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
 		return
 	case "shardingstats":
-		fullStatus := cache.GetSession().GetFullStatus()
+		clusterShards, err := helpers.GetClusterShards()
+		helpers.Relax(err)
+
+		fullStatus := clusterShards.Status
 		var content string
 		for _, shard := range fullStatus.Shards {
-			content += fmt.Sprintf("Shard **%d**: %s guilds\n", shard.Shard, humanize.Comma(int64(shard.NumGuilds)))
+			state := "ok"
+			if !shard.Started {
+				state = "not started"
+			} else if shard.Restarting {
+				state = "restarting"
+			} else if !shard.OK {
+				state = "not ready"
+			}
+
+			lastEvent := "never"
+			if !shard.LastEvent.IsZero() {
+				lastEvent = humanize.Time(shard.LastEvent)
+			}
+
+			content += fmt.Sprintf("Shard **%d**: %s, %s guilds, latency %s, last event %s, %d restarts\n",
+				shard.Shard, state, humanize.Comma(int64(shard.NumGuilds)), shard.Latency.Round(time.Millisecond),
+				lastEvent, shard.Restarts)
 		}
-		content += fmt.Sprintf("total guilds: %s", humanize.Comma(int64(fullStatus.NumGuilds)))
+		content += fmt.Sprintf("total guilds: %s\n", humanize.Comma(int64(fullStatus.NumGuilds)))
 
-		_, err := helpers.SendMessage(msg.ChannelID, content)
-		helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		if len(clusterShards.Restarts) > 0 {
+			content += "\nRestarts:\n"
+			for _, restart := range clusterShards.Restarts {
+				content += fmt.Sprintf("`%s` Shard **%d**: %s",
+					restart.Time.UTC().Format(time.RFC3339), restart.Shard, restart.Reason)
+				if restart.Error != "" {
+					content += fmt.Sprintf(" (failed: %s)", restart.Error)
+				}
+				content += "\n"
+			}
+		}
+
+		for _, page := range helpers.Pagify(content, "\n") {
+			_, err = helpers.SendMessage(msg.ChannelID, page)
+			helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+		}
 		return
 	}
 }
@@ -1,11 +1,14 @@
 package plugins
 
 import (
+	"sort"
 	"strings"
+	"time"
 
 	"fmt"
 
 	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/cluster"
 	"github.com/Seklfreak/Robyul2/helpers"
 	"github.com/Seklfreak/Robyul2/models"
 	"github.com/Seklfreak/Robyul2/shardmanager"
@@ -25,7 +28,36 @@ func (m *Storage) Commands() []string {
 	}
 }
 
+const (
+	storageGarbageCollectionInterval = time.Hour * 6
+)
+
 func (m *Storage) Init(session *shardmanager.Manager) {
+	go m.garbageCollectionLoop()
+}
+
+// garbageCollectionLoop deletes blobs which have not been referenced for storage.gc_after_days
+func (m *Storage) garbageCollectionLoop() {
+	defer helpers.Recover()
+	defer func() {
+		go func() {
+			m.logger().Error("the garbageCollectionLoop died. Please investigate! Will be restarted in 60 seconds")
+			time.Sleep(60 * time.Second)
+			m.garbageCollectionLoop()
+		}()
+	}()
+
+	for {
+		time.Sleep(storageGarbageCollectionInterval)
+
+		days, _ := helpers.GetConfig().Path("storage.gc_after_days").Data().(float64)
+		if days <= 0 || !cluster.IsLeader("storage-gc") {
+			continue
+		}
+
+		_, err := helpers.CollectStorageGarbage(time.Duration(days*24) * time.Hour)
+		helpers.RelaxLog(err)
+	}
 }
 
 func (m *Storage) Action(command string, content string, msg *discordgo.Message, session *discordgo.Session) {
@@ -45,9 +77,81 @@ func (m *Storage) Action(command string, content string, msg *discordgo.Message,
 func (m *Storage) actionStart(args []string, in *discordgo.Message, out **discordgo.MessageSend) storageAction {
 	cache.GetSession().SessionForGuildS(in.GuildID).ChannelTyping(in.ChannelID)
 
+	if len(args) >= 1 && args[0] == "usage" {
+		return m.actionUsage
+	}
+
 	return m.actionStatus
 }
 
+// [p]storage usage
+func (m *Storage) actionUsage(args []string, in *discordgo.Message, out **discordgo.MessageSend) storageAction {
+	channel, err := helpers.GetChannel(in.ChannelID)
+	helpers.Relax(err)
+
+	guild, err := helpers.GetGuild(channel.GuildID)
+	helpers.Relax(err)
+
+	guildUsage, err := helpers.GetStorageUsage("guildid", guild.ID)
+	helpers.Relax(err)
+
+	userUsage, err := helpers.GetStorageUsage("userid", in.Author.ID)
+	helpers.Relax(err)
+
+	embed := &discordgo.MessageEmbed{
+		Color: 0xFADED,
+		Title: "Storage Usage",
+		Fields: []*discordgo.MessageEmbedField{
+			{
+				Name:  "On " + guild.Name,
+				Value: m.getUsageText(guildUsage),
+			},
+			{
+				Name:  "Your Files on all Robyul Servers",
+				Value: m.getUsageText(userUsage),
+			},
+		},
+	}
+
+	if helpers.IsRobyulMod(in.Author.ID) {
+		if cacheStats, ok := helpers.GetStorageCacheStats(); ok {
+			maxSizeText := "unlimited"
+			if cacheStats.MaxSize > 0 {
+				maxSizeText = humanize.Bytes(uint64(cacheStats.MaxSize))
+			}
+			embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+				Name: "Cache",
+				Value: fmt.Sprintf("%s of %s (%d files)\n%d hits, %d misses, %d evictions",
+					humanize.Bytes(uint64(cacheStats.Size)), maxSizeText, cacheStats.Objects,
+					cacheStats.Hits, cacheStats.Misses, cacheStats.Evictions),
+			})
+		}
+	}
+
+	*out = &discordgo.MessageSend{Embed: embed}
+	return m.actionFinish
+}
+
+func (m *Storage) getUsageText(usage helpers.StorageUsage) (text string) {
+	text = "**Storage:** " + humanize.Bytes(uint64(usage.Total))
+	if usage.Quota > 0 {
+		text += fmt.Sprintf(" of %s (%.1f %%)",
+			humanize.Bytes(uint64(usage.Quota)), float64(usage.Total)/float64(usage.Quota)*100)
+	}
+	text += fmt.Sprintf(" (%d files)", usage.Files)
+
+	sources := make([]string, 0, len(usage.BySource))
+	for sourceName := range usage.BySource {
+		sources = append(sources, sourceName)
+	}
+	sort.Strings(sources)
+
+	for _, sourceName := range sources {
+		text += fmt.Sprintf("\n%s: %s", strings.Title(sourceName), humanize.Bytes(uint64(usage.BySource[sourceName])))
+	}
+	return text
+}
+
 // [p]storage
 func (m *Storage) actionStatus(args []string, in *discordgo.Message, out **discordgo.MessageSend) storageAction {
 	channel, err := helpers.GetChannel(in.ChannelID)
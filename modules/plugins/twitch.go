@@ -15,6 +15,7 @@ import (
 	"github.com/pkg/errors"
 
 	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/feeds"
 	"github.com/Seklfreak/Robyul2/helpers"
 	"github.com/Seklfreak/Robyul2/metrics"
 	"github.com/Seklfreak/Robyul2/models"
@@ -38,6 +39,7 @@ const (
 	twitchUsersEndpoint        = "https://api.twitch.tv/helix/users?login=%s"
 	twitchRefreshTokenEndpoint = "https://id.twitch.tv/oauth2/token?client_id=%s&client_secret=%s"
 	twitchHexColor             = "#6441a5"
+	twitchRequestsPerMinute    = 600
 )
 
 type TwitchUser struct {
@@ -126,121 +128,147 @@ func (m *Twitch) Init(session *shardmanager.Manager) {
 	m.secret = helpers.GetConfig().Path("twitch.secret").Data().(string)
 	m.refreshToken = helpers.GetConfig().Path("twitch.refresh_token").Data().(string)
 
-	go m.checkTwitchFeedsLoop()
-	cache.GetLogger().WithField("module", "twitch").Info("Started twitch loop (60s)")
+	feeds.NewPoller(&twitchFeedsSource{twitch: m}, feeds.Options{
+		Interval:    time.Minute,
+		Jitter:      0.1,
+		Budget:      feeds.NewBudget(twitchRequestsPerMinute, time.Minute),
+		RefreshTime: metrics.TwitchRefreshTime,
+	}).Start()
 }
-func (m *Twitch) checkTwitchFeedsLoop() {
-	defer helpers.Recover()
-	defer func() {
-		go func() {
-			cache.GetLogger().WithField("module", "twitch").Info("The checkTwitchFeedsLoop died. Please investigate! Will be restarted in 60 seconds")
-			time.Sleep(60 * time.Second)
-			m.checkTwitchFeedsLoop()
-		}()
-	}()
 
+// twitchFeedsSource polls the stream status of all twitch channels with feeds
+type twitchFeedsSource struct {
+	twitch *Twitch
+}
+
+func (s *twitchFeedsSource) Name() string {
+	return "twitch"
+}
+
+func (s *twitchFeedsSource) Subscriptions() (bundled map[string][]feeds.Subscription, err error) {
 	var entries []models.TwitchEntry
-	var bundledEntries map[string][]models.TwitchEntry
-
-	logger := cache.GetLogger().WithField("module", "twitch")
-
-	for {
-		err := helpers.MDbIterWithoutLogging(helpers.MdbCollection(models.TwitchTable).Find(nil)).All(&entries)
-		helpers.Relax(err)
-
-		// migration from twitch channel names to twitch user ids
-		// for _, entry := range entries {
-		// 	if entry.TwitchUserID != "" {
-		// 		continue
-		// 	}
-		//
-		// 	twichUserID, err := m.getTwitchID(entry.TwitchChannelName)
-		// 	if err != nil {
-		// 		continue
-		// 	}
-		//
-		// 	entry.TwitchUserID = twichUserID
-		//
-		// 	err = helpers.MDbUpdate(models.TwitchTable, entry.ID, entry)
-		// 	if err != nil {
-		// 		continue
-		// 	}
-		//
-		// 	logger.WithField("TwitchUserID", twichUserID).
-		// 		WithField("entryID", entry.ID).
-		// 		WithField("TwitchChannelName", entry.TwitchChannelName).
-		// 		Info("set Twitch User ID as part of migration")
-		// }
-
-		bundledEntries = make(map[string][]models.TwitchEntry, 0)
-
-		for _, entry := range entries {
+	err = helpers.MDbIterWithoutLogging(helpers.MdbCollection(models.TwitchTable).Find(nil)).All(&entries)
+	if err != nil {
+		return nil, err
+	}
+
+	bundled = make(map[string][]feeds.Subscription)
+	for _, entry := range entries {
+		// channels of guilds on other processes are not in the state
+		if cache.GetSession().IsLocalGuild(entry.GuildID) {
 			channel, err := helpers.GetChannelWithoutApi(entry.ChannelID)
 			if err != nil || channel == nil || channel.ID == "" {
-				//cache.GetLogger().WithField("module", "twitch").Warn(fmt.Sprintf("skipped twitch @%s for Channel #%s on Guild #%s: channel not found!",
-				//	entry.TwitchChannelName, entry.ChannelID, entry.ServerID))
 				continue
 			}
+		}
 
-			if entry.TwitchUserID == "" {
-				continue
-			}
+		if entry.TwitchUserID == "" {
+			continue
+		}
 
-			if _, ok := bundledEntries[entry.TwitchUserID]; ok {
-				bundledEntries[entry.TwitchUserID] = append(bundledEntries[entry.TwitchUserID], entry)
-			} else {
-				bundledEntries[entry.TwitchUserID] = []models.TwitchEntry{entry}
-			}
+		bundled[entry.TwitchUserID] = append(bundled[entry.TwitchUserID], entry)
+	}
+
+	return bundled, nil
+}
+
+// Fetch returns the current stream as a single item, or no items if the channel is offline
+func (s *twitchFeedsSource) Fetch(twitchUserID string) (items []feeds.Item, err error) {
+	twitchStatus, err := s.twitch.getTwitchStatus(twitchUserID)
+	if err != nil {
+		if strings.Contains(err.Error(), "user not found") ||
+			strings.Contains(err.Error(), "channel offline") {
+			return nil, nil
 		}
+		return nil, err
+	}
 
-		logger.Infof("checking %d channels for %d feeds", len(bundledEntries), len(entries))
-		start := time.Now()
+	return []feeds.Item{{
+		ID:   strconv.FormatInt(twitchStatus.Stream.ID, 10),
+		Time: twitchStatus.Stream.CreatedAt,
+		Data: *twitchStatus,
+	}}, nil
+}
 
-		// TODO: Check multiple entries at once
-		for twitchUserID, entries := range bundledEntries {
-			// logger.WithField("TwitchUserID", twitchUserID).
-			// 	WithField("TwitchChannelName", entries[0].TwitchChannelName).Info("checking twitch channel")
+func (s *twitchFeedsSource) Handle(twitchUserID string, items []feeds.Item, subscriptions []feeds.Subscription) (err error) {
+	for _, subscription := range subscriptions {
+		entry := subscription.(models.TwitchEntry)
+		before := entry.Live
+		wasLive := entry.IsLive
 
-			//cache.GetLogger().WithField("module", "twitch").Info(fmt.Sprintf("checking Twitch Channel %s", twitchChannelName))
-			twitchStatus, err := m.getTwitchStatus(twitchUserID)
-			if err != nil &&
-				!strings.Contains(err.Error(), "user not found") &&
-				!strings.Contains(err.Error(), "channel offline") {
-				logger.WithField("twitchUserID", twitchUserID).WithError(err).Error("failure checking twitch channel")
+		if len(items) <= 0 {
+			if !entry.IsLive {
 				continue
 			}
 
-			for _, entry := range entries {
-				changes := false
-				if !entry.IsLive {
-					if twitchStatus != nil && twitchStatus.Stream.ID != 0 {
-						go func(gEntry models.TwitchEntry, gTwitchStatus TwitchStatus) {
-							defer helpers.Recover()
-							m.postTwitchLiveToChannel(gEntry, gTwitchStatus)
-						}(entry, *twitchStatus)
-						entry.IsLive = true
-						changes = true
-					}
-				} else {
-					if twitchStatus == nil || twitchStatus.Stream.ID == 0 {
-						entry.IsLive = false
-						changes = true
-					}
+			entry.IsLive = false
+			entry.Live.EndedAt = time.Now()
+			if entry.Live.MessageID != "" {
+				err = s.twitch.endTwitchLiveMessage(entry)
+				if err != nil && !isTwitchIgnorableError(err) {
+					helpers.RelaxLog(err)
 				}
+			}
+		} else {
+			twitchStatus := items[0].Data.(TwitchStatus)
+
+			if entry.Live.StreamID != items[0].ID {
+				entry.Live = models.TwitchLiveState{
+					StreamID:  items[0].ID,
+					StartedAt: twitchStatus.Stream.CreatedAt,
+					// entries which were live before the live state was tracked have been posted already
+					Posted: entry.IsLive && entry.Live.StreamID == "",
+				}
+			}
+			entry.IsLive = true
+			entry.Live.EndedAt = time.Time{}
+			entry.Live.Title = twitchStatus.Stream.Channel.Status
+			entry.Live.Game = twitchStatus.Stream.Game
+			if twitchStatus.Stream.Viewers > entry.Live.PeakViewers {
+				entry.Live.PeakViewers = twitchStatus.Stream.Viewers
+			}
 
-				if changes {
-					err = helpers.MDbUpdateWithoutLogging(models.TwitchTable, entry.ID, entry)
-					helpers.Relax(err)
+			if !entry.Live.Posted {
+				// streams not matching the game filter are posted once they switch to a matching game
+				if twitchGameFilterMatches(entry, twitchStatus.Stream.Game) {
+					entry.Live.Posted = true
+					entry.Live.EditedAt = time.Now()
+					message, err := s.twitch.postTwitchLiveToChannel(entry, twitchStatus)
+					if err != nil {
+						if !isTwitchIgnorableError(err) {
+							helpers.RelaxLog(err)
+						}
+					} else {
+						entry.Live.MessageID = message.ID
+					}
+				}
+			} else if entry.Live.MessageID != "" &&
+				(!wasLive ||
+					entry.Live.Title != before.Title ||
+					entry.Live.Game != before.Game ||
+					time.Since(entry.Live.EditedAt) >= twitchLiveEditInterval) {
+				entry.Live.EditedAt = time.Now()
+				err = s.twitch.editTwitchLiveMessage(entry, twitchStatus)
+				if err != nil {
+					if isTwitchIgnorableError(err) {
+						// stop editing messages which are gone
+						entry.Live.MessageID = ""
+					} else {
+						helpers.RelaxLog(err)
+					}
 				}
 			}
 		}
 
-		elapsed := time.Since(start)
-		logger.Infof("checked %d channels for %d feeds, took %s", len(bundledEntries), len(entries), elapsed)
-		metrics.TwitchRefreshTime.Set(elapsed.Seconds())
-
-		time.Sleep(30 * time.Second)
+		if entry.IsLive != wasLive || entry.Live != before {
+			err = helpers.MDbUpdateWithoutLogging(models.TwitchTable, entry.ID, entry)
+			if err != nil {
+				return err
+			}
+		}
 	}
+
+	return nil
 }
 
 func (m *Twitch) Action(command string, content string, msg *discordgo.Message, session *discordgo.Session) {
@@ -389,6 +417,169 @@ func (m *Twitch) Action(command string, content string, msg *discordgo.Message,
 					return
 				}
 			})
+		case "filter": // [p]twitch filter <id> [<game>[, <game>…]|reset]
+			helpers.RequireMod(msg, func() {
+				entry, ok := m.getEntryFromArgs(args, msg)
+				if !ok {
+					return
+				}
+
+				if len(args) < 3 {
+					if len(entry.GameFilter) <= 0 {
+						_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.twitch.filter-none"))
+						helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+						return
+					}
+					_, err := helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.twitch.filter-status",
+						"`"+strings.Join(entry.GameFilter, "`, `")+"`"))
+					helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+					return
+				}
+
+				filterText := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(content), args[0]))
+				filterText = strings.TrimSpace(strings.TrimPrefix(filterText, args[1]))
+
+				beforeGameFilter := entry.GameFilter
+				entry.GameFilter = nil
+				if strings.ToLower(filterText) != "reset" {
+					for _, game := range strings.Split(filterText, ",") {
+						game = strings.TrimSpace(game)
+						if game != "" {
+							entry.GameFilter = append(entry.GameFilter, game)
+						}
+					}
+				}
+
+				err := helpers.MDbUpdate(models.TwitchTable, entry.ID, entry)
+				helpers.Relax(err)
+
+				_, err = helpers.EventlogLog(time.Now(), entry.GuildID, helpers.MdbIdToHuman(entry.ID),
+					models.EventlogTargetTypeRobyulTwitchFeed, msg.Author.ID,
+					models.EventlogTypeRobyulTwitchFeedUpdate, "",
+					[]models.ElasticEventlogChange{
+						{
+							Key:      "twitch_feed_gamefilter",
+							OldValue: strings.Join(beforeGameFilter, ", "),
+							NewValue: strings.Join(entry.GameFilter, ", "),
+						},
+					},
+					[]models.ElasticEventlogOption{
+						{
+							Key:   "twitch_feed_channelname",
+							Value: entry.TwitchChannelName,
+						},
+					}, false)
+				helpers.RelaxLog(err)
+
+				if len(entry.GameFilter) <= 0 {
+					_, err = helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.twitch.filter-reset", entry.TwitchChannelName))
+				} else {
+					_, err = helpers.SendMessage(msg.ChannelID, helpers.GetTextF("plugins.twitch.filter-success",
+						entry.TwitchChannelName, "`"+strings.Join(entry.GameFilter, "`, `")+"`"))
+				}
+				helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+			})
+		case "template": // [p]twitch template <id> [<embed code>|reset]
+			helpers.RequireMod(msg, func() {
+				entry, ok := m.getEntryFromArgs(args, msg)
+				if !ok {
+					return
+				}
+
+				if len(args) < 3 {
+					if entry.EmbedCode == "" {
+						_, err := helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.twitch.template-none"))
+						helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+						return
+					}
+					_, err := helpers.SendMessageBoxed(msg.ChannelID, entry.EmbedCode)
+					helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+					return
+				}
+
+				embedCode := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(content), args[0]))
+				embedCode = strings.TrimSpace(strings.TrimPrefix(embedCode, args[1]))
+				if strings.ToLower(embedCode) == "reset" {
+					embedCode = ""
+				}
+				if helpers.IsEmbedCode(embedCode) {
+					_, _, err := helpers.ParseEmbedCode(embedCode)
+					if err != nil {
+						helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.invalid"))
+						return
+					}
+				}
+
+				beforeEmbedCode := entry.EmbedCode
+				entry.EmbedCode = embedCode
+				err := helpers.MDbUpdate(models.TwitchTable, entry.ID, entry)
+				helpers.Relax(err)
+
+				_, err = helpers.EventlogLog(time.Now(), entry.GuildID, helpers.MdbIdToHuman(entry.ID),
+					models.EventlogTargetTypeRobyulTwitchFeed, msg.Author.ID,
+					models.EventlogTypeRobyulTwitchFeedUpdate, "",
+					[]models.ElasticEventlogChange{
+						{
+							Key:      "twitch_feed_template",
+							OldValue: beforeEmbedCode,
+							NewValue: entry.EmbedCode,
+						},
+					},
+					[]models.ElasticEventlogOption{
+						{
+							Key:   "twitch_feed_channelname",
+							Value: entry.TwitchChannelName,
+						},
+					}, false)
+				helpers.RelaxLog(err)
+
+				_, err = helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.twitch.template-success"))
+				helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+			})
+		case "test": // [p]twitch test <id>
+			helpers.RequireMod(msg, func() {
+				entry, ok := m.getEntryFromArgs(args, msg)
+				if !ok {
+					return
+				}
+
+				session.ChannelTyping(msg.ChannelID)
+
+				// preview with the channel information if the channel is offline
+				live := true
+				twitchStatus, err := m.getTwitchStatus(entry.TwitchUserID)
+				if err != nil && strings.Contains(err.Error(), "channel offline") {
+					live = false
+					twitchStatus, err = m.getTwitchChannelStatus(entry.TwitchUserID)
+				}
+				if err != nil {
+					if strings.Contains(err.Error(), "user not found") {
+						helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.twitch.channel-not-found"))
+						return
+					}
+					helpers.Relax(err)
+				}
+
+				var resultText string
+				if live {
+					resultText = helpers.GetText("plugins.twitch.test-live")
+				} else {
+					resultText = helpers.GetText("plugins.twitch.test-offline")
+				}
+				if twitchGameFilterMatches(entry, twitchStatus.Stream.Game) {
+					resultText += "\n" + helpers.GetTextF("plugins.twitch.test-filter-match", twitchStatus.Stream.Game)
+				} else {
+					resultText += "\n" + helpers.GetTextF("plugins.twitch.test-filter-no-match", twitchStatus.Stream.Game)
+				}
+
+				// do not mention the role for tests
+				entry.MentionRoleID = ""
+				messageSend := getTwitchLiveMessageSend(entry, *twitchStatus)
+				messageSend.Content = strings.TrimSpace(resultText + "\n" + messageSend.Content)
+
+				_, err = helpers.SendComplex(msg.ChannelID, messageSend)
+				helpers.RelaxMessage(err, msg.ChannelID, msg.ID)
+			})
 		case "list": // [p]twitch list
 			currentChannel, err := helpers.GetChannel(msg.ChannelID)
 			helpers.Relax(err)
@@ -411,6 +602,12 @@ func (m *Twitch) Action(command string, content string, msg *discordgo.Message,
 					helpers.Relax(err)
 					mentionText += fmt.Sprintf(" mentioning `@%s`", role.Name)
 				}
+				if len(entry.GameFilter) > 0 {
+					mentionText += fmt.Sprintf(" playing `%s`", strings.Join(entry.GameFilter, "`, `"))
+				}
+				if entry.EmbedCode != "" {
+					mentionText += " with a custom template"
+				}
 				resultMessage += fmt.Sprintf("`%s`: Twitch Channel `%s` posting to <#%s>%s\n", helpers.MdbIdToHuman(entry.ID), entry.TwitchChannelName, entry.ChannelID, mentionText)
 			}
 			resultMessage += fmt.Sprintf("Found **%d** Twitch Channels in total.", len(entryBucket))
@@ -477,6 +674,28 @@ func (m *Twitch) Action(command string, content string, msg *discordgo.Message,
 	}
 }
 
+func (m *Twitch) getEntryFromArgs(args []string, msg *discordgo.Message) (entry models.TwitchEntry, ok bool) {
+	if len(args) < 2 {
+		helpers.SendMessage(msg.ChannelID, helpers.GetText("bot.arguments.too-few"))
+		return entry, false
+	}
+
+	channel, err := helpers.GetChannel(msg.ChannelID)
+	helpers.Relax(err)
+
+	err = helpers.MdbOne(
+		helpers.MdbCollection(models.TwitchTable).Find(bson.M{"guildid": channel.GuildID, "_id": helpers.HumanToMdbId(args[1])}),
+		&entry,
+	)
+	if helpers.IsMdbNotFound(err) {
+		helpers.SendMessage(msg.ChannelID, helpers.GetText("plugins.twitch.channel-delete-not-found-error"))
+		return entry, false
+	}
+	helpers.Relax(err)
+
+	return entry, true
+}
+
 func (m *Twitch) newTwitchRequest(method, uri string, body io.Reader) (*http.Request, error) {
 	req, err := http.NewRequest(method, uri, body)
 	if err != nil {
@@ -578,47 +797,6 @@ func (m *Twitch) getTwitchStatus(id string) (*TwitchStatus, error) {
 	return &twitchStatus, nil
 }
 
-func (m *Twitch) postTwitchLiveToChannel(entry models.TwitchEntry, twitchStatus TwitchStatus) {
-	twitchStreamName := twitchStatus.Stream.Channel.DisplayName
-	if strings.ToLower(twitchStatus.Stream.Channel.Name) != strings.ToLower(twitchStatus.Stream.Channel.DisplayName) {
-		twitchStreamName += fmt.Sprintf(" (%s)", twitchStatus.Stream.Channel.Name)
-	}
-	var mentionText string
-	if entry.MentionRoleID != "" {
-		mentionText = fmt.Sprintf("<@&%s>\n", entry.MentionRoleID)
-	}
-
-	twitchChannelEmbed := &discordgo.MessageEmbed{
-		Title:  helpers.GetTextF("plugins.twitch.wentlive-embed-title", twitchStreamName),
-		URL:    twitchStatus.Stream.Channel.URL,
-		Footer: &discordgo.MessageEmbedFooter{Text: helpers.GetText("plugins.twitch.embed-footer")},
-		Fields: []*discordgo.MessageEmbedField{
-			{Name: "Followers", Value: humanize.Comma(int64(twitchStatus.Stream.Channel.Followers)), Inline: true},
-			{Name: "Total Views", Value: humanize.Comma(int64(twitchStatus.Stream.Channel.Views)), Inline: true}},
-		Color: helpers.GetDiscordColorFromHex(twitchHexColor),
-	}
-	if twitchStatus.Stream.Channel.Logo != "" {
-		twitchChannelEmbed.Thumbnail = &discordgo.MessageEmbedThumbnail{URL: twitchStatus.Stream.Channel.Logo}
-	}
-	if twitchStatus.Stream.Preview.Medium != "" {
-		twitchChannelEmbed.Image = &discordgo.MessageEmbedImage{URL: twitchStatus.Stream.Preview.Medium + "?" + strconv.FormatInt(time.Now().Unix(), 10)}
-	}
-	if twitchStatus.Stream.Channel.Status != "" {
-		twitchChannelEmbed.Description += fmt.Sprintf("**%s**\n", twitchStatus.Stream.Channel.Status)
-	}
-	if twitchStatus.Stream.Game != "" {
-		twitchChannelEmbed.Description += fmt.Sprintf("playing **%s**\n", twitchStatus.Stream.Game)
-	}
-	if twitchChannelEmbed.Description != "" {
-		twitchChannelEmbed.Description = strings.Trim(twitchChannelEmbed.Description, "\n")
-	}
-	_, err := helpers.SendComplex(entry.ChannelID, &discordgo.MessageSend{
-		Content: mentionText + fmt.Sprintf("<%s>", twitchStatus.Stream.Channel.URL),
-		Embed:   twitchChannelEmbed,
-	})
-	helpers.Relax(err)
-}
-
 func (m *Twitch) performTokenRefresh(ctx context.Context) error {
 	if time.Since(m.lastRefresh) < 1*time.Hour {
 		return errors.New("refreshed too shortly again, should not require another refresh")
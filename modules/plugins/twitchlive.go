@@ -0,0 +1,259 @@
+package plugins
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/bwmarrin/discordgo"
+	humanize "github.com/dustin/go-humanize"
+	"github.com/pkg/errors"
+)
+
+const (
+	twitchChannelEndpoint = "https://api.twitch.tv/kraken/channels/%s"
+	twitchVideosEndpoint  = "https://api.twitch.tv/kraken/channels/%s/videos?broadcast_type=archive&limit=1"
+	// live messages are edited at most once in this interval, unless the title or game changed
+	twitchLiveEditInterval = time.Minute * 5
+)
+
+type twitchVideos struct {
+	Videos []struct {
+		ID        string    `json:"_id"`
+		URL       string    `json:"url"`
+		CreatedAt time.Time `json:"created_at"`
+	} `json:"videos"`
+}
+
+// twitchGameFilterMatches returns true if the game matches the game filter of the entry, or the entry has no filter
+func twitchGameFilterMatches(entry models.TwitchEntry, game string) bool {
+	if len(entry.GameFilter) <= 0 {
+		return true
+	}
+
+	for _, filter := range entry.GameFilter {
+		if strings.EqualFold(strings.TrimSpace(filter), strings.TrimSpace(game)) {
+			return true
+		}
+	}
+	return false
+}
+
+// isTwitchIgnorableError returns true for errors caused by the channel or message being gone, or missing permissions
+func isTwitchIgnorableError(err error) bool {
+	if errD, ok := err.(*discordgo.RESTError); ok && errD.Message != nil {
+		return errD.Message.Code == discordgo.ErrCodeMissingPermissions ||
+			errD.Message.Code == discordgo.ErrCodeMissingAccess ||
+			errD.Message.Code == discordgo.ErrCodeUnknownChannel ||
+			errD.Message.Code == discordgo.ErrCodeUnknownMessage
+	}
+	return false
+}
+
+func getTwitchStreamName(twitchStatus TwitchStatus) string {
+	twitchStreamName := twitchStatus.Stream.Channel.DisplayName
+	if strings.ToLower(twitchStatus.Stream.Channel.Name) != strings.ToLower(twitchStatus.Stream.Channel.DisplayName) {
+		twitchStreamName += fmt.Sprintf(" (%s)", twitchStatus.Stream.Channel.Name)
+	}
+	return twitchStreamName
+}
+
+// getTwitchLiveMessageSend builds the live message, from the custom template of the entry or the default embed
+func getTwitchLiveMessageSend(entry models.TwitchEntry, twitchStatus TwitchStatus) *discordgo.MessageSend {
+	var mentionText string
+	if entry.MentionRoleID != "" {
+		mentionText = fmt.Sprintf("<@&%s>\n", entry.MentionRoleID)
+	}
+
+	var previewURL string
+	if twitchStatus.Stream.Preview.Medium != "" {
+		previewURL = twitchStatus.Stream.Preview.Medium + "?" + strconv.FormatInt(time.Now().Unix(), 10)
+	}
+
+	if entry.EmbedCode != "" {
+		messageSend := &discordgo.MessageSend{
+			Content: entry.EmbedCode,
+		}
+		if helpers.IsEmbedCode(entry.EmbedCode) {
+			ptext, embed, err := helpers.ParseEmbedCode(entry.EmbedCode)
+			if err == nil {
+				messageSend.Content = ptext
+				messageSend.Embed = embed
+			}
+		}
+		messageSend.Content = strings.TrimSpace(mentionText + messageSend.Content)
+
+		return helpers.ReplaceMessageSend(messageSend, []*helpers.ReplaceValues{
+			{Before: "{TWITCH_NAME}", After: twitchStatus.Stream.Channel.DisplayName},
+			{Before: "{TWITCH_LINK}", After: twitchStatus.Stream.Channel.URL},
+			{Before: "{TWITCH_TITLE}", After: twitchStatus.Stream.Channel.Status},
+			{Before: "{TWITCH_GAME}", After: twitchStatus.Stream.Game},
+			{Before: "{TWITCH_VIEWERS}", After: humanize.Comma(int64(twitchStatus.Stream.Viewers))},
+			{Before: "{TWITCH_LOGO}", After: twitchStatus.Stream.Channel.Logo},
+			{Before: "{TWITCH_PREVIEW}", After: previewURL},
+		})
+	}
+
+	twitchChannelEmbed := &discordgo.MessageEmbed{
+		Title:  helpers.GetTextF("plugins.twitch.wentlive-embed-title", getTwitchStreamName(twitchStatus)),
+		URL:    twitchStatus.Stream.Channel.URL,
+		Footer: &discordgo.MessageEmbedFooter{Text: helpers.GetText("plugins.twitch.embed-footer")},
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Viewers", Value: humanize.Comma(int64(twitchStatus.Stream.Viewers)), Inline: true},
+			{Name: "Followers", Value: humanize.Comma(int64(twitchStatus.Stream.Channel.Followers)), Inline: true},
+			{Name: "Total Views", Value: humanize.Comma(int64(twitchStatus.Stream.Channel.Views)), Inline: true}},
+		Color: helpers.GetDiscordColorFromHex(twitchHexColor),
+	}
+	if twitchStatus.Stream.Channel.Logo != "" {
+		twitchChannelEmbed.Thumbnail = &discordgo.MessageEmbedThumbnail{URL: twitchStatus.Stream.Channel.Logo}
+	}
+	if previewURL != "" {
+		twitchChannelEmbed.Image = &discordgo.MessageEmbedImage{URL: previewURL}
+	}
+	if twitchStatus.Stream.Channel.Status != "" {
+		twitchChannelEmbed.Description += fmt.Sprintf("**%s**\n", twitchStatus.Stream.Channel.Status)
+	}
+	if twitchStatus.Stream.Game != "" {
+		twitchChannelEmbed.Description += fmt.Sprintf("playing **%s**\n", twitchStatus.Stream.Game)
+	}
+	if twitchChannelEmbed.Description != "" {
+		twitchChannelEmbed.Description = strings.Trim(twitchChannelEmbed.Description, "\n")
+	}
+
+	return &discordgo.MessageSend{
+		Content: mentionText + fmt.Sprintf("<%s>", twitchStatus.Stream.Channel.URL),
+		Embed:   twitchChannelEmbed,
+	}
+}
+
+// getTwitchEndedEmbed builds the summary the live message is turned into after the stream ended
+func getTwitchEndedEmbed(entry models.TwitchEntry, vodURL string) *discordgo.MessageEmbed {
+	twitchURL := "https://www.twitch.tv/" + entry.TwitchChannelName
+
+	endedEmbed := &discordgo.MessageEmbed{
+		Title: helpers.GetTextF("plugins.twitch.ended-embed-title", entry.TwitchChannelName,
+			helpers.HumanizeDuration(entry.Live.EndedAt.Sub(entry.Live.StartedAt).Round(time.Minute))),
+		URL:    twitchURL,
+		Footer: &discordgo.MessageEmbedFooter{Text: helpers.GetText("plugins.twitch.embed-footer")},
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "Peak Viewers", Value: humanize.Comma(int64(entry.Live.PeakViewers)), Inline: true}},
+		Color: helpers.GetDiscordColorFromHex(twitchHexColor),
+	}
+	if vodURL != "" {
+		endedEmbed.Fields = append(endedEmbed.Fields, &discordgo.MessageEmbedField{
+			Name: "VOD", Value: vodURL, Inline: true,
+		})
+	}
+	if entry.Live.Title != "" {
+		endedEmbed.Description += fmt.Sprintf("**%s**\n", entry.Live.Title)
+	}
+	if entry.Live.Game != "" {
+		endedEmbed.Description += fmt.Sprintf("played **%s**\n", entry.Live.Game)
+	}
+	if endedEmbed.Description != "" {
+		endedEmbed.Description = strings.Trim(endedEmbed.Description, "\n")
+	}
+	return endedEmbed
+}
+
+func (m *Twitch) postTwitchLiveToChannel(entry models.TwitchEntry, twitchStatus TwitchStatus) (message *discordgo.Message, err error) {
+	messages, err := helpers.SendComplex(entry.ChannelID, getTwitchLiveMessageSend(entry, twitchStatus))
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) <= 0 {
+		return nil, errors.New("no message sent")
+	}
+
+	return messages[len(messages)-1], nil
+}
+
+func (m *Twitch) editTwitchLiveMessage(entry models.TwitchEntry, twitchStatus TwitchStatus) (err error) {
+	messageSend := getTwitchLiveMessageSend(entry, twitchStatus)
+
+	_, err = helpers.EditComplex(&discordgo.MessageEdit{
+		Content: &messageSend.Content,
+		Embed:   messageSend.Embed,
+		ID:      entry.Live.MessageID,
+		Channel: entry.ChannelID,
+	})
+	return err
+}
+
+func (m *Twitch) endTwitchLiveMessage(entry models.TwitchEntry) (err error) {
+	vodURL, err := m.getTwitchArchiveURL(entry.TwitchUserID, entry.Live.StartedAt)
+	helpers.RelaxLog(err)
+
+	_, err = helpers.EditComplex(&discordgo.MessageEdit{
+		Embed:   getTwitchEndedEmbed(entry, vodURL),
+		ID:      entry.Live.MessageID,
+		Channel: entry.ChannelID,
+	})
+	return err
+}
+
+// getTwitchArchiveURL returns the link to the VOD of the stream started at the given time, or an empty string if there is none
+func (m *Twitch) getTwitchArchiveURL(id string, startedAt time.Time) (link string, err error) {
+	body, err := m.getTwitchKraken(fmt.Sprintf(twitchVideosEndpoint, id))
+	if err != nil {
+		return "", err
+	}
+
+	var videos twitchVideos
+	err = json.Unmarshal(body, &videos)
+	if err != nil {
+		return "", err
+	}
+
+	// the archive is created when the stream starts
+	if len(videos.Videos) <= 0 || videos.Videos[0].CreatedAt.Before(startedAt.Add(-time.Minute*10)) {
+		return "", nil
+	}
+
+	return videos.Videos[0].URL, nil
+}
+
+// getTwitchChannelStatus returns the channel information in a TwitchStatus, used to preview messages while offline
+func (m *Twitch) getTwitchChannelStatus(id string) (*TwitchStatus, error) {
+	body, err := m.getTwitchKraken(fmt.Sprintf(twitchChannelEndpoint, id))
+	if err != nil {
+		return nil, err
+	}
+
+	var twitchStatus TwitchStatus
+	err = json.Unmarshal(body, &twitchStatus.Stream.Channel)
+	if err != nil {
+		return nil, err
+	}
+	if twitchStatus.Stream.Channel.ID == 0 {
+		return nil, errors.New("user not found")
+	}
+	twitchStatus.Stream.Game = twitchStatus.Stream.Channel.Game
+
+	return &twitchStatus, nil
+}
+
+func (m *Twitch) getTwitchKraken(uri string) ([]byte, error) {
+	request, err := m.newTwitchRequest(http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := helpers.DefaultClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code from twitch: %d", response.StatusCode)
+	}
+
+	return ioutil.ReadAll(response.Body)
+}
@@ -72,6 +72,11 @@ func (r *VLive) checkVliveFeedsLoop() {
 		helpers.Relax(err)
 
 		for _, entry := range entries {
+			// entries of guilds on other processes are checked by these processes
+			if !cache.GetSession().IsLocalGuild(entry.GuildID) {
+				continue
+			}
+
 			// check if channel exists
 			channel, err := helpers.GetChannelWithoutApi(entry.ChannelID)
 			if err != nil || channel == nil || channel.ID == "" {
@@ -10,12 +10,27 @@ import (
 	youtubeService "github.com/Seklfreak/Robyul2/services/youtube"
 
 	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/cluster"
+	robyulFeeds "github.com/Seklfreak/Robyul2/feeds"
 	"github.com/Seklfreak/Robyul2/helpers"
 	"github.com/Seklfreak/Robyul2/models"
 	"github.com/bwmarrin/discordgo"
 	"github.com/sirupsen/logrus"
 )
 
+const (
+	// posted videos are stored per entry with this source name in the shared feeds storage
+	youtubeFeedsSourceName = "youtube"
+)
+
+type youtubeVideo struct {
+	ID           string
+	Title        string
+	ChannelID    string
+	ChannelTitle string
+	ThumbnailURL string
+}
+
 type feeds struct {
 	service *youtubeService.Service
 	running uint32
@@ -53,6 +68,11 @@ func (f *feeds) run() {
 	}()
 
 	for ; ; time.Sleep(10 * time.Second) {
+		// only one process checks the youtube channels
+		if !cluster.IsLeader("youtube-feeds") {
+			continue
+		}
+
 		err := f.service.UpdateCheckingInterval()
 		helpers.Relax(err)
 
@@ -62,12 +82,16 @@ func (f *feeds) run() {
 
 func (f *feeds) check() {
 	t := time.Now().Unix()
+
+	// channels with an active websub subscription receive new videos by push
+	subscribedChannelIDs, err := getWebSubSubscribedChannelIDs()
+	helpers.Relax(err)
+
 	var entries []models.YoutubeChannelEntry
-	err := helpers.MDbIterWithoutLogging(helpers.MdbCollection(models.YoutubeChannelTable).Find(
-		bson.M{"nextchecktime": bson.M{"$lte": t}},
+	err = helpers.MDbIterWithoutLogging(helpers.MdbCollection(models.YoutubeChannelTable).Find(
+		bson.M{"nextchecktime": bson.M{"$lte": t}, "youtubechannelid": bson.M{"$nin": subscribedChannelIDs}},
 	)).All(&entries)
 	helpers.Relax(err)
-	// TODO: test
 
 	for _, e := range entries {
 		e = f.checkChannelFeeds(e)
@@ -80,21 +104,22 @@ func (f *feeds) check() {
 }
 
 func (f *feeds) checkChannelFeeds(e models.YoutubeChannelEntry) models.YoutubeChannelEntry {
-	// check if we have access to channel
-	channel, err := helpers.GetChannelWithoutApi(e.ChannelID)
-	if err != nil || channel == nil || channel.ID == "" {
-		return e
-	}
-
-	// check if we can send messages and embed links in channel
-	channelPermission, err := cache.GetSession().SessionForGuildS(channel.GuildID).State.UserChannelPermissions(cache.GetSession().SessionForGuildS(channel.GuildID).State.User.ID, channel.ID)
-	if err != nil {
+	if !canPostVideos(e) {
 		return e
 	}
 
-	if channelPermission&discordgo.PermissionSendMessages != discordgo.PermissionSendMessages ||
-		channelPermission&discordgo.PermissionEmbedLinks != discordgo.PermissionEmbedLinks {
-		return e
+	// move posted videos from the entry to the shared feeds storage
+	if len(e.YoutubePostedVideos) > 0 {
+		items := make([]robyulFeeds.Item, 0, len(e.YoutubePostedVideos))
+		for _, videoId := range e.YoutubePostedVideos {
+			items = append(items, robyulFeeds.Item{ID: videoId})
+		}
+		err := robyulFeeds.MarkHandled(youtubeFeedsSourceName, helpers.MdbIdToHuman(e.ID), items...)
+		if err != nil {
+			logger().Warn("migrating posted videos failed: " + err.Error())
+			return e
+		}
+		e.YoutubePostedVideos = nil
 	}
 
 	// set iso8601 time which will be used search query filter "published after"
@@ -110,10 +135,7 @@ func (f *feeds) checkChannelFeeds(e models.YoutubeChannelEntry) models.YoutubeCh
 		return e
 	}
 
-	newPostedVideos := make([]string, 0)
-	alreadyPostedVideos := make([]string, 0)
-
-	// check if posted videos and post new videos
+	// post new videos, oldest first
 	for i := len(feeds) - 1; i >= 0; i-- {
 		feed := feeds[i]
 
@@ -121,50 +143,28 @@ func (f *feeds) checkChannelFeeds(e models.YoutubeChannelEntry) models.YoutubeCh
 		if feed.Snippet.Type != "upload" {
 			continue
 		}
-		videoId := feed.ContentDetails.Upload.VideoId
 
-		// check if the video is already posted
-		if f.isPosted(videoId, e.YoutubePostedVideos) {
-			alreadyPostedVideos = append(alreadyPostedVideos, videoId)
-			continue
+		var thumbnailUrl string
+		if feed.Snippet.Thumbnails != nil && feed.Snippet.Thumbnails.High != nil {
+			thumbnailUrl = feed.Snippet.Thumbnails.High.Url
 		}
 
-		// make a message and send to discord channel
-		msg := &discordgo.MessageSend{
-			Content: fmt.Sprintf(youtubeVideoBaseUrl, videoId),
-			Embed: &discordgo.MessageEmbed{
-				Author: &discordgo.MessageEmbedAuthor{
-					Name: feed.Snippet.ChannelTitle,
-					URL:  fmt.Sprintf(youtubeChannelBaseUrl, feed.Snippet.ChannelId),
-				},
-				Title:       helpers.GetTextF("plugins.youtube.channel-embed-title-vod", feed.Snippet.ChannelTitle),
-				URL:         fmt.Sprintf(youtubeVideoBaseUrl, videoId),
-				Description: fmt.Sprintf("**%s**", feed.Snippet.Title),
-				Image:       &discordgo.MessageEmbedImage{URL: feed.Snippet.Thumbnails.High.Url},
-				Footer:      &discordgo.MessageEmbedFooter{Text: "YouTube"},
-				Color:       helpers.GetDiscordColorFromHex(youtubeColor),
-			},
-		}
-
-		_, err = helpers.SendComplex(e.ChannelID, msg)
+		err = postVideo(e, youtubeVideo{
+			ID:           feed.ContentDetails.Upload.VideoId,
+			Title:        feed.Snippet.Title,
+			ChannelID:    feed.Snippet.ChannelId,
+			ChannelTitle: feed.Snippet.ChannelTitle,
+			ThumbnailURL: thumbnailUrl,
+		})
 		if err != nil {
 			logger().Warn(err)
 			break
 		}
-
-		newPostedVideos = append(newPostedVideos, videoId)
-
-		logger().WithFields(logrus.Fields{
-			"title":   feed.Snippet.Title,
-			"channel": e.ChannelID,
-		}).Info("posting video")
 	}
 
 	if err == nil {
 		e.LastSuccessfulCheckTime = e.NextCheckTime
-		e.YoutubePostedVideos = alreadyPostedVideos
 	}
-	e.YoutubePostedVideos = append(e.YoutubePostedVideos, newPostedVideos...)
 
 	return e
 }
@@ -177,7 +177,73 @@ func (f *feeds) setNextCheckTime(e models.YoutubeChannelEntry) models.YoutubeCha
 	return e
 }
 
-func (f *feeds) isPosted(id string, postedIds []string) bool {
+// canPostVideos returns true if we can send messages and embed links in the channel of the entry
+// channels of guilds on other processes are not in the state, posting to them is tried without checking
+func canPostVideos(e models.YoutubeChannelEntry) bool {
+	if !cache.GetSession().IsLocalGuild(e.GuildID) {
+		return true
+	}
+
+	channel, err := helpers.GetChannelWithoutApi(e.ChannelID)
+	if err != nil || channel == nil || channel.ID == "" {
+		return false
+	}
+
+	channelPermission, err := cache.GetSession().SessionForGuildS(channel.GuildID).State.UserChannelPermissions(cache.GetSession().SessionForGuildS(channel.GuildID).State.User.ID, channel.ID)
+	if err != nil {
+		return false
+	}
+
+	return channelPermission&discordgo.PermissionSendMessages == discordgo.PermissionSendMessages &&
+		channelPermission&discordgo.PermissionEmbedLinks == discordgo.PermissionEmbedLinks
+}
+
+// postVideo posts the video to the channel of the entry, unless it has been posted before
+func postVideo(e models.YoutubeChannelEntry, video youtubeVideo) (err error) {
+	key := helpers.MdbIdToHuman(e.ID)
+	item := robyulFeeds.Item{ID: video.ID}
+
+	handled, err := robyulFeeds.IsHandled(youtubeFeedsSourceName, key, item)
+	if err != nil {
+		return err
+	}
+	if handled || isPosted(video.ID, e.YoutubePostedVideos) {
+		return nil
+	}
+
+	// make a message and send to discord channel
+	msg := &discordgo.MessageSend{
+		Content: fmt.Sprintf(youtubeVideoBaseUrl, video.ID),
+		Embed: &discordgo.MessageEmbed{
+			Author: &discordgo.MessageEmbedAuthor{
+				Name: video.ChannelTitle,
+				URL:  fmt.Sprintf(youtubeChannelBaseUrl, video.ChannelID),
+			},
+			Title:       helpers.GetTextF("plugins.youtube.channel-embed-title-vod", video.ChannelTitle),
+			URL:         fmt.Sprintf(youtubeVideoBaseUrl, video.ID),
+			Description: fmt.Sprintf("**%s**", video.Title),
+			Footer:      &discordgo.MessageEmbedFooter{Text: "YouTube"},
+			Color:       helpers.GetDiscordColorFromHex(youtubeColor),
+		},
+	}
+	if video.ThumbnailURL != "" {
+		msg.Embed.Image = &discordgo.MessageEmbedImage{URL: video.ThumbnailURL}
+	}
+
+	_, err = helpers.SendComplex(e.ChannelID, msg)
+	if err != nil {
+		return err
+	}
+
+	logger().WithFields(logrus.Fields{
+		"title":   video.Title,
+		"channel": e.ChannelID,
+	}).Info("posting video")
+
+	return robyulFeeds.MarkHandled(youtubeFeedsSourceName, key, item)
+}
+
+func isPosted(id string, postedIds []string) bool {
 	for _, posted := range postedIds {
 		if id == posted {
 			return true
@@ -16,8 +16,9 @@ import (
 )
 
 type Handler struct {
-	service   youtubeService.Service
-	feedsLoop feeds
+	service    youtubeService.Service
+	feedsLoop  feeds
+	websubLoop websub
 }
 
 type action func(args []string, in *discordgo.Message, out **discordgo.MessageSend) (next action)
@@ -25,6 +26,7 @@ type action func(args []string, in *discordgo.Message, out **discordgo.MessageSe
 const (
 	youtubeChannelBaseUrl = "https://www.youtube.com/channel/%s"
 	youtubeVideoBaseUrl   = "https://youtu.be/%s"
+	youtubeThumbnailUrl   = "https://i.ytimg.com/vi/%s/hqdefault.jpg"
 	youtubeColor          = "FF0000"
 
 	youtubeConfigFileName = "google.client_credentials_json_location"
@@ -42,6 +44,7 @@ func (h *Handler) Init(session *shardmanager.Manager) {
 
 	h.service.Init(youtubeConfigFileName)
 	h.feedsLoop.Init(&h.service)
+	h.websubLoop.Init(&h.service)
 	youtubeService.SetYouTubeService(&h.service)
 }
 
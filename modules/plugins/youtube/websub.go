@@ -0,0 +1,376 @@
+package youtube
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+
+	youtubeService "github.com/Seklfreak/Robyul2/services/youtube"
+
+	"github.com/Seklfreak/Robyul2/cluster"
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/models"
+)
+
+const (
+	webSubLease         = time.Hour * 24 * 5
+	webSubRenewBefore   = time.Hour * 24
+	webSubVerifyTimeout = time.Hour // requests the hub did not verify within this time are retried
+	webSubMinBackoff    = time.Minute * 10
+	webSubMaxBackoff    = time.Hour * 12
+	webSubSyncInterval  = time.Minute * 10
+	webSubMaxVideoAge   = time.Hour * 24 // notifications about older videos are updates, not uploads
+)
+
+// websub subscribes to the WebSub hub for all YouTube channels with feeds, and renews the leases before they expire.
+// YouTube channels without an active subscription are polled by the feeds loop.
+type websub struct {
+	service *youtubeService.Service
+	running uint32
+}
+
+func webSubConfig() (callback, secret string) {
+	callback, _ = helpers.GetConfig().Path("youtube.websub_callback").Data().(string)
+	secret, _ = helpers.GetConfig().Path("youtube.websub_secret").Data().(string)
+	return callback, secret
+}
+
+// webSubEnabled returns true if a callback and a secret are configured, notifications can't be verified without a secret
+func webSubEnabled() bool {
+	callback, secret := webSubConfig()
+	return callback != "" && secret != ""
+}
+
+func (w *websub) Init(e *youtubeService.Service) {
+	if e == nil {
+		helpers.Relax(fmt.Errorf("websub loop initialize failed"))
+	}
+	w.service = e
+
+	if !webSubEnabled() {
+		logger().Info("no websub callback or secret configured, polling all channels")
+		return
+	}
+
+	w.start()
+}
+
+func (w *websub) start() {
+	if atomic.SwapUint32(&w.running, uint32(1)) == 1 {
+		logger().Error("websub loop already running")
+		return
+	}
+
+	go w.run()
+}
+
+func (w *websub) run() {
+	defer helpers.Recover()
+	defer func() {
+		atomic.StoreUint32(&w.running, uint32(0))
+
+		logger().Error("The websub loop died. Please investigate! Will be restarted in 60 seconds")
+		time.Sleep(60 * time.Second)
+
+		w.start()
+	}()
+
+	for ; ; time.Sleep(webSubSyncInterval) {
+		// only one process subscribes to the hub
+		if !cluster.IsLeader("youtube-websub") {
+			continue
+		}
+
+		w.sync()
+	}
+}
+
+// sync subscribes to new channels, renews expiring leases, retries failed subscriptions, and unsubscribes from removed channels
+func (w *websub) sync() {
+	callback, secret := webSubConfig()
+	now := time.Now()
+
+	var channelIDs []string
+	err := helpers.MdbCollection(models.YoutubeChannelTable).Find(nil).Distinct("youtubechannelid", &channelIDs)
+	helpers.Relax(err)
+
+	var states []models.YoutubeWebSubEntry
+	err = helpers.MDbIterWithoutLogging(helpers.MdbCollection(models.YoutubeWebSubTable).Find(nil)).All(&states)
+	helpers.Relax(err)
+
+	statesByChannel := make(map[string]models.YoutubeWebSubEntry)
+	for _, state := range states {
+		statesByChannel[state.YoutubeChannelID] = state
+	}
+
+	wanted := make(map[string]bool)
+	for _, channelID := range channelIDs {
+		if channelID == "" {
+			continue
+		}
+		wanted[channelID] = true
+
+		state, ok := statesByChannel[channelID]
+		if !ok {
+			state = models.YoutubeWebSubEntry{YoutubeChannelID: channelID}
+		}
+
+		switch state.Status {
+		case models.YoutubeWebSubStatusSubscribed:
+			if state.LeaseExpiresAt.Sub(now) > webSubRenewBefore ||
+				(state.RequestedAt.After(state.VerifiedAt) && now.Sub(state.RequestedAt) < webSubVerifyTimeout) {
+				continue
+			}
+		case models.YoutubeWebSubStatusPending:
+			if now.Sub(state.RequestedAt) < webSubVerifyTimeout {
+				continue
+			}
+			failWebSubState(&state, "verification timed out", now)
+			err = saveWebSubState(state)
+			helpers.RelaxLog(err)
+			continue
+		}
+		if state.NextAttemptAt.After(now) {
+			continue
+		}
+
+		state.RequestedAt = now
+		err = youtubeService.RequestWebSubSubscription(callback, secret, channelID, youtubeService.WebSubModeSub, webSubLease)
+		if err != nil {
+			logger().WithField("channel", channelID).Warn("websub subscription request failed: " + err.Error())
+			failWebSubState(&state, err.Error(), now)
+		} else if state.Status != models.YoutubeWebSubStatusSubscribed {
+			state.Status = models.YoutubeWebSubStatusPending
+		}
+
+		err = saveWebSubState(state)
+		helpers.RelaxLog(err)
+	}
+
+	for _, state := range states {
+		if wanted[state.YoutubeChannelID] {
+			continue
+		}
+
+		err = youtubeService.RequestWebSubSubscription(callback, secret, state.YoutubeChannelID, youtubeService.WebSubModeUnsub, 0)
+		if err != nil {
+			logger().WithField("channel", state.YoutubeChannelID).Warn("websub unsubscription request failed: " + err.Error())
+		}
+
+		err = helpers.MDbDeleteWithoutLogging(models.YoutubeWebSubTable, state.ID)
+		helpers.RelaxLog(err)
+	}
+
+	subscribedChannelIDs, err := getWebSubSubscribedChannelIDs()
+	helpers.Relax(err)
+
+	// keep the polling window short for subscribed channels, in case the subscription fails later
+	_, err = helpers.MdbCollection(models.YoutubeChannelTable).UpdateAll(
+		bson.M{"youtubechannelid": bson.M{"$in": subscribedChannelIDs}},
+		bson.M{"$set": bson.M{"lastsuccessfulchecktime": now.Unix()}},
+	)
+	helpers.RelaxLog(err)
+
+	// only polled channels use the quota
+	polledCount, err := helpers.MdbCountWithoutLogging(models.YoutubeChannelTable,
+		bson.M{"youtubechannelid": bson.M{"$nin": subscribedChannelIDs}})
+	helpers.Relax(err)
+	w.service.SetQuotaEntryCount(int64(polledCount))
+
+	logger().Infof("websub: %d channels, %d subscribed, %d feeds polled", len(wanted), len(subscribedChannelIDs), polledCount)
+}
+
+func saveWebSubState(state models.YoutubeWebSubEntry) error {
+	return helpers.MDbUpsertWithoutLogging(models.YoutubeWebSubTable,
+		bson.M{"youtubechannelid": state.YoutubeChannelID}, state)
+}
+
+// failWebSubState backs off the next attempt, channels stay subscribed until their lease expires
+func failWebSubState(state *models.YoutubeWebSubEntry, reason string, now time.Time) {
+	state.Failures++
+	state.LastError = reason
+
+	backoff := webSubMinBackoff
+	for i := 1; i < state.Failures && backoff < webSubMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > webSubMaxBackoff {
+		backoff = webSubMaxBackoff
+	}
+	state.NextAttemptAt = now.Add(backoff)
+
+	if state.Status != models.YoutubeWebSubStatusSubscribed || !state.LeaseExpiresAt.After(now) {
+		state.Status = models.YoutubeWebSubStatusFailed
+	}
+}
+
+// getWebSubSubscribedChannelIDs returns all YouTube channels with an active subscription
+func getWebSubSubscribedChannelIDs() (channelIDs []string, err error) {
+	channelIDs = make([]string, 0)
+	if !webSubEnabled() {
+		return channelIDs, nil
+	}
+
+	err = helpers.MdbCollection(models.YoutubeWebSubTable).Find(bson.M{
+		"status":         models.YoutubeWebSubStatusSubscribed,
+		"leaseexpiresat": bson.M{"$gt": time.Now()},
+	}).Distinct("youtubechannelid", &channelIDs)
+	return channelIDs, err
+}
+
+// isWebSubVerificationExpected returns true if a subscribe request has been sent to the hub and not been verified yet
+func isWebSubVerificationExpected(state models.YoutubeWebSubEntry, now time.Time) bool {
+	return !state.RequestedAt.IsZero() &&
+		state.RequestedAt.After(state.VerifiedAt) &&
+		now.Sub(state.RequestedAt) < webSubVerifyTimeout
+}
+
+// getWebSubLease returns the lease granted by the hub, at most the requested lease
+func getWebSubLease(leaseSeconds string) time.Duration {
+	seconds, err := strconv.Atoi(leaseSeconds)
+	if err != nil || seconds <= 0 {
+		return webSubLease
+	}
+	lease := time.Duration(seconds) * time.Second
+	if lease > webSubLease {
+		return webSubLease
+	}
+	return lease
+}
+
+func getWebSubState(channelID string) (state models.YoutubeWebSubEntry, err error) {
+	err = helpers.MdbOneWithoutLogging(
+		helpers.MdbCollection(models.YoutubeWebSubTable).Find(bson.M{"youtubechannelid": channelID}),
+		&state,
+	)
+	if helpers.IsMdbNotFound(err) {
+		return models.YoutubeWebSubEntry{YoutubeChannelID: channelID}, nil
+	}
+	return state, err
+}
+
+// HandleWebSubVerification handles the verification of subscription requests by the hub, returns false to deny the request
+func HandleWebSubVerification(mode, topic, leaseSeconds, reason string) (ok bool) {
+	if !webSubEnabled() {
+		return false
+	}
+
+	channelID, ok := youtubeService.ChannelIDFromWebSubTopic(topic)
+	if !ok {
+		return false
+	}
+
+	count, err := helpers.MdbCountWithoutLogging(models.YoutubeChannelTable, bson.M{"youtubechannelid": channelID})
+	if err != nil {
+		helpers.RelaxLog(err)
+		return false
+	}
+
+	switch mode {
+	case youtubeService.WebSubModeSub:
+		if count <= 0 {
+			return false
+		}
+
+		state, err := getWebSubState(channelID)
+		if err != nil {
+			helpers.RelaxLog(err)
+			return false
+		}
+
+		// only requests sent by sync are verified, anyone can call the callback
+		if !isWebSubVerificationExpected(state, time.Now()) {
+			logger().WithField("channel", channelID).Warn("denied websub verification without a pending subscription request")
+			return false
+		}
+
+		state.Status = models.YoutubeWebSubStatusSubscribed
+		state.VerifiedAt = time.Now()
+		state.LeaseExpiresAt = state.VerifiedAt.Add(getWebSubLease(leaseSeconds))
+		state.Failures = 0
+		state.NextAttemptAt = time.Time{}
+		state.LastError = ""
+		err = saveWebSubState(state)
+		if err != nil {
+			helpers.RelaxLog(err)
+			return false
+		}
+
+		logger().WithField("channel", channelID).Infof("websub subscription verified, lease expires in %s",
+			state.LeaseExpiresAt.Sub(state.VerifiedAt))
+		return true
+	case youtubeService.WebSubModeUnsub:
+		return count <= 0
+	case youtubeService.WebSubModeDenied:
+		state, err := getWebSubState(channelID)
+		if err != nil {
+			helpers.RelaxLog(err)
+			return false
+		}
+
+		failWebSubState(&state, "denied by hub: "+reason, time.Now())
+		err = saveWebSubState(state)
+		helpers.RelaxLog(err)
+
+		logger().WithField("channel", channelID).Warn("websub subscription denied: " + reason)
+		return true
+	}
+
+	return false
+}
+
+// HandleWebSubNotification verifies and parses a notification by the hub, and posts new videos in the background
+func HandleWebSubNotification(body []byte, signature string) (err error) {
+	if !webSubEnabled() {
+		return errors.New("websub is not configured")
+	}
+
+	_, secret := webSubConfig()
+	if !youtubeService.VerifyWebSubSignature(secret, body, signature) {
+		return errors.New("invalid websub signature")
+	}
+
+	videos, err := youtubeService.ParseWebSubNotification(body)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		defer helpers.Recover()
+
+		for _, video := range videos {
+			if time.Since(video.Published) > webSubMaxVideoAge {
+				continue
+			}
+
+			var entries []models.YoutubeChannelEntry
+			err := helpers.MDbIterWithoutLogging(helpers.MdbCollection(models.YoutubeChannelTable).Find(
+				bson.M{"youtubechannelid": video.ChannelID},
+			)).All(&entries)
+			helpers.Relax(err)
+
+			for _, entry := range entries {
+				if !canPostVideos(entry) {
+					continue
+				}
+
+				err = postVideo(entry, youtubeVideo{
+					ID:           video.VideoID,
+					Title:        video.Title,
+					ChannelID:    video.ChannelID,
+					ChannelTitle: video.Author,
+					ThumbnailURL: fmt.Sprintf(youtubeThumbnailUrl, video.VideoID),
+				})
+				if err != nil {
+					logger().Warn(err)
+				}
+			}
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package youtube
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/models"
+)
+
+func TestIsWebSubVerificationExpected(t *testing.T) {
+	now := time.Now()
+
+	if isWebSubVerificationExpected(models.YoutubeWebSubEntry{}, now) {
+		t.Fatalf("youtube.isWebSubVerificationExpected() accepted a channel without request")
+	}
+	if !isWebSubVerificationExpected(models.YoutubeWebSubEntry{RequestedAt: now.Add(-time.Minute)}, now) {
+		t.Fatalf("youtube.isWebSubVerificationExpected() denied a pending request")
+	}
+	if isWebSubVerificationExpected(models.YoutubeWebSubEntry{
+		RequestedAt: now.Add(-time.Hour * 2), VerifiedAt: now.Add(-time.Hour),
+	}, now) {
+		t.Fatalf("youtube.isWebSubVerificationExpected() accepted an already verified request")
+	}
+	if isWebSubVerificationExpected(models.YoutubeWebSubEntry{RequestedAt: now.Add(-webSubVerifyTimeout)}, now) {
+		t.Fatalf("youtube.isWebSubVerificationExpected() accepted a timed out request")
+	}
+}
+
+func TestGetWebSubLease(t *testing.T) {
+	for leaseSeconds, expected := range map[string]time.Duration{
+		"3600":      time.Hour,
+		"999999999": webSubLease,
+		"-1":        webSubLease,
+		"":          webSubLease,
+	} {
+		if lease := getWebSubLease(leaseSeconds); lease != expected {
+			t.Fatalf("youtube.getWebSubLease() failed to cap lease %q, got %s", leaseSeconds, lease)
+		}
+	}
+}
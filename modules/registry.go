@@ -0,0 +1,375 @@
+package modules
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/generator"
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/modules/plugins/levels"
+	"github.com/Seklfreak/Robyul2/shardmanager"
+	"github.com/pkg/errors"
+)
+
+// The registry keeps track of the enabled plugins. Plugins are enabled if
+// 1. the feature flag module-<name> is enabled, or without the feature flag
+// 2. they are listed in modules.enabled in the config, and not listed in modules.disabled, or without both
+// 3. they are not in pluginsDisabledByDefault.
+// Bot admins can enable and disable plugins at runtime until the next restart.
+
+var (
+	// the enabled plugins, replaced on every change, never modified
+	enabledPlugins         []Plugin
+	enabledExtendedPlugins []ExtendedPlugin
+	// protects the enabled plugins and the command caches
+	pluginsLock sync.RWMutex
+
+	// serializes changes to the enabled plugins, Init and Uninit of plugins is called while holding it
+	pluginsChangeLock   sync.Mutex
+	pluginsEnabled      = make(map[string]bool)
+	pluginsInitialized  = make(map[string]bool)
+	pluginsSession      *shardmanager.Manager
+	errPluginNotFound   = errors.New("plugin not found")
+	errPluginIsRequired = errors.New("plugin can not be disabled")
+	errPluginNotReloadable = errors.New("plugin can not be disabled or reloaded at runtime")
+)
+
+// PluginStatus describes a plugin of the registry
+type PluginStatus struct {
+	Name     string
+	Extended bool
+	Enabled  bool
+	Commands []string
+}
+
+// PluginName returns the name of a plugin, the type name for plugins in the plugins package, <package>.<type> otherwise
+func PluginName(plugin BaseModule) string {
+	pluginType := reflect.TypeOf(plugin)
+	if pluginType.Kind() == reflect.Ptr {
+		pluginType = pluginType.Elem()
+	}
+
+	packageName := path.Base(pluginType.PkgPath())
+	if packageName == "plugins" {
+		return pluginType.Name()
+	}
+	return packageName + "." + pluginType.Name()
+}
+
+func pluginFeatureFlag(name string) string {
+	return "module-" + strings.ToLower(strings.Replace(name, ".", "-", -1))
+}
+
+// isPluginEnabledByConfig returns if the plugin should be enabled according to the feature flags and the config
+func isPluginEnabledByConfig(name string) bool {
+	enabled := !pluginsDisabledByDefault[name]
+	if configListContains("modules.enabled", name) {
+		enabled = true
+	}
+	if configListContains("modules.disabled", name) {
+		enabled = false
+	}
+	if pluginsRequired[name] {
+		return true
+	}
+
+	return helpers.FeatureEnabled(pluginFeatureFlag(name), enabled)
+}
+
+func configListContains(configPath, name string) bool {
+	items, _ := helpers.GetConfig().Path(configPath).Data().([]interface{})
+	for _, item := range items {
+		if itemText, ok := item.(string); ok && strings.EqualFold(itemText, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// getEnabledPlugins returns the enabled plugins, the result must not be modified
+func getEnabledPlugins() []Plugin {
+	pluginsLock.RLock()
+	defer pluginsLock.RUnlock()
+
+	return enabledPlugins
+}
+
+// getEnabledExtendedPlugins returns the enabled extended plugins, the result must not be modified
+func getEnabledExtendedPlugins() []ExtendedPlugin {
+	pluginsLock.RLock()
+	defer pluginsLock.RUnlock()
+
+	return enabledExtendedPlugins
+}
+
+// getPluginsForCommand returns the enabled plugin and extended plugin for the command, or nil
+func getPluginsForCommand(command string) (plugin *Plugin, extendedPlugin *ExtendedPlugin) {
+	pluginsLock.RLock()
+	defer pluginsLock.RUnlock()
+
+	return pluginCache[command], extendedPluginCache[command]
+}
+
+// publishEnabledPlugins rebuilds the enabled plugins and the command caches, requires pluginsChangeLock
+func publishEnabledPlugins() {
+	newEnabledPlugins := make([]Plugin, 0)
+	newEnabledExtendedPlugins := make([]ExtendedPlugin, 0)
+	newPluginCache := make(map[string]*Plugin)
+	newExtendedPluginCache := make(map[string]*ExtendedPlugin)
+	pluginCommands := make([]string, 0)
+	extendedPluginCommands := make([]string, 0)
+
+	for i := range PluginList {
+		ref := &PluginList[i]
+		if !pluginsEnabled[PluginName(*ref)] {
+			continue
+		}
+
+		newEnabledPlugins = append(newEnabledPlugins, *ref)
+		for _, cmd := range (*ref).Commands() {
+			newPluginCache[cmd] = ref
+			pluginCommands = append(pluginCommands, cmd)
+		}
+	}
+	for i := range PluginExtendedList {
+		ref := &PluginExtendedList[i]
+		if !pluginsEnabled[PluginName(*ref)] {
+			continue
+		}
+
+		newEnabledExtendedPlugins = append(newEnabledExtendedPlugins, *ref)
+		for _, cmd := range (*ref).Commands() {
+			newExtendedPluginCache[cmd] = ref
+			extendedPluginCommands = append(extendedPluginCommands, cmd)
+		}
+	}
+
+	pluginsLock.Lock()
+	enabledPlugins = newEnabledPlugins
+	enabledExtendedPlugins = newEnabledExtendedPlugins
+	pluginCache = newPluginCache
+	extendedPluginCache = newExtendedPluginCache
+	pluginsLock.Unlock()
+
+	cache.SetPluginList(pluginCommands)
+	cache.SetPluginExtendedList(extendedPluginCommands)
+}
+
+// findPlugin returns the plugin or extended plugin with the name, case insensitive
+func findPlugin(name string) (plugin Plugin, extendedPlugin ExtendedPlugin, err error) {
+	for _, item := range PluginList {
+		if strings.EqualFold(PluginName(item), name) {
+			return item, nil, nil
+		}
+	}
+	for _, item := range PluginExtendedList {
+		if strings.EqualFold(PluginName(item), name) {
+			return nil, item, nil
+		}
+	}
+	return nil, nil, errPluginNotFound
+}
+
+// initPlugin initializes the plugin, unless it is initialized already, requires pluginsChangeLock
+func initPlugin(plugin BaseModule) {
+	name := PluginName(plugin)
+	if pluginsInitialized[name] {
+		return
+	}
+
+	logTemplate := "[PLUG] %s reacts to [ %s]"
+	if _, ok := plugin.(ExtendedPlugin); ok {
+		logTemplate = "[EXTENDED-PLUG] %s reacts to [ %s]"
+	}
+	var commands []string
+	switch item := plugin.(type) {
+	case ExtendedPlugin:
+		commands = item.Commands()
+		if levelsPlugin, ok := item.(*levels.Levels); ok {
+			generator.SetProfileGenerator(levelsPlugin)
+		}
+	case Plugin:
+		commands = item.Commands()
+	}
+	cache.GetLogger().WithField("module", "modules").Info(fmt.Sprintf(
+		logTemplate,
+		name,
+		strings.Join(commands, " ")+" ",
+	))
+
+	switch item := plugin.(type) {
+	case ExtendedPlugin:
+		item.Init(pluginsSession)
+	case Plugin:
+		item.Init(pluginsSession)
+	}
+	pluginsInitialized[name] = true
+}
+
+// uninitializer is implemented by all extended plugins and some plugins
+type uninitializer interface {
+	Uninit(session *shardmanager.Manager)
+}
+
+// uninitPlugin deinitializes the plugin, plugins without Uninit stay initialized, requires pluginsChangeLock
+func uninitPlugin(plugin BaseModule) {
+	uninitializablePlugin, ok := plugin.(uninitializer)
+	if !ok {
+		return
+	}
+
+	name := PluginName(plugin)
+	if !pluginsInitialized[name] {
+		return
+	}
+
+	cache.GetLogger().WithField("module", "modules").Info(fmt.Sprintf(
+		"[PLUG] %s deintializing…",
+		name,
+	))
+
+	uninitializablePlugin.Uninit(pluginsSession)
+	pluginsInitialized[name] = false
+}
+
+// GetPluginStatuses returns all plugins of the registry, sorted by name
+func GetPluginStatuses() (statuses []PluginStatus) {
+	pluginsChangeLock.Lock()
+	defer pluginsChangeLock.Unlock()
+
+	for _, plugin := range PluginList {
+		statuses = append(statuses, PluginStatus{
+			Name:     PluginName(plugin),
+			Enabled:  pluginsEnabled[PluginName(plugin)],
+			Commands: plugin.Commands(),
+		})
+	}
+	for _, plugin := range PluginExtendedList {
+		statuses = append(statuses, PluginStatus{
+			Name:     PluginName(plugin),
+			Extended: true,
+			Enabled:  pluginsEnabled[PluginName(plugin)],
+			Commands: plugin.Commands(),
+		})
+	}
+
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
+
+// EnablePlugin initializes the plugin and starts dispatching commands and events to it
+func EnablePlugin(name string) (err error) {
+	plugin, extendedPlugin, err := findPlugin(name)
+	if err != nil {
+		return err
+	}
+
+	pluginsChangeLock.Lock()
+	defer pluginsChangeLock.Unlock()
+
+	var item BaseModule = plugin
+	if extendedPlugin != nil {
+		item = extendedPlugin
+	}
+	if err = checkPluginCommands(item); err != nil {
+		return err
+	}
+
+	initPlugin(item)
+	pluginsEnabled[PluginName(item)] = true
+	publishEnabledPlugins()
+	return nil
+}
+
+// DisablePlugin stops dispatching commands and events to the plugin and deinitializes it,
+// plugins in pluginsNotReloadable can not be disabled
+func DisablePlugin(name string) (err error) {
+	plugin, extendedPlugin, err := findPlugin(name)
+	if err != nil {
+		return err
+	}
+
+	var item BaseModule = plugin
+	if extendedPlugin != nil {
+		item = extendedPlugin
+	}
+	if pluginsRequired[PluginName(item)] {
+		return errPluginIsRequired
+	}
+	if pluginsNotReloadable[PluginName(item)] {
+		return errPluginNotReloadable
+	}
+
+	pluginsChangeLock.Lock()
+	defer pluginsChangeLock.Unlock()
+
+	pluginsEnabled[PluginName(item)] = false
+	publishEnabledPlugins()
+	uninitPlugin(item)
+	return nil
+}
+
+// ReloadPlugin deinitializes and initializes an enabled plugin, plugins in pluginsNotReloadable can not be reloaded
+func ReloadPlugin(name string) (err error) {
+	plugin, extendedPlugin, err := findPlugin(name)
+	if err != nil {
+		return err
+	}
+
+	var item BaseModule = plugin
+	if extendedPlugin != nil {
+		item = extendedPlugin
+	}
+	if _, ok := item.(uninitializer); !ok {
+		return errors.New("plugin can not be deinitialized")
+	}
+	if pluginsNotReloadable[PluginName(item)] {
+		return errPluginNotReloadable
+	}
+
+	pluginsChangeLock.Lock()
+	defer pluginsChangeLock.Unlock()
+
+	if !pluginsEnabled[PluginName(item)] {
+		return errors.New("plugin is disabled")
+	}
+
+	pluginsEnabled[PluginName(item)] = false
+	publishEnabledPlugins()
+	uninitPlugin(item)
+
+	initPlugin(item)
+	pluginsEnabled[PluginName(item)] = true
+	publishEnabledPlugins()
+	return nil
+}
+
+// checkPluginCommands returns an error if a command of the plugin is used by another enabled plugin, requires pluginsChangeLock
+func checkPluginCommands(plugin BaseModule) error {
+	name := PluginName(plugin)
+
+	var commands []string
+	switch item := plugin.(type) {
+	case ExtendedPlugin:
+		commands = item.Commands()
+	case Plugin:
+		commands = item.Commands()
+	}
+
+	for _, cmd := range commands {
+		if occupant, ok := pluginCache[cmd]; ok && PluginName(*occupant) != name {
+			return fmt.Errorf("command %s is already registered by %s", cmd, PluginName(*occupant))
+		}
+		if occupant, ok := extendedPluginCache[cmd]; ok && PluginName(*occupant) != name {
+			return fmt.Errorf("command %s is already registered by %s", cmd, PluginName(*occupant))
+		}
+	}
+	return nil
+}
@@ -1,116 +1,75 @@
 package modules
 
 import (
-	"fmt"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Seklfreak/Robyul2/cache"
-	"github.com/Seklfreak/Robyul2/generator"
 	"github.com/Seklfreak/Robyul2/helpers"
 	"github.com/Seklfreak/Robyul2/metrics"
-	"github.com/Seklfreak/Robyul2/modules/plugins/levels"
 	"github.com/Seklfreak/Robyul2/ratelimits"
 	"github.com/Seklfreak/Robyul2/shardmanager"
 	"github.com/bwmarrin/discordgo"
 )
 
-// Init warms the caches and initializes the plugins
+// calls of plugin handlers running longer are logged and counted, most handlers should spawn coroutines for costly work
+const pluginHandlerTimeout = time.Second * 10
+
+// Init warms the caches and initializes the enabled plugins
 func Init(session *shardmanager.Manager) {
 	checkDuplicateCommands()
 
-	pluginCount := len(PluginList)
-	extendedPluginCount := len(PluginExtendedList)
-	pluginCache = make(map[string]*Plugin)
-	extendedPluginCache = make(map[string]*ExtendedPlugin)
-
-	logTemplate := "[PLUG] %s reacts to [ %s]"
-	listeners := ""
+	pluginsChangeLock.Lock()
+	defer pluginsChangeLock.Unlock()
 
-	for i := 0; i < pluginCount; i++ {
-		ref := &PluginList[i]
+	pluginsSession = session
 
-		for _, cmd := range (*ref).Commands() {
-			pluginCache[cmd] = ref
-			listeners += cmd + " "
+	var pluginCount, extendedPluginCount int
+	for _, plugin := range PluginList {
+		name := PluginName(plugin)
+		pluginsEnabled[name] = isPluginEnabledByConfig(name)
+		if !pluginsEnabled[name] {
+			continue
 		}
 
-		cache.GetLogger().WithField("module", "modules").Info(fmt.Sprintf(
-			logTemplate,
-			helpers.Typeof(*ref),
-			listeners,
-		))
-		listeners = ""
-
-		(*ref).Init(session)
+		initPlugin(plugin)
+		pluginCount++
 	}
-
-	listeners = ""
-	logTemplate = "[EXTENDED-PLUG] %s reacts to [ %s]"
-	for i := 0; i < extendedPluginCount; i++ {
-		ref := &PluginExtendedList[i]
-
-		for _, cmd := range (*ref).Commands() {
-			extendedPluginCache[cmd] = ref
-			listeners += cmd + " "
-		}
-
-		cache.GetLogger().WithField("module", "modules").Info(fmt.Sprintf(
-			logTemplate,
-			helpers.Typeof(*ref),
-			listeners,
-		))
-		listeners = ""
-
-		if helpers.Typeof(*ref) == "*Levels" {
-			generator.SetProfileGenerator((*ref).(*levels.Levels))
+	for _, extendedPlugin := range PluginExtendedList {
+		name := PluginName(extendedPlugin)
+		pluginsEnabled[name] = isPluginEnabledByConfig(name)
+		if !pluginsEnabled[name] {
+			continue
 		}
 
-		(*ref).Init(session)
+		initPlugin(extendedPlugin)
+		extendedPluginCount++
 	}
 
-	pluginCommands := make([]string, 0)
-	for k := range pluginCache {
-		pluginCommands = append(pluginCommands, k)
-	}
-	cache.SetPluginList(pluginCommands)
-	extendedPluginCommands := make([]string, 0)
-	for k := range extendedPluginCache {
-		extendedPluginCommands = append(extendedPluginCommands, k)
-	}
-	cache.SetPluginExtendedList(extendedPluginCommands)
+	publishEnabledPlugins()
 
 	cache.GetLogger().WithField("module", "modules").Info(
 		"modules",
-		"Initializer finished. Loaded "+strconv.Itoa(len(PluginList))+" plugins and "+strconv.Itoa(len(PluginExtendedList))+" extended plugins",
+		"Initializer finished. Loaded "+strconv.Itoa(pluginCount)+" plugins and "+strconv.Itoa(extendedPluginCount)+" extended plugins",
 	)
 }
 
-// Uninit deintializes the plugins
+// Uninit deintializes the enabled extended plugins
 func Uninit(session *shardmanager.Manager) {
-	extendedPluginCount := len(PluginExtendedList)
-	extendedPluginCache = make(map[string]*ExtendedPlugin)
-
-	logTemplate := "[EXTENDED-PLUG] %s deintializing…"
-	for i := 0; i < extendedPluginCount; i++ {
-		ref := &PluginExtendedList[i]
+	pluginsChangeLock.Lock()
+	defer pluginsChangeLock.Unlock()
 
-		for _, cmd := range (*ref).Commands() {
-			extendedPluginCache[cmd] = ref
-		}
-
-		cache.GetLogger().WithField("module", "modules").Info(fmt.Sprintf(
-			logTemplate,
-			helpers.Typeof(*ref),
-		))
-
-		(*ref).Uninit(session)
+	var extendedPluginCount int
+	for _, extendedPlugin := range getEnabledExtendedPlugins() {
+		uninitPlugin(extendedPlugin)
+		extendedPluginCount++
 	}
 
 	cache.GetLogger().WithField("module", "modules").Info(
 		"modules",
-		"Uninit finished. Unitialized "+strconv.Itoa(len(PluginExtendedList))+" extended plugins",
+		"Uninit finished. Unitialized "+strconv.Itoa(extendedPluginCount)+" extended plugins",
 	)
 }
 
@@ -128,79 +87,127 @@ func CallBotPlugin(command string, content string, msg *discordgo.Message) {
 	// Track metrics
 	metrics.CommandsExecuted.Add(1)
 
+	plugin, extendedPlugin := getPluginsForCommand(command)
+
+	session := cache.GetSession().SessionForGuildS(msg.GuildID)
+
 	// Call the module
-	if ref, ok := pluginCache[command]; ok {
-		(*ref).Action(command, content, msg, cache.GetSession().SessionForGuildS(msg.GuildID))
+	if ref := plugin; ref != nil {
+		callPluginAction(*ref, command, msg, session, func() {
+			(*ref).Action(command, content, msg, session)
+		})
 	}
 	// call the extended module
-	if ref, ok := extendedPluginCache[command]; ok {
-		(*ref).Action(command, content, msg, cache.GetSession().SessionForGuildS(msg.GuildID))
+	if ref := extendedPlugin; ref != nil {
+		callPluginAction(*ref, command, msg, session, func() {
+			(*ref).Action(command, content, msg, session)
+		})
 	}
 }
 
-func CallExtendedPlugin(content string, msg *discordgo.Message) {
-	defer helpers.Recover()
+// callPluginAction tracks a command of a plugin, panics are passed on to notify the user
+func callPluginAction(plugin BaseModule, command string, msg *discordgo.Message, session *discordgo.Session, call func()) {
+	var finished bool
+	started := time.Now()
 
-	for _, extendedPlugin := range PluginExtendedList {
-		extendedPlugin.OnMessage(strings.TrimSpace(content), msg, cache.GetSession().SessionForGuildS(msg.GuildID))
+	// commands can wait for users, without timeout
+	done := trackPluginHandler(plugin, "Action", 0)
+	defer func() {
+		done(finished)
+		metrics.ObserveCommand(session.ShardID, msg.GuildID, command, PluginName(plugin), time.Since(started))
+	}()
+
+	call()
+	finished = true
+}
+
+func CallExtendedPlugin(content string, msg *discordgo.Message) {
+	session := cache.GetSession().SessionForGuildS(msg.GuildID)
+	content = strings.TrimSpace(content)
+
+	for _, extendedPlugin := range getEnabledExtendedPlugins() {
+		extendedPlugin := extendedPlugin
+		callPluginHandler(extendedPlugin, "OnMessage", func() {
+			extendedPlugin.OnMessage(content, msg, session)
+		})
 	}
-	//go safePluginExtendedCall(strings.TrimSpace(content), msg, plug)
 }
 
 func CallExtendedPluginOnMessageDelete(message *discordgo.MessageDelete) {
-	defer helpers.Recover()
+	session := cache.GetSession().SessionForGuildS(message.GuildID)
 
-	for _, extendedPlugin := range PluginExtendedList {
-		extendedPlugin.OnMessageDelete(message, cache.GetSession().SessionForGuildS(message.GuildID))
+	for _, extendedPlugin := range getEnabledExtendedPlugins() {
+		extendedPlugin := extendedPlugin
+		callPluginHandler(extendedPlugin, "OnMessageDelete", func() {
+			extendedPlugin.OnMessageDelete(message, session)
+		})
 	}
 }
 
 func CallExtendedPluginOnGuildMemberAdd(member *discordgo.Member) {
-	defer helpers.Recover()
+	session := cache.GetSession().SessionForGuildS(member.GuildID)
 
 	// Iterate over all plugins
-	for _, extendedPlugin := range PluginExtendedList {
-		extendedPlugin.OnGuildMemberAdd(member, cache.GetSession().SessionForGuildS(member.GuildID))
+	for _, extendedPlugin := range getEnabledExtendedPlugins() {
+		extendedPlugin := extendedPlugin
+		callPluginHandler(extendedPlugin, "OnGuildMemberAdd", func() {
+			extendedPlugin.OnGuildMemberAdd(member, session)
+		})
 	}
 }
 func CallExtendedPluginOnGuildMemberRemove(member *discordgo.Member) {
-	defer helpers.Recover()
+	session := cache.GetSession().SessionForGuildS(member.GuildID)
 
 	// Iterate over all plugins
-	for _, extendedPlugin := range PluginExtendedList {
-		extendedPlugin.OnGuildMemberRemove(member, cache.GetSession().SessionForGuildS(member.GuildID))
+	for _, extendedPlugin := range getEnabledExtendedPlugins() {
+		extendedPlugin := extendedPlugin
+		callPluginHandler(extendedPlugin, "OnGuildMemberRemove", func() {
+			extendedPlugin.OnGuildMemberRemove(member, session)
+		})
 	}
 }
 func CallExtendedPluginOnReactionAdd(reaction *discordgo.MessageReactionAdd) {
-	defer helpers.Recover()
+	session := cache.GetSession().SessionForGuildS(reaction.GuildID)
 
 	// Iterate over all plugins
-	for _, extendedPlugin := range PluginExtendedList {
-		extendedPlugin.OnReactionAdd(reaction, cache.GetSession().SessionForGuildS(reaction.GuildID))
+	for _, extendedPlugin := range getEnabledExtendedPlugins() {
+		extendedPlugin := extendedPlugin
+		callPluginHandler(extendedPlugin, "OnReactionAdd", func() {
+			extendedPlugin.OnReactionAdd(reaction, session)
+		})
 	}
 }
 func CallExtendedPluginOnReactionRemove(reaction *discordgo.MessageReactionRemove) {
-	defer helpers.Recover()
+	session := cache.GetSession().SessionForGuildS(reaction.GuildID)
 
 	// Iterate over all plugins
-	for _, extendedPlugin := range PluginExtendedList {
-		extendedPlugin.OnReactionRemove(reaction, cache.GetSession().SessionForGuildS(reaction.GuildID))
+	for _, extendedPlugin := range getEnabledExtendedPlugins() {
+		extendedPlugin := extendedPlugin
+		callPluginHandler(extendedPlugin, "OnReactionRemove", func() {
+			extendedPlugin.OnReactionRemove(reaction, session)
+		})
 	}
 }
 func CallExtendedPluginOnGuildBanAdd(user *discordgo.GuildBanAdd) {
-	defer helpers.Recover()
+	session := cache.GetSession().SessionForGuildS(user.GuildID)
 
 	// Iterate over all plugins
-	for _, extendedPlugin := range PluginExtendedList {
-		extendedPlugin.OnGuildBanAdd(user, cache.GetSession().SessionForGuildS(user.GuildID))
+	for _, extendedPlugin := range getEnabledExtendedPlugins() {
+		extendedPlugin := extendedPlugin
+		callPluginHandler(extendedPlugin, "OnGuildBanAdd", func() {
+			extendedPlugin.OnGuildBanAdd(user, session)
+		})
 	}
 }
 func CallExtendedPluginOnGuildBanRemove(user *discordgo.GuildBanRemove) {
-	defer helpers.Recover()
+	session := cache.GetSession().SessionForGuildS(user.GuildID)
 
 	// Iterate over all plugins
-	for _, extendedPlugin := range PluginExtendedList {
-		extendedPlugin.OnGuildBanRemove(user, cache.GetSession().SessionForGuildS(user.GuildID))
+	for _, extendedPlugin := range getEnabledExtendedPlugins() {
+		extendedPlugin := extendedPlugin
+		callPluginHandler(extendedPlugin, "OnGuildBanRemove", func() {
+			extendedPlugin.OnGuildBanRemove(user, session)
+		})
 	}
 }
 
@@ -220,3 +227,181 @@ func checkDuplicateCommands() {
 		}
 	}
 }
+
+// eachPlugin calls the function for every enabled plugin and extended plugin
+func eachPlugin(fn func(plugin BaseModule)) {
+	for _, plugin := range getEnabledPlugins() {
+		fn(plugin)
+	}
+	for _, extendedPlugin := range getEnabledExtendedPlugins() {
+		fn(extendedPlugin)
+	}
+}
+
+// callPluginHandler calls the handler of a plugin, a panic only aborts this call
+func callPluginHandler(plugin BaseModule, handler string, call func()) {
+	var finished bool
+
+	defer helpers.Recover()
+	done := trackPluginHandler(plugin, handler, pluginHandlerTimeout)
+	defer func() {
+		done(finished)
+	}()
+
+	call()
+	finished = true
+}
+
+// trackPluginHandler starts the watchdog for a call of a plugin handler, a timeout of 0 disables the watchdog,
+// the returned function records the call and has to be called with false if the handler panicked
+func trackPluginHandler(plugin BaseModule, handler string, timeout time.Duration) (done func(finished bool)) {
+	pluginName := PluginName(plugin)
+	key := pluginName + "." + handler
+	started := time.Now()
+
+	// the handler can not be stopped, but slow handlers should be visible
+	var watchdog *time.Timer
+	if timeout > 0 {
+		watchdog = time.AfterFunc(timeout, func() {
+			metrics.PluginHandlerTimeouts.Add(key, 1)
+			cache.GetLogger().WithField("module", "modules").Warnf(
+				"%s is running for more than %s", key, timeout)
+		})
+	}
+
+	return func(finished bool) {
+		if watchdog != nil {
+			watchdog.Stop()
+		}
+		metrics.ObservePluginHandler(pluginName, handler, time.Since(started))
+		if !finished {
+			metrics.PluginHandlerPanics.Add(key, 1)
+		}
+	}
+}
+
+func CallPluginOnMessageUpdate(msg *discordgo.MessageUpdate) {
+	session := cache.GetSession().SessionForGuildS(msg.GuildID)
+	eachPlugin(func(plugin BaseModule) {
+		if handler, ok := plugin.(MessageUpdateHandler); ok {
+			callPluginHandler(plugin, "OnMessageUpdate", func() { handler.OnMessageUpdate(msg, session) })
+		}
+	})
+}
+
+func CallPluginOnVoiceStateUpdate(state *discordgo.VoiceStateUpdate) {
+	session := cache.GetSession().SessionForGuildS(state.GuildID)
+	eachPlugin(func(plugin BaseModule) {
+		if handler, ok := plugin.(VoiceStateHandler); ok {
+			callPluginHandler(plugin, "OnVoiceStateUpdate", func() { handler.OnVoiceStateUpdate(state, session) })
+		}
+	})
+}
+
+func CallPluginOnChannelCreate(channel *discordgo.ChannelCreate) {
+	session := cache.GetSession().SessionForGuildS(channel.GuildID)
+	eachPlugin(func(plugin BaseModule) {
+		if handler, ok := plugin.(ChannelCreateHandler); ok {
+			callPluginHandler(plugin, "OnChannelCreate", func() { handler.OnChannelCreate(channel, session) })
+		}
+	})
+}
+
+func CallPluginOnChannelUpdate(channel *discordgo.ChannelUpdate) {
+	session := cache.GetSession().SessionForGuildS(channel.GuildID)
+	eachPlugin(func(plugin BaseModule) {
+		if handler, ok := plugin.(ChannelUpdateHandler); ok {
+			callPluginHandler(plugin, "OnChannelUpdate", func() { handler.OnChannelUpdate(channel, session) })
+		}
+	})
+}
+
+func CallPluginOnChannelDelete(channel *discordgo.ChannelDelete) {
+	session := cache.GetSession().SessionForGuildS(channel.GuildID)
+	eachPlugin(func(plugin BaseModule) {
+		if handler, ok := plugin.(ChannelDeleteHandler); ok {
+			callPluginHandler(plugin, "OnChannelDelete", func() { handler.OnChannelDelete(channel, session) })
+		}
+	})
+}
+
+func CallPluginOnGuildUpdate(guild *discordgo.GuildUpdate) {
+	session := cache.GetSession().SessionForGuildS(guild.ID)
+	eachPlugin(func(plugin BaseModule) {
+		if handler, ok := plugin.(GuildUpdateHandler); ok {
+			callPluginHandler(plugin, "OnGuildUpdate", func() { handler.OnGuildUpdate(guild, session) })
+		}
+	})
+}
+
+func CallPluginOnGuildRoleCreate(role *discordgo.GuildRoleCreate) {
+	session := cache.GetSession().SessionForGuildS(role.GuildID)
+	eachPlugin(func(plugin BaseModule) {
+		if handler, ok := plugin.(GuildRoleCreateHandler); ok {
+			callPluginHandler(plugin, "OnGuildRoleCreate", func() { handler.OnGuildRoleCreate(role, session) })
+		}
+	})
+}
+
+func CallPluginOnGuildRoleUpdate(role *discordgo.GuildRoleUpdate) {
+	session := cache.GetSession().SessionForGuildS(role.GuildID)
+	eachPlugin(func(plugin BaseModule) {
+		if handler, ok := plugin.(GuildRoleUpdateHandler); ok {
+			callPluginHandler(plugin, "OnGuildRoleUpdate", func() { handler.OnGuildRoleUpdate(role, session) })
+		}
+	})
+}
+
+func CallPluginOnGuildRoleDelete(role *discordgo.GuildRoleDelete) {
+	session := cache.GetSession().SessionForGuildS(role.GuildID)
+	eachPlugin(func(plugin BaseModule) {
+		if handler, ok := plugin.(GuildRoleDeleteHandler); ok {
+			callPluginHandler(plugin, "OnGuildRoleDelete", func() { handler.OnGuildRoleDelete(role, session) })
+		}
+	})
+}
+
+func CallPluginOnGuildMemberUpdate(member *discordgo.GuildMemberUpdate) {
+	session := cache.GetSession().SessionForGuildS(member.GuildID)
+	eachPlugin(func(plugin BaseModule) {
+		if handler, ok := plugin.(GuildMemberUpdateHandler); ok {
+			callPluginHandler(plugin, "OnGuildMemberUpdate", func() { handler.OnGuildMemberUpdate(member, session) })
+		}
+	})
+}
+
+func CallPluginOnPresenceUpdate(presence *discordgo.PresenceUpdate) {
+	session := cache.GetSession().SessionForGuildS(presence.GuildID)
+	eachPlugin(func(plugin BaseModule) {
+		if handler, ok := plugin.(PresenceUpdateHandler); ok {
+			callPluginHandler(plugin, "OnPresenceUpdate", func() { handler.OnPresenceUpdate(presence, session) })
+		}
+	})
+}
+
+func CallPluginOnWebhooksUpdate(webhooks *discordgo.WebhooksUpdate) {
+	session := cache.GetSession().SessionForGuildS(webhooks.GuildID)
+	eachPlugin(func(plugin BaseModule) {
+		if handler, ok := plugin.(WebhooksUpdateHandler); ok {
+			callPluginHandler(plugin, "OnWebhooksUpdate", func() { handler.OnWebhooksUpdate(webhooks, session) })
+		}
+	})
+}
+
+func CallPluginOnInviteCreate(invite *InviteCreate) {
+	session := cache.GetSession().SessionForGuildS(invite.GuildID)
+	eachPlugin(func(plugin BaseModule) {
+		if handler, ok := plugin.(InviteCreateHandler); ok {
+			callPluginHandler(plugin, "OnInviteCreate", func() { handler.OnInviteCreate(invite, session) })
+		}
+	})
+}
+
+func CallPluginOnInviteDelete(invite *InviteDelete) {
+	session := cache.GetSession().SessionForGuildS(invite.GuildID)
+	eachPlugin(func(plugin BaseModule) {
+		if handler, ok := plugin.(InviteDeleteHandler); ok {
+			callPluginHandler(plugin, "OnInviteDelete", func() { handler.OnInviteDelete(invite, session) })
+		}
+	})
+}
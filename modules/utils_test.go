@@ -0,0 +1,131 @@
+package modules
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/shardmanager"
+	"github.com/bwmarrin/discordgo"
+	"github.com/sirupsen/logrus"
+)
+
+type testPlugin struct {
+	initialized bool
+}
+
+func (p *testPlugin) Commands() []string {
+	return []string{"testcommand"}
+}
+
+func (p *testPlugin) Init(session *shardmanager.Manager) {
+	p.initialized = true
+}
+
+func (p *testPlugin) Action(command string, content string, msg *discordgo.Message, session *discordgo.Session) {
+}
+
+type testExtendedPlugin struct {
+	initialized bool
+}
+
+func (p *testExtendedPlugin) Commands() []string {
+	return []string{"testextendedcommand"}
+}
+
+func (p *testExtendedPlugin) Init(session *shardmanager.Manager) {
+	p.initialized = true
+}
+
+func (p *testExtendedPlugin) Uninit(session *shardmanager.Manager) {
+	p.initialized = false
+}
+
+func (p *testExtendedPlugin) Action(command string, content string, msg *discordgo.Message, session *discordgo.Session) {
+}
+
+func (p *testExtendedPlugin) OnMessage(content string, msg *discordgo.Message, session *discordgo.Session) {
+}
+
+func (p *testExtendedPlugin) OnMessageDelete(msg *discordgo.MessageDelete, session *discordgo.Session) {
+}
+
+func (p *testExtendedPlugin) OnGuildMemberAdd(member *discordgo.Member, session *discordgo.Session) {
+}
+
+func (p *testExtendedPlugin) OnGuildMemberRemove(member *discordgo.Member, session *discordgo.Session) {
+}
+
+func (p *testExtendedPlugin) OnReactionAdd(reaction *discordgo.MessageReactionAdd, session *discordgo.Session) {
+}
+
+func (p *testExtendedPlugin) OnReactionRemove(reaction *discordgo.MessageReactionRemove, session *discordgo.Session) {
+}
+
+func (p *testExtendedPlugin) OnGuildBanAdd(user *discordgo.GuildBanAdd, session *discordgo.Session) {
+}
+
+func (p *testExtendedPlugin) OnGuildBanRemove(user *discordgo.GuildBanRemove, session *discordgo.Session) {
+}
+
+func init() {
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	cache.SetLogger(logger)
+}
+
+func TestInit(t *testing.T) {
+	plugin := &testPlugin{}
+	extendedPlugin := &testExtendedPlugin{}
+
+	oldPluginList, oldPluginExtendedList := PluginList, PluginExtendedList
+	PluginList = []Plugin{plugin}
+	PluginExtendedList = []ExtendedPlugin{extendedPlugin}
+	defer func() {
+		PluginList, PluginExtendedList = oldPluginList, oldPluginExtendedList
+	}()
+
+	Init(nil)
+
+	if !plugin.initialized {
+		t.Fatalf("modules.Init() failed to initialize the plugin")
+	}
+	if !extendedPlugin.initialized {
+		t.Fatalf("modules.Init() failed to initialize the extended plugin")
+	}
+	if enabled := getEnabledExtendedPlugins(); len(enabled) != 1 || enabled[0] != ExtendedPlugin(extendedPlugin) {
+		t.Fatalf("modules.Init() failed to enable the extended plugin, got %v", enabled)
+	}
+	if _, extended := getPluginsForCommand("testextendedcommand"); extended == nil || *extended != ExtendedPlugin(extendedPlugin) {
+		t.Fatalf("modules.Init() failed to cache the commands of the extended plugin, got %v", extended)
+	}
+	if commands := cache.GetPluginExtendedList(); len(commands) != 1 || commands[0] != "testextendedcommand" {
+		t.Fatalf("modules.Init() failed to publish the commands of the extended plugin, got %v", commands)
+	}
+}
+
+func TestDisablePluginNotReloadable(t *testing.T) {
+	extendedPlugin := &testExtendedPlugin{}
+
+	oldPluginList, oldPluginExtendedList := PluginList, PluginExtendedList
+	PluginList = []Plugin{}
+	PluginExtendedList = []ExtendedPlugin{extendedPlugin}
+	pluginsNotReloadable[PluginName(extendedPlugin)] = true
+	delete(pluginsInitialized, PluginName(extendedPlugin))
+	defer func() {
+		PluginList, PluginExtendedList = oldPluginList, oldPluginExtendedList
+		delete(pluginsNotReloadable, PluginName(extendedPlugin))
+	}()
+
+	Init(nil)
+
+	if err := DisablePlugin(PluginName(extendedPlugin)); err != errPluginNotReloadable {
+		t.Fatalf("modules.DisablePlugin() failed to refuse a plugin which is not reloadable, got %v", err)
+	}
+	if err := ReloadPlugin(PluginName(extendedPlugin)); err != errPluginNotReloadable {
+		t.Fatalf("modules.ReloadPlugin() failed to refuse a plugin which is not reloadable, got %v", err)
+	}
+	if !extendedPlugin.initialized || len(getEnabledExtendedPlugins()) != 1 {
+		t.Fatalf("modules.DisablePlugin() failed to keep the plugin enabled")
+	}
+}
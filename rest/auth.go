@@ -0,0 +1,343 @@
+package rest
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/models"
+	restful "github.com/emicklei/go-restful"
+	"github.com/vmihailenco/msgpack"
+)
+
+const (
+	oauthUserCacheKeyPrefix = "robyul-api:oauth-user:"
+	// users of OAuth2 access tokens are cached for this time, to keep the number of requests to Discord low
+	oauthUserCacheTime      = time.Minute * 10
+	oauthInvalidCacheTime   = time.Minute // invalid access tokens are cached for this time
+	oauthInvalidUserID      = "-"
+	oauthIPRateLimit        = 30 // lookups of uncached access tokens per minute and IP
+	rateLimitKeyPrefix      = "robyul-api:ratelimit:"
+	defaultRateLimit        = 120  // requests per minute
+	defaultWebkeyRateLimit  = 6000 // requests per minute, the website calls the API with the webkey for all its users
+	discordOAuthUserURL     = "https://discordapp.com/api/v6/users/@me"
+	attributeUserID         = "UserID"
+	attributeApiToken       = "ApiToken"
+	authorizationWebkey     = "Webkey "
+	authorizationToken      = "Token "
+	authorizationBearer     = "Bearer "
+	authorizationPHPSession = "PHP-Session "
+)
+
+var (
+	errOAuthInvalid     = errors.New("invalid access token")
+	errOAuthRateLimited = errors.New("too many access token lookups")
+)
+
+// apiAuthenticate allows the webkey and API tokens with access to the endpoint group
+func apiAuthenticate(group string) restful.FilterFunction {
+	return authenticate(group, false)
+}
+
+// userAuthenticate allows the webkey, API tokens with access to the endpoint group, website sessions and
+// Discord OAuth2 access tokens. Users have to be a member of the guild of the request.
+func userAuthenticate(group string) restful.FilterFunction {
+	return authenticate(group, true)
+}
+
+func authenticate(group string, allowUsers bool) restful.FilterFunction {
+	return func(request *restful.Request, response *restful.Response, chain *restful.FilterChain) {
+		authorizationHeader := strings.TrimSpace(request.HeaderParameter("Authorization"))
+		guildID := request.PathParameter("guild-id")
+
+		auditEntry := models.ApiAuditLogEntry{
+			Method:  request.Request.Method,
+			Path:    request.Request.URL.Path,
+			Group:   group,
+			GuildID: guildID,
+			IP:      getRequestIP(request),
+		}
+
+		var userID, rateLimitKey string
+		var rateLimit int
+		switch {
+		case strings.HasPrefix(authorizationHeader, authorizationWebkey):
+			if isWebkey(strings.TrimPrefix(authorizationHeader, authorizationWebkey)) {
+				userID = "global"
+				rateLimitKey, rateLimit = getWebkeyRateLimit()
+			}
+		case strings.HasPrefix(authorizationHeader, authorizationToken):
+			token, err := helpers.GetApiToken(strings.TrimSpace(strings.TrimPrefix(authorizationHeader, authorizationToken)))
+			if err != nil {
+				if err != helpers.ErrApiTokenInvalid {
+					helpers.RelaxLog(err)
+				}
+				break
+			}
+
+			auditEntry.TokenID = token.ID
+			if !isApiTokenAllowed(token, group, guildID, isWriteRequest(request)) {
+				writeForbidden(response, auditEntry)
+				return
+			}
+
+			userID = "global"
+			request.SetAttribute(attributeApiToken, &token)
+			rateLimitKey = token.ID.Hex()
+			rateLimit = token.RateLimit
+		case allowUsers && strings.HasPrefix(authorizationHeader, authorizationBearer):
+			oauthUserID, err := getOAuthUserID(strings.TrimSpace(strings.TrimPrefix(authorizationHeader, authorizationBearer)), auditEntry.IP)
+			if err == errOAuthRateLimited {
+				writeTooManyRequests(response, auditEntry)
+				return
+			}
+			if err != nil {
+				break
+			}
+			userID = oauthUserID
+			rateLimitKey = "user-" + oauthUserID
+		case allowUsers && strings.HasPrefix(authorizationHeader, authorizationPHPSession):
+			userID = getSessionUserID(strings.TrimSpace(strings.TrimPrefix(authorizationHeader, authorizationPHPSession)))
+			if userID != "" {
+				rateLimitKey = "user-" + userID
+			}
+		}
+
+		// the query parameter leaks into logs, it is only accepted if enabled for old clients
+		if userID == "" && helpers.GetConfig().Path("website.webkey_query").Data() == true &&
+			isWebkey(request.QueryParameter("webkey")) {
+			userID = "global"
+			rateLimitKey, rateLimit = getWebkeyRateLimit()
+		}
+
+		if userID == "" {
+			response.WriteErrorString(http.StatusUnauthorized, "401: Not Authorized")
+			return
+		}
+		auditEntry.UserID = userID
+
+		if userID != "global" && guildID != "" && !helpers.GetIsInGuild(guildID, userID) {
+			writeForbidden(response, auditEntry)
+			return
+		}
+
+		if rateLimitKey != "" {
+			limited, err := isRateLimited(rateLimitKey, rateLimit)
+			helpers.RelaxLog(err)
+			if limited {
+				writeTooManyRequests(response, auditEntry)
+				return
+			}
+		}
+
+		request.SetAttribute(attributeUserID, userID)
+		chain.ProcessFilter(request, response)
+
+		auditEntry.Status = response.StatusCode()
+		go helpers.LogApiCall(auditEntry)
+	}
+}
+
+func writeForbidden(response *restful.Response, auditEntry models.ApiAuditLogEntry) {
+	response.WriteErrorString(http.StatusForbidden, "403: Forbidden")
+	auditEntry.Status = http.StatusForbidden
+	go helpers.LogApiCall(auditEntry)
+}
+
+func writeTooManyRequests(response *restful.Response, auditEntry models.ApiAuditLogEntry) {
+	response.AddHeader("Retry-After", strconv.Itoa(60-time.Now().Second()))
+	response.WriteErrorString(http.StatusTooManyRequests, "429: Too Many Requests")
+	auditEntry.Status = http.StatusTooManyRequests
+	go helpers.LogApiCall(auditEntry)
+}
+
+func isWebkey(webkey string) bool {
+	configWebkey, _ := helpers.GetConfig().Path("website.webkey").Data().(string)
+	webkey = strings.TrimSpace(webkey)
+	if configWebkey == "" || webkey == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(webkey), []byte(configWebkey)) == 1
+}
+
+// getWebkeyRateLimit returns the rate limit key and the requests per minute of the webkey,
+// website.webkey_rate_limit or defaultWebkeyRateLimit
+func getWebkeyRateLimit() (key string, limit int) {
+	limit = defaultWebkeyRateLimit
+	if configLimit, ok := helpers.GetConfig().Path("website.webkey_rate_limit").Data().(float64); ok && configLimit > 0 {
+		limit = int(configLimit)
+	}
+	return "webkey", limit
+}
+
+// isApiTokenAllowed returns true if the token can call an endpoint of the group,
+// tokens limited to guilds can only call endpoints of these guilds
+// guildID	: the guild of the endpoint, empty for endpoints without a guild
+// write	: if the endpoint changes data
+func isApiTokenAllowed(token models.ApiTokenEntry, group, guildID string, write bool) bool {
+	if !helpers.ApiTokenAllowsGroup(token, group) {
+		return false
+	}
+	if write && !token.Write {
+		return false
+	}
+	if len(token.GuildIDs) > 0 && (guildID == "" || !helpers.ApiTokenAllowsGuild(token, guildID)) {
+		return false
+	}
+	return true
+}
+
+func isWriteRequest(request *restful.Request) bool {
+	switch request.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return true
+}
+
+// isRateLimited counts the request, and returns true if the client made more requests than allowed in this minute
+// limit	: requests per minute, website.api_rate_limit or defaultRateLimit if 0
+func isRateLimited(client string, limit int) (limited bool, err error) {
+	if limit <= 0 {
+		limit = defaultRateLimit
+		if configLimit, ok := helpers.GetConfig().Path("website.api_rate_limit").Data().(float64); ok && configLimit > 0 {
+			limit = int(configLimit)
+		}
+	}
+
+	key := rateLimitKeyPrefix + client + ":" + strconv.FormatInt(time.Now().Unix()/60, 10)
+	redis := cache.GetRedisClient()
+	count, err := redis.Incr(key).Result()
+	if err != nil {
+		return false, err
+	}
+	if count == 1 {
+		redis.Expire(key, time.Minute)
+	}
+	return count > int64(limit), nil
+}
+
+// getSessionUserID returns the user of a session of the website, or an empty string if the session is invalid
+func getSessionUserID(sessionID string) string {
+	sessionDataString, err := cache.GetRedisClient().Get("robyul2-web:robyul-session:" + sessionID).Result()
+	if err != nil {
+		return ""
+	}
+
+	var sessionData models.Website_Session_Data
+	msgpack.Unmarshal([]byte(sessionDataString), &sessionData)
+	return sessionData.DiscordUserID
+}
+
+// getOAuthUserID returns the user a Discord OAuth2 access token belongs to, the token needs the identify scope
+// Tokens which are not cached are looked up on Discord, this is rate limited per IP, and invalid tokens are cached
+// for a short time as well, so clients sending invalid tokens can't get the bot banned by Discord.
+// ip	: the IP of the client
+func getOAuthUserID(accessToken, ip string) (userID string, err error) {
+	if accessToken == "" {
+		return "", errors.New("empty access token")
+	}
+
+	key := oauthUserCacheKeyPrefix + helpers.HashApiToken(accessToken)
+	userID, err = cache.GetRedisClient().Get(key).Result()
+	if err == nil && userID == oauthInvalidUserID {
+		return "", errOAuthInvalid
+	}
+	if err == nil && userID != "" {
+		return userID, nil
+	}
+
+	limited, err := isRateLimited("oauth-ip-"+ip, oauthIPRateLimit)
+	if err != nil {
+		return "", err
+	}
+	if limited {
+		return "", errOAuthRateLimited
+	}
+
+	userID, err = requestOAuthUserID(accessToken)
+	if err != nil {
+		helpers.RelaxLog(cache.GetRedisClient().Set(key, oauthInvalidUserID, oauthInvalidCacheTime).Err())
+		return "", err
+	}
+
+	helpers.RelaxLog(cache.GetRedisClient().Set(key, userID, oauthUserCacheTime).Err())
+	return userID, nil
+}
+
+// requestOAuthUserID looks up the user of a Discord OAuth2 access token on Discord
+func requestOAuthUserID(accessToken string) (userID string, err error) {
+
+	request, err := http.NewRequest(http.MethodGet, discordOAuthUserURL, nil)
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Authorization", authorizationBearer+accessToken)
+	request.Header.Set("User-Agent", helpers.DEFAULT_UA)
+
+	client := &http.Client{Timeout: time.Second * 10}
+	response, err := client.Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", errors.New("discord returned " + response.Status)
+	}
+
+	var user struct {
+		ID string `json:"id"`
+	}
+	err = json.NewDecoder(response.Body).Decode(&user)
+	if err != nil {
+		return "", err
+	}
+	if user.ID == "" {
+		return "", errOAuthInvalid
+	}
+
+	return user.ID, nil
+}
+
+// getRequestIP returns the IP of the client. X-Forwarded-For is only used for requests of a trusted proxy,
+// the last address is the one the proxy added, the others are sent by the client.
+func getRequestIP(request *restful.Request) string {
+	remoteIP := request.Request.RemoteAddr
+	if host, _, err := net.SplitHostPort(remoteIP); err == nil {
+		remoteIP = host
+	}
+
+	forwardedFor := request.HeaderParameter("X-Forwarded-For")
+	if forwardedFor == "" || !isTrustedProxy(remoteIP) {
+		return remoteIP
+	}
+
+	addresses := strings.Split(forwardedFor, ",")
+	return strings.TrimSpace(addresses[len(addresses)-1])
+}
+
+// isTrustedProxy returns true if the IP is on localhost, or listed in website.trusted_proxies
+func isTrustedProxy(ip string) bool {
+	parsedIP := net.ParseIP(ip)
+	if parsedIP == nil {
+		return false
+	}
+	if parsedIP.IsLoopback() {
+		return true
+	}
+
+	trustedProxies, _ := helpers.GetConfig().Path("website.trusted_proxies").Data().([]interface{})
+	for _, trustedProxy := range trustedProxies {
+		if trustedProxyText, ok := trustedProxy.(string); ok && net.ParseIP(trustedProxyText).Equal(parsedIP) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package rest
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Seklfreak/Robyul2/models"
+	restful "github.com/emicklei/go-restful"
+)
+
+func TestIsApiTokenAllowed(t *testing.T) {
+	tests := []struct {
+		name    string
+		token   models.ApiTokenEntry
+		group   string
+		guildID string
+		write   bool
+		allowed bool
+	}{
+		{"unrestricted read", models.ApiTokenEntry{}, "statistics", "1", false, true},
+		{"unrestricted read without guild", models.ApiTokenEntry{}, "statistics", "", false, true},
+		{"read only write", models.ApiTokenEntry{}, "statistics", "1", true, false},
+		{"write", models.ApiTokenEntry{Write: true}, "statistics", "1", true, true},
+		{"allowed group", models.ApiTokenEntry{Groups: []string{"statistics"}}, "statistics", "1", false, true},
+		{"other group", models.ApiTokenEntry{Groups: []string{"statistics"}}, "rankings", "1", false, false},
+		{"allowed guild", models.ApiTokenEntry{GuildIDs: []string{"1"}}, "statistics", "1", false, true},
+		{"other guild", models.ApiTokenEntry{GuildIDs: []string{"1"}}, "statistics", "2", false, false},
+		{"guild token without guild", models.ApiTokenEntry{GuildIDs: []string{"1"}}, "statistics", "", false, false},
+		{"all restrictions", models.ApiTokenEntry{Groups: []string{"dashboard"}, GuildIDs: []string{"1"}, Write: true},
+			"dashboard", "1", true, true},
+	}
+
+	for _, test := range tests {
+		if allowed := isApiTokenAllowed(test.token, test.group, test.guildID, test.write); allowed != test.allowed {
+			t.Fatalf("rest.isApiTokenAllowed() failed for %s, expected %v, got %v", test.name, test.allowed, allowed)
+		}
+	}
+}
+
+func TestGetRequestIP(t *testing.T) {
+	tests := []struct {
+		name         string
+		remoteAddr   string
+		forwardedFor string
+		ip           string
+	}{
+		{"direct", "203.0.113.1:1234", "", "203.0.113.1"},
+		{"forwarded by an untrusted client", "203.0.113.1:1234", "198.51.100.1", "203.0.113.1"},
+		{"forwarded by the proxy", "127.0.0.1:1234", "198.51.100.1", "198.51.100.1"},
+		{"forwarded by the proxy, spoofed by the client", "127.0.0.1:1234", "192.0.2.1, 198.51.100.1", "198.51.100.1"},
+		{"forwarded by the proxy on IPv6", "[::1]:1234", "198.51.100.1", "198.51.100.1"},
+	}
+
+	for _, test := range tests {
+		httpRequest := httptest.NewRequest("GET", "/", nil)
+		httpRequest.RemoteAddr = test.remoteAddr
+		if test.forwardedFor != "" {
+			httpRequest.Header.Set("X-Forwarded-For", test.forwardedFor)
+		}
+
+		if ip := getRequestIP(restful.NewRequest(httpRequest)); ip != test.ip {
+			t.Fatalf("rest.getRequestIP() failed for %s, expected %s, got %s", test.name, test.ip, ip)
+		}
+	}
+}
+
+func TestGetWebkeyRateLimit(t *testing.T) {
+	key, limit := getWebkeyRateLimit()
+	if key == "" || limit != defaultWebkeyRateLimit {
+		t.Fatalf("rest.getWebkeyRateLimit() failed to return the default limit, got %s %d", key, limit)
+	}
+}
@@ -0,0 +1,736 @@
+package rest
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/Seklfreak/Robyul2/modules/plugins"
+	"github.com/Seklfreak/Robyul2/modules/plugins/feeds"
+	"github.com/Seklfreak/Robyul2/modules/plugins/levels"
+	restful "github.com/emicklei/go-restful"
+)
+
+// addDashboardRoutes adds the endpoints to manage the modules of a guild to the /guild service
+// the endpoints use the same functions as the commands, so all changes are validated and logged to the eventlog
+func addDashboardRoutes(service *restful.WebService) {
+	guildIDParameter := service.PathParameter("guild-id", "the ID of the guild")
+
+	service.Route(service.GET("/{guild-id}/customcommands").Filter(userAuthenticate("customcommands")).To(GetCustomCommands).
+		Doc("list the custom commands of the guild").Param(guildIDParameter).
+		Writes([]models.Rest_CustomCommand{}))
+	service.Route(service.POST("/{guild-id}/customcommands").Filter(userAuthenticate("customcommands")).To(AddCustomCommand).
+		Doc("add a custom command").Param(guildIDParameter).
+		Reads(models.Rest_Receive_CustomCommand{}).Writes(models.Rest_CustomCommand{}))
+	service.Route(service.PUT("/{guild-id}/customcommands/{keyword}").Filter(userAuthenticate("customcommands")).To(EditCustomCommand).
+		Doc("change the content of a custom command").Param(guildIDParameter).
+		Param(service.PathParameter("keyword", "the keyword of the custom command")).
+		Reads(models.Rest_Receive_CustomCommand{}).Writes(models.Rest_CustomCommand{}))
+	service.Route(service.DELETE("/{guild-id}/customcommands/{keyword}").Filter(userAuthenticate("customcommands")).To(DeleteCustomCommand).
+		Doc("delete a custom command").Param(guildIDParameter).
+		Param(service.PathParameter("keyword", "the keyword of the custom command")))
+
+	service.Route(service.GET("/{guild-id}/autoroles").Filter(userAuthenticate("autoroles")).Filter(requireDashboardAdmin).To(GetAutoroles).
+		Doc("list the autoroles of the guild").Param(guildIDParameter).
+		Writes([]models.Rest_Autorole{}))
+	service.Route(service.POST("/{guild-id}/autoroles").Filter(userAuthenticate("autoroles")).Filter(requireDashboardAdmin).To(AddAutorole).
+		Doc("add an autorole").Param(guildIDParameter).
+		Reads(models.Rest_Receive_Autorole{}).Writes([]models.Rest_Autorole{}))
+	service.Route(service.DELETE("/{guild-id}/autoroles/{role-id}").Filter(userAuthenticate("autoroles")).Filter(requireDashboardAdmin).To(DeleteAutorole).
+		Doc("remove an autorole").Param(guildIDParameter).
+		Param(service.PathParameter("role-id", "the ID of the role")))
+
+	service.Route(service.GET("/{guild-id}/starboard").Filter(userAuthenticate("starboard")).Filter(requireDashboardMod).To(GetStarboard).
+		Doc("get the starboard settings of the guild").Param(guildIDParameter).
+		Writes(models.Rest_Starboard{}))
+	service.Route(service.PUT("/{guild-id}/starboard").Filter(userAuthenticate("starboard")).Filter(requireDashboardMod).To(SetStarboard).
+		Doc("change the starboard settings, fields which are not sent stay unchanged").Param(guildIDParameter).
+		Reads(models.Rest_Receive_Starboard{}).Writes(models.Rest_Starboard{}))
+
+	service.Route(service.GET("/{guild-id}/greeter").Filter(userAuthenticate("greeter")).Filter(requireDashboardAdmin).To(GetGreeters).
+		Doc("list the join, leave and ban messages of the guild").Param(guildIDParameter).
+		Writes([]models.Rest_Greeter{}))
+	service.Route(service.PUT("/{guild-id}/greeter/{type}/{channel-id}").Filter(userAuthenticate("greeter")).Filter(requireDashboardAdmin).To(SetGreeter).
+		Doc("set a join, leave or ban message").Param(guildIDParameter).
+		Param(service.PathParameter("type", "join, leave or ban")).
+		Param(service.PathParameter("channel-id", "the ID of the channel")).
+		Reads(models.Rest_Receive_Greeter{}).Writes(models.Rest_Greeter{}))
+	service.Route(service.DELETE("/{guild-id}/greeter/{type}/{channel-id}").Filter(userAuthenticate("greeter")).Filter(requireDashboardAdmin).To(DeleteGreeter).
+		Doc("remove a join, leave or ban message").Param(guildIDParameter).
+		Param(service.PathParameter("type", "join, leave or ban")).
+		Param(service.PathParameter("channel-id", "the ID of the channel")))
+
+	service.Route(service.GET("/{guild-id}/levels/roles").Filter(userAuthenticate("levels")).Filter(requireDashboardMod).To(GetLevelsRoles).
+		Doc("list the level roles of the guild").Param(guildIDParameter).
+		Writes([]models.Rest_LevelsRole{}))
+	service.Route(service.POST("/{guild-id}/levels/roles").Filter(userAuthenticate("levels")).Filter(requireDashboardMod).To(AddLevelsRole).
+		Doc("add a level role").Param(guildIDParameter).
+		Reads(models.Rest_Receive_LevelsRole{}).Writes(models.Rest_LevelsRole{}))
+	service.Route(service.DELETE("/{guild-id}/levels/roles/{levels-role-id}").Filter(userAuthenticate("levels")).Filter(requireDashboardMod).To(DeleteLevelsRole).
+		Doc("remove a level role").Param(guildIDParameter).
+		Param(service.PathParameter("levels-role-id", "the ID of the level role")))
+
+	service.Route(service.GET("/{guild-id}/module-permissions").Filter(userAuthenticate("modulepermissions")).Filter(requireDashboardMod).To(GetModulePermissions).
+		Doc("list the module permissions of the channels and roles of the guild").Param(guildIDParameter).
+		Writes([]models.Rest_ModulePermission{}))
+	service.Route(service.PUT("/{guild-id}/module-permissions/{target-type}/{target-id}/{permission}/{module}").Filter(userAuthenticate("modulepermissions")).Filter(requireDashboardMod).To(SetModulePermission).
+		Doc("allow or deny a module for a channel or role").Param(guildIDParameter).
+		Param(service.PathParameter("target-type", "channel or role")).
+		Param(service.PathParameter("target-id", "the ID of the channel or role")).
+		Param(service.PathParameter("permission", "allow or deny")).
+		Param(service.PathParameter("module", "the name of the module, or all")))
+	service.Route(service.DELETE("/{guild-id}/module-permissions/{target-type}/{target-id}/{permission}/{module}").Filter(userAuthenticate("modulepermissions")).Filter(requireDashboardMod).To(DeleteModulePermission).
+		Doc("remove an allowed or denied module of a channel or role").Param(guildIDParameter).
+		Param(service.PathParameter("target-type", "channel or role")).
+		Param(service.PathParameter("target-id", "the ID of the channel or role")).
+		Param(service.PathParameter("permission", "allow or deny")).
+		Param(service.PathParameter("module", "the name of the module, or all")))
+
+	service.Route(service.GET("/{guild-id}/feeds").Filter(userAuthenticate("feeds")).Filter(requireDashboardMod).To(GetFeeds).
+		Doc("list the feeds of the guild").Param(guildIDParameter).
+		Writes([]models.Rest_Feed{}))
+	service.Route(service.POST("/{guild-id}/feeds").Filter(userAuthenticate("feeds")).Filter(requireDashboardMod).To(AddFeed).
+		Doc("add a RSS or Atom feed").Param(guildIDParameter).
+		Reads(models.Rest_Receive_Feed{}).Writes(models.Rest_Feed{}))
+	service.Route(service.PUT("/{guild-id}/feeds/{feed-id}").Filter(userAuthenticate("feeds")).Filter(requireDashboardMod).To(SetFeedTemplate).
+		Doc("set the template of the posts of a feed").Param(guildIDParameter).
+		Param(service.PathParameter("feed-id", "the ID of the feed")).
+		Reads(models.Rest_Receive_FeedTemplate{}).Writes(models.Rest_Feed{}))
+	service.Route(service.DELETE("/{guild-id}/feeds/{feed-id}").Filter(userAuthenticate("feeds")).Filter(requireDashboardMod).To(DeleteFeed).
+		Doc("remove a feed").Param(guildIDParameter).
+		Param(service.PathParameter("feed-id", "the ID of the feed")))
+}
+
+func GetCustomCommands(request *restful.Request, response *restful.Response) {
+	guildID := request.PathParameter("guild-id")
+
+	entries, err := plugins.CustomCommandsGet(guildID)
+	if err != nil {
+		writeDashboardError(response, err)
+		return
+	}
+
+	result := make([]models.Rest_CustomCommand, 0)
+	for _, entry := range entries {
+		result = append(result, restCustomCommand(entry))
+	}
+	response.WriteEntity(result)
+}
+
+func AddCustomCommand(request *restful.Request, response *restful.Response) {
+	guildID := request.PathParameter("guild-id")
+
+	if !isDashboardGlobal(request) && !plugins.CustomCommandCanEdit(guildID, request.Attribute(attributeUserID).(string), nil) {
+		response.WriteErrorString(401, "401: Not Authorized")
+		return
+	}
+
+	received := new(models.Rest_Receive_CustomCommand)
+	err := request.ReadEntity(received)
+	if err != nil {
+		response.WriteError(http.StatusBadRequest, err)
+		return
+	}
+
+	entry, err := plugins.CustomCommandAdd(guildID, getDashboardUserID(request, guildID),
+		strings.TrimSpace(received.Keyword), strings.TrimSpace(received.Content), "")
+	if err != nil {
+		writeDashboardError(response, err)
+		return
+	}
+
+	response.WriteHeaderAndEntity(http.StatusCreated, restCustomCommand(entry))
+}
+
+func EditCustomCommand(request *restful.Request, response *restful.Response) {
+	guildID := request.PathParameter("guild-id")
+
+	entry, err := plugins.CustomCommandGet(guildID, request.PathParameter("keyword"))
+	if err != nil {
+		writeDashboardError(response, err)
+		return
+	}
+
+	if !isDashboardGlobal(request) && !plugins.CustomCommandCanEdit(guildID, request.Attribute(attributeUserID).(string), &entry) {
+		response.WriteErrorString(401, "401: Not Authorized")
+		return
+	}
+
+	received := new(models.Rest_Receive_CustomCommand)
+	err = request.ReadEntity(received)
+	if err != nil {
+		response.WriteError(http.StatusBadRequest, err)
+		return
+	}
+
+	entry, err = plugins.CustomCommandEdit(guildID, getDashboardUserID(request, guildID),
+		entry.Keyword, strings.TrimSpace(received.Content), "")
+	if err != nil {
+		writeDashboardError(response, err)
+		return
+	}
+
+	response.WriteEntity(restCustomCommand(entry))
+}
+
+func DeleteCustomCommand(request *restful.Request, response *restful.Response) {
+	guildID := request.PathParameter("guild-id")
+
+	entry, err := plugins.CustomCommandGet(guildID, request.PathParameter("keyword"))
+	if err != nil {
+		writeDashboardError(response, err)
+		return
+	}
+
+	if !isDashboardGlobal(request) && !plugins.CustomCommandCanEdit(guildID, request.Attribute(attributeUserID).(string), &entry) {
+		response.WriteErrorString(401, "401: Not Authorized")
+		return
+	}
+
+	err = plugins.CustomCommandDelete(guildID, getDashboardUserID(request, guildID), entry.Keyword)
+	if err != nil {
+		writeDashboardError(response, err)
+		return
+	}
+
+	response.WriteHeader(http.StatusNoContent)
+}
+
+func GetAutoroles(request *restful.Request, response *restful.Response) {
+	guildID := request.PathParameter("guild-id")
+
+	response.WriteEntity(getAutoroles(guildID))
+}
+
+func AddAutorole(request *restful.Request, response *restful.Response) {
+	guildID := request.PathParameter("guild-id")
+
+	received := new(models.Rest_Receive_Autorole)
+	err := request.ReadEntity(received)
+	if err != nil {
+		response.WriteError(http.StatusBadRequest, err)
+		return
+	}
+
+	err = plugins.AutoroleAdd(guildID, getDashboardUserID(request, guildID),
+		received.RoleID, time.Duration(received.DelaySeconds)*time.Second)
+	if err != nil {
+		writeDashboardError(response, err)
+		return
+	}
+
+	response.WriteHeaderAndEntity(http.StatusCreated, getAutoroles(guildID))
+}
+
+func DeleteAutorole(request *restful.Request, response *restful.Response) {
+	guildID := request.PathParameter("guild-id")
+
+	err := plugins.AutoroleRemove(guildID, getDashboardUserID(request, guildID), request.PathParameter("role-id"))
+	if err != nil {
+		writeDashboardError(response, err)
+		return
+	}
+
+	response.WriteHeader(http.StatusNoContent)
+}
+
+func GetStarboard(request *restful.Request, response *restful.Response) {
+	guildID := request.PathParameter("guild-id")
+
+	response.WriteEntity(getStarboard(guildID))
+}
+
+func SetStarboard(request *restful.Request, response *restful.Response) {
+	guildID := request.PathParameter("guild-id")
+
+	received := new(models.Rest_Receive_Starboard)
+	err := request.ReadEntity(received)
+	if err != nil {
+		response.WriteError(http.StatusBadRequest, err)
+		return
+	}
+
+	userID := getDashboardUserID(request, guildID)
+	before := getStarboard(guildID)
+
+	if received.ChannelID != nil && *received.ChannelID != before.ChannelID {
+		err = plugins.StarboardSetChannel(guildID, userID, *received.ChannelID)
+		if err != nil {
+			writeDashboardError(response, err)
+			return
+		}
+	}
+	if received.Minimum != nil && *received.Minimum != before.Minimum {
+		err = plugins.StarboardSetMinimum(guildID, userID, *received.Minimum)
+		if err != nil {
+			writeDashboardError(response, err)
+			return
+		}
+	}
+	if received.Emoji != nil {
+		err = plugins.StarboardSetEmoji(guildID, userID, received.Emoji)
+		if err != nil {
+			writeDashboardError(response, err)
+			return
+		}
+	}
+
+	response.WriteEntity(getStarboard(guildID))
+}
+
+func GetGreeters(request *restful.Request, response *restful.Response) {
+	guildID := request.PathParameter("guild-id")
+
+	entries, err := plugins.GreeterGet(guildID)
+	if err != nil {
+		writeDashboardError(response, err)
+		return
+	}
+
+	result := make([]models.Rest_Greeter, 0)
+	for _, entry := range entries {
+		result = append(result, restGreeter(entry))
+	}
+	response.WriteEntity(result)
+}
+
+func SetGreeter(request *restful.Request, response *restful.Response) {
+	guildID := request.PathParameter("guild-id")
+	channelID := request.PathParameter("channel-id")
+
+	greeterType, ok := plugins.GreeterParseType(request.PathParameter("type"))
+	if !ok {
+		writeDashboardError(response, helpers.NewInputError("bot.arguments.invalid"))
+		return
+	}
+
+	received := new(models.Rest_Receive_Greeter)
+	err := request.ReadEntity(received)
+	if err != nil {
+		response.WriteError(http.StatusBadRequest, err)
+		return
+	}
+	if strings.TrimSpace(received.EmbedCode) == "" {
+		writeDashboardError(response, helpers.NewInputError("bot.arguments.too-few"))
+		return
+	}
+
+	userID := getDashboardUserID(request, guildID)
+	err = plugins.GreeterSet(greeterType, guildID, channelID, userID, strings.TrimSpace(received.EmbedCode))
+	if err != nil {
+		writeDashboardError(response, err)
+		return
+	}
+
+	entry, err := getGreeter(greeterType, guildID, channelID)
+	if err != nil {
+		writeDashboardError(response, err)
+		return
+	}
+
+	if received.CardEnabled != nil || received.CardBackground != nil {
+		cardEnabled, cardBackground := entry.CardEnabled, entry.CardBackground
+		if received.CardEnabled != nil {
+			cardEnabled = *received.CardEnabled
+		}
+		if received.CardBackground != nil {
+			cardBackground = strings.TrimSpace(*received.CardBackground)
+		}
+
+		if cardEnabled != entry.CardEnabled || cardBackground != entry.CardBackground {
+			err = plugins.GreeterSetCard(greeterType, guildID, channelID, userID, cardEnabled, cardBackground)
+			if err != nil {
+				writeDashboardError(response, err)
+				return
+			}
+
+			entry, err = getGreeter(greeterType, guildID, channelID)
+			if err != nil {
+				writeDashboardError(response, err)
+				return
+			}
+		}
+	}
+
+	response.WriteEntity(restGreeter(entry))
+}
+
+func DeleteGreeter(request *restful.Request, response *restful.Response) {
+	guildID := request.PathParameter("guild-id")
+	channelID := request.PathParameter("channel-id")
+
+	greeterType, ok := plugins.GreeterParseType(request.PathParameter("type"))
+	if !ok {
+		writeDashboardError(response, helpers.NewInputError("bot.arguments.invalid"))
+		return
+	}
+
+	err := plugins.GreeterSet(greeterType, guildID, channelID, getDashboardUserID(request, guildID), "")
+	if err != nil {
+		writeDashboardError(response, err)
+		return
+	}
+
+	response.WriteHeader(http.StatusNoContent)
+}
+
+func GetLevelsRoles(request *restful.Request, response *restful.Response) {
+	guildID := request.PathParameter("guild-id")
+
+	entries, err := levels.LevelsRolesGet(guildID)
+	if err != nil {
+		writeDashboardError(response, err)
+		return
+	}
+
+	result := make([]models.Rest_LevelsRole, 0)
+	for _, entry := range entries {
+		result = append(result, restLevelsRole(entry))
+	}
+	response.WriteEntity(result)
+}
+
+func AddLevelsRole(request *restful.Request, response *restful.Response) {
+	guildID := request.PathParameter("guild-id")
+
+	received := new(models.Rest_Receive_LevelsRole)
+	err := request.ReadEntity(received)
+	if err != nil {
+		response.WriteError(http.StatusBadRequest, err)
+		return
+	}
+
+	entry, err := levels.LevelsRoleAdd(guildID, getDashboardUserID(request, guildID),
+		received.RoleID, received.StartLevel, received.LastLevel)
+	if err != nil {
+		writeDashboardError(response, err)
+		return
+	}
+
+	response.WriteHeaderAndEntity(http.StatusCreated, restLevelsRole(entry))
+}
+
+func DeleteLevelsRole(request *restful.Request, response *restful.Response) {
+	guildID := request.PathParameter("guild-id")
+
+	id := helpers.HumanToMdbId(request.PathParameter("levels-role-id"))
+	if id == "" {
+		writeDashboardError(response, helpers.NewInputError("bot.arguments.invalid"))
+		return
+	}
+
+	_, err := levels.LevelsRoleRemove(guildID, getDashboardUserID(request, guildID), id)
+	if err != nil {
+		writeDashboardError(response, err)
+		return
+	}
+
+	response.WriteHeader(http.StatusNoContent)
+}
+
+func GetModulePermissions(request *restful.Request, response *restful.Response) {
+	guildID := request.PathParameter("guild-id")
+
+	result := make([]models.Rest_ModulePermission, 0)
+	for _, entry := range helpers.GetModulePermissionEntries(guildID) {
+		result = append(result, models.Rest_ModulePermission{
+			TargetType: entry.Type,
+			TargetID:   entry.TargetID,
+			Allowed:    getModulePermissionNames(entry.Allowed),
+			Denied:     getModulePermissionNames(entry.Denied),
+		})
+	}
+	response.WriteEntity(result)
+}
+
+func SetModulePermission(request *restful.Request, response *restful.Response) {
+	setModulePermission(request, response, true)
+}
+
+func DeleteModulePermission(request *restful.Request, response *restful.Response) {
+	setModulePermission(request, response, false)
+}
+
+func setModulePermission(request *restful.Request, response *restful.Response, enabled bool) {
+	guildID := request.PathParameter("guild-id")
+
+	var deny bool
+	switch request.PathParameter("permission") {
+	case "allow":
+	case "deny":
+		deny = true
+	default:
+		writeDashboardError(response, helpers.NewInputError("bot.arguments.invalid"))
+		return
+	}
+
+	module, err := plugins.ModulePermissionParseModule(request.PathParameter("module"))
+	if err != nil {
+		writeDashboardError(response, err)
+		return
+	}
+
+	err = plugins.ModulePermissionSet(guildID, getDashboardUserID(request, guildID),
+		request.PathParameter("target-type"), request.PathParameter("target-id"), module, deny, enabled)
+	if err != nil {
+		writeDashboardError(response, err)
+		return
+	}
+
+	response.WriteHeader(http.StatusNoContent)
+}
+
+func GetFeeds(request *restful.Request, response *restful.Response) {
+	guildID := request.PathParameter("guild-id")
+
+	entries, err := feeds.FeedsGet(guildID)
+	if err != nil {
+		writeDashboardError(response, err)
+		return
+	}
+
+	result := make([]models.Rest_Feed, 0)
+	for _, entry := range entries {
+		result = append(result, restFeed(entry))
+	}
+	response.WriteEntity(result)
+}
+
+func AddFeed(request *restful.Request, response *restful.Response) {
+	guildID := request.PathParameter("guild-id")
+
+	received := new(models.Rest_Receive_Feed)
+	err := request.ReadEntity(received)
+	if err != nil {
+		response.WriteError(http.StatusBadRequest, err)
+		return
+	}
+
+	entry, _, err := feeds.FeedAdd(guildID, received.ChannelID, getDashboardUserID(request, guildID),
+		strings.TrimSpace(received.URL), received.MentionRoleID)
+	if err != nil {
+		writeDashboardError(response, err)
+		return
+	}
+
+	response.WriteHeaderAndEntity(http.StatusCreated, restFeed(entry))
+}
+
+func SetFeedTemplate(request *restful.Request, response *restful.Response) {
+	guildID := request.PathParameter("guild-id")
+
+	id := helpers.HumanToMdbId(request.PathParameter("feed-id"))
+	if id == "" {
+		writeDashboardError(response, helpers.NewInputError("plugins.feeds.not-found"))
+		return
+	}
+
+	received := new(models.Rest_Receive_FeedTemplate)
+	err := request.ReadEntity(received)
+	if err != nil {
+		response.WriteError(http.StatusBadRequest, err)
+		return
+	}
+
+	err = feeds.FeedSetTemplate(guildID, getDashboardUserID(request, guildID), id, strings.TrimSpace(received.EmbedCode))
+	if err != nil {
+		writeDashboardError(response, err)
+		return
+	}
+
+	entry, err := feeds.FeedGet(guildID, id)
+	if err != nil {
+		writeDashboardError(response, err)
+		return
+	}
+
+	response.WriteEntity(restFeed(entry))
+}
+
+func DeleteFeed(request *restful.Request, response *restful.Response) {
+	guildID := request.PathParameter("guild-id")
+
+	id := helpers.HumanToMdbId(request.PathParameter("feed-id"))
+	if id == "" {
+		writeDashboardError(response, helpers.NewInputError("plugins.feeds.not-found"))
+		return
+	}
+
+	err := feeds.FeedRemove(guildID, getDashboardUserID(request, guildID), id)
+	if err != nil {
+		writeDashboardError(response, err)
+		return
+	}
+
+	response.WriteHeader(http.StatusNoContent)
+}
+
+// isDashboardGlobal returns true for the webkey and API tokens, they can manage all modules
+func isDashboardGlobal(request *restful.Request) bool {
+	return request.Attribute(attributeUserID).(string) == "global"
+}
+
+// requireDashboardMod only passes moderators and admins of the guild, the webkey and API tokens,
+// it has to be added after userAuthenticate
+func requireDashboardMod(request *restful.Request, response *restful.Response, chain *restful.FilterChain) {
+	if !isDashboardMod(request, request.PathParameter("guild-id")) {
+		response.WriteErrorString(401, "401: Not Authorized")
+		return
+	}
+	chain.ProcessFilter(request, response)
+}
+
+// requireDashboardAdmin only passes admins of the guild, the webkey and API tokens,
+// it has to be added after userAuthenticate
+func requireDashboardAdmin(request *restful.Request, response *restful.Response, chain *restful.FilterChain) {
+	if !isDashboardAdmin(request, request.PathParameter("guild-id")) {
+		response.WriteErrorString(401, "401: Not Authorized")
+		return
+	}
+	chain.ProcessFilter(request, response)
+}
+
+func isDashboardMod(request *restful.Request, guildID string) bool {
+	userID := request.Attribute(attributeUserID).(string)
+	return userID == "global" || helpers.IsModByID(guildID, userID) || helpers.IsAdminByID(guildID, userID)
+}
+
+func isDashboardAdmin(request *restful.Request, guildID string) bool {
+	userID := request.Attribute(attributeUserID).(string)
+	return userID == "global" || helpers.IsAdminByID(guildID, userID)
+}
+
+// getDashboardUserID returns the user changes are logged for in the eventlog,
+// the creator of the API token, or the bot for the webkey
+func getDashboardUserID(request *restful.Request, guildID string) string {
+	userID := request.Attribute(attributeUserID).(string)
+	if userID != "global" {
+		return userID
+	}
+
+	if token, ok := request.Attribute(attributeApiToken).(*models.ApiTokenEntry); ok && token.CreatedByUserID != "" {
+		return token.CreatedByUserID
+	}
+	return cache.GetSession().SessionForGuildS(guildID).State.User.ID
+}
+
+// writeDashboardError writes invalid input with its message as a bad request, and all other errors as internal errors
+func writeDashboardError(response *restful.Response, err error) {
+	if helpers.IsInputError(err) {
+		response.WriteErrorString(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	helpers.RelaxLog(err)
+	response.WriteError(http.StatusInternalServerError, err)
+}
+
+func restCustomCommand(entry models.CustomCommandsEntry) models.Rest_CustomCommand {
+	return models.Rest_CustomCommand{
+		ID:              helpers.MdbIdToHuman(entry.ID),
+		Keyword:         entry.Keyword,
+		Content:         entry.Content,
+		HasFile:         entry.StorageObjectName != "" || entry.StorageHash != "",
+		CreatedByUserID: entry.CreatedByUserID,
+		CreatedAt:       entry.CreatedAt,
+		Triggered:       entry.Triggered,
+	}
+}
+
+func getAutoroles(guildID string) []models.Rest_Autorole {
+	settings := helpers.GuildSettingsGetCached(guildID)
+
+	result := make([]models.Rest_Autorole, 0)
+	for _, roleID := range settings.AutoRoleIDs {
+		result = append(result, models.Rest_Autorole{RoleID: roleID})
+	}
+	for _, delayedRole := range settings.DelayedAutoRoles {
+		result = append(result, models.Rest_Autorole{
+			RoleID:       delayedRole.RoleID,
+			DelaySeconds: int64(delayedRole.Delay / time.Second),
+		})
+	}
+	return result
+}
+
+func getStarboard(guildID string) models.Rest_Starboard {
+	return models.Rest_Starboard{
+		ChannelID: helpers.GuildSettingsGetCached(guildID).StarboardChannelID,
+		Minimum:   plugins.StarboardGetMinimum(guildID),
+		Emoji:     plugins.StarboardGetEmoji(guildID),
+	}
+}
+
+func getGreeter(greeterType models.GreeterType, guildID, channelID string) (entry models.GreeterEntry, err error) {
+	entries, err := plugins.GreeterGet(guildID)
+	if err != nil {
+		return entry, err
+	}
+
+	for _, entry = range entries {
+		if entry.Type == greeterType && entry.ChannelID == channelID {
+			return entry, nil
+		}
+	}
+	return entry, helpers.NewInputError("plugins.guildannouncements.card-error-no-greeter")
+}
+
+func restGreeter(entry models.GreeterEntry) models.Rest_Greeter {
+	greeterType := "join"
+	switch entry.Type {
+	case models.GreeterTypeLeave:
+		greeterType = "leave"
+	case models.GreeterTypeBan:
+		greeterType = "ban"
+	}
+
+	return models.Rest_Greeter{
+		Type:           greeterType,
+		ChannelID:      entry.ChannelID,
+		EmbedCode:      entry.EmbedCode,
+		CardEnabled:    entry.CardEnabled,
+		CardBackground: entry.CardBackground,
+	}
+}
+
+func restLevelsRole(entry models.LevelsRoleEntry) models.Rest_LevelsRole {
+	return models.Rest_LevelsRole{
+		ID:         helpers.MdbIdToHuman(entry.ID),
+		RoleID:     entry.RoleID,
+		StartLevel: entry.StartLevel,
+		LastLevel:  entry.LastLevel,
+	}
+}
+
+// getModulePermissionNames returns the names of the modules, all if all modules are set
+func getModulePermissionNames(permissions models.ModulePermissionsModule) []string {
+	names := make([]string, 0)
+	if permissions < 0 { // unset
+		return names
+	}
+	if permissions&helpers.ModulePermAllPlaceholder == helpers.ModulePermAllPlaceholder {
+		return append(names, "all")
+	}
+
+	for _, module := range helpers.Modules {
+		if permissions&module.Permission == module.Permission {
+			names = append(names, helpers.GetModuleNameById(module.Permission))
+		}
+	}
+	return names
+}
+
+func restFeed(entry models.FeedsEntry) models.Rest_Feed {
+	return models.Rest_Feed{
+		ID:            helpers.MdbIdToHuman(entry.ID),
+		ChannelID:     entry.ChannelID,
+		URL:           entry.URL,
+		MentionRoleID: entry.MentionRoleID,
+		EmbedCode:     entry.EmbedCode,
+		AddedByUserID: entry.AddedByUserID,
+		AddedAt:       entry.AddedAt,
+	}
+}
@@ -0,0 +1,190 @@
+package rest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/Seklfreak/Robyul2/models"
+	"github.com/Seklfreak/Robyul2/modules/plugins"
+	"github.com/Seklfreak/Robyul2/shardmanager"
+	"github.com/bwmarrin/discordgo"
+	restful "github.com/emicklei/go-restful"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	testGuildID = "116620585638821891"
+	testOwnerID = "1"
+	testUserID  = "2"
+
+	testChannelID = "116620585638821892"
+)
+
+func init() {
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	cache.SetLogger(logger)
+
+	// a single shard with the guild in its state, the owner is the only member
+	session := &discordgo.Session{State: discordgo.NewState()}
+	session.State.User = &discordgo.User{ID: "3"}
+	session.State.GuildAdd(&discordgo.Guild{
+		ID:       testGuildID,
+		OwnerID:  testOwnerID,
+		Members:  []*discordgo.Member{{GuildID: testGuildID, User: &discordgo.User{ID: testOwnerID}}},
+		Channels: []*discordgo.Channel{{ID: testChannelID, GuildID: testGuildID}},
+	})
+
+	manager := shardmanager.New("")
+	manager.SetNumShards(1)
+	manager.SetShardRange(0, 0)
+	manager.Sessions = []*discordgo.Session{session}
+	cache.SetSession(manager)
+}
+
+func newTestDashboardRequest(userID string, pathParameters map[string]string, body string) *restful.Request {
+	httpRequest := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	httpRequest.Header.Set("Content-Type", restful.MIME_JSON)
+
+	request := restful.NewRequest(httpRequest)
+	request.SetAttribute(attributeUserID, userID)
+	for key, value := range pathParameters {
+		request.PathParameters()[key] = value
+	}
+	return request
+}
+
+func newTestDashboardResponse() (*restful.Response, *httptest.ResponseRecorder) {
+	recorder := httptest.NewRecorder()
+	response := restful.NewResponse(recorder)
+	response.SetRequestAccepts(restful.MIME_JSON)
+	return response, recorder
+}
+
+func TestIsDashboardMod(t *testing.T) {
+	tests := []struct {
+		name    string
+		userID  string
+		allowed bool
+	}{
+		{"global", "global", true},
+		{"owner", testOwnerID, true},
+		{"not a member", testUserID, false},
+	}
+
+	for _, test := range tests {
+		request := newTestDashboardRequest(test.userID, nil, "")
+		if allowed := isDashboardMod(request, testGuildID); allowed != test.allowed {
+			t.Fatalf("rest.isDashboardMod() failed for %s, expected %v, got %v", test.name, test.allowed, allowed)
+		}
+		if allowed := isDashboardAdmin(request, testGuildID); allowed != test.allowed {
+			t.Fatalf("rest.isDashboardAdmin() failed for %s, expected %v, got %v", test.name, test.allowed, allowed)
+		}
+	}
+}
+
+func TestRequireDashboard(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter restful.FilterFunction
+		userID string
+		passed bool
+	}{
+		{"mod filter for global", requireDashboardMod, "global", true},
+		{"mod filter for the owner", requireDashboardMod, testOwnerID, true},
+		{"mod filter for a user who is not a member", requireDashboardMod, testUserID, false},
+		{"admin filter for global", requireDashboardAdmin, "global", true},
+		{"admin filter for the owner", requireDashboardAdmin, testOwnerID, true},
+		{"admin filter for a user who is not a member", requireDashboardAdmin, testUserID, false},
+	}
+
+	for _, test := range tests {
+		var passed bool
+		chain := &restful.FilterChain{Target: func(request *restful.Request, response *restful.Response) {
+			passed = true
+		}}
+		response, recorder := newTestDashboardResponse()
+		test.filter(newTestDashboardRequest(test.userID, map[string]string{"guild-id": testGuildID}, ""), response, chain)
+		if passed != test.passed {
+			t.Fatalf("rest dashboard filter failed for %s, expected %v, got %v", test.name, test.passed, passed)
+		}
+		if !passed && recorder.Code != http.StatusUnauthorized {
+			t.Fatalf("rest dashboard filter failed to reject %s with %d, got %d", test.name, http.StatusUnauthorized, recorder.Code)
+		}
+	}
+}
+
+func TestCustomCommandCanEdit(t *testing.T) {
+	ownCommand := &models.CustomCommandsEntry{CreatedByUserID: testUserID}
+	otherCommand := &models.CustomCommandsEntry{CreatedByUserID: "4"}
+
+	tests := []struct {
+		name    string
+		userID  string
+		entry   *models.CustomCommandsEntry
+		allowed bool
+	}{
+		{"owner adding", testOwnerID, nil, true},
+		{"owner editing a command of another user", testOwnerID, otherCommand, true},
+		{"creator editing", testUserID, ownCommand, true},
+		{"user editing a command of another user", testUserID, otherCommand, false},
+	}
+
+	for _, test := range tests {
+		if allowed := plugins.CustomCommandCanEdit(testGuildID, test.userID, test.entry); allowed != test.allowed {
+			t.Fatalf("plugins.CustomCommandCanEdit() failed for %s, expected %v, got %v", test.name, test.allowed, allowed)
+		}
+	}
+}
+
+func TestDashboardValidation(t *testing.T) {
+	tests := []struct {
+		name           string
+		handler        restful.RouteFunction
+		pathParameters map[string]string
+		body           string
+		status         int
+	}{
+		{"greeter with invalid type", SetGreeter,
+			map[string]string{"guild-id": testGuildID, "type": "invalid"}, `{"EmbedCode": "ptext=hi"}`, http.StatusBadRequest},
+		{"greeter without embed code", SetGreeter,
+			map[string]string{"guild-id": testGuildID, "type": "join"}, `{"EmbedCode": " "}`, http.StatusBadRequest},
+		{"greeter with invalid body", SetGreeter,
+			map[string]string{"guild-id": testGuildID, "type": "join"}, `{`, http.StatusBadRequest},
+		{"delete greeter with invalid type", DeleteGreeter,
+			map[string]string{"guild-id": testGuildID, "type": "invalid"}, "", http.StatusBadRequest},
+		{"delete levels role with invalid ID", DeleteLevelsRole,
+			map[string]string{"guild-id": testGuildID, "levels-role-id": ""}, "", http.StatusBadRequest},
+		{"module permission with invalid permission", SetModulePermission,
+			map[string]string{"guild-id": testGuildID, "permission": "invalid", "module": "all"}, "", http.StatusBadRequest},
+		{"module permission with invalid module", SetModulePermission,
+			map[string]string{"guild-id": testGuildID, "permission": "allow", "module": "invalid"}, "", http.StatusBadRequest},
+		{"feed template with invalid ID", SetFeedTemplate,
+			map[string]string{"guild-id": testGuildID, "feed-id": ""}, "", http.StatusBadRequest},
+		{"delete feed with invalid ID", DeleteFeed,
+			map[string]string{"guild-id": testGuildID, "feed-id": ""}, "", http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		response, recorder := newTestDashboardResponse()
+		test.handler(newTestDashboardRequest("global", test.pathParameters, test.body), response)
+		if recorder.Code != test.status {
+			t.Fatalf("rest dashboard failed to reject %s, expected %d, got %d: %s",
+				test.name, test.status, recorder.Code, recorder.Body.String())
+		}
+	}
+}
+
+func TestWriteDashboardError(t *testing.T) {
+	response, recorder := newTestDashboardResponse()
+	writeDashboardError(response, helpers.NewInputError("bot.arguments.invalid"))
+	if recorder.Code != http.StatusBadRequest || !strings.Contains(recorder.Body.String(), "bot.arguments.invalid") {
+		t.Fatalf("rest.writeDashboardError() failed to write the input error as a bad request, got %d: %s",
+			recorder.Code, recorder.Body.String())
+	}
+}
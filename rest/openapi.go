@@ -0,0 +1,252 @@
+package rest
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/version"
+	restful "github.com/emicklei/go-restful"
+)
+
+var openAPIPathParameterRegex = regexp.MustCompile(`{([^}:]+)(:[^}]*)?}`)
+
+// newOpenAPIHandler returns a handler serving an OpenAPI 3 spec of the routes of the services
+// the spec is generated on the first request, from the paths, parameters, docs and read and write samples of the routes,
+// so it contains all routes no matter in which order they are added
+func newOpenAPIHandler(getServices func() []*restful.WebService) restful.RouteFunction {
+	var spec map[string]interface{}
+	var generateOnce sync.Once
+
+	return func(request *restful.Request, response *restful.Response) {
+		generateOnce.Do(func() {
+			spec = generateOpenAPISpec(getServices())
+		})
+		response.WriteHeaderAndEntity(http.StatusOK, spec)
+	}
+}
+
+func generateOpenAPISpec(services []*restful.WebService) map[string]interface{} {
+	schemas := make(map[string]interface{})
+	paths := make(map[string]map[string]interface{})
+
+	for _, service := range services {
+		for _, route := range service.Routes() {
+			path := openAPIPathParameterRegex.ReplaceAllString(route.Path, "{$1}")
+			if _, ok := paths[path]; !ok {
+				paths[path] = make(map[string]interface{})
+			}
+
+			operation := map[string]interface{}{
+				"parameters": getOpenAPIParameters(route),
+				"responses":  getOpenAPIResponses(route, schemas),
+			}
+			if route.Doc != "" {
+				operation["summary"] = route.Doc
+			}
+			if route.Notes != "" {
+				operation["description"] = route.Notes
+			}
+			if tag := strings.Trim(service.RootPath(), "/"); tag != "" {
+				operation["tags"] = []string{tag}
+			}
+			if route.ReadSample != nil {
+				operation["requestBody"] = map[string]interface{}{
+					"required": true,
+					"content": map[string]interface{}{
+						restful.MIME_JSON: map[string]interface{}{
+							"schema": getOpenAPISchema(reflect.TypeOf(route.ReadSample), schemas),
+						},
+					},
+				}
+			}
+			// routes without filters are public
+			if len(route.Filters) <= 0 {
+				operation["security"] = []interface{}{}
+			}
+
+			paths[path][strings.ToLower(route.Method)] = operation
+		}
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.0",
+		"info": map[string]interface{}{
+			"title":   "Robyul API",
+			"version": version.BOT_VERSION,
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"authorization": map[string]interface{}{
+					"type": "apiKey",
+					"in":   "header",
+					"name": "Authorization",
+					"description": "\"" + authorizationToken + "<token>\", \"" + authorizationBearer + "<Discord OAuth2 access token>\", " +
+						"\"" + authorizationWebkey + "<webkey>\" or \"" + authorizationPHPSession + "<session>\"",
+				},
+			},
+		},
+		"security": []interface{}{
+			map[string]interface{}{"authorization": []string{}},
+		},
+	}
+}
+
+// getOpenAPIParameters returns the documented parameters of the route,
+// and all path parameters, which are required in OpenAPI even if they are not documented
+func getOpenAPIParameters(route restful.Route) []interface{} {
+	parameters := make([]interface{}, 0)
+	documented := make(map[string]bool)
+
+	for _, parameter := range route.ParameterDocs {
+		data := parameter.Data()
+		var in string
+		switch data.Kind {
+		case restful.PathParameterKind:
+			in = "path"
+		case restful.QueryParameterKind:
+			in = "query"
+		case restful.HeaderParameterKind:
+			in = "header"
+		default: // body and form parameters are described by the request body
+			continue
+		}
+
+		dataType := data.DataType
+		if dataType == "" {
+			dataType = "string"
+		}
+		parameters = append(parameters, map[string]interface{}{
+			"name":        data.Name,
+			"in":          in,
+			"description": data.Description,
+			"required":    data.Required || in == "path",
+			"schema":      map[string]interface{}{"type": dataType},
+		})
+		documented[in+":"+data.Name] = true
+	}
+
+	for _, match := range openAPIPathParameterRegex.FindAllStringSubmatch(route.Path, -1) {
+		if documented["path:"+match[1]] {
+			continue
+		}
+		parameters = append(parameters, map[string]interface{}{
+			"name":     match[1],
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]interface{}{"type": "string"},
+		})
+	}
+
+	return parameters
+}
+
+func getOpenAPIResponses(route restful.Route, schemas map[string]interface{}) map[string]interface{} {
+	responses := make(map[string]interface{})
+
+	success := map[string]interface{}{"description": "success"}
+	if route.WriteSample != nil {
+		success["content"] = map[string]interface{}{
+			getOpenAPIResponseMIME(route): map[string]interface{}{
+				"schema": getOpenAPISchema(reflect.TypeOf(route.WriteSample), schemas),
+			},
+		}
+	}
+	responses["default"] = success
+
+	codes := make([]int, 0, len(route.ResponseErrors))
+	for code := range route.ResponseErrors {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		responses[strconv.Itoa(code)] = map[string]interface{}{"description": route.ResponseErrors[code].Message}
+	}
+
+	return responses
+}
+
+func getOpenAPIResponseMIME(route restful.Route) string {
+	if len(route.Produces) > 0 {
+		return route.Produces[0]
+	}
+	return restful.MIME_JSON
+}
+
+// getOpenAPISchema returns the schema of the type, structs are added to the schemas and referenced
+func getOpenAPISchema(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 { // []byte is encoded as base64
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": getOpenAPISchema(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": getOpenAPISchema(t.Elem(), schemas)}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return getOpenAPIStructSchema(t, schemas)
+		}
+		if _, ok := schemas[t.Name()]; !ok {
+			schemas[t.Name()] = map[string]interface{}{} // placeholder for recursive types
+			schemas[t.Name()] = getOpenAPIStructSchema(t, schemas)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+	}
+	return map[string]interface{}{}
+}
+
+// getOpenAPIStructSchema returns the schema of the exported fields of the struct, as they are encoded by encoding/json
+func getOpenAPIStructSchema(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	properties := make(map[string]interface{})
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" { // unexported
+			continue
+		}
+
+		name := field.Name
+		if tag := strings.Split(field.Tag.Get("json"), ",")[0]; tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		}
+
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			embedded := getOpenAPIStructSchema(field.Type, schemas)
+			for key, value := range embedded["properties"].(map[string]interface{}) {
+				properties[key] = value
+			}
+			continue
+		}
+
+		properties[name] = getOpenAPISchema(field.Type, schemas)
+	}
+
+	return map[string]interface{}{"type": "object", "properties": properties}
+}
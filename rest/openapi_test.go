@@ -0,0 +1,52 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	restful "github.com/emicklei/go-restful"
+)
+
+func TestOpenAPIHandler(t *testing.T) {
+	services := make([]*restful.WebService, 0)
+
+	service := new(restful.WebService)
+	service.Path("/spec").Produces(restful.MIME_JSON)
+	service.Route(service.GET("/openapi.json").To(newOpenAPIHandler(func() []*restful.WebService {
+		return services
+	})))
+	services = append(services, service)
+
+	// added after the spec route
+	service = new(restful.WebService)
+	service.Path("/things").Produces(restful.MIME_JSON)
+	service.Route(service.GET("/{thing-id}").To(func(request *restful.Request, response *restful.Response) {}).
+		Doc("get a thing"))
+	services = append(services, service)
+
+	container := restful.NewContainer()
+	for _, service := range services {
+		container.Add(service)
+	}
+
+	recorder := httptest.NewRecorder()
+	container.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/spec/openapi.json", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("rest.newOpenAPIHandler() failed to serve the spec, got %d", recorder.Code)
+	}
+
+	var spec struct {
+		Paths map[string]map[string]struct {
+			Summary string `json:"summary"`
+		} `json:"paths"`
+	}
+	err := json.Unmarshal(recorder.Body.Bytes(), &spec)
+	if err != nil {
+		t.Fatalf("rest.newOpenAPIHandler() failed to serve JSON: %s", err.Error())
+	}
+	if spec.Paths["/things/{thing-id}"]["get"].Summary != "get a thing" {
+		t.Fatalf("rest.newOpenAPIHandler() failed to include routes added after the spec route, got %+v", spec.Paths)
+	}
+}
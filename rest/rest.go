@@ -3,6 +3,7 @@ package rest
 import (
 	"context"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"sort"
 	"strconv"
@@ -20,6 +21,7 @@ import (
 	"github.com/Seklfreak/Robyul2/models"
 	"github.com/Seklfreak/Robyul2/modules/plugins"
 	"github.com/Seklfreak/Robyul2/modules/plugins/levels"
+	"github.com/Seklfreak/Robyul2/modules/plugins/youtube"
 	"github.com/bradfitz/slice"
 	"github.com/bwmarrin/discordgo"
 	restful "github.com/emicklei/go-restful"
@@ -37,7 +39,7 @@ func NewRestServices() []*restful.WebService {
 		Path("/bot/guilds").
 		Consumes(restful.MIME_JSON).
 		Produces(restful.MIME_JSON)
-	service.Route(service.GET("").Filter(webkeyAuthenticate).To(GetAllBotGuilds))
+	service.Route(service.GET("").Filter(apiAuthenticate("bot")).To(GetAllBotGuilds))
 	services = append(services, service)
 
 	service = new(restful.WebService)
@@ -46,8 +48,8 @@ func NewRestServices() []*restful.WebService {
 		Consumes(restful.MIME_JSON).
 		Produces(restful.MIME_JSON)
 
-	service.Route(service.GET("/{user-id}").Filter(sessionAndWebkeyAuthenticate).To(FindUser))
-	service.Route(service.GET("/{user-id}/guilds").Filter(webkeyAuthenticate).To(FindUserGuilds))
+	service.Route(service.GET("/{user-id}").Filter(userAuthenticate("users")).To(FindUser))
+	service.Route(service.GET("/{user-id}/guilds").Filter(apiAuthenticate("users")).To(FindUserGuilds))
 	services = append(services, service)
 
 	service = new(restful.WebService)
@@ -56,9 +58,9 @@ func NewRestServices() []*restful.WebService {
 		Consumes(restful.MIME_JSON).
 		Produces(restful.MIME_JSON)
 
-	service.Route(service.GET("/{guild-id}/{user-id}").Filter(webkeyAuthenticate).To(FindMember))
-	service.Route(service.GET("/{guild-id}/{user-id}/is").Filter(webkeyAuthenticate).To(IsMember))
-	service.Route(service.GET("/{guild-id}/{user-id}/status").Filter(webkeyAuthenticate).To(StatusMember))
+	service.Route(service.GET("/{guild-id}/{user-id}").Filter(apiAuthenticate("members")).To(FindMember))
+	service.Route(service.GET("/{guild-id}/{user-id}/is").Filter(apiAuthenticate("members")).To(IsMember))
+	service.Route(service.GET("/{guild-id}/{user-id}/status").Filter(apiAuthenticate("members")).To(StatusMember))
 	services = append(services, service)
 
 	service = new(restful.WebService)
@@ -67,7 +69,7 @@ func NewRestServices() []*restful.WebService {
 		Consumes(restful.MIME_JSON).
 		Produces("text/html")
 
-	service.Route(service.GET("/{user-id}/{guild-id}").Filter(webkeyAuthenticate).To(GetProfile))
+	service.Route(service.GET("/{user-id}/{guild-id}").Filter(apiAuthenticate("profiles")).To(GetProfile))
 	services = append(services, service)
 
 	service = new(restful.WebService)
@@ -76,9 +78,9 @@ func NewRestServices() []*restful.WebService {
 		Consumes(restful.MIME_JSON).
 		Produces(restful.MIME_JSON)
 
-	service.Route(service.GET("/{guild-id}").Filter(webkeyAuthenticate).To(GetRankings))
-	service.Route(service.GET("/user/{user-id}/{guild-id}").Filter(webkeyAuthenticate).To(GetUserRanking))
-	service.Route(service.GET("/user/{user-id}/all").Filter(webkeyAuthenticate).To(GetAllUserRanking))
+	service.Route(service.GET("/{guild-id}").Filter(apiAuthenticate("rankings")).To(GetRankings))
+	service.Route(service.GET("/user/{user-id}/{guild-id}").Filter(apiAuthenticate("rankings")).To(GetUserRanking))
+	service.Route(service.GET("/user/{user-id}/all").Filter(apiAuthenticate("rankings")).To(GetAllUserRanking))
 	services = append(services, service)
 
 	service = new(restful.WebService)
@@ -87,8 +89,9 @@ func NewRestServices() []*restful.WebService {
 		Consumes(restful.MIME_JSON).
 		Produces(restful.MIME_JSON)
 
-	service.Route(service.GET("/{guild-id}").Filter(sessionAndWebkeyAuthenticate).To(FindGuild))
-	service.Route(service.POST("/{guild-id}/set-settings").Filter(sessionAndWebkeyAuthenticate).To(SetGuildSettings).Reads(&models.Rest_Receive_SetSettings{}))
+	service.Route(service.GET("/{guild-id}").Filter(userAuthenticate("guilds")).To(FindGuild))
+	service.Route(service.POST("/{guild-id}/set-settings").Filter(userAuthenticate("guilds")).To(SetGuildSettings).Reads(&models.Rest_Receive_SetSettings{}))
+	addDashboardRoutes(service)
 	services = append(services, service)
 
 	service = new(restful.WebService)
@@ -97,7 +100,7 @@ func NewRestServices() []*restful.WebService {
 		Consumes(restful.MIME_JSON).
 		Produces(restful.MIME_JSON)
 
-	service.Route(service.GET("/history/{guild-id}/{start}/{end}").Filter(webkeyAuthenticate).To(GetRandomPicturesGuildHistory))
+	service.Route(service.GET("/history/{guild-id}/{start}/{end}").Filter(apiAuthenticate("randompictures")).To(GetRandomPicturesGuildHistory))
 	services = append(services, service)
 
 	service = new(restful.WebService)
@@ -106,13 +109,13 @@ func NewRestServices() []*restful.WebService {
 		Consumes(restful.MIME_JSON).
 		Produces(restful.MIME_JSON)
 
-	service.Route(service.GET("/{guild-id}/messages/{interval}/count").Filter(sessionAndWebkeyAuthenticate).To(GetMessageStatisticsCount))
-	service.Route(service.GET("/{guild-id}/joins/{interval}/count").Filter(sessionAndWebkeyAuthenticate).To(GetJoinsStatisticsCount))
-	service.Route(service.GET("/{guild-id}/leaves/{interval}/count").Filter(sessionAndWebkeyAuthenticate).To(GetLeavesStatisticsCount))
-	service.Route(service.GET("/{guild-id}/by-uniques/{interval}/count").Filter(sessionAndWebkeyAuthenticate).To(GetMessageByUniqueUsersStatisticsCount))
-	service.Route(service.GET("/{guild-id}/serveractivity/{interval}/histogram/{count}").Filter(sessionAndWebkeyAuthenticate).To(GetServerActivityStatisticsHistogram))
-	service.Route(service.GET("/{guild-id}/vanityinvite/{interval}/histogram/{count}").Filter(sessionAndWebkeyAuthenticate).To(GetVanityInviteStatistics))
-	service.Route(service.GET("/bot").Filter(webkeyAuthenticate).To(GotBotStatistics))
+	service.Route(service.GET("/{guild-id}/messages/{interval}/count").Filter(userAuthenticate("statistics")).To(GetMessageStatisticsCount))
+	service.Route(service.GET("/{guild-id}/joins/{interval}/count").Filter(userAuthenticate("statistics")).To(GetJoinsStatisticsCount))
+	service.Route(service.GET("/{guild-id}/leaves/{interval}/count").Filter(userAuthenticate("statistics")).To(GetLeavesStatisticsCount))
+	service.Route(service.GET("/{guild-id}/by-uniques/{interval}/count").Filter(userAuthenticate("statistics")).To(GetMessageByUniqueUsersStatisticsCount))
+	service.Route(service.GET("/{guild-id}/serveractivity/{interval}/histogram/{count}").Filter(userAuthenticate("statistics")).To(GetServerActivityStatisticsHistogram))
+	service.Route(service.GET("/{guild-id}/vanityinvite/{interval}/histogram/{count}").Filter(userAuthenticate("statistics")).To(GetVanityInviteStatistics))
+	service.Route(service.GET("/bot").Filter(apiAuthenticate("bot")).To(GotBotStatistics))
 	services = append(services, service)
 
 	service = new(restful.WebService)
@@ -121,7 +124,7 @@ func NewRestServices() []*restful.WebService {
 		Consumes(restful.MIME_JSON).
 		Produces(restful.MIME_JSON)
 
-	service.Route(service.GET("/{guild-id}/{channel-id}/around/{message-id}").Filter(sessionAndWebkeyAuthenticate).To(GetChatlogAroundMessageID))
+	service.Route(service.GET("/{guild-id}/{channel-id}/around/{message-id}").Filter(userAuthenticate("chatlog")).To(GetChatlogAroundMessageID))
 	services = append(services, service)
 
 	service = new(restful.WebService)
@@ -130,7 +133,7 @@ func NewRestServices() []*restful.WebService {
 		Consumes(restful.MIME_JSON).
 		Produces(restful.MIME_JSON)
 
-	service.Route(service.GET("/{guild-id}").Filter(sessionAndWebkeyAuthenticate).To(GetEventlog))
+	service.Route(service.GET("/{guild-id}").Filter(userAuthenticate("eventlog")).To(GetEventlog))
 	services = append(services, service)
 
 	service = new(restful.WebService)
@@ -139,7 +142,7 @@ func NewRestServices() []*restful.WebService {
 		Consumes(restful.MIME_JSON).
 		Produces(restful.MIME_JSON)
 
-	service.Route(service.GET("/{vanity-name}").Filter(webkeyAuthenticate).To(GetVanityInviteByName))
+	service.Route(service.GET("/{vanity-name}").Filter(apiAuthenticate("vanityinvites")).To(GetVanityInviteByName))
 	services = append(services, service)
 
 	service = new(restful.WebService)
@@ -148,7 +151,13 @@ func NewRestServices() []*restful.WebService {
 		Consumes(restful.MIME_JSON).
 		Produces(restful.MIME_JSON)
 
-	service.Route(service.GET("/{filehash}").Filter(webkeyAuthenticate).To(GetFileByFilehash))
+	service.Route(service.GET("/{filehash}").Filter(apiAuthenticate("files")).To(GetFileByFilehash))
+	// serves public files for instances without an image proxy, see imageproxy.base_url
+	// the route is unauthenticated, RetrievePublicFileByHash only finds files stored with public set
+	service.Route(service.GET("/{filehash}/{filename}").Produces("*/*").To(GetPublicFileByFilehash).
+		Doc("get a public file").
+		Notes("unauthenticated, serves every file stored as public to everyone knowing the hash of its object name, " +
+			"files which are not public are not found, the file name is not checked"))
 	services = append(services, service)
 
 	service = new(restful.WebService)
@@ -156,107 +165,47 @@ func NewRestServices() []*restful.WebService {
 		Path("/backgrounds").
 		Consumes(restful.MIME_JSON).
 		Produces(restful.MIME_JSON)
-	service.Route(service.GET("").Filter(webkeyAuthenticate).To(GetAllBackgrounds))
+	service.Route(service.GET("").Filter(apiAuthenticate("backgrounds")).To(GetAllBackgrounds))
 	services = append(services, service)
 
+	// called by the YouTube WebSub hub, notifications are authenticated by their signature
 	service = new(restful.WebService)
-	service.Route(service.GET("/ping").Filter(webkeyAuthenticate).To(Ping))
+	service.
+		Path("/youtube/websub").
+		Consumes("application/atom+xml", "application/xml", "text/xml").
+		Produces("text/plain")
+	service.Route(service.GET("").To(VerifyYouTubeWebSub))
+	service.Route(service.POST("").To(ReceiveYouTubeWebSub))
 	services = append(services, service)
 
-	return services
-}
-
-func webkeyAuthenticate(request *restful.Request, response *restful.Response, chain *restful.FilterChain) {
-	authorizationHeader := strings.TrimSpace(request.HeaderParameter("Authorization"))
-
-	isAuthenticated := false
-
-	if strings.HasPrefix(authorizationHeader, "Webkey ") {
-		webkey := strings.TrimSpace(strings.Replace(authorizationHeader, "Webkey ", "", -1))
-		if webkey == helpers.GetConfig().Path("website.webkey").Data().(string) {
-			isAuthenticated = true
-			request.SetAttribute("UserID", "global")
-		}
-	}
-
-	queryWebkey := strings.TrimSpace(request.QueryParameter("webkey"))
-	if queryWebkey == helpers.GetConfig().Path("website.webkey").Data().(string) {
-		isAuthenticated = true
-		request.SetAttribute("UserID", "global")
-	}
-
-	if isAuthenticated == false {
-		response.WriteErrorString(401, "401: Not Authorized")
-		return
-	}
-
-	chain.ProcessFilter(request, response)
-	return
-}
-
-func sessionAndWebkeyAuthenticate(request *restful.Request, response *restful.Response, chain *restful.FilterChain) {
-	authorizationHeader := strings.TrimSpace(request.HeaderParameter("Authorization"))
-
-	isAuthenticated := false
-
-	if strings.HasPrefix(authorizationHeader, "Webkey ") {
-		webkey := strings.TrimSpace(strings.Replace(authorizationHeader, "Webkey ", "", -1))
-		if webkey == helpers.GetConfig().Path("website.webkey").Data().(string) {
-			isAuthenticated = true
-			request.SetAttribute("UserID", "global")
-		}
-	}
-
-	queryWebkey := strings.TrimSpace(request.QueryParameter("webkey"))
-	if queryWebkey == helpers.GetConfig().Path("website.webkey").Data().(string) {
-		isAuthenticated = true
-		request.SetAttribute("UserID", "global")
-	}
-
-	if strings.HasPrefix(authorizationHeader, "PHP-Session ") {
-		sessionID := strings.TrimSpace(strings.Replace(authorizationHeader, "PHP-Session ", "", -1))
-		key := "robyul2-web:robyul-session:" + sessionID
-		redis := cache.GetRedisClient()
-		sessionDataString, err := redis.Get(key).Result()
-		if err == nil {
-			var sessionData models.Website_Session_Data
-			msgpack.Unmarshal([]byte(sessionDataString), &sessionData)
-			if sessionData.DiscordUserID != "" {
-				isAuthenticated = true
-				request.SetAttribute("UserID", sessionData.DiscordUserID)
-			}
-		}
-	}
-
-	if isAuthenticated == false {
-		response.WriteErrorString(401, "401: Not Authorized")
-		return
-	}
+	service = new(restful.WebService)
+	service.Route(service.GET("/ping").Filter(apiAuthenticate("bot")).To(Ping))
+	service.Route(service.GET("/openapi.json").Produces(restful.MIME_JSON).To(newOpenAPIHandler(func() []*restful.WebService {
+		return services
+	})))
+	services = append(services, service)
 
-	chain.ProcessFilter(request, response)
-	return
+	return services
 }
 
 func GetAllBotGuilds(request *restful.Request, response *restful.Response) {
 	var botPrefix string
 
 	returnGuilds := make([]models.Rest_Guild, 0)
-	for _, shard := range cache.GetSession().Sessions {
-		for _, guild := range shard.State.Guilds {
-			joinedAt := helpers.GetTimeFromSnowflake(guild.ID)
-			botPrefix = helpers.GetPrefixForServer(guild.ID)
+	for _, guild := range helpers.AllGuilds() {
+		joinedAt := helpers.GetTimeFromSnowflake(guild.ID)
+		botPrefix = helpers.GetPrefixForServer(guild.ID)
 
-			returnGuilds = append(returnGuilds, models.Rest_Guild{
-				ID:        guild.ID,
-				Name:      guild.Name,
-				Icon:      guild.Icon,
-				OwnerID:   guild.OwnerID,
-				JoinedAt:  joinedAt,
-				BotPrefix: botPrefix,
-				Features:  getGuildFeatures(guild.ID),
-				Settings:  getGuildSettings(guild.ID, request.Attribute("UserID").(string)),
-			})
-		}
+		returnGuilds = append(returnGuilds, models.Rest_Guild{
+			ID:        guild.ID,
+			Name:      guild.Name,
+			Icon:      guild.Icon,
+			OwnerID:   guild.OwnerID,
+			JoinedAt:  joinedAt,
+			BotPrefix: botPrefix,
+			Features:  getGuildFeatures(guild.ID),
+			Settings:  getGuildSettings(guild.ID, request.Attribute("UserID").(string)),
+		})
 	}
 
 	response.WriteEntity(returnGuilds)
@@ -1193,21 +1142,15 @@ func GetVanityInviteStatistics(request *restful.Request, response *restful.Respo
 }
 
 func GotBotStatistics(request *restful.Request, response *restful.Response) {
-	users := make(map[string]string)
-	var guildCount int
-
-	for _, shard := range cache.GetSession().Sessions {
-		for _, guild := range shard.State.Guilds {
-			guildCount++
-			for _, u := range guild.Members {
-				users[u.User.ID] = u.User.Username
-			}
-		}
+	stats, err := helpers.GetClusterStats()
+	if err != nil {
+		response.WriteErrorString(http.StatusInternalServerError, "error getting statistics")
+		return
 	}
 
 	response.WriteEntity(models.Rest_Statitics_Bot{
-		Guilds: guildCount,
-		Users:  len(users),
+		Guilds: stats.Guilds,
+		Users:  stats.Users,
 	})
 }
 
@@ -1229,13 +1172,18 @@ func GetChatlogAroundMessageID(request *restful.Request, response *restful.Respo
 		return
 	}
 
+	if !isChatlogChannelOfGuild(guildID, channelID) {
+		response.WriteErrorString(401, "401: Not Authorized")
+		return
+	}
+
 	if !cache.HasElastic() {
 		response.WriteErrorString(http.StatusServiceUnavailable, "unavailable")
 		return
 	}
 
 	if messageID == "last" {
-		termQuery := elastic.NewQueryStringQuery("GuildID:" + guildID + " AND ChannelID:" + channelID)
+		termQuery := chatlogChannelQuery(guildID, channelID)
 		searchResult, err := cache.GetElastic().Search().
 			Index(models.ElasticIndexMessages).
 			Type("doc").
@@ -1268,7 +1216,7 @@ func GetChatlogAroundMessageID(request *restful.Request, response *restful.Respo
 		return
 	}
 
-	termQuery := elastic.NewQueryStringQuery("GuildID:" + guildID + " AND ChannelID:" + channelID + " AND MessageID:" + messageID)
+	termQuery := chatlogChannelQuery(guildID, channelID).Must(elastic.NewTermQuery("MessageID.keyword", messageID))
 	searchResult, err := cache.GetElastic().Search().
 		Index(models.ElasticIndexMessages).
 		Type("doc").
@@ -1320,7 +1268,7 @@ func GetChatlogAroundMessageID(request *restful.Request, response *restful.Respo
 		sortValues = item.Sort
 	}
 
-	termQuery = elastic.NewQueryStringQuery("GuildID:" + guildID + " AND ChannelID:" + channelID)
+	termQuery = chatlogChannelQuery(guildID, channelID)
 	searchResult, err = cache.GetElastic().Search().
 		Index(models.ElasticIndexMessages).
 		Type("doc").
@@ -1364,7 +1312,7 @@ func GetChatlogAroundMessageID(request *restful.Request, response *restful.Respo
 		})
 	}
 
-	termQuery = elastic.NewQueryStringQuery("GuildID:" + guildID + " AND ChannelID:" + channelID)
+	termQuery = chatlogChannelQuery(guildID, channelID)
 	searchResult, err = cache.GetElastic().Search().
 		Index(models.ElasticIndexMessages).
 		Type("doc").
@@ -1420,6 +1368,29 @@ func GetChatlogAroundMessageID(request *restful.Request, response *restful.Respo
 	response.WriteEntity(result)
 }
 
+// isChatlogChannelOfGuild returns false if the channel ID is no snowflake or belongs to another guild
+func isChatlogChannelOfGuild(guildID, channelID string) bool {
+	if !helpers.IsSnowflake(channelID) {
+		return false
+	}
+
+	// channels which are not cached (anymore) are still limited to the guild by the query
+	channel, err := helpers.GetChannelWithoutApi(channelID)
+	if err == nil && channel != nil && channel.GuildID != guildID {
+		return false
+	}
+
+	return true
+}
+
+// chatlogChannelQuery matches the messages of the given channel in the given guild
+func chatlogChannelQuery(guildID, channelID string) *elastic.BoolQuery {
+	return elastic.NewBoolQuery().Must(
+		elastic.NewTermQuery("GuildID", guildID),
+		elastic.NewTermQuery("ChannelID", channelID),
+	)
+}
+
 func GetEventlog(request *restful.Request, response *restful.Response) {
 	guildID := request.PathParameter("guild-id")
 
@@ -1815,6 +1786,28 @@ func GetFileByFilehash(request *restful.Request, response *restful.Response) {
 	})
 }
 
+func GetPublicFileByFilehash(request *restful.Request, response *restful.Response) {
+	fileHash := request.PathParameter("filehash")
+
+	_, filetype, data, err := helpers.RetrievePublicFileByHash(fileHash)
+	if err != nil {
+		if strings.Contains(err.Error(), "file not found") {
+			response.WriteError(http.StatusNotFound, errors.New("file not found"))
+			return
+		}
+		response.WriteError(http.StatusInternalServerError, err)
+		return
+	}
+
+	if filetype == "" {
+		filetype = http.DetectContentType(data)
+	}
+	response.AddHeader("Content-Type", filetype)
+	response.AddHeader("Cache-Control", "public, max-age=31536000")
+	response.WriteHeader(http.StatusOK)
+	response.Write(data)
+}
+
 func SetGuildSettings(request *restful.Request, response *restful.Response) {
 	guildID := request.PathParameter("guild-id")
 
@@ -1875,3 +1868,34 @@ func Ping(_ *restful.Request, response *restful.Response) {
 	response.Write([]byte("pong"))
 	return
 }
+
+func VerifyYouTubeWebSub(request *restful.Request, response *restful.Response) {
+	if !youtube.HandleWebSubVerification(
+		request.QueryParameter("hub.mode"),
+		request.QueryParameter("hub.topic"),
+		request.QueryParameter("hub.lease_seconds"),
+		request.QueryParameter("hub.reason"),
+	) {
+		response.WriteErrorString(http.StatusNotFound, "404: Not Found")
+		return
+	}
+
+	response.WriteHeader(http.StatusOK)
+	response.Write([]byte(request.QueryParameter("hub.challenge")))
+}
+
+func ReceiveYouTubeWebSub(request *restful.Request, response *restful.Response) {
+	body, err := ioutil.ReadAll(request.Request.Body)
+	if err != nil {
+		response.WriteErrorString(http.StatusBadRequest, "400: Bad Request")
+		return
+	}
+
+	// the hub expects a success response even if we ignore the notification
+	err = youtube.HandleWebSubNotification(body, request.HeaderParameter("X-Hub-Signature"))
+	if err != nil {
+		cache.GetLogger().WithField("module", "rest").Warn("ignored youtube websub notification: " + err.Error())
+	}
+
+	response.WriteHeader(http.StatusNoContent)
+}
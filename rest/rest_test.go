@@ -0,0 +1,26 @@
+package rest
+
+import (
+	"testing"
+)
+
+func TestIsChatlogChannelOfGuild(t *testing.T) {
+	tests := []struct {
+		name      string
+		guildID   string
+		channelID string
+		allowed   bool
+	}{
+		{"channel of the guild", testGuildID, testChannelID, true},
+		{"channel of another guild", "116620585638821893", testChannelID, false},
+		{"uncached channel", testGuildID, "116620585638821894", true},
+		{"query", testGuildID, "1 OR GuildID:116620585638821893", false},
+		{"empty", testGuildID, "", false},
+	}
+
+	for _, test := range tests {
+		if allowed := isChatlogChannelOfGuild(test.guildID, test.channelID); allowed != test.allowed {
+			t.Fatalf("rest.isChatlogChannelOfGuild() failed for %s, expected %v, got %v", test.name, test.allowed, allowed)
+		}
+	}
+}
@@ -110,6 +110,13 @@ func (q *quota) DecEntryCount() {
 	}
 }
 
+func (q *quota) SetEntryCount(count int64) {
+	q.Lock()
+	defer q.Unlock()
+
+	q.entriesCount = count
+}
+
 func (q *quota) UpdateCheckingInterval() error {
 	q.Lock()
 	defer q.Unlock()
@@ -152,6 +152,11 @@ func (s *Service) DecQuotaEntryCount() {
 	s.quota.DecEntryCount()
 }
 
+// SetQuotaEntryCount sets the number of polled feed entries, used to calculate the checking interval
+func (s *Service) SetQuotaEntryCount(count int64) {
+	s.quota.SetEntryCount(count)
+}
+
 func (s *Service) UpdateCheckingInterval() error {
 	return s.quota.UpdateCheckingInterval()
 }
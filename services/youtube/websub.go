@@ -0,0 +1,156 @@
+package youtube
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/helpers"
+	"github.com/pkg/errors"
+)
+
+const (
+	WebSubHub        = "https://pubsubhubbub.appspot.com/subscribe"
+	webSubTopicUrl   = "https://www.youtube.com/xml/feeds/videos.xml?channel_id="
+	webSubTimeout    = time.Second * 30
+	WebSubModeSub    = "subscribe"
+	WebSubModeUnsub  = "unsubscribe"
+	WebSubModeDenied = "denied"
+)
+
+// WebSubVideo is a video entry of a WebSub notification
+type WebSubVideo struct {
+	VideoID   string
+	ChannelID string
+	Title     string
+	Link      string
+	Author    string
+	Published time.Time
+	Updated   time.Time
+}
+
+type webSubFeed struct {
+	Entries []struct {
+		VideoID   string `xml:"http://www.youtube.com/xml/schemas/2015 videoId"`
+		ChannelID string `xml:"http://www.youtube.com/xml/schemas/2015 channelId"`
+		Title     string `xml:"title"`
+		Link      struct {
+			Href string `xml:"href,attr"`
+		} `xml:"link"`
+		Author struct {
+			Name string `xml:"name"`
+		} `xml:"author"`
+		Published string `xml:"published"`
+		Updated   string `xml:"updated"`
+	} `xml:"entry"`
+}
+
+// WebSubTopic returns the WebSub topic of a YouTube channel
+func WebSubTopic(channelID string) string {
+	return webSubTopicUrl + url.QueryEscape(channelID)
+}
+
+// ChannelIDFromWebSubTopic returns the YouTube channel of a WebSub topic
+func ChannelIDFromWebSubTopic(topic string) (channelID string, ok bool) {
+	if !strings.HasPrefix(topic, webSubTopicUrl) {
+		return "", false
+	}
+
+	parsedTopic, err := url.Parse(topic)
+	if err != nil {
+		return "", false
+	}
+
+	channelID = parsedTopic.Query().Get("channel_id")
+	return channelID, channelID != ""
+}
+
+// RequestWebSubSubscription asks the hub to subscribe or unsubscribe the callback to the YouTube channel,
+// the hub verifies the request asynchronously by calling the callback
+func RequestWebSubSubscription(callback, secret, channelID, mode string, lease time.Duration) (err error) {
+	values := url.Values{}
+	values.Set("hub.callback", callback)
+	values.Set("hub.topic", WebSubTopic(channelID))
+	values.Set("hub.mode", mode)
+	values.Set("hub.verify", "async")
+	if secret != "" {
+		values.Set("hub.secret", secret)
+	}
+	if lease > 0 {
+		values.Set("hub.lease_seconds", strconv.Itoa(int(lease.Seconds())))
+	}
+
+	request, err := http.NewRequest(http.MethodPost, WebSubHub, strings.NewReader(values.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("User-Agent", helpers.DEFAULT_UA)
+
+	client := &http.Client{Timeout: webSubTimeout}
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(response.Body)
+		return fmt.Errorf("unexpected status code from hub: %d: %s", response.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
+
+// VerifyWebSubSignature checks the X-Hub-Signature header of a notification against the secret
+func VerifyWebSubSignature(secret string, body []byte, signature string) bool {
+	parts := strings.SplitN(signature, "=", 2)
+	if len(parts) != 2 || strings.ToLower(parts[0]) != "sha1" {
+		return false
+	}
+
+	expected, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// ParseWebSubNotification parses the Atom feed pushed by the hub
+func ParseWebSubNotification(body []byte) (videos []WebSubVideo, err error) {
+	var feed webSubFeed
+	err = xml.Unmarshal(body, &feed)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse notification")
+	}
+
+	for _, entry := range feed.Entries {
+		if entry.VideoID == "" {
+			continue
+		}
+
+		video := WebSubVideo{
+			VideoID:   entry.VideoID,
+			ChannelID: entry.ChannelID,
+			Title:     entry.Title,
+			Link:      entry.Link.Href,
+			Author:    entry.Author.Name,
+		}
+		video.Published, _ = time.Parse(time.RFC3339, entry.Published)
+		video.Updated, _ = time.Parse(time.RFC3339, entry.Updated)
+		videos = append(videos, video)
+	}
+
+	return videos, nil
+}
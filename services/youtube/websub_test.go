@@ -0,0 +1,87 @@
+package youtube
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"testing"
+)
+
+const testWebSubNotification = `<?xml version='1.0' encoding='UTF-8'?>
+<feed xmlns:yt="http://www.youtube.com/xml/schemas/2015" xmlns="http://www.w3.org/2005/Atom">
+  <link rel="hub" href="https://pubsubhubbub.appspot.com"/>
+  <link rel="self" href="https://www.youtube.com/xml/feeds/videos.xml?channel_id=UChwOX1m8gxuf_3191ozxqWw"/>
+  <title>YouTube video feed</title>
+  <updated>2018-12-24T18:00:30.123456789+00:00</updated>
+  <entry>
+    <id>yt:video:zXPc4Gmj4B8</id>
+    <yt:videoId>zXPc4Gmj4B8</yt:videoId>
+    <yt:channelId>UChwOX1m8gxuf_3191ozxqWw</yt:channelId>
+    <title>Video title</title>
+    <link rel="alternate" href="https://www.youtube.com/watch?v=zXPc4Gmj4B8"/>
+    <author>
+     <name>Channel title</name>
+     <uri>https://www.youtube.com/channel/UChwOX1m8gxuf_3191ozxqWw</uri>
+    </author>
+    <published>2018-12-24T18:00:00+00:00</published>
+    <updated>2018-12-24T18:00:30.123456789+00:00</updated>
+  </entry>
+</feed>`
+
+func TestParseWebSubNotification(t *testing.T) {
+	videos, err := ParseWebSubNotification([]byte(testWebSubNotification))
+	if err != nil {
+		t.Fatalf("youtube.ParseWebSubNotification() failed to parse notification: %s", err.Error())
+	}
+
+	if len(videos) != 1 {
+		t.Fatalf("youtube.ParseWebSubNotification() failed to parse entries, got %d", len(videos))
+	}
+
+	video := videos[0]
+	if video.VideoID != "zXPc4Gmj4B8" || video.ChannelID != "UChwOX1m8gxuf_3191ozxqWw" ||
+		video.Title != "Video title" || video.Author != "Channel title" ||
+		video.Link != "https://www.youtube.com/watch?v=zXPc4Gmj4B8" {
+		t.Fatalf("youtube.ParseWebSubNotification() failed to parse entry, got %+v", video)
+	}
+
+	if video.Published.Unix() != 1545674400 || video.Updated.IsZero() {
+		t.Fatalf("youtube.ParseWebSubNotification() failed to parse dates, got %s and %s", video.Published, video.Updated)
+	}
+}
+
+func TestVerifyWebSubSignature(t *testing.T) {
+	body := []byte(testWebSubNotification)
+
+	mac := hmac.New(sha1.New, []byte("secret"))
+	mac.Write(body)
+	signature := "sha1=" + hex.EncodeToString(mac.Sum(nil))
+
+	if !VerifyWebSubSignature("secret", body, signature) {
+		t.Fatalf("youtube.VerifyWebSubSignature() failed to verify valid signature")
+	}
+
+	if VerifyWebSubSignature("other secret", body, signature) {
+		t.Fatalf("youtube.VerifyWebSubSignature() failed to reject signature with wrong secret")
+	}
+
+	if VerifyWebSubSignature("secret", append(body, ' '), signature) {
+		t.Fatalf("youtube.VerifyWebSubSignature() failed to reject signature of modified body")
+	}
+
+	if VerifyWebSubSignature("secret", body, "") || VerifyWebSubSignature("secret", body, "sha1=xyz") {
+		t.Fatalf("youtube.VerifyWebSubSignature() failed to reject invalid signature")
+	}
+}
+
+func TestChannelIDFromWebSubTopic(t *testing.T) {
+	channelID, ok := ChannelIDFromWebSubTopic(WebSubTopic("UChwOX1m8gxuf_3191ozxqWw"))
+	if !ok || channelID != "UChwOX1m8gxuf_3191ozxqWw" {
+		t.Fatalf("youtube.ChannelIDFromWebSubTopic() failed to extract channel from topic")
+	}
+
+	_, ok = ChannelIDFromWebSubTopic("https://example.com/feed?channel_id=UChwOX1m8gxuf_3191ozxqWw")
+	if ok {
+		t.Fatalf("youtube.ChannelIDFromWebSubTopic() failed to reject foreign topic")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
@@ -21,7 +22,7 @@ type Manager struct {
 	// and in the title of the updated status message
 	Name string
 
-	// All the shard sessions
+	// The sessions of all shards run by this process, ordered by shard ID
 	Sessions      []*discordgo.Session
 	eventHandlers []interface{}
 
@@ -32,7 +33,7 @@ type Manager struct {
 	StatusMessageChannel string
 
 	// The function that provides the guild counts per shard, used fro the updated status message
-	// Should return a slice of guild counts, with the index being the shard number
+	// Should return a slice of guild counts, with the index being the index of the session in Sessions
 	GuildCountsFunc func() []int
 
 	// Called on events, by default this is set to a function that logs it to log.Printf
@@ -43,14 +44,25 @@ type Manager struct {
 	// session settings to apply
 	SessionFunc SessionFunc
 
+	// StallTimeout is the time without heartbeat ACKs after which the supervisor restarts a shard,
+	// 0 disables the supervisor
+	StallTimeout time.Duration
+
 	nextStatusUpdate     time.Time
 	statusUpdaterStarted bool
 
 	numShards int
-	token     string
+	// the shards run by this process, all shards if lastShard is -1
+	firstShard int
+	lastShard  int
+	token      string
 
 	bareSession *discordgo.Session
 	started     bool
+
+	health       []*shardHealth
+	identifyLock sync.Mutex
+	lastIdentify time.Time
 }
 
 // New creates a new shard manager with the defaults set, after you have created this you call Manager.Start
@@ -59,8 +71,10 @@ type Manager struct {
 func New(token string) *Manager {
 	// Setup defaults
 	manager := &Manager{
-		token:     token,
-		numShards: -1,
+		token:        token,
+		numShards:    -1,
+		lastShard:    -1,
+		StallTimeout: time.Minute * 5,
 	}
 
 	manager.OnEvent = manager.LogConnectionEventStd
@@ -105,6 +119,41 @@ func (m *Manager) SetNumShards(n int) {
 	m.numShards = n
 }
 
+// SetShardRange sets the shards run by this process, from first to last including, other processes run the other shards
+// Should not be called after calling Start(), will panic
+func (m *Manager) SetShardRange(first, last int) {
+	m.Lock()
+	defer m.Unlock()
+	if m.started {
+		panic("Can't set shard range after started")
+	}
+
+	m.firstShard = first
+	m.lastShard = last
+}
+
+// GetShardRange returns the first and last shard run by this process
+func (m *Manager) GetShardRange() (first, last int) {
+	m.RLock()
+	defer m.RUnlock()
+
+	if m.lastShard < 0 {
+		return 0, m.numShards - 1
+	}
+	return m.firstShard, m.lastShard
+}
+
+// IsLocalShard returns true if the shard is run by this process
+func (m *Manager) IsLocalShard(shard int) bool {
+	first, last := m.GetShardRange()
+	return shard >= first && shard <= last
+}
+
+// IsLocalGuild returns true if the guild is on a shard run by this process
+func (m *Manager) IsLocalGuild(guildID string) bool {
+	return m.IsLocalShard(int(m.ShardForGuild(guildID)))
+}
+
 // Adds an event handler to all shards
 // All event handlers will be added to new sessions automatically.
 func (m *Manager) AddHandler(handler interface{}) {
@@ -130,8 +179,17 @@ func (m *Manager) Init() error {
 		}
 	}
 
-	m.Sessions = make([]*discordgo.Session, m.numShards)
-	for i := 0; i < m.numShards; i++ {
+	if m.lastShard < 0 || m.lastShard >= m.numShards {
+		m.lastShard = m.numShards - 1
+	}
+	if m.firstShard < 0 || m.firstShard > m.lastShard {
+		m.Unlock()
+		return errors.New(fmt.Sprintf("Invalid shard range %d-%d for %d shards", m.firstShard, m.lastShard, m.numShards))
+	}
+
+	m.Sessions = make([]*discordgo.Session, m.lastShard-m.firstShard+1)
+	m.health = make([]*shardHealth, len(m.Sessions))
+	for i := m.firstShard; i <= m.lastShard; i++ {
 		err := m.initSession(i)
 		if err != nil {
 			m.Unlock()
@@ -166,11 +224,9 @@ func (m *Manager) Start() error {
 
 	m.Unlock()
 
-	for i := 0; i < m.numShards; i++ {
-		if i != 0 {
-			// One indentify every 5 seconds
-			time.Sleep(time.Second * 5)
-		}
+	first, last := m.GetShardRange()
+	for i := first; i <= last; i++ {
+		m.waitForIdentify()
 
 		m.Lock()
 		err := m.startSession(i)
@@ -180,6 +236,13 @@ func (m *Manager) Start() error {
 		}
 	}
 
+	m.Lock()
+	if !m.started && m.StallTimeout > 0 {
+		go m.supervisorRoutine()
+	}
+	m.started = true
+	m.Unlock()
+
 	return nil
 }
 
@@ -216,19 +279,21 @@ func (m *Manager) initSession(shard int) error {
 	session.AddHandler(m.OnDiscordDisconnected)
 	session.AddHandler(m.OnDiscordReady)
 	session.AddHandler(m.OnDiscordResumed)
+	session.AddHandler(m.onDiscordEvent)
 
 	// Add the user event handlers retroactively
 	for _, v := range m.eventHandlers {
 		session.AddHandler(v)
 	}
 
-	m.Sessions[shard] = session
+	m.Sessions[shard-m.firstShard] = session
+	m.health[shard-m.firstShard] = &shardHealth{}
 	return nil
 }
 
 func (m *Manager) startSession(shard int) error {
 
-	err := m.Sessions[shard].Open()
+	err := m.Sessions[shard-m.firstShard].Open()
 	if err != nil {
 		return errors.Wrap(err, "startSession.Open")
 	}
@@ -246,13 +311,12 @@ func (m *Manager) SessionForGuildS(guildID string) *discordgo.Session {
 }
 
 // SessionForGuild returns the session for the specified guild
+// If the guild is on a shard run by another process, a local session is returned, it can only be used for REST requests
 func (m *Manager) SessionForGuild(guildID int64) *discordgo.Session {
 	// (guild_id >> 22) % num_shards == shard_id
 	// That formula is taken from the sharding issue on the api docs repository on github
-	m.RLock()
-	defer m.RUnlock()
 	shardID := (guildID >> 22) % int64(m.numShards)
-	return m.Sessions[shardID]
+	return m.Session(int(shardID))
 }
 
 func (m *Manager) ShardForGuild(guildID string) int64 {
@@ -261,10 +325,27 @@ func (m *Manager) ShardForGuild(guildID string) int64 {
 }
 
 // Session retrieves a session from the sessions map, rlocking it in the process
+// If the shard is run by another process, the first local session is returned, it can only be used for REST requests
 func (m *Manager) Session(shardID int) *discordgo.Session {
 	m.RLock()
 	defer m.RUnlock()
-	return m.Sessions[shardID]
+
+	if session := m.localSession(shardID); session != nil {
+		return session
+	}
+	if len(m.Sessions) > 0 {
+		return m.Sessions[0]
+	}
+	return nil
+}
+
+// localSession returns the session of the shard if it is run by this process, the lock has to be held
+func (m *Manager) localSession(shardID int) *discordgo.Session {
+	index := shardID - m.firstShard
+	if index < 0 || index >= len(m.Sessions) {
+		return nil
+	}
+	return m.Sessions[index]
 }
 
 // LogConnectionEventStd is the standard connection event logger, it logs it to whatever log.output is set to.
@@ -373,6 +454,8 @@ func (m *Manager) updateStatusMessage(mID string) (string, error) {
 		emoji := ""
 		if !shard.Started {
 			emoji = "🕒"
+		} else if shard.Restarting {
+			emoji = "♻"
 		} else if shard.OK {
 			emoji = "👌"
 		} else {
@@ -420,7 +503,7 @@ func (m *Manager) GetFullStatus() *Status {
 	result := make([]*ShardStatus, len(m.Sessions))
 	for i, shard := range m.Sessions {
 		result[i] = &ShardStatus{
-			Shard: i,
+			Shard: m.firstShard + i,
 		}
 
 		if shard != nil {
@@ -428,15 +511,25 @@ func (m *Manager) GetFullStatus() *Status {
 
 			shard.RLock()
 			result[i].OK = shard.DataReady
+			result[i].Latency = shard.HeartbeatLatency()
 			shard.RUnlock()
 		}
+
+		if health := m.health[i]; health != nil {
+			result[i].LastEvent = health.LastEvent()
+			result[i].Restarting = atomic.LoadInt32(&health.restarting) == 1
+			result[i].Restarts = len(health.getHistory())
+		}
 	}
 	m.RUnlock()
 
 	totalGuilds := 0
-	for shard, guilds := range shardGuilds {
+	for i, guilds := range shardGuilds {
+		if i >= len(result) {
+			break
+		}
 		totalGuilds += guilds
-		result[shard].NumGuilds = guilds
+		result[i].NumGuilds = guilds
 	}
 
 	return &Status{
@@ -449,8 +542,7 @@ func (m *Manager) GetFullStatus() *Status {
 func (m *Manager) StdGuildCountsFunc() []int {
 
 	m.RLock()
-	nShards := m.numShards
-	result := make([]int, nShards)
+	result := make([]int, len(m.Sessions))
 
 	for i, session := range m.Sessions {
 		if session == nil {
@@ -471,10 +563,15 @@ type Status struct {
 }
 
 type ShardStatus struct {
-	Shard     int  `json:"shard"`
-	OK        bool `json:"ok"`
-	Started   bool `json:"started"`
-	NumGuilds int  `json:"num_guilds"`
+	Shard      int           `json:"shard"`
+	OK         bool          `json:"ok"`
+	Started    bool          `json:"started"`
+	NumGuilds  int           `json:"num_guilds"`
+	Latency    time.Duration `json:"latency"`
+	LastEvent  time.Time     `json:"last_event"`
+	Restarting bool          `json:"restarting"`
+	// the number of restarts by the supervisor, up to the length of the history
+	Restarts int `json:"restarts"`
 }
 
 // Event holds data for an event
@@ -527,6 +624,9 @@ const (
 
 	// Sent when an error occurs
 	EventError
+
+	// Sent when the supervisor restarts a stalled shard
+	EventRestart
 )
 
 var (
@@ -538,6 +638,7 @@ var (
 		EventResumed:      "resumed",
 		EventReady:        "ready",
 		EventError:        "error",
+		EventRestart:      "restarting",
 	}
 
 	eventColors = map[EventType]int{
@@ -548,6 +649,7 @@ var (
 		EventResumed:      0x5985ff,
 		EventReady:        0x00ffbf,
 		EventError:        0x7a1bad,
+		EventRestart:      0xffd21f,
 	}
 )
 
@@ -0,0 +1,198 @@
+package shardmanager
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/pkg/errors"
+)
+
+const (
+	// Discord allows one identify every 5 seconds
+	identifyInterval = time.Second * 5
+	// the number of restarts kept per shard
+	maxRestartHistory = 10
+)
+
+// Restart is a restart of a shard by the supervisor
+type Restart struct {
+	Shard  int       `json:"shard"`
+	Time   time.Time `json:"time"`
+	Reason string    `json:"reason"`
+	// Error is empty if the shard has been opened again
+	Error string `json:"error,omitempty"`
+}
+
+// shardHealth is what the supervisor knows about a shard
+type shardHealth struct {
+	lastEvent  int64 // unix nanoseconds, accessed atomically
+	restarting int32 // accessed atomically
+
+	history     []*Restart
+	historyLock sync.Mutex
+}
+
+func (h *shardHealth) LastEvent() time.Time {
+	lastEvent := atomic.LoadInt64(&h.lastEvent)
+	if lastEvent == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, lastEvent)
+}
+
+func (h *shardHealth) addRestart(restart *Restart) {
+	h.historyLock.Lock()
+	defer h.historyLock.Unlock()
+
+	h.history = append(h.history, restart)
+	if len(h.history) > maxRestartHistory {
+		h.history = h.history[len(h.history)-maxRestartHistory:]
+	}
+}
+
+func (h *shardHealth) getHistory() []*Restart {
+	h.historyLock.Lock()
+	defer h.historyLock.Unlock()
+
+	return append([]*Restart{}, h.history...)
+}
+
+// onDiscordEvent marks the shard as alive, it receives every event of the session
+func (m *Manager) onDiscordEvent(s *discordgo.Session, evt interface{}) {
+	if health := m.getShardHealth(s.ShardID); health != nil {
+		atomic.StoreInt64(&health.lastEvent, time.Now().UnixNano())
+	}
+}
+
+func (m *Manager) getShardHealth(shard int) *shardHealth {
+	m.RLock()
+	defer m.RUnlock()
+
+	index := shard - m.firstShard
+	if index < 0 || index >= len(m.health) {
+		return nil
+	}
+	return m.health[index]
+}
+
+// waitForIdentify blocks until the shard is allowed to identify, to pace identifies across all shards
+func (m *Manager) waitForIdentify() {
+	m.identifyLock.Lock()
+	defer m.identifyLock.Unlock()
+
+	wait := time.Until(m.lastIdentify.Add(identifyInterval))
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+	m.lastIdentify = time.Now()
+}
+
+// getStallReason returns why the shard is stalled, or an empty string if the shard is healthy
+func (m *Manager) getStallReason(shard int) string {
+	m.RLock()
+	session := m.localSession(shard)
+	m.RUnlock()
+	health := m.getShardHealth(shard)
+	if session == nil || health == nil || atomic.LoadInt32(&health.restarting) == 1 {
+		return ""
+	}
+
+	session.RLock()
+	lastHeartbeatAck := session.LastHeartbeatAck
+	lastHeartbeatSent := session.LastHeartbeatSent
+	session.RUnlock()
+
+	if !lastHeartbeatSent.IsZero() && lastHeartbeatSent.After(lastHeartbeatAck) &&
+		time.Since(lastHeartbeatAck) > m.StallTimeout {
+		return fmt.Sprintf("no heartbeat ACK for %s", time.Since(lastHeartbeatAck).Round(time.Second))
+	}
+
+	// quiet shards receive no events for a long time, they are only stalled if they stopped heartbeating as well
+	lastEvent := health.LastEvent()
+	if !lastEvent.IsZero() && time.Since(lastEvent) > m.StallTimeout &&
+		!lastHeartbeatAck.IsZero() && time.Since(lastHeartbeatAck) > m.StallTimeout {
+		return fmt.Sprintf("no events and no heartbeat ACK for %s", time.Since(lastEvent).Round(time.Second))
+	}
+
+	return ""
+}
+
+// supervisorRoutine restarts stalled shards
+func (m *Manager) supervisorRoutine() {
+	ticker := time.NewTicker(m.StallTimeout / 5)
+	for range ticker.C {
+		first, last := m.GetShardRange()
+		for shard := first; shard <= last; shard++ {
+			reason := m.getStallReason(shard)
+			if reason == "" {
+				continue
+			}
+
+			go m.RestartShard(shard, reason)
+		}
+	}
+}
+
+// RestartShard closes the gateway connection of the shard and opens it again, other shards keep running.
+// Restarts of a shard already restarting are ignored.
+func (m *Manager) RestartShard(shard int, reason string) error {
+	m.RLock()
+	session := m.localSession(shard)
+	m.RUnlock()
+	health := m.getShardHealth(shard)
+	if session == nil || health == nil {
+		return errors.New(fmt.Sprintf("shard %d does not exist", shard))
+	}
+
+	if !atomic.CompareAndSwapInt32(&health.restarting, 0, 1) {
+		return nil
+	}
+	defer atomic.StoreInt32(&health.restarting, 0)
+
+	m.handleEvent(EventRestart, shard, reason)
+
+	restart := &Restart{
+		Shard:  shard,
+		Time:   time.Now(),
+		Reason: reason,
+	}
+	defer health.addRestart(restart)
+
+	// errors when closing are expected, the connection is broken already
+	session.Close()
+
+	m.waitForIdentify()
+	err := session.Open()
+	if err != nil {
+		restart.Error = err.Error()
+		m.handleError(err, shard, "Failed restarting shard")
+		return errors.Wrap(err, "RestartShard.Open")
+	}
+	// give the new connection the full timeout to send events
+	atomic.StoreInt64(&health.lastEvent, time.Now().UnixNano())
+	m.handleEvent(EventOpen, shard, "")
+
+	return nil
+}
+
+// GetRestartHistory returns the restarts of all shards, oldest first
+func (m *Manager) GetRestartHistory() (history []*Restart) {
+	m.RLock()
+	health := append([]*shardHealth{}, m.health...)
+	m.RUnlock()
+
+	for _, shardHealth := range health {
+		history = append(history, shardHealth.getHistory()...)
+	}
+
+	// merge the histories of the shards
+	for i := 1; i < len(history); i++ {
+		for j := i; j > 0 && history[j].Time.Before(history[j-1].Time); j-- {
+			history[j], history[j-1] = history[j-1], history[j]
+		}
+	}
+	return history
+}
@@ -0,0 +1,569 @@
+// Package statecache keeps guilds, channels, roles and members in redis.
+// The state survives restarts and is shared by all processes, it is updated incrementally from the gateway events.
+// Without Init every lookup returns discordgo.ErrStateNotFound.
+package statecache
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Seklfreak/Robyul2/cache"
+	"github.com/bwmarrin/discordgo"
+	"github.com/go-redis/redis"
+	"github.com/sirupsen/logrus"
+	"github.com/vmihailenco/msgpack"
+)
+
+const (
+	keyPrefix = "robyul-state:"
+	// channelGuildsKey maps channel IDs to the ID of their guild
+	channelGuildsKey = keyPrefix + "channel-guilds"
+	// membersSyncedKey maps guild IDs to the unix time all members have been received last
+	membersSyncedKey = keyPrefix + "members-synced"
+	// incomplete member syncs are removed after this time
+	membersSyncTimeout = time.Minute * 10
+)
+
+var (
+	enabled     bool
+	memberLimit int
+	lock        sync.RWMutex
+
+	// membersSyncSetScript sets members in the hash of a running member sync, if there is one
+	membersSyncSetScript = redis.NewScript(`
+if redis.call("exists", KEYS[1]) == 1 then
+	return redis.call("hmset", KEYS[1], unpack(ARGV))
+end
+return 0`)
+)
+
+// Init enables the state cache
+// limit	: guilds with more members keep their members only in redis, not in the state of the session, 0 for no limit
+func Init(limit int) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	enabled = true
+	memberLimit = limit
+	logger().Infof("enabled redis state cache, member limit %d", limit)
+}
+
+// IsEnabled returns true if the state cache is used
+func IsEnabled() bool {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return enabled
+}
+
+func getMemberLimit() int {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return memberLimit
+}
+
+// OnInterface updates the cache with all events of the session, has to be added as an event handler
+func OnInterface(session *discordgo.Session, i interface{}) {
+	if !IsEnabled() {
+		return
+	}
+
+	var err error
+	switch t := i.(type) {
+	case *discordgo.GuildCreate:
+		err = guildAdd(t.Guild)
+		trimSessionMembers(session, t.Guild.ID, t.Guild.Members...)
+	case *discordgo.GuildUpdate:
+		err = guildUpdate(t.Guild)
+	case *discordgo.GuildDelete:
+		// unavailable guilds come back with a guild create
+		if !t.Unavailable {
+			err = guildRemove(t.Guild.ID)
+		}
+	case *discordgo.GuildEmojisUpdate:
+		err = emojisUpdate(t.GuildID, t.Emojis)
+	case *discordgo.ChannelCreate:
+		err = channelAdd(t.Channel)
+	case *discordgo.ChannelUpdate:
+		err = channelAdd(t.Channel)
+	case *discordgo.ChannelDelete:
+		err = channelRemove(t.Channel)
+	case *discordgo.GuildRoleCreate:
+		err = roleAdd(t.GuildID, t.Role)
+	case *discordgo.GuildRoleUpdate:
+		err = roleAdd(t.GuildID, t.Role)
+	case *discordgo.GuildRoleDelete:
+		err = roleRemove(t.GuildID, t.RoleID)
+	case *discordgo.GuildMemberAdd:
+		err = membersAdd(t.GuildID, t.Member)
+		trimSessionMembers(session, t.GuildID, t.Member)
+	case *discordgo.GuildMemberUpdate:
+		err = memberUpdate(t.Member)
+		trimSessionMembers(session, t.GuildID, t.Member)
+	case *discordgo.GuildMemberRemove:
+		err = memberRemove(t.Member)
+	case *discordgo.GuildMembersChunk:
+		err = membersChunkAdd(t)
+		trimSessionMembers(session, t.GuildID, t.Members...)
+	}
+	if err != nil {
+		logger().WithError(err).Warnf("failed to update state for %T", i)
+	}
+}
+
+// IsMemberLimited returns true if guilds with the member count keep their members only in redis, not in the state of the session
+func IsMemberLimited(memberCount int) bool {
+	if !IsEnabled() {
+		return false
+	}
+
+	limit := getMemberLimit()
+	return limit > 0 && memberCount > limit
+}
+
+// trimSessionMembers removes the members of guilds above the member limit from the state of the session,
+// they are only kept in redis. The member of the bot stays, it is needed for permission checks.
+func trimSessionMembers(session *discordgo.Session, guildID string, members ...*discordgo.Member) {
+	if session == nil || session.State == nil || session.State.User == nil {
+		return
+	}
+
+	guild, err := session.State.Guild(guildID)
+	if err != nil || !IsMemberLimited(guild.MemberCount) {
+		return
+	}
+
+	// the members can be the slice of the guild in the state, which is changed while removing
+	for _, member := range append([]*discordgo.Member{}, members...) {
+		if member == nil || member.User == nil || member.User.ID == session.State.User.ID {
+			continue
+		}
+		session.State.MemberRemove(&discordgo.Member{GuildID: guildID, User: member.User})
+	}
+}
+
+// IsMembersSynced returns true if all members of the guild have been received within maxAge
+func IsMembersSynced(guildID string, maxAge time.Duration) bool {
+	if !IsEnabled() {
+		return false
+	}
+
+	syncedAt, err := cache.GetRedisClient().HGet(membersSyncedKey, guildID).Int64()
+	if err != nil {
+		return false
+	}
+	return time.Since(time.Unix(syncedAt, 0)) <= maxAge
+}
+
+// Guild returns the guild with its channels and roles, members are not included
+func Guild(guildID string) (*discordgo.Guild, error) {
+	if !IsEnabled() {
+		return nil, discordgo.ErrStateNotFound
+	}
+
+	var guild discordgo.Guild
+	err := get(getGuildKey(guildID), &guild)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := cache.GetRedisClient().HGetAll(getChannelsKey(guildID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	for _, value := range values {
+		var channel discordgo.Channel
+		if msgpack.Unmarshal([]byte(value), &channel) == nil {
+			guild.Channels = append(guild.Channels, &channel)
+		}
+	}
+
+	values, err = cache.GetRedisClient().HGetAll(getRolesKey(guildID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	for _, value := range values {
+		var role discordgo.Role
+		if msgpack.Unmarshal([]byte(value), &role) == nil {
+			guild.Roles = append(guild.Roles, &role)
+		}
+	}
+
+	return &guild, nil
+}
+
+// Channel returns a channel of any guild
+func Channel(channelID string) (*discordgo.Channel, error) {
+	if !IsEnabled() {
+		return nil, discordgo.ErrStateNotFound
+	}
+
+	guildID, err := cache.GetRedisClient().HGet(channelGuildsKey, channelID).Result()
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	var channel discordgo.Channel
+	err = hGet(getChannelsKey(guildID), channelID, &channel)
+	if err != nil {
+		return nil, err
+	}
+	return &channel, nil
+}
+
+// Role returns a role of the guild
+func Role(guildID, roleID string) (*discordgo.Role, error) {
+	if !IsEnabled() {
+		return nil, discordgo.ErrStateNotFound
+	}
+
+	var role discordgo.Role
+	err := hGet(getRolesKey(guildID), roleID, &role)
+	if err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+// Member returns a member of the guild
+func Member(guildID, userID string) (*discordgo.Member, error) {
+	if !IsEnabled() {
+		return nil, discordgo.ErrStateNotFound
+	}
+
+	var member discordgo.Member
+	err := hGet(getMembersKey(guildID), userID, &member)
+	if err != nil {
+		return nil, err
+	}
+	member.GuildID = guildID
+	return &member, nil
+}
+
+// Members returns all members of the guild
+func Members(guildID string) ([]*discordgo.Member, error) {
+	if !IsEnabled() {
+		return nil, discordgo.ErrStateNotFound
+	}
+
+	values, err := cache.GetRedisClient().HGetAll(getMembersKey(guildID)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]*discordgo.Member, 0, len(values))
+	for _, value := range values {
+		var member discordgo.Member
+		if msgpack.Unmarshal([]byte(value), &member) == nil {
+			member.GuildID = guildID
+			members = append(members, &member)
+		}
+	}
+	return members, nil
+}
+
+func guildAdd(guild *discordgo.Guild) error {
+	data, err := msgpack.Marshal(stripGuild(guild))
+	if err != nil {
+		return err
+	}
+
+	// channels and roles are replaced, members of large guilds are kept for warm starts until they are synced,
+	// other guilds contain all members
+	pipeline := cache.GetRedisClient().TxPipeline()
+	pipeline.Set(getGuildKey(guild.ID), data, 0)
+	pipeline.Del(getChannelsKey(guild.ID), getRolesKey(guild.ID))
+	if !guild.Large {
+		pipeline.Del(getMembersKey(guild.ID))
+	}
+	for _, channel := range guild.Channels {
+		data, err = msgpack.Marshal(channel)
+		if err != nil {
+			return err
+		}
+		pipeline.HSet(getChannelsKey(guild.ID), channel.ID, data)
+		pipeline.HSet(channelGuildsKey, channel.ID, guild.ID)
+	}
+	for _, role := range guild.Roles {
+		data, err = msgpack.Marshal(role)
+		if err != nil {
+			return err
+		}
+		pipeline.HSet(getRolesKey(guild.ID), role.ID, data)
+	}
+	for _, member := range guild.Members {
+		if member.User == nil {
+			continue
+		}
+		data, err = msgpack.Marshal(member)
+		if err != nil {
+			return err
+		}
+		pipeline.HSet(getMembersKey(guild.ID), member.User.ID, data)
+	}
+	_, err = pipeline.Exec()
+	return err
+}
+
+func guildUpdate(guild *discordgo.Guild) error {
+	var oldGuild discordgo.Guild
+	err := get(getGuildKey(guild.ID), &oldGuild)
+	if err != nil && err != discordgo.ErrStateNotFound {
+		return err
+	}
+
+	// guild updates don't contain all fields
+	newGuild := stripGuild(guild)
+	if newGuild.JoinedAt == "" {
+		newGuild.JoinedAt = oldGuild.JoinedAt
+	}
+	if newGuild.MemberCount == 0 {
+		newGuild.MemberCount = oldGuild.MemberCount
+	}
+	if newGuild.Emojis == nil {
+		newGuild.Emojis = oldGuild.Emojis
+	}
+
+	data, err := msgpack.Marshal(newGuild)
+	if err != nil {
+		return err
+	}
+
+	pipeline := cache.GetRedisClient().TxPipeline()
+	pipeline.Set(getGuildKey(guild.ID), data, 0)
+	for _, role := range guild.Roles {
+		data, err = msgpack.Marshal(role)
+		if err != nil {
+			return err
+		}
+		pipeline.HSet(getRolesKey(guild.ID), role.ID, data)
+	}
+	_, err = pipeline.Exec()
+	return err
+}
+
+func guildRemove(guildID string) error {
+	channelIDs, err := cache.GetRedisClient().HKeys(getChannelsKey(guildID)).Result()
+	if err != nil {
+		return err
+	}
+
+	pipeline := cache.GetRedisClient().TxPipeline()
+	pipeline.Del(getGuildKey(guildID), getChannelsKey(guildID), getRolesKey(guildID), getMembersKey(guildID),
+		getMembersSyncKey(guildID))
+	pipeline.HDel(membersSyncedKey, guildID)
+	if len(channelIDs) > 0 {
+		pipeline.HDel(channelGuildsKey, channelIDs...)
+	}
+	_, err = pipeline.Exec()
+	return err
+}
+
+func emojisUpdate(guildID string, emojis []*discordgo.Emoji) error {
+	var guild discordgo.Guild
+	err := get(getGuildKey(guildID), &guild)
+	if err != nil {
+		return err
+	}
+
+	guild.Emojis = emojis
+	data, err := msgpack.Marshal(&guild)
+	if err != nil {
+		return err
+	}
+	return cache.GetRedisClient().Set(getGuildKey(guildID), data, 0).Err()
+}
+
+func channelAdd(channel *discordgo.Channel) error {
+	// direct messages are not cached
+	if channel.GuildID == "" {
+		return nil
+	}
+
+	data, err := msgpack.Marshal(channel)
+	if err != nil {
+		return err
+	}
+
+	pipeline := cache.GetRedisClient().TxPipeline()
+	pipeline.HSet(getChannelsKey(channel.GuildID), channel.ID, data)
+	pipeline.HSet(channelGuildsKey, channel.ID, channel.GuildID)
+	_, err = pipeline.Exec()
+	return err
+}
+
+func channelRemove(channel *discordgo.Channel) error {
+	if channel.GuildID == "" {
+		return nil
+	}
+
+	pipeline := cache.GetRedisClient().TxPipeline()
+	pipeline.HDel(getChannelsKey(channel.GuildID), channel.ID)
+	pipeline.HDel(channelGuildsKey, channel.ID)
+	_, err := pipeline.Exec()
+	return err
+}
+
+func roleAdd(guildID string, role *discordgo.Role) error {
+	data, err := msgpack.Marshal(role)
+	if err != nil {
+		return err
+	}
+	return cache.GetRedisClient().HSet(getRolesKey(guildID), role.ID, data).Err()
+}
+
+func roleRemove(guildID, roleID string) error {
+	return cache.GetRedisClient().HDel(getRolesKey(guildID), roleID).Err()
+}
+
+// membersAdd adds or replaces the members, and updates them in a running member sync as well
+func membersAdd(guildID string, members ...*discordgo.Member) error {
+	values, err := encodeMembers(members)
+	if err != nil || len(values) <= 0 {
+		return err
+	}
+
+	err = cache.GetRedisClient().HMSet(getMembersKey(guildID), values).Err()
+	if err != nil {
+		return err
+	}
+
+	arguments := make([]interface{}, 0, len(values)*2)
+	for userID, data := range values {
+		arguments = append(arguments, userID, data)
+	}
+	return membersSyncSetScript.Run(cache.GetRedisClient(), []string{getMembersSyncKey(guildID)}, arguments...).Err()
+}
+
+// membersChunkAdd collects the chunks of a member sync in a temporary hash,
+// the last chunk replaces the members, so members who left while the bot was offline are removed
+func membersChunkAdd(chunk *discordgo.GuildMembersChunk) error {
+	values, err := encodeMembers(chunk.Members)
+	if err != nil {
+		return err
+	}
+
+	syncKey := getMembersSyncKey(chunk.GuildID)
+	pipeline := cache.GetRedisClient().TxPipeline()
+	if chunk.ChunkIndex == 0 {
+		pipeline.Del(syncKey)
+	}
+	if len(values) > 0 {
+		pipeline.HMSet(syncKey, values)
+	}
+	pipeline.Expire(syncKey, membersSyncTimeout)
+	if chunk.ChunkIndex >= chunk.ChunkCount-1 {
+		pipeline.Rename(syncKey, getMembersKey(chunk.GuildID))
+		pipeline.Persist(getMembersKey(chunk.GuildID))
+		pipeline.HSet(membersSyncedKey, chunk.GuildID, time.Now().Unix())
+	}
+	_, err = pipeline.Exec()
+	return err
+}
+
+func encodeMembers(members []*discordgo.Member) (values map[string]interface{}, err error) {
+	values = make(map[string]interface{}, len(members))
+	for _, member := range members {
+		if member == nil || member.User == nil {
+			continue
+		}
+		data, err := msgpack.Marshal(member)
+		if err != nil {
+			return nil, err
+		}
+		values[member.User.ID] = data
+	}
+	return values, nil
+}
+
+func memberUpdate(member *discordgo.Member) error {
+	if member.User == nil {
+		return nil
+	}
+
+	// member updates don't contain the join date
+	memberCopy := new(discordgo.Member)
+	*memberCopy = *member
+	if memberCopy.JoinedAt == "" {
+		oldMember, err := Member(member.GuildID, member.User.ID)
+		if err == nil {
+			memberCopy.JoinedAt = oldMember.JoinedAt
+		}
+	}
+
+	return membersAdd(member.GuildID, memberCopy)
+}
+
+func memberRemove(member *discordgo.Member) error {
+	if member.User == nil {
+		return nil
+	}
+
+	pipeline := cache.GetRedisClient().TxPipeline()
+	pipeline.HDel(getMembersKey(member.GuildID), member.User.ID)
+	pipeline.HDel(getMembersSyncKey(member.GuildID), member.User.ID)
+	_, err := pipeline.Exec()
+	return err
+}
+
+// stripGuild returns a copy of the guild without the fields which are stored separately or not at all
+func stripGuild(guild *discordgo.Guild) *discordgo.Guild {
+	guildCopy := new(discordgo.Guild)
+	*guildCopy = *guild
+
+	guildCopy.Channels = nil
+	guildCopy.Roles = nil
+	guildCopy.Members = nil
+	guildCopy.Presences = nil
+	guildCopy.VoiceStates = nil
+	return guildCopy
+}
+
+func get(key string, value interface{}) error {
+	data, err := cache.GetRedisClient().Get(key).Bytes()
+	if err != nil {
+		return notFound(err)
+	}
+	return msgpack.Unmarshal(data, value)
+}
+
+func hGet(key, field string, value interface{}) error {
+	data, err := cache.GetRedisClient().HGet(key, field).Bytes()
+	if err != nil {
+		return notFound(err)
+	}
+	return msgpack.Unmarshal(data, value)
+}
+
+// notFound returns discordgo.ErrStateNotFound for missing keys, so callers can handle it like a state miss
+func notFound(err error) error {
+	if err == redis.Nil {
+		return discordgo.ErrStateNotFound
+	}
+	return err
+}
+
+func getGuildKey(guildID string) string {
+	return keyPrefix + "guild:" + guildID
+}
+
+func getChannelsKey(guildID string) string {
+	return keyPrefix + "channels:" + guildID
+}
+
+func getRolesKey(guildID string) string {
+	return keyPrefix + "roles:" + guildID
+}
+
+func getMembersKey(guildID string) string {
+	return keyPrefix + "members:" + guildID
+}
+
+func getMembersSyncKey(guildID string) string {
+	return getMembersKey(guildID) + ":sync"
+}
+
+func logger() *logrus.Entry {
+	return cache.GetLogger().WithField("module", "statecache")
+}
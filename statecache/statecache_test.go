@@ -0,0 +1,93 @@
+package statecache
+
+import (
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/go-redis/redis"
+	"github.com/vmihailenco/msgpack"
+)
+
+func TestStripGuild(t *testing.T) {
+	guild := &discordgo.Guild{
+		ID:       "1",
+		Name:     "Robyul",
+		Emojis:   []*discordgo.Emoji{{ID: "2"}},
+		Channels: []*discordgo.Channel{{ID: "3"}},
+		Roles:    []*discordgo.Role{{ID: "4"}},
+		Members:  []*discordgo.Member{{User: &discordgo.User{ID: "5"}}},
+	}
+
+	stripped := stripGuild(guild)
+	if stripped.Channels != nil || stripped.Roles != nil || stripped.Members != nil {
+		t.Fatalf("statecache.stripGuild() failed to remove channels, roles and members, got %+v", stripped)
+	}
+	if stripped.Name != "Robyul" || len(stripped.Emojis) != 1 {
+		t.Fatalf("statecache.stripGuild() failed to keep name and emojis, got %+v", stripped)
+	}
+	if len(guild.Channels) != 1 || len(guild.Members) != 1 {
+		t.Fatalf("statecache.stripGuild() failed to leave the guild unchanged, got %+v", guild)
+	}
+}
+
+func TestMemberEncoding(t *testing.T) {
+	member := &discordgo.Member{
+		GuildID:  "1",
+		JoinedAt: "2018-01-01T00:00:00+00:00",
+		Nick:     "Robyul",
+		User:     &discordgo.User{ID: "2", Username: "robyul", Discriminator: "0001"},
+		Roles:    []string{"3", "4"},
+	}
+
+	data, err := msgpack.Marshal(member)
+	if err != nil {
+		t.Fatalf("msgpack.Marshal() failed to encode member: %s", err.Error())
+	}
+	var decoded discordgo.Member
+	err = msgpack.Unmarshal(data, &decoded)
+	if err != nil {
+		t.Fatalf("msgpack.Unmarshal() failed to decode member: %s", err.Error())
+	}
+	if decoded.User == nil || decoded.User.ID != "2" || decoded.Nick != "Robyul" ||
+		decoded.JoinedAt != member.JoinedAt || len(decoded.Roles) != 2 {
+		t.Fatalf("msgpack.Unmarshal() failed to decode member, got %+v", decoded)
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	if err := notFound(redis.Nil); err != discordgo.ErrStateNotFound {
+		t.Fatalf("statecache.notFound() failed to return ErrStateNotFound for missing keys, got %v", err)
+	}
+
+	_, err := Member("1", "2")
+	if err != discordgo.ErrStateNotFound {
+		t.Fatalf("statecache.Member() failed to return ErrStateNotFound while disabled, got %v", err)
+	}
+
+	_, err = Members("1")
+	if err != discordgo.ErrStateNotFound {
+		t.Fatalf("statecache.Members() failed to return ErrStateNotFound while disabled, got %v", err)
+	}
+}
+
+func TestIsMemberLimited(t *testing.T) {
+	if IsMemberLimited(1000) {
+		t.Fatalf("statecache.IsMemberLimited() failed to return false while disabled")
+	}
+
+	lock.Lock()
+	enabled, memberLimit = true, 100
+	lock.Unlock()
+	defer func() {
+		lock.Lock()
+		enabled, memberLimit = false, 0
+		lock.Unlock()
+	}()
+
+	if IsMemberLimited(100) {
+		t.Fatalf("statecache.IsMemberLimited() failed to keep members of guilds at the limit")
+	}
+	if !IsMemberLimited(101) {
+		t.Fatalf("statecache.IsMemberLimited() failed to limit members of guilds above the limit")
+	}
+}
@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"container/list"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Cached keeps a copy of retrieved objects in a local folder, for backends with slow access.
+// The least recently used objects are evicted when the cache grows above its maximum size.
+type Cached struct {
+	Backend
+	folder  string
+	maxSize int64
+
+	lock    sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List // front is the most recently used
+	size    int64
+
+	hits      int64
+	misses    int64
+	evictions int64
+}
+
+type cachedEntry struct {
+	name string
+	size int64
+}
+
+// CacheStats are statistics about a cache
+type CacheStats struct {
+	Hits      int64
+	Misses    int64
+	Evictions int64
+	Objects   int
+	Size      int64 // in bytes
+	MaxSize   int64 // in bytes, 0 if unbounded
+}
+
+// NewCached wraps the backend with a cache in the folder, maxSize is in bytes, 0 means unbounded.
+// Objects cached by earlier runs are picked up, ordered by their modification time.
+func NewCached(backend Backend, folder string, maxSize int64) *Cached {
+	c := &Cached{
+		Backend: backend,
+		folder:  folder,
+		maxSize: maxSize,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+
+	files, err := ioutil.ReadDir(folder)
+	if err == nil {
+		sort.Slice(files, func(i, j int) bool {
+			return files[i].ModTime().After(files[j].ModTime())
+		})
+		for _, file := range files {
+			if file.IsDir() || file.Name()[0] == '.' {
+				continue
+			}
+			c.entries[file.Name()] = c.lru.PushBack(&cachedEntry{name: file.Name(), size: file.Size()})
+			c.size += file.Size()
+		}
+		c.evict()
+	}
+
+	return c
+}
+
+func (c *Cached) cachePath(name string) string {
+	return filepath.Join(c.folder, objectName(name))
+}
+
+func (c *Cached) Put(name string, data []byte, contentType string, metadata map[string]string) error {
+	// drop outdated copies
+	err := c.deleteCache(name)
+	if err != nil {
+		return err
+	}
+
+	return c.Backend.Put(name, data, contentType, metadata)
+}
+
+func (c *Cached) Get(name string) ([]byte, error) {
+	data, err := ioutil.ReadFile(c.cachePath(name))
+	if err == nil {
+		atomic.AddInt64(&c.hits, 1)
+		c.touch(name)
+		return data, nil
+	}
+	atomic.AddInt64(&c.misses, 1)
+
+	data, err = c.Backend.Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// failing to cache the object is no reason to fail the request
+	c.setCache(name, data)
+	return data, nil
+}
+
+func (c *Cached) Delete(name string) error {
+	err := c.deleteCache(name)
+	if err != nil {
+		return err
+	}
+
+	return c.Backend.Delete(name)
+}
+
+// Stats returns statistics about the cache
+func (c *Cached) Stats() CacheStats {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return CacheStats{
+		Hits:      atomic.LoadInt64(&c.hits),
+		Misses:    atomic.LoadInt64(&c.misses),
+		Evictions: atomic.LoadInt64(&c.evictions),
+		Objects:   c.lru.Len(),
+		Size:      c.size,
+		MaxSize:   c.maxSize,
+	}
+}
+
+// touch marks the object as recently used
+func (c *Cached) touch(name string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if element, ok := c.entries[objectName(name)]; ok {
+		c.lru.MoveToFront(element)
+	}
+}
+
+func (c *Cached) setCache(name string, data []byte) error {
+	size := int64(len(data))
+	if c.maxSize > 0 && size > c.maxSize {
+		return nil
+	}
+
+	err := os.MkdirAll(c.folder, os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	err = writeFileAtomic(c.cachePath(name), data)
+	if err != nil {
+		return err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	name = objectName(name)
+	if element, ok := c.entries[name]; ok {
+		entry := element.Value.(*cachedEntry)
+		c.size += size - entry.size
+		entry.size = size
+		c.lru.MoveToFront(element)
+	} else {
+		c.entries[name] = c.lru.PushFront(&cachedEntry{name: name, size: size})
+		c.size += size
+	}
+	c.evict()
+
+	return nil
+}
+
+func (c *Cached) deleteCache(name string) error {
+	c.lock.Lock()
+	c.removeEntry(objectName(name))
+	c.lock.Unlock()
+
+	err := os.Remove(c.cachePath(name))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// evict removes the least recently used objects until the cache fits its maximum size, the lock has to be held
+func (c *Cached) evict() {
+	if c.maxSize <= 0 {
+		return
+	}
+
+	for c.size > c.maxSize {
+		element := c.lru.Back()
+		if element == nil {
+			return
+		}
+
+		entry := element.Value.(*cachedEntry)
+		c.removeEntry(entry.name)
+		os.Remove(filepath.Join(c.folder, entry.name))
+		atomic.AddInt64(&c.evictions, 1)
+	}
+}
+
+// removeEntry removes the object from the index, the lock has to be held
+func (c *Cached) removeEntry(name string) {
+	element, ok := c.entries[name]
+	if !ok {
+		return
+	}
+
+	c.size -= element.Value.(*cachedEntry).size
+	c.lru.Remove(element)
+	delete(c.entries, name)
+}
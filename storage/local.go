@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const localMetadataFolder = ".metadata"
+
+// Local stores objects as files in a folder, the metadata is stored next to them as JSON
+type Local struct {
+	folder string
+}
+
+// NewLocal creates a backend storing objects in the folder, the folder is created if it doesn't exist yet
+func NewLocal(folder string) (*Local, error) {
+	err := os.MkdirAll(filepath.Join(folder, localMetadataFolder), os.ModePerm)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Local{folder: folder}, nil
+}
+
+func (l *Local) objectPath(name string) string {
+	return filepath.Join(l.folder, objectName(name))
+}
+
+func (l *Local) metadataPath(name string) string {
+	return filepath.Join(l.folder, localMetadataFolder, objectName(name)+".json")
+}
+
+func (l *Local) Put(name string, data []byte, contentType string, metadata map[string]string) error {
+	infoData, err := json.Marshal(ObjectInfo{
+		Name:        objectName(name),
+		Size:        int64(len(data)),
+		ContentType: contentType,
+		Metadata:    normalizeMetadata(metadata),
+	})
+	if err != nil {
+		return err
+	}
+
+	err = writeFileAtomic(l.objectPath(name), data)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(l.metadataPath(name), infoData)
+}
+
+func (l *Local) Get(name string) ([]byte, error) {
+	data, err := ioutil.ReadFile(l.objectPath(name))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (l *Local) Stat(name string) (info ObjectInfo, err error) {
+	infoData, err := ioutil.ReadFile(l.metadataPath(name))
+	if os.IsNotExist(err) {
+		return info, ErrNotFound
+	}
+	if err != nil {
+		return info, err
+	}
+
+	err = json.Unmarshal(infoData, &info)
+	return info, err
+}
+
+func (l *Local) Delete(name string) error {
+	err := os.Remove(l.objectPath(name))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	err = os.Remove(l.metadataPath(name))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (l *Local) ListByMetadata(key, value string) ([]string, error) {
+	metadataFiles, err := filepath.Glob(filepath.Join(l.folder, localMetadataFolder, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0)
+	for _, metadataFile := range metadataFiles {
+		infoData, err := ioutil.ReadFile(metadataFile)
+		if err != nil {
+			continue
+		}
+
+		var info ObjectInfo
+		err = json.Unmarshal(infoData, &info)
+		if err != nil {
+			continue
+		}
+
+		if info.Metadata[strings.ToLower(key)] == value {
+			names = append(names, info.Name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// writeFileAtomic writes to a temporary file first, so readers never see partially written files
+func writeFileAtomic(path string, data []byte) error {
+	tempFile, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+
+	_, err = tempFile.Write(data)
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tempFile.Name())
+		return err
+	}
+
+	err = os.Chmod(tempFile.Name(), 0644)
+	if err != nil {
+		os.Remove(tempFile.Name())
+		return err
+	}
+
+	return os.Rename(tempFile.Name(), path)
+}
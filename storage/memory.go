@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+type memoryObject struct {
+	data []byte
+	info ObjectInfo
+}
+
+// Memory keeps objects in memory, objects are lost when the process exits
+type Memory struct {
+	objects map[string]memoryObject
+	lock    sync.RWMutex
+}
+
+// NewMemory creates an empty in-memory backend
+func NewMemory() *Memory {
+	return &Memory{
+		objects: make(map[string]memoryObject),
+	}
+}
+
+func (m *Memory) Put(name string, data []byte, contentType string, metadata map[string]string) error {
+	name = objectName(name)
+
+	// copy the data, callers may reuse the slice
+	dataCopy := make([]byte, len(data))
+	copy(dataCopy, data)
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.objects[name] = memoryObject{
+		data: dataCopy,
+		info: ObjectInfo{
+			Name:        name,
+			Size:        int64(len(dataCopy)),
+			ContentType: contentType,
+			Metadata:    normalizeMetadata(metadata),
+		},
+	}
+	return nil
+}
+
+func (m *Memory) Get(name string) ([]byte, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	object, ok := m.objects[objectName(name)]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	data := make([]byte, len(object.data))
+	copy(data, object.data)
+	return data, nil
+}
+
+func (m *Memory) Stat(name string) (ObjectInfo, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	object, ok := m.objects[objectName(name)]
+	if !ok {
+		return ObjectInfo{}, ErrNotFound
+	}
+	return object.info, nil
+}
+
+func (m *Memory) Delete(name string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	delete(m.objects, objectName(name))
+	return nil
+}
+
+func (m *Memory) ListByMetadata(key, value string) ([]string, error) {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+
+	names := make([]string, 0)
+	for name, object := range m.objects {
+		if object.info.Metadata[strings.ToLower(key)] == value {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	minio "github.com/minio/minio-go"
+)
+
+const (
+	minioMetadataPrefix = "X-Amz-Meta-"
+	minioMaxRetries     = 10
+)
+
+// Minio stores objects in a S3 compatible object storage
+type Minio struct {
+	client *minio.Client
+	bucket string
+}
+
+// NewMinio connects to the object storage, and creates the bucket if it doesn't exist yet
+func NewMinio(endpoint, accessKey, secretKey, bucket string) (*Minio, error) {
+	client, err := minio.New(endpoint, accessKey, secretKey, true)
+	if err != nil {
+		return nil, err
+	}
+
+	bucketExists, err := client.BucketExists(bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bucketExists {
+		err = client.MakeBucket(bucket, "ams3")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &Minio{client: client, bucket: bucket}, nil
+}
+
+// retry retries the request on rate limits and network errors
+func (m *Minio) retry(request func() error) (err error) {
+	for i := 0; i < minioMaxRetries; i++ {
+		err = request()
+		if err == nil || !isMinioTemporaryError(err) {
+			return err
+		}
+		time.Sleep(1 * time.Second)
+	}
+	return err
+}
+
+func isMinioTemporaryError(err error) bool {
+	return strings.Contains(err.Error(), "Please reduce your request rate.") ||
+		strings.Contains(err.Error(), "net/http") || strings.Contains(err.Error(), "timeout")
+}
+
+func isMinioNotFoundError(err error) bool {
+	code := minio.ToErrorResponse(err).Code
+	return code == "NoSuchKey" || code == "NotFound"
+}
+
+func (m *Minio) Put(name string, data []byte, contentType string, metadata map[string]string) error {
+	options := minio.PutObjectOptions{
+		ContentType: contentType,
+	}
+	if len(metadata) > 0 {
+		options.UserMetadata = metadata
+	}
+
+	return m.retry(func() error {
+		_, err := m.client.PutObject(m.bucket, objectName(name), bytes.NewReader(data), int64(len(data)), options)
+		return err
+	})
+}
+
+func (m *Minio) Get(name string) (data []byte, err error) {
+	err = m.retry(func() error {
+		object, err := m.client.GetObject(m.bucket, objectName(name), minio.GetObjectOptions{})
+		if err != nil {
+			return err
+		}
+		defer object.Close()
+
+		data, err = ioutil.ReadAll(object)
+		return err
+	})
+	if err != nil && isMinioNotFoundError(err) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (m *Minio) Stat(name string) (info ObjectInfo, err error) {
+	var objectInfo minio.ObjectInfo
+	err = m.retry(func() error {
+		objectInfo, err = m.client.StatObject(m.bucket, objectName(name), minio.StatObjectOptions{})
+		return err
+	})
+	if err != nil {
+		if isMinioNotFoundError(err) {
+			return info, ErrNotFound
+		}
+		return info, err
+	}
+
+	return getMinioObjectInfo(objectInfo), nil
+}
+
+func getMinioObjectInfo(objectInfo minio.ObjectInfo) ObjectInfo {
+	info := ObjectInfo{
+		Name:        objectInfo.Key,
+		Size:        objectInfo.Size,
+		ContentType: objectInfo.ContentType,
+		Metadata:    make(map[string]string),
+	}
+	for key, values := range objectInfo.Metadata {
+		if len(values) <= 0 || !strings.HasPrefix(key, minioMetadataPrefix) {
+			continue
+		}
+		info.Metadata[strings.ToLower(strings.TrimPrefix(key, minioMetadataPrefix))] = values[0]
+	}
+	return info
+}
+
+func (m *Minio) Delete(name string) error {
+	return m.retry(func() error {
+		return m.client.RemoveObject(m.bucket, objectName(name))
+	})
+}
+
+// ListByMetadata has to request the metadata of every object in the bucket
+func (m *Minio) ListByMetadata(key, value string) ([]string, error) {
+	doneCh := make(chan struct{})
+	defer close(doneCh)
+
+	names := make([]string, 0)
+	for object := range m.client.ListObjectsV2(m.bucket, "", true, doneCh) {
+		if object.Err != nil {
+			return nil, object.Err
+		}
+
+		info, err := m.Stat(object.Key)
+		if err != nil {
+			if err == ErrNotFound {
+				continue
+			}
+			return nil, err
+		}
+
+		if info.Metadata[strings.ToLower(key)] == value {
+			names = append(names, object.Key)
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,47 @@
+// Package storage contains the object storage backends files are stored in
+package storage
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/kennygrant/sanitize"
+)
+
+// ErrNotFound is returned if an object does not exist
+var ErrNotFound = errors.New("object not found")
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Name        string
+	Size        int64
+	ContentType string
+	Metadata    map[string]string // keys are lower case
+}
+
+// Backend stores objects by name
+type Backend interface {
+	// Put stores the object, overwriting any existing object with the same name
+	Put(name string, data []byte, contentType string, metadata map[string]string) error
+	// Get returns the content of the object, or ErrNotFound
+	Get(name string) ([]byte, error)
+	// Stat returns information about the object, or ErrNotFound
+	Stat(name string) (ObjectInfo, error)
+	// Delete deletes the object, deleting an object that does not exist is no error
+	Delete(name string) error
+	// ListByMetadata returns the names of all objects with the metadata value, this may be slow
+	ListByMetadata(key, value string) ([]string, error)
+}
+
+// objectName returns the name objects are stored as, to keep names safe to use as file names
+func objectName(name string) string {
+	return sanitize.BaseName(name)
+}
+
+func normalizeMetadata(metadata map[string]string) map[string]string {
+	normalized := make(map[string]string, len(metadata))
+	for key, value := range metadata {
+		normalized[strings.ToLower(key)] = value
+	}
+	return normalized
+}
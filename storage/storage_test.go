@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testBackend(t *testing.T, name string, backend Backend) {
+	data := []byte("robyul")
+
+	_, err := backend.Get("sha256-abc")
+	if err != ErrNotFound {
+		t.Fatalf("%s.Get() failed to return ErrNotFound for missing object, got %v", name, err)
+	}
+	_, err = backend.Stat("sha256-abc")
+	if err != ErrNotFound {
+		t.Fatalf("%s.Stat() failed to return ErrNotFound for missing object, got %v", name, err)
+	}
+
+	err = backend.Put("sha256-abc", data, "text/plain", map[string]string{"ContentHash": "abc"})
+	if err != nil {
+		t.Fatalf("%s.Put() failed: %s", name, err.Error())
+	}
+	data[0] = 'R'
+
+	storedData, err := backend.Get("sha256-abc")
+	if err != nil || !bytes.Equal(storedData, []byte("robyul")) {
+		t.Fatalf("%s.Get() failed to return stored data, got %q, %v", name, storedData, err)
+	}
+
+	info, err := backend.Stat("sha256-abc")
+	if err != nil || info.Size != 6 || info.ContentType != "text/plain" || info.Metadata["contenthash"] != "abc" {
+		t.Fatalf("%s.Stat() failed to return object information, got %+v, %v", name, info, err)
+	}
+
+	err = backend.Put("other", []byte("other"), "text/plain", map[string]string{"contenthash": "def"})
+	if err != nil {
+		t.Fatalf("%s.Put() failed: %s", name, err.Error())
+	}
+
+	names, err := backend.ListByMetadata("contentHash", "abc")
+	if err != nil || len(names) != 1 || names[0] != "sha256-abc" {
+		t.Fatalf("%s.ListByMetadata() failed to list matching objects, got %v, %v", name, names, err)
+	}
+
+	err = backend.Delete("sha256-abc")
+	if err != nil {
+		t.Fatalf("%s.Delete() failed: %s", name, err.Error())
+	}
+	_, err = backend.Get("sha256-abc")
+	if err != ErrNotFound {
+		t.Fatalf("%s.Delete() failed to delete object, got %v", name, err)
+	}
+	err = backend.Delete("sha256-abc")
+	if err != nil {
+		t.Fatalf("%s.Delete() failed for missing object: %s", name, err.Error())
+	}
+}
+
+func TestMemory(t *testing.T) {
+	testBackend(t, "storage.Memory", NewMemory())
+}
+
+func TestLocal(t *testing.T) {
+	folder, err := ioutil.TempDir("", "robyul-storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+
+	backend, err := NewLocal(folder)
+	if err != nil {
+		t.Fatalf("storage.NewLocal() failed: %s", err.Error())
+	}
+	testBackend(t, "storage.Local", backend)
+
+	err = backend.Put("../escape", []byte("robyul"), "text/plain", nil)
+	if err != nil {
+		t.Fatalf("storage.Local.Put() failed: %s", err.Error())
+	}
+	if _, err = os.Stat(filepath.Join(filepath.Dir(folder), "escape")); err == nil {
+		t.Fatalf("storage.Local.Put() failed to keep objects inside the folder")
+	}
+}
+
+func TestCached(t *testing.T) {
+	folder, err := ioutil.TempDir("", "robyul-storage-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+
+	memory := NewMemory()
+	testBackend(t, "storage.Cached", NewCached(memory, folder, 0))
+
+	backend := NewCached(memory, folder, 0)
+	err = backend.Put("cached", []byte("robyul"), "text/plain", nil)
+	if err != nil {
+		t.Fatalf("storage.Cached.Put() failed: %s", err.Error())
+	}
+	_, err = backend.Get("cached")
+	if err != nil {
+		t.Fatalf("storage.Cached.Get() failed: %s", err.Error())
+	}
+
+	// cached objects are served without the backend
+	memory.Delete("cached")
+	data, err := backend.Get("cached")
+	if err != nil || string(data) != "robyul" {
+		t.Fatalf("storage.Cached.Get() failed to serve cached object, got %q, %v", data, err)
+	}
+}
+
+func TestCachedEviction(t *testing.T) {
+	folder, err := ioutil.TempDir("", "robyul-storage-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder)
+
+	memory := NewMemory()
+	backend := NewCached(memory, folder, 10)
+	for _, name := range []string{"a", "b", "c"} {
+		memory.Put(name, []byte("1234"), "text/plain", nil)
+	}
+
+	backend.Get("a")
+	backend.Get("b")
+	backend.Get("a")
+	backend.Get("c")
+
+	stats := backend.Stats()
+	if stats.Objects != 2 || stats.Size != 8 || stats.Evictions != 1 || stats.Misses != 3 || stats.Hits != 1 {
+		t.Fatalf("storage.Cached.Stats() failed to track the cache, got %+v", stats)
+	}
+
+	// the least recently used object has been evicted
+	if _, err = os.Stat(filepath.Join(folder, "b")); !os.IsNotExist(err) {
+		t.Fatalf("storage.Cached failed to evict the least recently used object")
+	}
+	if _, err = os.Stat(filepath.Join(folder, "a")); err != nil {
+		t.Fatalf("storage.Cached evicted a recently used object")
+	}
+
+	// the index is restored from the folder
+	stats = NewCached(memory, folder, 10).Stats()
+	if stats.Objects != 2 || stats.Size != 8 {
+		t.Fatalf("storage.NewCached() failed to pick up cached objects, got %+v", stats)
+	}
+}